@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/harshadpatil/dhaavak/internal/config"
+	"github.com/harshadpatil/dhaavak/internal/gateway"
+	"github.com/harshadpatil/dhaavak/internal/routing"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// bindingAdmin manages the routing bindings added at runtime, through the
+// routing.bindings.* methods and the /bind, /unbind and /bindings commands.
+type bindingAdmin struct {
+	cfg    *config.Config
+	store  *routing.BindingStore
+	audit  *routing.AuditLog
+	admins map[string]bool // routing.admins
+}
+
+// newBindingAdmin loads the runtime bindings into store and saves later
+// changes to routing.store.
+func newBindingAdmin(cfg *config.Config, store *routing.BindingStore) (*bindingAdmin, error) {
+	a := &bindingAdmin{cfg: cfg, store: store, admins: make(map[string]bool)}
+	if p := cfg.Routing.Store.AuditPath; p != "" {
+		a.audit = routing.NewAuditLog(p)
+	}
+	if err := store.Attach(routing.NewFileStore(cfg.Routing.Store.Path), a.audit); err != nil {
+		return nil, fmt.Errorf("load runtime bindings: %w", err)
+	}
+	for _, id := range cfg.Routing.Admins {
+		a.admins[id] = true
+	}
+	return a, nil
+}
+
+// add validates b like a configured binding and adds it.
+func (a *bindingAdmin) add(b routing.Binding, actor string) (routing.Binding, error) {
+	if b.AgentID == "" && len(b.Variants) > 0 {
+		b.AgentID = b.Variants[0].AgentID
+	}
+	if b.Timezone == "" && (len(b.Days) > 0 || b.Hours != "") {
+		b.Timezone = a.cfg.Session.Timezone
+	}
+	rule := config.BindingRule{
+		Channel:  b.Channel,
+		PeerKind: b.PeerKind,
+		PeerID:   b.PeerID,
+		GuildID:  b.GuildID,
+		TeamID:   b.TeamID,
+		AgentID:  b.AgentID,
+		Priority: b.Priority,
+
+		Command:    b.Command,
+		Pattern:    b.Pattern,
+		Keywords:   b.Keywords,
+		Language:   b.Language,
+		Attachment: b.Attachment,
+
+		Experiment: b.Experiment,
+		Sticky:     b.Sticky,
+
+		Days:     b.Days,
+		Hours:    b.Hours,
+		Timezone: b.Timezone,
+		From:     b.From,
+		Until:    b.Until,
+
+		Reply: b.Reply,
+	}
+	for _, v := range b.Variants {
+		rule.Variants = append(rule.Variants, config.BindingVariant{AgentID: v.AgentID, Weight: v.Weight})
+	}
+	if err := a.cfg.ValidateBinding(rule); err != nil {
+		return routing.Binding{}, err
+	}
+	return a.store.Add(b, actor)
+}
+
+// isAdmin reports whether the sender of msg is in routing.admins.
+func (a *bindingAdmin) isAdmin(msg protocol.InboundMessage) bool {
+	sender := msg.SenderID
+	if sender == "" {
+		sender = msg.PeerID
+	}
+	return a.admins[msg.Channel+":"+sender] || (msg.UserID != "" && a.admins[msg.UserID])
+}
+
+// command handles the admin chat commands and returns the reply.
+//
+//	/bind ops                    send this chat (group or DM) to agent ops
+//	/bind ops command=/ops       only messages starting with /ops
+//	/bind ops channel=telegram   any field may be set; scope fields replace the chat
+//	/bind v1 variants=v1:90,v2:10   split this chat's conversations between agents
+//	/bind oncall days=sat-sun       only on weekends
+//	/bind until=2026-10-20T04:00:00Z reply=Back soon!   auto-reply until then
+//	/unbind b-3                  remove a binding added at runtime
+//	/bindings                    list every binding with its ID
+func (a *bindingAdmin) command(msg protocol.InboundMessage) string {
+	if !a.isAdmin(msg) {
+		return "Only routing admins can manage bindings."
+	}
+	sender := msg.SenderID
+	if sender == "" {
+		sender = msg.PeerID
+	}
+	actor := msg.Channel + ":" + sender
+	args := strings.Fields(msg.Text)
+	if len(args) > 0 {
+		args = args[1:]
+	}
+
+	switch msg.Command {
+	case "bind":
+		if len(args) == 0 {
+			return "Usage: /bind [agent] [field=value ...] [reply=text]"
+		}
+		b, err := parseBindArgs(msg, args)
+		if err != nil {
+			return "Could not bind: " + err.Error()
+		}
+		b, err = a.add(b, actor)
+		if err != nil {
+			return "Could not bind: " + err.Error()
+		}
+		return fmt.Sprintf("Added %s: %s -> %s", b.ID, describeBinding(b), b.AgentID)
+
+	case "unbind":
+		if len(args) == 0 {
+			return "Usage: /unbind <id>. Send /bindings for the IDs."
+		}
+		b, err := a.store.Remove(args[0], actor)
+		if errors.Is(err, routing.ErrBindingNotFound) {
+			return fmt.Sprintf("There is no runtime binding %q; bindings from the config file can only be changed there.", args[0])
+		}
+		if err != nil {
+			return "Could not unbind: " + err.Error()
+		}
+		return fmt.Sprintf("Removed %s: %s -> %s", b.ID, describeBinding(b), b.AgentID)
+	}
+
+	var sb strings.Builder
+	for _, b := range a.store.Bindings() {
+		id := b.ID
+		if id == "" {
+			id = "config"
+		}
+		fmt.Fprintf(&sb, "%s: %s -> %s\n", id, describeBinding(b), b.AgentID)
+	}
+	fmt.Fprintf(&sb, "default: %s", a.store.DefaultAgent())
+	return sb.String()
+}
+
+// parseBindArgs builds a binding from "/bind [agent] [field=value ...]".
+// reply takes the rest of the text. Without a channel, peer, guild or
+// team field, the binding covers the chat the command was sent in.
+func parseBindArgs(msg protocol.InboundMessage, args []string) (routing.Binding, error) {
+	var b routing.Binding
+	if !strings.Contains(args[0], "=") {
+		b.AgentID, args = args[0], args[1:]
+	}
+	scoped := false
+	for i, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return b, fmt.Errorf("expected field=value, got %q", arg)
+		}
+		switch key {
+		case "channel":
+			b.Channel = value
+		case "peer_kind":
+			b.PeerKind = value
+		case "peer_id":
+			b.PeerID = value
+		case "guild_id":
+			b.GuildID = value
+		case "team_id":
+			b.TeamID = value
+		case "priority":
+			b.Priority = value
+		case "command":
+			b.Command = value
+		case "pattern":
+			b.Pattern = value
+		case "keywords":
+			b.Keywords = strings.Split(value, ",")
+		case "language":
+			b.Language = value
+		case "attachment":
+			b.Attachment = value
+		case "variants":
+			vs, err := parseVariants(value)
+			if err != nil {
+				return b, err
+			}
+			b.Variants = vs
+		case "experiment":
+			b.Experiment = value
+		case "sticky":
+			b.Sticky = value
+		case "days":
+			b.Days = strings.Split(value, ",")
+		case "hours":
+			b.Hours = value
+		case "timezone":
+			b.Timezone = value
+		case "from":
+			b.From = value
+		case "until":
+			b.Until = value
+		case "reply":
+			// The rest of the message, with its line breaks.
+			b.Reply = strings.Join(append([]string{value}, args[i+1:]...), " ")
+			if j := strings.Index(msg.Text, arg); j >= 0 {
+				b.Reply = strings.TrimSpace(msg.Text[j+len("reply="):])
+			}
+		default:
+			return b, fmt.Errorf("unknown field %q", key)
+		}
+		switch key {
+		case "channel", "peer_kind", "peer_id", "guild_id", "team_id":
+			scoped = true
+		}
+		if key == "reply" {
+			break
+		}
+	}
+	if !scoped {
+		b.Channel = msg.Channel
+		if msg.GuildID != "" {
+			b.GuildID = msg.GuildID
+		} else {
+			b.PeerKind, b.PeerID = msg.PeerKind, msg.PeerID
+		}
+	}
+	return b, nil
+}
+
+// parseVariants parses "agent:weight,agent:weight".
+func parseVariants(s string) ([]routing.Variant, error) {
+	var out []routing.Variant
+	for _, part := range strings.Split(s, ",") {
+		id, w, ok := strings.Cut(part, ":")
+		weight, err := strconv.Atoi(w)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("variants must look like v1:90,v2:10, got %q", s)
+		}
+		out = append(out, routing.Variant{AgentID: id, Weight: weight})
+	}
+	return out, nil
+}
+
+// registerBindingMethods adds the runtime binding methods. Changes need an
+// admin token and are audited under the admin's name.
+func registerBindingMethods(gw *gateway.Server, a *bindingAdmin) {
+	actor := func(clientID string) string {
+		name, _ := gw.ClientAdmin(clientID)
+		return "gateway:" + name
+	}
+
+	gw.Handle(protocol.MethodBindingList, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{
+			"bindings":      a.store.Bindings(),
+			"default_agent": a.store.DefaultAgent(),
+		}, nil
+	})
+
+	gw.HandleAdmin(protocol.MethodBindingAdd, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var b routing.Binding
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return nil, gateway.Errorf(400, "invalid routing.bindings.add params")
+		}
+		b, err := a.add(b, actor(clientID))
+		if err != nil {
+			return nil, gateway.Errorf(400, "%s", err.Error())
+		}
+		return b, nil
+	})
+
+	gw.HandleAdmin(protocol.MethodBindingRemove, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.ID == "" {
+			return nil, gateway.Errorf(400, "invalid routing.bindings.remove params")
+		}
+		b, err := a.store.Remove(params.ID, actor(clientID))
+		if errors.Is(err, routing.ErrBindingNotFound) {
+			return nil, gateway.Errorf(404, "runtime binding not found: %s", params.ID)
+		}
+		if err != nil {
+			return nil, gateway.Errorf(500, "%s", err.Error())
+		}
+		return b, nil
+	})
+
+	gw.Handle(protocol.MethodBindingAudit, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			Limit int `json:"limit"`
+		}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &params); err != nil {
+				return nil, gateway.Errorf(400, "invalid routing.bindings.audit params")
+			}
+		}
+		if a.audit == nil {
+			return map[string]interface{}{"entries": []routing.AuditEntry{}}, nil
+		}
+		if params.Limit <= 0 {
+			params.Limit = 100
+		}
+		entries, err := a.audit.Recent(params.Limit)
+		if err != nil {
+			return nil, gateway.Errorf(500, "%s", err.Error())
+		}
+		return map[string]interface{}{"entries": entries}, nil
+	})
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/harshadpatil/dhaavak/internal/config"
+	"github.com/harshadpatil/dhaavak/internal/session"
+)
+
+const sessionsUsage = `usage: dhaavak sessions <command> [flags]
+
+commands:
+  export   write a session to JSONL or Markdown
+  import   recreate a session from a JSONL export
+  rekey    re-encrypt all stored sessions with the current key
+  keygen   print a new random encryption key
+
+The session store is opened directly, so stop the server first when using
+the bolt store, or use the session.export / session.import gateway methods.
+`
+
+// runSessions implements the "dhaavak sessions ..." subcommands.
+func runSessions(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, sessionsUsage)
+		return fmt.Errorf("missing sessions command")
+	}
+	switch args[0] {
+	case "export":
+		return runSessionsExport(args[1:])
+	case "import":
+		return runSessionsImport(args[1:])
+	case "rekey":
+		return runSessionsRekey(args[1:])
+	case "keygen":
+		key, err := session.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, sessionsUsage)
+		return fmt.Errorf("unknown sessions command: %s", args[0])
+	}
+}
+
+func runSessionsExport(args []string) error {
+	fs := flag.NewFlagSet("sessions export", flag.ExitOnError)
+	configPath := fs.String("config", "dhaavak.yaml", "path to config file")
+	key := fs.String("key", "", "session key to export (required)")
+	format := fs.String("format", session.FormatJSONL, "output format: jsonl or markdown")
+	out := fs.String("out", "", "output file (default stdout)")
+	fs.Parse(args)
+	if *key == "" {
+		return fmt.Errorf("sessions export: --key is required")
+	}
+
+	mgr, err := openSessionManager(*configPath)
+	if err != nil {
+		return err
+	}
+	defer mgr.Close()
+
+	rec, err := mgr.Export(*key)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.OpenFile(*out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return fmt.Errorf("sessions export: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	return session.Export(w, rec, *format)
+}
+
+func runSessionsImport(args []string) error {
+	fs := flag.NewFlagSet("sessions import", flag.ExitOnError)
+	configPath := fs.String("config", "dhaavak.yaml", "path to config file")
+	in := fs.String("in", "", "JSONL export to import (default stdin)")
+	key := fs.String("key", "", "import under this session key instead of the exported one")
+	replace := fs.Bool("replace", false, "archive an existing session under the key instead of failing")
+	fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return fmt.Errorf("sessions import: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	rec, err := session.ImportJSONL(r)
+	if err != nil {
+		return err
+	}
+
+	mgr, err := openSessionManager(*configPath)
+	if err != nil {
+		return err
+	}
+	defer mgr.Close()
+
+	e, err := mgr.Import(rec, *key, *replace)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %s (%d messages)\n", e.Key, len(rec.History))
+	return nil
+}
+
+// runSessionsRekey seals every stored session with the first configured key.
+// Used after adding a new key in front of the old one, and to encrypt a store
+// that was written before encryption was enabled.
+func runSessionsRekey(args []string) error {
+	fs := flag.NewFlagSet("sessions rekey", flag.ExitOnError)
+	configPath := fs.String("config", "dhaavak.yaml", "path to config file")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	if !cfg.Session.Encryption.Enabled() {
+		return fmt.Errorf("sessions rekey: session.encryption is not configured")
+	}
+	c, err := loadSessionCipher(cfg.Session.Encryption)
+	if err != nil {
+		return err
+	}
+	store, err := session.OpenBoltStore(cfg.Session.Path, c)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	n, err := store.Rekey()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("re-encrypted %d records with key %s\n", n, c.KeyID())
+	return nil
+}
+
+// openSessionManager opens the configured persistent session store for CLI use.
+func openSessionManager(configPath string) (*session.Manager, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Session.Store == "memory" {
+		return nil, fmt.Errorf("session.store is memory; sessions are only available from a running server")
+	}
+	store, err := openSessionStore(cfg.Session)
+	if err != nil {
+		return nil, err
+	}
+	return session.NewManager(cfg.Session.TTL, cfg.Session.MaxHistory, store), nil
+}
+
+// openSessionStore opens the backend selected by session.store.
+// It returns nil for the memory store.
+func openSessionStore(cfg config.SessionConfig) (session.Store, error) {
+	switch cfg.Store {
+	case "bolt":
+		var c *session.Cipher
+		if cfg.Encryption.Enabled() {
+			var err error
+			if c, err = loadSessionCipher(cfg.Encryption); err != nil {
+				return nil, err
+			}
+		}
+		store, err := session.OpenBoltStore(cfg.Path, c)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, nil
+	}
+}
+
+// loadSessionCipher reads the session encryption keys from the configured
+// file or environment variable.
+func loadSessionCipher(cfg config.EncryptionConfig) (*session.Cipher, error) {
+	var text string
+	switch {
+	case cfg.KeyFile != "":
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("session encryption key file: %w", err)
+		}
+		text = string(data)
+	default:
+		text = os.Getenv(cfg.KeyEnv)
+		if text == "" {
+			return nil, fmt.Errorf("session encryption: $%s is not set", cfg.KeyEnv)
+		}
+	}
+	keys, err := session.ParseKeys(text)
+	if err != nil {
+		return nil, err
+	}
+	return session.NewCipher(keys...)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/harshadpatil/dhaavak/internal/config"
+	"github.com/harshadpatil/dhaavak/internal/gateway"
+	"github.com/harshadpatil/dhaavak/internal/queue"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// retryPrefix marks the message ID of a dead letter retry, so the retry's
+// outcome can be matched back to its letter.
+const retryPrefix = "deadletter:"
+
+// retryMessageID is the message ID a dead letter is retried under. Each
+// attempt gets its own ID so the journal does not drop it as a duplicate.
+func retryMessageID(dl queue.DeadLetter) string {
+	return fmt.Sprintf("%s%s:%d", retryPrefix, dl.ID, dl.Attempts)
+}
+
+// retriedLetter returns the dead letter ID a message retries, if any.
+func retriedLetter(msg protocol.InboundMessage) (string, bool) {
+	rest, ok := strings.CutPrefix(msg.MessageID, retryPrefix)
+	if !ok {
+		return "", false
+	}
+	id, _, ok := strings.Cut(rest, ":")
+	return id, ok
+}
+
+// openDeadLetters opens the dead letter store.
+func openDeadLetters(cfg config.QueueDeadLetter, sess config.SessionConfig) (*queue.DeadLetters, error) {
+	sealer, err := queueSealer(sess)
+	if err != nil {
+		return nil, err
+	}
+	return queue.OpenDeadLetters(cfg.Path, sealer)
+}
+
+// registerDeadLetterMethods adds the dead letter admin methods. submit
+// queues a retried message like a newly received one.
+func registerDeadLetterMethods(gw *gateway.Server, dls *queue.DeadLetters, submit func(context.Context, protocol.InboundMessage) error) {
+	gw.Handle(protocol.MethodDeadLetterList, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			SessionID string `json:"session_id"`
+		}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &params); err != nil {
+				return nil, gateway.Errorf(400, "invalid queue.deadletter.list params")
+			}
+		}
+		letters, err := dls.List(params.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		// The payload is the unredacted message; it is only for retries.
+		for i := range letters {
+			letters[i].Payload = nil
+		}
+		return map[string]interface{}{"dead_letters": letters}, nil
+	})
+
+	gw.Handle(protocol.MethodDeadLetterRetry, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.ID == "" {
+			return nil, gateway.Errorf(400, "invalid queue.deadletter.retry params")
+		}
+		prev, err := dls.Get(params.ID)
+		if err != nil {
+			return nil, deadLetterError(err)
+		}
+		if len(prev.Payload) == 0 {
+			return nil, gateway.Errorf(409, "dead letter %s kept no message to retry", prev.ID)
+		}
+		dl, err := dls.Retry(params.ID)
+		if err != nil {
+			return nil, deadLetterError(err)
+		}
+		var msg protocol.InboundMessage
+		if err := json.Unmarshal(dl.Payload, &msg); err != nil {
+			return nil, fmt.Errorf("dead letter %s: %w", dl.ID, err)
+		}
+		msg.MessageID = retryMessageID(dl)
+		if err := submit(ctx, msg); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"id": dl.ID, "attempts": dl.Attempts, "status": "queued"}, nil
+	})
+
+	gw.Handle(protocol.MethodDeadLetterDelete, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.ID == "" {
+			return nil, gateway.Errorf(400, "invalid queue.deadletter.delete params")
+		}
+		if _, err := dls.Get(params.ID); err != nil {
+			return nil, deadLetterError(err)
+		}
+		if err := dls.Remove(params.ID); err != nil {
+			return nil, err
+		}
+		return map[string]string{"status": "deleted"}, nil
+	})
+}
+
+// deadLetterError maps dead letter store errors to gateway error codes.
+func deadLetterError(err error) error {
+	if errors.Is(err, queue.ErrNotFound) {
+		return gateway.Errorf(404, "%s", err.Error())
+	}
+	return err
+}
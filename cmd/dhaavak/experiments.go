@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/harshadpatil/dhaavak/internal/gateway"
+	"github.com/harshadpatil/dhaavak/internal/routing"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// Session metadata keys tagging a session with the variant it was
+// assigned by a binding with variants.
+const (
+	metaExperiment = "experiment"
+	metaVariant    = "variant"
+)
+
+// variantKey returns what a message's variant sticks to: its session key,
+// or with sticky "user" the sender's identity.
+func variantKey(b routing.Binding, msg protocol.InboundMessage, sessKey string) string {
+	if b.Sticky != routing.StickyUser {
+		return sessKey
+	}
+	if msg.UserID != "" {
+		return msg.UserID
+	}
+	sender := msg.SenderID
+	if sender == "" {
+		sender = msg.PeerID
+	}
+	return msg.Channel + ":" + sender
+}
+
+// variantUsage is what the runs of one experiment variant used.
+type variantUsage struct {
+	Experiment   string `json:"experiment"`
+	Variant      string `json:"variant"` // agent ID
+	Runs         int64  `json:"runs"`
+	Failures     int64  `json:"failures"`
+	InputTokens  int64  `json:"input_tokens"`
+	OutputTokens int64  `json:"output_tokens"`
+}
+
+// experimentStats counts runs and tokens per experiment variant since
+// startup.
+type experimentStats struct {
+	mu    sync.Mutex
+	usage map[[2]string]*variantUsage
+}
+
+func newExperimentStats() *experimentStats {
+	return &experimentStats{usage: make(map[[2]string]*variantUsage)}
+}
+
+// record counts one run of variant; a failed run counts no tokens.
+func (s *experimentStats) record(experiment, variant string, inputTokens, outputTokens int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := [2]string{experiment, variant}
+	u := s.usage[key]
+	if u == nil {
+		u = &variantUsage{Experiment: experiment, Variant: variant}
+		s.usage[key] = u
+	}
+	u.Runs++
+	if err != nil {
+		u.Failures++
+		return
+	}
+	u.InputTokens += inputTokens
+	u.OutputTokens += outputTokens
+}
+
+// list returns the usage of every variant, by experiment and variant.
+func (s *experimentStats) list() []variantUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]variantUsage, 0, len(s.usage))
+	for _, u := range s.usage {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Experiment != out[j].Experiment {
+			return out[i].Experiment < out[j].Experiment
+		}
+		return out[i].Variant < out[j].Variant
+	})
+	return out
+}
+
+// registerExperimentMethods exposes the variant usage on /metrics and
+// through routing.experiments.
+func registerExperimentMethods(gw *gateway.Server, s *experimentStats) {
+	vec := func(value func(variantUsage) int64) func() []gateway.Sample {
+		return func() []gateway.Sample {
+			var samples []gateway.Sample
+			for _, u := range s.list() {
+				samples = append(samples, gateway.Sample{
+					Labels: map[string]string{"experiment": u.Experiment, "variant": u.Variant},
+					Value:  value(u),
+				})
+			}
+			return samples
+		}
+	}
+	gw.CounterVec("dhaavak_experiment_runs_total", "Agent runs per experiment variant.",
+		vec(func(u variantUsage) int64 { return u.Runs }))
+	gw.CounterVec("dhaavak_experiment_failures_total", "Failed agent runs per experiment variant.",
+		vec(func(u variantUsage) int64 { return u.Failures }))
+	gw.CounterVec("dhaavak_experiment_input_tokens_total", "LLM input tokens per experiment variant.",
+		vec(func(u variantUsage) int64 { return u.InputTokens }))
+	gw.CounterVec("dhaavak_experiment_output_tokens_total", "LLM output tokens per experiment variant.",
+		vec(func(u variantUsage) int64 { return u.OutputTokens }))
+
+	gw.Handle(protocol.MethodExperiments, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"variants": s.list()}, nil
+	})
+}
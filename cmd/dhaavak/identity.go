@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/harshadpatil/dhaavak/internal/gateway"
+	"github.com/harshadpatil/dhaavak/internal/identity"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+var linkCodePattern = regexp.MustCompile(`^\d{6}$`)
+
+// identityCommand handles the /link, /unlink and /whoami chat commands and
+// returns the reply text. The peer is the message sender.
+//
+//	/link           start a link and receive a code
+//	/link alice     start a link that names the identity "alice"
+//	/link 123456    confirm a code started from another channel
+func identityCommand(dir *identity.Directory, msg protocol.InboundMessage) string {
+	if dir == nil {
+		return "Identity linking is not enabled."
+	}
+	if msg.GuildID != "" {
+		return "Please use /" + msg.Command + " in a private chat."
+	}
+	peer := msg.SenderID
+	if peer == "" {
+		peer = msg.PeerID
+	}
+	args := strings.Fields(msg.Text)
+	if len(args) > 0 {
+		args = args[1:]
+	}
+
+	switch msg.Command {
+	case "link":
+		if len(args) > 0 && linkCodePattern.MatchString(args[0]) {
+			userID, err := dir.ConfirmLink(args[0], msg.Channel, peer)
+			if err != nil {
+				return "Could not link: " + err.Error()
+			}
+			return fmt.Sprintf("Linked. You are now %s on every linked channel.", userID)
+		}
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		code, expires, err := dir.StartLink(msg.Channel, peer, name)
+		if err != nil {
+			return "Could not start link: " + err.Error()
+		}
+		return fmt.Sprintf("Your link code is %s. Send \"/link %s\" from the other channel (or confirm it in the web UI) within %s.",
+			code, code, time.Until(expires).Round(time.Minute))
+
+	case "unlink":
+		if err := dir.Unlink(msg.Channel, peer); err != nil {
+			return "Could not unlink: " + err.Error()
+		}
+		return "This channel is no longer linked to your identity."
+
+	case "whoami":
+		userID, ok := dir.Resolve(msg.Channel, peer)
+		if !ok {
+			return "This channel is not linked to an identity. Send /link to start."
+		}
+		var peers []string
+		for _, p := range dir.Peers(userID) {
+			peers = append(peers, p.String())
+		}
+		return fmt.Sprintf("You are %s (linked: %s).", userID, strings.Join(peers, ", "))
+	}
+	return ""
+}
+
+// registerIdentityMethods wires the identity.* gateway methods. The peer is
+// the calling client's peer ID on the "websocket" channel.
+func registerIdentityMethods(gw *gateway.Server, dir *identity.Directory) {
+	peerOf := func(clientID string) (string, error) {
+		peer, ok := gw.ClientPeer(clientID)
+		if !ok {
+			return "", gateway.Errorf(410, "client disconnected")
+		}
+		return peer, nil
+	}
+
+	gw.Handle(protocol.MethodIdentityGet, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		peer, err := peerOf(clientID)
+		if err != nil {
+			return nil, err
+		}
+		userID, ok := dir.Resolve("websocket", peer)
+		if !ok {
+			return map[string]interface{}{"peer_id": peer}, nil
+		}
+		return map[string]interface{}{"peer_id": peer, "user_id": userID, "peers": dir.Peers(userID)}, nil
+	})
+
+	gw.Handle(protocol.MethodLinkStart, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			Name string `json:"name"`
+		}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &params); err != nil {
+				return nil, gateway.Errorf(400, "invalid identity.link.start params")
+			}
+		}
+		peer, err := peerOf(clientID)
+		if err != nil {
+			return nil, err
+		}
+		code, expires, err := dir.StartLink("websocket", peer, params.Name)
+		if err != nil {
+			return nil, identityError(err)
+		}
+		return map[string]interface{}{"code": code, "expires_at": expires}, nil
+	})
+
+	gw.Handle(protocol.MethodLinkConfirm, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			Code string `json:"code"`
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.Code == "" {
+			return nil, gateway.Errorf(400, "invalid identity.link.confirm params")
+		}
+		peer, err := peerOf(clientID)
+		if err != nil {
+			return nil, err
+		}
+		userID, err := dir.ConfirmLink(params.Code, "websocket", peer)
+		if err != nil {
+			return nil, identityError(err)
+		}
+		return map[string]string{"user_id": userID}, nil
+	})
+
+	gw.Handle(protocol.MethodUnlink, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		peer, err := peerOf(clientID)
+		if err != nil {
+			return nil, err
+		}
+		if err := dir.Unlink("websocket", peer); err != nil {
+			return nil, err
+		}
+		return map[string]string{"status": "unlinked"}, nil
+	})
+}
+
+// identityError maps identity errors to gateway error codes.
+func identityError(err error) error {
+	switch {
+	case errors.Is(err, identity.ErrInvalidCode), errors.Is(err, identity.ErrSamePeer):
+		return gateway.Errorf(400, "%s", err.Error())
+	case errors.Is(err, identity.ErrNameTaken):
+		return gateway.Errorf(409, "%s", err.Error())
+	case errors.Is(err, identity.ErrTooManyAttempts):
+		return gateway.Errorf(429, "%s", err.Error())
+	default:
+		return gateway.Errorf(400, "%s", err.Error())
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -13,17 +14,45 @@ import (
 
 	"github.com/harshadpatil/dhaavak/internal/agent"
 	"github.com/harshadpatil/dhaavak/internal/channel"
+	"github.com/harshadpatil/dhaavak/internal/channel/discord"
+	"github.com/harshadpatil/dhaavak/internal/channel/matrix"
+	"github.com/harshadpatil/dhaavak/internal/channel/slack"
 	"github.com/harshadpatil/dhaavak/internal/channel/telegram"
 	"github.com/harshadpatil/dhaavak/internal/config"
 	"github.com/harshadpatil/dhaavak/internal/gateway"
+	"github.com/harshadpatil/dhaavak/internal/identity"
 	"github.com/harshadpatil/dhaavak/internal/llm"
 	"github.com/harshadpatil/dhaavak/internal/queue"
+	"github.com/harshadpatil/dhaavak/internal/redact"
 	"github.com/harshadpatil/dhaavak/internal/routing"
 	"github.com/harshadpatil/dhaavak/internal/session"
 	"github.com/harshadpatil/dhaavak/pkg/protocol"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "sessions" {
+		if err := runSessions(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "route" {
+		if err := runRoute(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "queue" {
+		if err := runQueue(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	configPath := flag.String("config", "dhaavak.yaml", "path to config file")
 	flag.Parse()
 
@@ -37,36 +66,104 @@ func main() {
 		os.Exit(1)
 	}
 
+	// --- Redaction ---
+	var redaction *redactPipeline
+	if cfg.Redact.Enabled {
+		redactor, err := newRedactor(cfg.Redact)
+		if err != nil {
+			slog.Error("invalid redaction config", "err", err)
+			os.Exit(1)
+		}
+		redaction = newRedactPipeline(redactor, cfg.Redact.LLM)
+		if cfg.Redact.Logs {
+			slog.SetDefault(slog.New(redact.NewHandler(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+				Level: slog.LevelDebug,
+			}), redactor)))
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// --- Session Manager ---
-	sessionMgr := session.NewManager(cfg.Session.TTL, cfg.Session.MaxHistory)
+	sessionStore, err := openSessionStore(cfg.Session)
+	if err != nil {
+		slog.Error("failed to open session store", "err", err)
+		os.Exit(1)
+	}
+	sessionMgr := session.NewManager(cfg.Session.TTL, cfg.Session.MaxHistory, sessionStore)
+	scopes, err := sessionScopes(cfg.Session)
+	if err != nil {
+		slog.Error("invalid session scope", "err", err)
+		os.Exit(1)
+	}
+	dayLoc, _ := time.LoadLocation(cfg.Session.Timezone) // validated by config.Load
 	sessionMgr.StartCleanup(ctx, cfg.Session.CleanupInterval)
 
+	// --- Identity Directory ---
+	var identities *identity.Directory
+	if cfg.Identity.Enabled {
+		identities, err = identity.NewDirectory(identity.NewFileStore(cfg.Identity.Path), cfg.Identity.CodeTTL)
+		if err != nil {
+			slog.Error("failed to load identities", "err", err)
+			os.Exit(1)
+		}
+	}
+
 	// --- Queue Manager ---
 	queueMgr := queue.NewManager(ctx, cfg.Queue.BufferSize, cfg.Queue.IdleTimeout)
+	queueMgr.SetDebounce(cfg.Queue.Debounce)
+	limiter := queueLimiter(cfg.Queue.Limits)
+	queueMgr.SetLimiter(limiter)
 	queueMgr.StartCleanup(ctx, cfg.Queue.CleanupInterval)
-
-	// --- Router ---
-	var bindings []routing.Binding
-	for _, b := range cfg.Channels.Telegram.Bindings {
-		bindings = append(bindings, routing.Binding{
-			Channel:  "telegram",
-			PeerKind: b.PeerKind,
-			PeerID:   b.PeerID,
-			AgentID:  b.AgentID,
-		})
+	modes, err := queueModes(cfg.Queue)
+	if err != nil {
+		slog.Error("invalid queue mode", "err", err)
+		os.Exit(1)
 	}
-	defaultAgent := ""
-	if len(cfg.Agents) > 0 {
-		defaultAgent = cfg.Agents[0].ID
+	overflow, err := queueOverflow(cfg.Queue.Overflow)
+	if err != nil {
+		slog.Error("invalid queue overflow policy", "err", err)
+		os.Exit(1)
 	}
-	if cfg.Channels.Telegram.DefaultAgent != "" {
-		defaultAgent = cfg.Channels.Telegram.DefaultAgent
+	queueMgr.SetOverflow(overflow)
+	var spill *queue.Spill
+	if overflow.Overflow == queue.OverflowSpill {
+		spill, err = openSpill(cfg.Queue.Overflow, cfg.Session)
+		if err != nil {
+			slog.Error("failed to open queue spill", "err", err)
+			os.Exit(1)
+		}
 	}
-	store := routing.NewBindingStore(bindings, defaultAgent)
+	var deadLetters *queue.DeadLetters
+	if cfg.Queue.DeadLetter.Enabled {
+		deadLetters, err = openDeadLetters(cfg.Queue.DeadLetter, cfg.Session)
+		if err != nil {
+			slog.Error("failed to open dead letters", "err", err)
+			os.Exit(1)
+		}
+	}
+	var journal *queue.Journal
+	if cfg.Queue.Journal.Enabled {
+		journal, err = openJournal(cfg.Queue.Journal, cfg.Session)
+		if err != nil {
+			slog.Error("failed to open queue journal", "err", err)
+			os.Exit(1)
+		}
+		journal.StartCleanup(ctx, cfg.Queue.CleanupInterval, cfg.Queue.Journal.Retention)
+	}
+
+	// --- Router ---
+	store := routing.NewBindingStore(routeBindings(cfg), cfg.Agents[0].ID)
 	router := routing.NewResolver(store)
+	bindingAdmins, err := newBindingAdmin(cfg, store)
+	if err != nil {
+		slog.Error("failed to open binding store", "err", err)
+		os.Exit(1)
+	}
+	sessionRoutes := newSessionRouter(cfg, sessionMgr)
+	experiments := newExperimentStats()
+	slog.Info("routing bindings loaded", "count", len(store.Bindings()), "default_agent", store.DefaultAgent())
 
 	// --- LLM Provider ---
 	var provider llm.Provider
@@ -89,8 +186,13 @@ func main() {
 		})
 	}
 
+	// Tokenized history is restored for the LLM when it may see originals.
+	if redaction.tokenize() && !cfg.Redact.LLM {
+		runtime.SetPromptFilter(redaction.restore)
+	}
+
 	// --- Gateway Server ---
-	gw := gateway.New(cfg.Server, cfg.Auth.Token)
+	gw := gateway.New(cfg.Server, cfg.Auth)
 
 	// --- Channel Registry ---
 	registry := channel.NewRegistry()
@@ -99,7 +201,13 @@ func main() {
 	runtime.SetEventSink(func(evt agent.Event) {
 		switch evt.Type {
 		case "delta":
-			gw.ChatState.AccumulateDelta(evt.SessionID, evt.RunSeq, evt.Text)
+			text := evt.Text
+			if redaction.tokenize() {
+				if e, ok := sessionMgr.Get(evt.SessionID); ok {
+					text = redaction.delta(e, evt.SessionID, text)
+				}
+			}
+			gw.ChatState.AccumulateDelta(evt.SessionID, evt.RunSeq, text)
 		case "tool_use":
 			data, _ := json.Marshal(map[string]string{
 				"tool_use_id": evt.ToolUseID,
@@ -123,6 +231,13 @@ func main() {
 				Data:      data,
 			})
 		case "complete":
+			if redaction.tokenize() {
+				if e, ok := sessionMgr.Get(evt.SessionID); ok {
+					if rest := redaction.flush(e, evt.SessionID); rest != "" {
+						gw.ChatState.AccumulateDelta(evt.SessionID, evt.RunSeq, rest)
+					}
+				}
+			}
 			gw.ChatState.Flush(evt.SessionID)
 			gw.BroadcastSession(evt.SessionID, protocol.EventFrame{
 				Event:     protocol.EventChatComplete,
@@ -130,6 +245,11 @@ func main() {
 				RunSeq:    evt.RunSeq,
 			})
 		case "error":
+			if redaction.tokenize() {
+				if e, ok := sessionMgr.Get(evt.SessionID); ok {
+					redaction.flush(e, evt.SessionID)
+				}
+			}
 			errMsg := "unknown error"
 			if evt.Err != nil {
 				errMsg = evt.Err.Error()
@@ -144,33 +264,309 @@ func main() {
 		}
 	})
 
-	// processMessage is the unified message handler for both WS and channel messages.
-	processMessage := func(ctx context.Context, msg protocol.InboundMessage) error {
+	// Tell waiting clients where they are in line.
+	limiter.OnPosition(func(sessionID string, position int) {
+		data, _ := json.Marshal(map[string]int{"position": position})
+		gw.BroadcastSession(sessionID, protocol.EventFrame{
+			Event:     protocol.EventQueuePosition,
+			SessionID: sessionID,
+			Data:      data,
+		})
+	})
+
+	// reply sends a short text back through the channel a message came from.
+	reply := func(ctx context.Context, msg protocol.InboundMessage, text string) error {
+		return registry.SendMessage(ctx, protocol.OutboundMessage{
+			SessionID: msg.SessionID,
+			Channel:   msg.Channel,
+			PeerID:    msg.PeerID,
+			ThreadID:  msg.ThreadID,
+			Text:      text,
+			Format:    "text",
+		})
+	}
+
+	// finish marks a journaled message as handled; forget drops one that was
+	// rejected so a redelivery is accepted.
+	finish := func(msg protocol.InboundMessage) {
+		if journal == nil || msg.MessageID == "" {
+			return
+		}
+		if err := journal.Done(msg.MessageID); err != nil {
+			slog.Error("queue journal error", "id", msg.MessageID, "err", err)
+		}
+	}
+	forget := func(msg protocol.InboundMessage) {
+		if journal == nil || msg.MessageID == "" {
+			return
+		}
+		if err := journal.Forget(msg.MessageID); err != nil {
+			slog.Error("queue journal error", "id", msg.MessageID, "err", err)
+		}
+	}
+
+	// notify tells a sender their message was not answered: WebSocket
+	// sessions get a chat.error event, other channels a reply.
+	notify := func(msg protocol.InboundMessage, text string) {
+		if msg.Channel == "websocket" {
+			data, _ := json.Marshal(map[string]string{"error": text})
+			gw.BroadcastSession(msg.SessionID, protocol.EventFrame{
+				Event:     protocol.EventChatError,
+				SessionID: msg.SessionID,
+				Data:      data,
+			})
+			return
+		}
+		if err := reply(ctx, msg, text); err != nil {
+			slog.Error("notify reply error", "channel", msg.Channel, "err", err)
+		}
+	}
+	busyText := cfg.Queue.Overflow.BusyReply
+
+	// fail records a failed run as a dead letter and tells the sender. A
+	// failed retry updates the letter it retried. The letter's text is
+	// redacted; the original message is only kept for retries when dead
+	// letters are sealed, like the journal and spill, or nothing is redacted.
+	keepPayload := redaction == nil || cfg.Session.Encryption.Enabled()
+	fail := func(msg protocol.InboundMessage, runErr error) {
+		if deadLetters != nil {
+			dl := queue.DeadLetter{
+				SessionID: msg.SessionID,
+				AgentID:   msg.AgentID,
+				Text:      redaction.archived(msg.Text),
+				Error:     runErr.Error(),
+			}
+			if keepPayload {
+				dl.Payload, _ = json.Marshal(msg)
+			}
+			if id, ok := retriedLetter(msg); ok {
+				if prev, err := deadLetters.Get(id); err == nil {
+					dl.ID, dl.Attempts, dl.Payload = prev.ID, prev.Attempts, prev.Payload
+				}
+			}
+			if dl, err := deadLetters.Add(dl); err != nil {
+				slog.Error("dead letter error", "session", msg.SessionID, "err", err)
+			} else {
+				slog.Warn("run moved to dead letters", "id", dl.ID, "session", msg.SessionID, "attempts", dl.Attempts)
+			}
+		}
+		notify(msg, cfg.Queue.FailureReply)
+	}
+
+	// done is the OnDone of a message's task.
+	done := func(msg protocol.InboundMessage) func(error) {
+		return func(err error) {
+			switch {
+			case err == nil:
+				if id, ok := retriedLetter(msg); ok && deadLetters != nil {
+					if err := deadLetters.Remove(id); err != nil {
+						slog.Error("dead letter error", "id", id, "err", err)
+					}
+				}
+			case errors.Is(err, queue.ErrCanceled), errors.Is(err, queue.ErrPurged):
+			case errors.Is(err, queue.ErrDropped):
+				notify(msg, busyText)
+			default:
+				fail(msg, err)
+			}
+			finish(msg)
+		}
+	}
+
+	// prepare routes a message and builds the task for its lane. Commands
+	// that need no lane are answered here, and queued is false.
+	prepare := func(ctx context.Context, msg protocol.InboundMessage) (t queue.Task, queued bool, err error) {
+		switch msg.Command {
+		case "link", "unlink", "whoami":
+			err := reply(ctx, msg, identityCommand(identities, msg))
+			finish(msg)
+			return queue.Task{}, false, err
+		}
+
+		// Resolve the sender's canonical identity.
+		if identities != nil {
+			peer := msg.SenderID
+			if peer == "" {
+				peer = msg.PeerID
+			}
+			if userID, ok := identities.Resolve(msg.Channel, peer); ok {
+				msg.UserID = userID
+			}
+		}
+
+		// Runtime binding commands, for routing.admins.
+		switch msg.Command {
+		case "bind", "unbind", "bindings":
+			err := reply(ctx, msg, bindingAdmins.command(msg))
+			finish(msg)
+			return queue.Task{}, false, err
+		}
+
 		// Resolve agent.
+		route := routing.ResolveParams{
+			Channel:  msg.Channel,
+			PeerKind: msg.PeerKind,
+			PeerID:   msg.PeerID,
+			GuildID:  msg.GuildID,
+			TeamID:   msg.TeamID,
+
+			Text:        msg.Text,
+			Command:     msg.Command,
+			Attachments: msg.Attachments,
+		}
+		explicit := msg.AgentID != ""
 		agentID := msg.AgentID
 		if agentID == "" {
-			agentID = router.Resolve(routing.ResolveParams{
+			agentID = router.Resolve(route)
+		}
+		msg.AgentID = agentID
+		binding, _ := router.Match(route)
+		priority := taskPriority(cfg.Queue.Priority, msg, binding)
+
+		// Build session key.
+		sessKey := msg.SessionID
+		if sessKey == "" {
+			kp := session.KeyParams{
+				AgentID:  agentID,
 				Channel:  msg.Channel,
 				PeerKind: msg.PeerKind,
 				PeerID:   msg.PeerID,
 				GuildID:  msg.GuildID,
-			})
+				ThreadID: msg.ThreadID,
+				SenderID: msg.SenderID,
+				Time:     time.Now().In(dayLoc),
+			}
+			// Linked users share their DM sessions across channels.
+			if msg.UserID != "" {
+				kp.SenderID = msg.UserID
+				if msg.GuildID == "" {
+					kp.Channel, kp.PeerKind, kp.PeerID = "identity", "user", msg.UserID
+				}
+			}
+			key, err := session.BuildKey(scopes.For(agentID, msg.Channel), kp)
+			if err != nil {
+				return queue.Task{}, false, err
+			}
+			sessKey = key
+		}
+		msg.SessionID = sessKey
+		if msg.Channel == "websocket" {
+			gw.SubscribePeer(msg.PeerID, sessKey)
 		}
-		msg.AgentID = agentID
 
-		// Build session key.
-		sessKey := session.Key(agentID, msg.Channel, msg.PeerKind, msg.PeerID, msg.GuildID, msg.ThreadID)
-		if msg.SessionID != "" {
-			sessKey = msg.SessionID
+		// /stop cancels the session's runs without waiting in its lanes.
+		if msg.Command == "stop" {
+			text := "Nothing to stop."
+			if queueMgr.Cancel(sessKey) {
+				text = "Stopped."
+			}
+			err := reply(ctx, msg, text)
+			finish(msg)
+			return queue.Task{}, false, err
+		}
+
+		// A reply binding, e.g. a maintenance window, answers without a run;
+		// WebSocket clients get it as an error, like the busy reply.
+		if !explicit && binding.Reply != "" {
+			finish(msg)
+			if msg.Channel == "websocket" {
+				return queue.Task{}, false, gateway.Errorf(503, "%s", binding.Reply)
+			}
+			return queue.Task{}, false, reply(ctx, msg, binding.Reply)
+		}
+
+		// /agent switches the session's agent; otherwise the session's agent
+		// (or the classifier's pick) applies unless the message named one or
+		// matched a content binding. The session key stays the binding's.
+		if msg.Command == "agent" {
+			entry := sessionMgr.GetOrCreate(sessKey, agentID)
+			err := reply(ctx, msg, sessionRoutes.command(entry, msg.Text))
+			finish(msg)
+			return queue.Task{}, false, err
+		}
+		// A binding with variants splits conversations between agents; the
+		// variant sticks to the session or user through a hash.
+		variant := ""
+		if !explicit && len(binding.Variants) > 0 {
+			variant = binding.Assign(variantKey(binding, msg, sessKey))
+			agentID, msg.AgentID = variant, variant
+		}
+		// The classifier is asked inside the task, where the run limiter
+		// applies, so routing never waits on it; until then the task is
+		// limited and timed as the fallback agent's.
+		classify := false
+		if !explicit && !binding.HasContent() && msg.Command != "new" && msg.Command != "reset" {
+			entry := sessionMgr.GetOrCreate(sessKey, agentID)
+			if id := sessionRoutes.sessionAgent(entry); id != "" {
+				agentID, msg.AgentID = id, id
+			} else {
+				classify = sessionRoutes.classifies(msg.Text)
+			}
+		}
+		if agentID != variant {
+			variant = ""
+		}
+		experiment := binding.ExperimentName()
+
+		// The routed message is the task's payload, so a spilled task can be
+		// rebuilt without routing it again. One still to be classified is
+		// routed again, so the rebuilt task asks the classifier too.
+		routed := msg
+		if classify {
+			routed.AgentID = ""
+		}
+		payload, err := json.Marshal(routed)
+		if err != nil {
+			return queue.Task{}, false, err
+		}
+
+		// Chat commands run through the lane so they serialize with agent runs.
+		switch msg.Command {
+		case "new", "reset":
+			return queue.Task{
+				SessionID: sessKey,
+				Payload:   payload,
+				Fn: func(ctx context.Context, _ string) error {
+					sessionMgr.Reset(sessKey, agentID)
+					gw.BroadcastSession(sessKey, protocol.EventFrame{
+						Event:     protocol.EventSessionReset,
+						SessionID: sessKey,
+					})
+					return reply(ctx, msg, "Started a new conversation.")
+				},
+				OnDone: done(msg),
+			}, true, nil
 		}
-		msg.SessionID = sessKey
 
 		entry := sessionMgr.GetOrCreate(sessKey, agentID)
+		if msg.UserID != "" {
+			entry.SetMeta("user_id", msg.UserID)
+		}
 
-		// Enqueue task for serial execution.
-		ok := queueMgr.Enqueue(queue.Task{
+		// Build the task for serial execution.
+		return queue.Task{
 			SessionID: sessKey,
-			Fn: func(ctx context.Context) error {
+			Payload:   payload,
+			Mode:      modes.For(agentID),
+			Priority:  priority,
+			Text:      msg.Text,
+			AgentID:   agentID,
+			Provider:  cfg.LLM.Provider,
+			Weight:    runWeight(cfg.Queue.Limits, agentID),
+			Timeout:   runTimeout(cfg, agentID),
+			Fn: func(ctx context.Context, text string) error {
+				agentID, variant := agentID, variant
+				if classify {
+					agentID = sessionRoutes.agentFor(ctx, entry, redaction.masked(text), agentID)
+					if agentID != variant {
+						variant = ""
+					}
+				}
+				if variant != "" {
+					entry.SetMeta(metaExperiment, experiment)
+					entry.SetMeta(metaVariant, variant)
+				}
+
 				runSeq := gw.RunState.Next(sessKey)
 
 				// Broadcast run start.
@@ -180,14 +576,41 @@ func main() {
 					RunSeq:    runSeq,
 				})
 
-				result, err := runtime.Run(ctx, agentID, entry, msg.Text, runSeq)
+				// Text steered in while the run is in progress joins it at
+				// the next turn boundary and is stored after the user message.
+				history := []session.Message{{Role: "user"}}
+				storedText, prompt := redaction.inbound(entry, text)
+				history[0].Content = storedText
+				runCtx := agent.WithSteering(ctx, func() string {
+					steered := queue.Steered(ctx)
+					if steered == "" {
+						return ""
+					}
+					stored, prompt := redaction.inbound(entry, steered)
+					history = append(history, session.Message{Role: "user", Content: stored})
+					return prompt
+				})
+
+				result, err := runtime.Run(runCtx, agentID, entry, prompt, runSeq)
 				if err != nil {
+					if variant != "" {
+						experiments.record(experiment, variant, 0, 0, err)
+					}
 					return err
 				}
+				if variant != "" {
+					experiments.record(experiment, variant, result.InputTokens, result.OutputTokens, nil)
+				}
+				storedReply, replyText := redaction.outbound(entry, result.Text)
 
-				// Save to session history.
-				entry.AppendHistory(session.Message{Role: "user", Content: msg.Text}, sessionMgr.MaxHistory())
-				entry.AppendHistory(session.Message{Role: "assistant", Content: result.Text}, sessionMgr.MaxHistory())
+				// Save to session history and write through to the store.
+				entry.AddUsage(session.Usage{
+					InputTokens:  result.InputTokens,
+					OutputTokens: result.OutputTokens,
+					Runs:         1,
+				})
+				history = append(history, session.Message{Role: "assistant", Content: storedReply})
+				sessionMgr.Append(entry, history...)
 
 				// Broadcast run end.
 				gw.BroadcastSession(sessKey, protocol.EventFrame{
@@ -203,19 +626,104 @@ func main() {
 						Channel:   msg.Channel,
 						PeerID:    msg.PeerID,
 						ThreadID:  msg.ThreadID,
-						Text:      result.Text,
+						Text:      replyText,
 						Format:    "markdown",
 					})
 				}
 				return nil
 			},
-		})
-		if !ok {
-			return fmt.Errorf("queue full for session %s", sessKey)
+			OnDone: done(msg),
+		}, true, nil
+	}
+
+	// dispatch routes a message and queues its work. A message refused by a
+	// full lane gets the busy reply.
+	dispatch := func(ctx context.Context, msg protocol.InboundMessage) error {
+		t, queued, err := prepare(ctx, msg)
+		if err != nil || !queued {
+			return err
+		}
+		if !queueMgr.Enqueue(t) {
+			forget(msg)
+			if msg.Channel == "websocket" {
+				return gateway.Errorf(503, "%s", busyText)
+			}
+			return reply(ctx, msg, busyText)
 		}
 		return nil
 	}
 
+	// Spilled tasks are rebuilt from their routed message.
+	if spill != nil {
+		queueMgr.SetSpill(spill, func(payload []byte) (queue.Task, error) {
+			var msg protocol.InboundMessage
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				return queue.Task{}, err
+			}
+			t, _, err := prepare(ctx, msg)
+			return t, err
+		})
+	}
+
+	// accept records a message in the journal, if enabled, and reports
+	// false for a redelivered one.
+	accept := func(ctx context.Context, msg protocol.InboundMessage) (bool, error) {
+		if journal == nil || msg.MessageID == "" {
+			return true, nil
+		}
+		added, err := journal.Add(msg.MessageID, msg)
+		if err != nil {
+			return false, fmt.Errorf("journal message: %w", err)
+		}
+		if !added {
+			slog.Info("duplicate message dropped", "id", msg.MessageID)
+		}
+		return added, nil
+	}
+
+	// The journal is replayed once the channels have started, so replies
+	// to replayed work find their adapter ready. Live messages wait for the
+	// replay to finish so they queue up behind it.
+	replayed := make(chan struct{})
+	dispatchLive := func(ctx context.Context, msg protocol.InboundMessage) error {
+		select {
+		case <-replayed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return dispatch(ctx, msg)
+	}
+
+	// processMessage is the unified message handler for both WS and channel
+	// messages. With the journal enabled a message is recorded before it is
+	// acknowledged, and a redelivered message is dropped.
+	processMessage := func(ctx context.Context, msg protocol.InboundMessage) error {
+		added, err := accept(ctx, msg)
+		if err != nil || !added {
+			return err
+		}
+		return dispatchLive(ctx, msg)
+	}
+
+	registerSessionMethods(gw, sessionMgr, identities)
+	registerRoutingMethods(gw, sessionRoutes, router)
+	registerBindingMethods(gw, bindingAdmins)
+	registerExperimentMethods(gw, experiments)
+	registerQueueMetrics(gw, queueMgr)
+	shutdownCh := make(chan struct{}, 1)
+	registerQueueMethods(gw, queueMgr, func() {
+		select {
+		case shutdownCh <- struct{}{}:
+		default:
+		}
+	})
+	if deadLetters != nil {
+		registerDeadLetterMethods(gw, deadLetters, processMessage)
+	}
+	if identities != nil {
+		registerIdentityMethods(gw, identities)
+	}
+
 	// Wire WebSocket chat.send -> processMessage.
 	gw.OnChatSend = func(ctx context.Context, clientID string, msg protocol.InboundMessage) error {
 		return processMessage(ctx, msg)
@@ -235,12 +743,61 @@ func main() {
 		registry.Register(bot)
 	}
 
+	// --- Slack Adapter ---
+	if cfg.Channels.Slack.Enabled {
+		// Slack acknowledges an event once it is journaled, then routes it
+		// on its own worker.
+		bot := slack.NewBot(slack.ConfigFromApp(cfg.Channels.Slack))
+		bot.SetAccept(accept)
+		bot.SetSink(dispatchLive)
+		if cfg.Channels.Slack.Mode == "events" {
+			gw.HandleHTTP(cfg.Channels.Slack.EventsPath, bot.EventsHandler())
+		}
+		registry.Register(bot)
+	}
+
+	// --- Discord Adapter ---
+	if cfg.Channels.Discord.Enabled {
+		bot := discord.NewBot(discord.ConfigFromApp(cfg.Channels.Discord))
+		bot.SetSink(func(ctx context.Context, msg protocol.InboundMessage) error {
+			return processMessage(ctx, msg)
+		})
+		registry.Register(bot)
+	}
+
+	// --- Matrix Adapter ---
+	if cfg.Channels.Matrix.Enabled {
+		bot := matrix.NewBot(matrix.ConfigFromApp(cfg.Channels.Matrix))
+		bot.SetSink(func(ctx context.Context, msg protocol.InboundMessage) error {
+			return processMessage(ctx, msg)
+		})
+		registry.Register(bot)
+	}
+
 	// --- Start ---
 	if err := registry.StartAll(ctx); err != nil {
 		slog.Error("failed to start channels", "err", err)
 		os.Exit(1)
 	}
 
+	// Replay work that was accepted but not finished before the last
+	// shutdown, then let live messages through.
+	if journal != nil {
+		n, err := journal.Replay(func(item queue.JournalItem) error {
+			var msg protocol.InboundMessage
+			if err := json.Unmarshal(item.Payload, &msg); err != nil {
+				return err
+			}
+			return dispatch(ctx, msg)
+		})
+		if err != nil {
+			slog.Error("queue journal replay error", "err", err)
+		} else if n > 0 {
+			slog.Info("replayed unfinished messages", "count", n)
+		}
+	}
+	close(replayed)
+
 	// Start gateway in a goroutine.
 	go func() {
 		if err := gw.Start(ctx); err != nil {
@@ -254,7 +811,25 @@ func main() {
 	// --- Signal Handling ---
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	select {
+	case <-sigCh:
+		if cfg.Queue.DrainTimeout > 0 {
+			drainCtx, drainCancel := context.WithTimeout(ctx, cfg.Queue.DrainTimeout)
+			go func() { // a second signal stops waiting
+				select {
+				case <-sigCh:
+					drainCancel()
+				case <-drainCtx.Done():
+				}
+			}()
+			slog.Info("draining queue before shutdown", "timeout", cfg.Queue.DrainTimeout)
+			if err := queueMgr.Drain(drainCtx); err != nil {
+				slog.Warn("queue not drained before shutdown", "err", err)
+			}
+			drainCancel()
+		}
+	case <-shutdownCh: // queue.drain with shutdown, already drained
+	}
 
 	slog.Info("shutting down...")
 	cancel()
@@ -263,10 +838,28 @@ func main() {
 	defer shutdownCancel()
 
 	queueMgr.StopAll()
+	if deadLetters != nil {
+		if err := deadLetters.Close(); err != nil {
+			slog.Error("dead letter close error", "err", err)
+		}
+	}
+	if spill != nil {
+		if err := spill.Close(); err != nil {
+			slog.Error("queue spill close error", "err", err)
+		}
+	}
+	if journal != nil {
+		if err := journal.Close(); err != nil {
+			slog.Error("queue journal close error", "err", err)
+		}
+	}
 	registry.StopAll(shutdownCtx)
 	if err := gw.Stop(shutdownCtx); err != nil {
 		slog.Error("gateway shutdown error", "err", err)
 	}
+	if err := sessionMgr.Close(); err != nil {
+		slog.Error("session store close error", "err", err)
+	}
 
 	slog.Info("dhaavak stopped")
 }
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/harshadpatil/dhaavak/internal/config"
+	"github.com/harshadpatil/dhaavak/internal/gateway"
+	"github.com/harshadpatil/dhaavak/internal/queue"
+	"github.com/harshadpatil/dhaavak/internal/routing"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// queueModes builds the queue mode table from config.
+func queueModes(cfg config.QueueConfig) (queue.Modes, error) {
+	def, err := queue.ParseMode(cfg.Mode)
+	if err != nil {
+		return queue.Modes{}, fmt.Errorf("queue.mode: %w", err)
+	}
+	modes := queue.Modes{
+		Default: def,
+		Agents:  make(map[string]queue.Mode, len(cfg.AgentModes)),
+	}
+	for id, name := range cfg.AgentModes {
+		if modes.Agents[id], err = queue.ParseMode(name); err != nil {
+			return queue.Modes{}, fmt.Errorf("queue.agent_modes.%s: %w", id, err)
+		}
+	}
+	return modes, nil
+}
+
+// queueLimiter builds the cross-lane concurrency limiter from config.
+func queueLimiter(cfg config.QueueLimits) *queue.Limiter {
+	limits := queue.Limits{
+		Global:    int64(cfg.Global),
+		Agents:    make(map[string]int64, len(cfg.Agents)),
+		Providers: make(map[string]int64, len(cfg.Providers)),
+	}
+	for id, n := range cfg.Agents {
+		limits.Agents[id] = int64(n)
+	}
+	for name, n := range cfg.Providers {
+		limits.Providers[name] = int64(n)
+	}
+	return queue.NewLimiter(limits)
+}
+
+// runWeight returns how many limiter slots one run of agentID takes.
+func runWeight(cfg config.QueueLimits, agentID string) int64 {
+	if w, ok := cfg.Weights[agentID]; ok {
+		return int64(w)
+	}
+	return 1
+}
+
+// openJournal opens the inbound message journal.
+func openJournal(cfg config.QueueJournal, sess config.SessionConfig) (*queue.Journal, error) {
+	sealer, err := queueSealer(sess)
+	if err != nil {
+		return nil, err
+	}
+	return queue.OpenJournal(cfg.Path, sealer)
+}
+
+// queueSealer returns the session cipher when session encryption is
+// configured, so queued messages on disk are sealed like sessions.
+func queueSealer(sess config.SessionConfig) (queue.Sealer, error) {
+	if !sess.Encryption.Enabled() {
+		return nil, nil
+	}
+	return loadSessionCipher(sess.Encryption)
+}
+
+// runTimeout returns the run deadline of agentID.
+func runTimeout(cfg *config.Config, agentID string) time.Duration {
+	for _, a := range cfg.Agents {
+		if a.ID == agentID && a.Timeout > 0 {
+			return a.Timeout
+		}
+	}
+	return cfg.Queue.RunTimeout
+}
+
+// queueOverflow builds the lane overflow policy from config.
+func queueOverflow(cfg config.QueueOverflow) (queue.Policy, error) {
+	o, err := queue.ParseOverflow(cfg.Policy)
+	if err != nil {
+		return queue.Policy{}, fmt.Errorf("queue.overflow.policy: %w", err)
+	}
+	return queue.Policy{Overflow: o, Timeout: cfg.Timeout}, nil
+}
+
+// openSpill opens the overflow spill file.
+func openSpill(cfg config.QueueOverflow, sess config.SessionConfig) (*queue.Spill, error) {
+	sealer, err := queueSealer(sess)
+	if err != nil {
+		return nil, err
+	}
+	return queue.OpenSpill(cfg.SpillPath, sealer)
+}
+
+// registerQueueMetrics exposes the lane overflow counters on /metrics.
+func registerQueueMetrics(gw *gateway.Server, m *queue.Manager) {
+	gw.Counter("dhaavak_queue_rejected_total", "Messages refused because their lane was full.",
+		func() int64 { return m.Stats().Rejected })
+	gw.Counter("dhaavak_queue_block_timeouts_total", "Messages refused after waiting for room in a full lane.",
+		func() int64 { return m.Stats().BlockTimeouts })
+	gw.Counter("dhaavak_queue_dropped_total", "Queued messages dropped to make room for newer ones.",
+		func() int64 { return m.Stats().Dropped })
+	gw.Counter("dhaavak_queue_spilled_total", "Messages parked on disk because their lane was full.",
+		func() int64 { return m.Stats().Spilled })
+}
+
+// taskPriority returns the highest queue priority the message gets from
+// its own request, its sender or the binding it matched.
+func taskPriority(cfg config.QueuePriority, msg protocol.InboundMessage, b routing.Binding) queue.Priority {
+	names := []string{msg.Priority, b.Priority, cfg.Senders[msg.Channel+":"+msg.SenderID]}
+	if msg.UserID != "" {
+		names = append(names, cfg.Senders[msg.UserID])
+	}
+	p := queue.PriorityNormal
+	for _, name := range names {
+		if q, err := queue.ParsePriority(name); err == nil && q > p {
+			p = q
+		}
+	}
+	return p
+}
+
+// defaultDrainTimeout bounds a queue.drain request that sets no timeout.
+const defaultDrainTimeout = time.Minute
+
+// registerQueueMethods adds the queue control and introspection methods.
+// shutdown stops the server; queue.drain calls it when asked to.
+func registerQueueMethods(gw *gateway.Server, m *queue.Manager, shutdown func()) {
+	gw.Handle(protocol.MethodQueueLanes, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{
+			"draining": m.Draining(),
+			"lanes":    m.Lanes(),
+			"stats":    m.Stats(),
+		}, nil
+	})
+
+	gw.HandleAdmin(protocol.MethodQueuePurge, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			SessionID string `json:"session_id"`
+			Cancel    bool   `json:"cancel"` // also stop the runs in progress
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.SessionID == "" {
+			return nil, gateway.Errorf(400, "invalid queue.purge params")
+		}
+		canceled := params.Cancel && m.Cancel(params.SessionID)
+		return map[string]interface{}{"purged": m.Purge(params.SessionID), "canceled": canceled}, nil
+	})
+
+	gw.HandleAdmin(protocol.MethodQueueDrain, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			Timeout  string `json:"timeout"`  // Go duration, default 1m
+			Shutdown bool   `json:"shutdown"` // stop the server once drained
+		}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &params); err != nil {
+				return nil, gateway.Errorf(400, "invalid queue.drain params")
+			}
+		}
+		timeout := defaultDrainTimeout
+		if params.Timeout != "" {
+			d, err := time.ParseDuration(params.Timeout)
+			if err != nil || d <= 0 {
+				return nil, gateway.Errorf(400, "invalid queue.drain timeout")
+			}
+			timeout = d
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		if err := m.Drain(ctx); err != nil {
+			busy := 0
+			for _, l := range m.Lanes() {
+				if l.Current != nil || l.Depth > 0 {
+					busy++
+				}
+			}
+			return map[string]interface{}{"drained": false, "busy_lanes": busy}, nil
+		}
+		if params.Shutdown {
+			go shutdown()
+		}
+		return map[string]interface{}{"drained": true, "shutdown": params.Shutdown}, nil
+	})
+
+	gw.HandleAdmin(protocol.MethodQueueResume, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		m.Resume()
+		return map[string]bool{"draining": false}, nil
+	})
+
+	gw.Handle(protocol.MethodChatCancel, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			SessionID string `json:"session_id"`
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.SessionID == "" {
+			return nil, gateway.Errorf(400, "invalid chat.cancel params")
+		}
+		return map[string]bool{"canceled": m.Cancel(params.SessionID)}, nil
+	})
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/harshadpatil/dhaavak/internal/config"
+	"github.com/harshadpatil/dhaavak/internal/queue"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+const queueUsage = `usage: dhaavak queue <command> [flags]
+
+commands:
+  lanes    list active lanes with their depth and current run
+  purge    drop the queued messages of a session
+  drain    stop taking messages and wait for queued work to finish
+  resume   take messages again after drain
+
+These commands talk to the running server's gateway, found through the
+config file's server and auth settings or --url.
+`
+
+// gatewayCallTimeout bounds a CLI call to the gateway, on top of any time
+// the method itself is asked to wait.
+const gatewayCallTimeout = 30 * time.Second
+
+// runQueue implements the "dhaavak queue ..." subcommands.
+func runQueue(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, queueUsage)
+		return fmt.Errorf("missing queue command")
+	}
+	fs := flag.NewFlagSet("queue "+args[0], flag.ExitOnError)
+	configPath := fs.String("config", "dhaavak.yaml", "path to config file")
+	gwURL := fs.String("url", "", "gateway WebSocket URL (default from config)")
+
+	switch args[0] {
+	case "lanes":
+		fs.Parse(args[1:])
+		var res struct {
+			Draining bool             `json:"draining"`
+			Lanes    []queue.LaneInfo `json:"lanes"`
+			Stats    queue.Stats      `json:"stats"`
+		}
+		if err := callGateway(gatewayCallTimeout, *configPath, *gwURL, protocol.MethodQueueLanes, nil, &res); err != nil {
+			return err
+		}
+		printLanes(res.Lanes, time.Now())
+		if res.Draining {
+			fmt.Println("queue is draining; new messages are refused")
+		}
+		return nil
+	case "purge":
+		sessionID := fs.String("session", "", "session key to purge (required)")
+		cancel := fs.Bool("cancel", false, "also stop the session's run in progress")
+		fs.Parse(args[1:])
+		if *sessionID == "" {
+			return fmt.Errorf("queue purge: --session is required")
+		}
+		var res struct {
+			Purged   int  `json:"purged"`
+			Canceled bool `json:"canceled"`
+		}
+		params := map[string]interface{}{"session_id": *sessionID, "cancel": *cancel}
+		if err := callGateway(gatewayCallTimeout, *configPath, *gwURL, protocol.MethodQueuePurge, params, &res); err != nil {
+			return err
+		}
+		fmt.Printf("purged %d queued messages", res.Purged)
+		if res.Canceled {
+			fmt.Print(", stopped the run in progress")
+		}
+		fmt.Println()
+		return nil
+	case "drain":
+		timeout := fs.Duration("timeout", defaultDrainTimeout, "how long to wait for queued work")
+		shutdown := fs.Bool("shutdown", false, "stop the server once drained")
+		fs.Parse(args[1:])
+		var res struct {
+			Drained   bool `json:"drained"`
+			BusyLanes int  `json:"busy_lanes"`
+		}
+		params := map[string]interface{}{"timeout": timeout.String(), "shutdown": *shutdown}
+		if err := callGateway(*timeout+gatewayCallTimeout, *configPath, *gwURL, protocol.MethodQueueDrain, params, &res); err != nil {
+			return err
+		}
+		if !res.Drained {
+			return fmt.Errorf("queue drain: %d lanes still busy after %s; new messages stay refused until \"dhaavak queue resume\"", res.BusyLanes, timeout)
+		}
+		if *shutdown {
+			fmt.Println("queue drained, server shutting down")
+		} else {
+			fmt.Println("queue drained; new messages are refused until \"dhaavak queue resume\"")
+		}
+		return nil
+	case "resume":
+		fs.Parse(args[1:])
+		if err := callGateway(gatewayCallTimeout, *configPath, *gwURL, protocol.MethodQueueResume, nil, nil); err != nil {
+			return err
+		}
+		fmt.Println("queue resumed")
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, queueUsage)
+		return fmt.Errorf("unknown queue command: %s", args[0])
+	}
+}
+
+func printLanes(lanes []queue.LaneInfo, now time.Time) {
+	if len(lanes) == 0 {
+		fmt.Println("no active lanes")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SESSION\tDEPTH\tAGENT\tRUNNING\tIDLE")
+	for _, l := range lanes {
+		agent, running, idle := "-", "-", "-"
+		if c := l.Current; c != nil {
+			agent = c.AgentID
+			running = now.Sub(c.StartedAt).Round(time.Second).String()
+			if c.Waiting {
+				running += " (waiting for slot)"
+			}
+		} else {
+			idle = now.Sub(l.LastActive).Round(time.Second).String()
+		}
+		depth := strconv.Itoa(l.Depth)
+		if l.HighPriority > 0 {
+			depth += fmt.Sprintf(" (%d high)", l.HighPriority)
+		}
+		if l.Spilled > 0 {
+			depth += fmt.Sprintf(" (%d on disk)", l.Spilled)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", l.SessionID, depth, agent, running, idle)
+	}
+	w.Flush()
+}
+
+// callGateway invokes a gateway method on the running server and decodes
+// its result into out, if out is not nil.
+func callGateway(timeout time.Duration, configPath, gwURL, method string, params, out interface{}) error {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	if gwURL == "" {
+		host := cfg.Server.Host
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			host = "127.0.0.1"
+		}
+		gwURL = "ws://" + net.JoinHostPort(host, strconv.Itoa(cfg.Server.Port)) + "/ws"
+	}
+	u, err := url.Parse(gwURL)
+	if err != nil {
+		return fmt.Errorf("gateway url: %w", err)
+	}
+	// The CLI calls admin methods, so it connects as the first admin.
+	token := cfg.Auth.Token
+	if len(cfg.Auth.Admins) > 0 {
+		token = cfg.Auth.Admins[0].Token
+	}
+	if token != "" {
+		q := u.Query()
+		q.Set("token", token)
+		u.RawQuery = q.Encode()
+	}
+
+	req := protocol.RequestFrame{ID: "cli", Method: method}
+	if params != nil {
+		if req.Params, err = json.Marshal(params); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("connect to gateway %s: %w", gwURL, err)
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(-1)
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if err := conn.Write(ctx, websocket.MessageText, data); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+		var resp protocol.ResponseFrame
+		if err := json.Unmarshal(data, &resp); err != nil || resp.ID != req.ID {
+			continue // an event
+		}
+		conn.Close(websocket.StatusNormalClosure, "")
+		if resp.Error != nil {
+			return fmt.Errorf("%s: %s (%d)", method, resp.Error.Message, resp.Error.Code)
+		}
+		if out == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, out)
+	}
+}
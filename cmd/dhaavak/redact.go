@@ -0,0 +1,119 @@
+package main
+
+import (
+	"github.com/harshadpatil/dhaavak/internal/config"
+	"github.com/harshadpatil/dhaavak/internal/redact"
+	"github.com/harshadpatil/dhaavak/internal/session"
+)
+
+// newRedactor builds the redactor configured under redaction:.
+func newRedactor(cfg config.RedactConfig) (*redact.Redactor, error) {
+	names := cfg.Detectors
+	if len(names) == 0 {
+		names = redact.BuiltinNames()
+	}
+	var rules []redact.Rule
+	for _, c := range cfg.Custom {
+		rule, err := redact.Custom(c.Name, c.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	for _, name := range names {
+		if rule, ok := redact.Builtin(name); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return redact.New(redact.Mode(cfg.Mode), rules...), nil
+}
+
+// redactPipeline applies redaction to the text flowing through one agent
+// run. A nil pipeline passes everything through unchanged.
+//
+// History always holds redacted text. With llm set, the LLM sees the same
+// redacted text; otherwise it sees the original (tokens in history are
+// restored first). In tokenize mode, tokens in replies are restored before
+// they reach the user.
+type redactPipeline struct {
+	r      *redact.Redactor
+	llm    bool
+	stream *redact.StreamRestorer
+}
+
+func newRedactPipeline(r *redact.Redactor, llm bool) *redactPipeline {
+	return &redactPipeline{r: r, llm: llm, stream: redact.NewStreamRestorer()}
+}
+
+func (p *redactPipeline) tokenize() bool {
+	return p != nil && p.r.Mode() == redact.ModeTokenize
+}
+
+// inbound redacts a user message and returns the text to store in history
+// and the text to send to the LLM.
+func (p *redactPipeline) inbound(e *session.Entry, text string) (stored, prompt string) {
+	if p == nil {
+		return text, text
+	}
+	if p.tokenize() {
+		e.WithTokens(func(t map[string]string) { stored = p.r.Redact(text, t) })
+	} else {
+		stored = p.r.Mask(text)
+	}
+	if p.llm {
+		return stored, stored
+	}
+	return stored, text
+}
+
+// outbound handles an assistant reply and returns the text to store in
+// history and the text to send to the user.
+func (p *redactPipeline) outbound(e *session.Entry, text string) (stored, reply string) {
+	if p == nil {
+		return text, text
+	}
+	if !p.tokenize() {
+		return p.r.Mask(text), text
+	}
+	e.WithTokens(func(t map[string]string) {
+		reply = redact.Restore(text, t)
+		stored = p.r.Redact(reply, t)
+	})
+	return stored, reply
+}
+
+// restore replaces tokens in text with the originals from e.
+func (p *redactPipeline) restore(e *session.Entry, text string) string {
+	e.WithTokens(func(t map[string]string) { text = redact.Restore(text, t) })
+	return text
+}
+
+// delta restores tokens in one streamed chunk of a reply.
+func (p *redactPipeline) delta(e *session.Entry, sessionID, chunk string) (out string) {
+	e.WithTokens(func(t map[string]string) { out = p.stream.Next(sessionID, chunk, t) })
+	return out
+}
+
+// flush returns streamed text still held back for sessionID.
+func (p *redactPipeline) flush(e *session.Entry, sessionID string) (out string) {
+	e.WithTokens(func(t map[string]string) { out = p.stream.Flush(sessionID, t) })
+	return out
+}
+
+// archived returns text as it may be kept outside a session, such as in a
+// dead letter: redacted like history, but without tokens to restore.
+func (p *redactPipeline) archived(text string) string {
+	if p == nil {
+		return text
+	}
+	return p.r.Mask(text)
+}
+
+// masked returns text as it may be sent to an LLM outside an agent run,
+// such as to the intent classifier.
+func (p *redactPipeline) masked(text string) string {
+	if p == nil || !p.llm {
+		return text
+	}
+	return p.r.Mask(text)
+}
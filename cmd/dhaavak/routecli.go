@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/harshadpatil/dhaavak/internal/config"
+	"github.com/harshadpatil/dhaavak/internal/routing"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+const routeUsage = `usage: dhaavak route <command> [flags]
+
+commands:
+  explain   show how a message would be routed and why
+  bindings  list the routing bindings, or with --audit the recent changes
+  bind      add a binding at runtime
+  unbind    remove a binding added at runtime
+
+These commands talk to the running server's gateway, found through the
+config file's server and auth settings or --url. explain --offline
+evaluates the config file's bindings instead.
+`
+
+// runRoute implements the "dhaavak route ..." subcommands.
+func runRoute(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, routeUsage)
+		return fmt.Errorf("missing route command")
+	}
+	switch args[0] {
+	case "explain":
+		return runRouteExplain(args[1:])
+	case "bindings", "bind", "unbind":
+		return runRouteBindings(args[0], args[1:])
+	}
+	fmt.Fprint(os.Stderr, routeUsage)
+	return fmt.Errorf("unknown route command: %s", args[0])
+}
+
+// runRouteExplain implements "dhaavak route explain".
+func runRouteExplain(args []string) error {
+	fs := flag.NewFlagSet("route explain", flag.ExitOnError)
+	configPath := fs.String("config", "dhaavak.yaml", "path to config file")
+	gwURL := fs.String("url", "", "gateway WebSocket URL (default from config)")
+	offline := fs.Bool("offline", false, "evaluate the config file's bindings without the server")
+	asJSON := fs.Bool("json", false, "print the raw explanation")
+	var p routing.ResolveParams
+	fs.StringVar(&p.Channel, "channel", "", "channel, e.g. telegram (required)")
+	fs.StringVar(&p.PeerKind, "peer-kind", "", "user, group or channel")
+	fs.StringVar(&p.PeerID, "peer-id", "", "peer ID")
+	fs.StringVar(&p.GuildID, "guild", "", "guild or group ID")
+	fs.StringVar(&p.TeamID, "team", "", "team or workspace ID")
+	fs.StringVar(&p.Text, "text", "", "message text, for content bindings")
+	attachment := fs.String("attachment", "", "attachment type, e.g. photo")
+	sessionID := fs.String("session", "", "session key, to include the agent it is switched to")
+	classify := fs.Bool("classify", false, "ask the intent classifier (not stored)")
+	at := fs.String("at", "", "time the message is sent, RFC 3339 (default now)")
+	fs.Parse(args)
+	if p.Channel == "" {
+		return fmt.Errorf("route explain: --channel is required")
+	}
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return fmt.Errorf("route explain: --at: %w", err)
+		}
+		p.Time = t
+	}
+	if *attachment != "" {
+		p.Attachments = []string{*attachment}
+	}
+
+	var ex routeExplanation
+	if *offline {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			return err
+		}
+		store := routing.NewBindingStore(routeBindings(cfg), cfg.Agents[0].ID)
+		ex.Explanation = routing.NewResolver(store).Explain(p)
+		ex.AgentID = ex.Route.AgentID
+		step, ok := ex.Chosen()
+		ex.Classifier = cfg.Routing.Classifier.Enabled && !(ok && (step.Content || step.Binding.Reply != "")) && p.Text != ""
+	} else {
+		params := struct {
+			routing.ResolveParams
+			SessionID string `json:"session_id,omitempty"`
+			Classify  bool   `json:"classify,omitempty"`
+		}{p, *sessionID, *classify}
+		if err := callGateway(gatewayCallTimeout, *configPath, *gwURL, protocol.MethodRoutingExplain, params, &ex); err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ex)
+	}
+	printExplanation(ex)
+	return nil
+}
+
+func printExplanation(ex routeExplanation) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tRESULT\tLEVEL\tAGENT\tRULE")
+	for _, s := range ex.Steps {
+		level := s.Level
+		if level == "" {
+			level = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", s.Index, s.Result, level, s.Binding.AgentID, describeBinding(s.Binding))
+	}
+	w.Flush()
+	if len(ex.Steps) == 0 {
+		fmt.Println("no bindings configured")
+	}
+
+	if ex.Route.Reply != "" {
+		fmt.Printf("\nbindings: reply %q (priority %d, %s)\n", ex.Route.Reply, ex.Route.Priority, ex.Route.Source)
+		return
+	}
+	fmt.Printf("\nbindings: %s (priority %d, %s)\n", ex.Route.AgentID, ex.Route.Priority, ex.Route.Source)
+	if ex.Language != "" {
+		fmt.Printf("detected language: %s\n", ex.Language)
+	}
+	switch {
+	case ex.SessionAgent != "":
+		fmt.Printf("session: switched to %s\n", ex.SessionAgent)
+	case ex.Intent != nil:
+		fmt.Printf("classifier: %s (confidence %.2f)\n", ex.Intent.AgentID, ex.Intent.Confidence)
+	case ex.Classifier:
+		fmt.Println("classifier: would be asked (pass --classify to ask it)")
+	}
+	fmt.Printf("agent: %s\n", ex.AgentID)
+}
+
+// runRouteBindings implements "dhaavak route bindings", "bind" and "unbind".
+func runRouteBindings(cmd string, args []string) error {
+	fs := flag.NewFlagSet("route "+cmd, flag.ExitOnError)
+	configPath := fs.String("config", "dhaavak.yaml", "path to config file")
+	gwURL := fs.String("url", "", "gateway WebSocket URL (default from config)")
+
+	switch cmd {
+	case "bindings":
+		audit := fs.Int("audit", 0, "show the last N binding changes instead")
+		fs.Parse(args)
+		if *audit > 0 {
+			var res struct {
+				Entries []routing.AuditEntry `json:"entries"`
+			}
+			params := map[string]int{"limit": *audit}
+			if err := callGateway(gatewayCallTimeout, *configPath, *gwURL, protocol.MethodBindingAudit, params, &res); err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TIME\tACTOR\tACTION\tID\tAGENT\tRULE")
+			for _, e := range res.Entries {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format(time.DateTime), e.Actor, e.Action,
+					e.Binding.ID, e.Binding.AgentID, describeBinding(e.Binding))
+			}
+			return w.Flush()
+		}
+		var res struct {
+			Bindings     []routing.Binding `json:"bindings"`
+			DefaultAgent string            `json:"default_agent"`
+		}
+		if err := callGateway(gatewayCallTimeout, *configPath, *gwURL, protocol.MethodBindingList, nil, &res); err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tAGENT\tRULE")
+		for _, b := range res.Bindings {
+			id := b.ID
+			if id == "" {
+				id = "config"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", id, b.AgentID, describeBinding(b))
+		}
+		fmt.Fprintf(w, "default\t%s\t\n", res.DefaultAgent)
+		return w.Flush()
+
+	case "bind":
+		var b routing.Binding
+		fs.StringVar(&b.AgentID, "agent", "", "agent ID; with --variants, the agent the session key uses")
+		fs.StringVar(&b.Channel, "channel", "", "channel, e.g. telegram")
+		fs.StringVar(&b.PeerKind, "peer-kind", "", "user, group or channel")
+		fs.StringVar(&b.PeerID, "peer-id", "", "peer ID")
+		fs.StringVar(&b.GuildID, "guild", "", "guild or group ID")
+		fs.StringVar(&b.TeamID, "team", "", "team or workspace ID")
+		fs.StringVar(&b.Priority, "priority", "", "queue priority: normal or high")
+		fs.StringVar(&b.Command, "command", "", "leading command, e.g. /ops")
+		fs.StringVar(&b.Pattern, "pattern", "", "regular expression the text must match")
+		keywords := fs.String("keywords", "", "comma-separated keywords")
+		fs.StringVar(&b.Language, "language", "", "ISO 639-1 language code")
+		fs.StringVar(&b.Attachment, "attachment", "", "attachment type, e.g. photo")
+		variants := fs.String("variants", "", "weighted agents, e.g. v1:90,v2:10")
+		fs.StringVar(&b.Experiment, "experiment", "", "experiment name for the variants")
+		fs.StringVar(&b.Sticky, "sticky", "", "keep a variant per session (default) or user")
+		days := fs.String("days", "", "days, e.g. mon-fri or sat,sun")
+		fs.StringVar(&b.Hours, "hours", "", "time of day, e.g. 09:00-17:30")
+		fs.StringVar(&b.Timezone, "timezone", "", "IANA timezone for --days and --hours (default session.timezone)")
+		fs.StringVar(&b.From, "from", "", "start of a one-off window, RFC 3339")
+		fs.StringVar(&b.Until, "until", "", "end of a one-off window, RFC 3339")
+		fs.StringVar(&b.Reply, "reply", "", "static reply sent instead of running an agent")
+		fs.Parse(args)
+		if *days != "" {
+			b.Days = strings.Split(*days, ",")
+		}
+		if *keywords != "" {
+			b.Keywords = strings.Split(*keywords, ",")
+		}
+		if *variants != "" {
+			vs, err := parseVariants(*variants)
+			if err != nil {
+				return err
+			}
+			b.Variants = vs
+		}
+		if b.AgentID == "" && len(b.Variants) == 0 && b.Reply == "" {
+			return fmt.Errorf("route bind: --agent, --variants or --reply is required")
+		}
+		var res routing.Binding
+		if err := callGateway(gatewayCallTimeout, *configPath, *gwURL, protocol.MethodBindingAdd, b, &res); err != nil {
+			return err
+		}
+		fmt.Printf("added %s: %s -> %s\n", res.ID, describeBinding(res), res.AgentID)
+		return nil
+
+	default:
+		id := fs.String("id", "", "runtime binding ID, e.g. b-3 (required)")
+		fs.Parse(args)
+		if *id == "" {
+			return fmt.Errorf("route unbind: --id is required")
+		}
+		var res routing.Binding
+		if err := callGateway(gatewayCallTimeout, *configPath, *gwURL, protocol.MethodBindingRemove, map[string]string{"id": *id}, &res); err != nil {
+			return err
+		}
+		fmt.Printf("removed %s: %s -> %s\n", res.ID, describeBinding(res), res.AgentID)
+		return nil
+	}
+}
+
+// describeBinding summarizes the fields a binding matches on.
+func describeBinding(b routing.Binding) string {
+	var parts []string
+	add := func(name, value string) {
+		if value != "" {
+			parts = append(parts, name+"="+value)
+		}
+	}
+	add("channel", b.Channel)
+	add("peer_kind", b.PeerKind)
+	add("peer_id", b.PeerID)
+	add("guild_id", b.GuildID)
+	add("team_id", b.TeamID)
+	add("command", b.Command)
+	add("pattern", b.Pattern)
+	add("keywords", strings.Join(b.Keywords, ","))
+	add("language", b.Language)
+	add("attachment", b.Attachment)
+	var variants []string
+	for _, v := range b.Variants {
+		variants = append(variants, fmt.Sprintf("%s:%d", v.AgentID, v.Weight))
+	}
+	add("variants", strings.Join(variants, ","))
+	add("experiment", b.Experiment)
+	add("sticky", b.Sticky)
+	add("days", strings.Join(b.Days, ","))
+	add("hours", b.Hours)
+	add("timezone", b.Timezone)
+	add("from", b.From)
+	add("until", b.Until)
+	if b.Reply != "" {
+		parts = append(parts, fmt.Sprintf("reply=%q", b.Reply))
+	}
+	if len(parts) == 0 {
+		return "(any message)"
+	}
+	return strings.Join(parts, " ")
+}
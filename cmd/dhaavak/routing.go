@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/harshadpatil/dhaavak/internal/config"
+	"github.com/harshadpatil/dhaavak/internal/gateway"
+	"github.com/harshadpatil/dhaavak/internal/llm"
+	"github.com/harshadpatil/dhaavak/internal/routing"
+	"github.com/harshadpatil/dhaavak/internal/session"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// metaAgent is the session metadata key holding the agent a session is
+// switched to, by the intent classifier or with /agent.
+const metaAgent = "agent"
+
+// routeBindings collects the routing bindings from config: the channels'
+// default agents as channel wildcards, then the channels' own bindings,
+// then the top-level bindings. At the same level, later bindings win.
+// Schedules without a timezone use session.timezone.
+func routeBindings(cfg *config.Config) []routing.Binding {
+	var bindings []routing.Binding
+	var rules []config.BindingRule
+	for _, r := range cfg.Channels.Routes() {
+		if r.DefaultAgent != "" {
+			bindings = append(bindings, routing.Binding{Channel: r.Channel, AgentID: r.DefaultAgent})
+		}
+		rules = append(rules, r.Bindings...)
+	}
+	rules = append(rules, cfg.Bindings...)
+	for _, b := range rules {
+		if b.Timezone == "" && (len(b.Days) > 0 || b.Hours != "") {
+			b.Timezone = cfg.Session.Timezone
+		}
+		bindings = append(bindings, routing.Binding{
+			Channel:  b.Channel,
+			PeerKind: b.PeerKind,
+			PeerID:   b.PeerID,
+			GuildID:  b.GuildID,
+			TeamID:   b.TeamID,
+			AgentID:  b.AgentID,
+			Priority: b.Priority,
+
+			Command:    b.Command,
+			Pattern:    b.Pattern,
+			Keywords:   b.Keywords,
+			Language:   b.Language,
+			Attachment: b.Attachment,
+
+			Variants:   routeVariants(b.Variants),
+			Experiment: b.Experiment,
+			Sticky:     b.Sticky,
+
+			Days:     b.Days,
+			Hours:    b.Hours,
+			Timezone: b.Timezone,
+			From:     b.From,
+			Until:    b.Until,
+
+			Reply: b.Reply,
+		})
+	}
+	return bindings
+}
+
+func routeVariants(vs []config.BindingVariant) []routing.Variant {
+	var out []routing.Variant
+	for _, v := range vs {
+		out = append(out, routing.Variant{AgentID: v.AgentID, Weight: v.Weight})
+	}
+	return out
+}
+
+// sessionRouter picks a session's agent once the bindings have given a
+// fallback: the agent the session was switched to, else the intent
+// classifier's pick, which then sticks to the session.
+type sessionRouter struct {
+	sessions      *session.Manager
+	agents        []config.AgentConfig
+	classifier    *routing.Classifier // nil when disabled
+	minConfidence float64
+	timeout       time.Duration
+}
+
+func newSessionRouter(cfg *config.Config, sessions *session.Manager) *sessionRouter {
+	r := &sessionRouter{sessions: sessions, agents: cfg.Agents}
+	c := cfg.Routing.Classifier
+	if !c.Enabled {
+		return r
+	}
+	var candidates []routing.Candidate
+	for _, a := range cfg.ClassifierAgents() {
+		candidates = append(candidates, routing.Candidate{ID: a.ID, Name: a.Name, Description: a.Description})
+	}
+	r.classifier = routing.NewClassifier(llm.NewAnthropicProvider(cfg.LLM.APIKey, c.Model), candidates)
+	r.minConfidence, r.timeout = c.MinConfidence, c.Timeout
+	return r
+}
+
+// sessionAgent returns the agent the session e is switched to, or "".
+func (r *sessionRouter) sessionAgent(e *session.Entry) string {
+	if id := e.GetMeta(metaAgent); id != "" && r.known(id) {
+		return id
+	}
+	return ""
+}
+
+// classifies reports whether the classifier would be asked about text.
+func (r *sessionRouter) classifies(text string) bool {
+	return r.classifier != nil && strings.TrimSpace(text) != ""
+}
+
+// agentFor returns the agent for a message in the session e. text is what
+// the classifier may see. A failed or unsure classification returns
+// fallback and does not stick, so the next message is classified again.
+// It may call the classifier, so it runs inside a queued task, where the
+// run limiter applies, never on the inbound path.
+func (r *sessionRouter) agentFor(ctx context.Context, e *session.Entry, text, fallback string) string {
+	if id := r.sessionAgent(e); id != "" {
+		return id
+	}
+	if !r.classifies(text) {
+		return fallback
+	}
+	intent, err := r.classify(ctx, text)
+	if err != nil {
+		slog.Warn("intent classifier failed", "session", e.Key, "err", err)
+		return fallback
+	}
+	if intent.Confidence < r.minConfidence {
+		slog.Debug("intent classifier unsure", "session", e.Key, "agent", intent.AgentID, "confidence", intent.Confidence)
+		return fallback
+	}
+	slog.Info("session routed by intent", "session", e.Key, "agent", intent.AgentID, "confidence", intent.Confidence)
+	r.switchTo(e, intent.AgentID)
+	return intent.AgentID
+}
+
+func (r *sessionRouter) classify(ctx context.Context, text string) (routing.Intent, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.classifier.Classify(ctx, text)
+}
+
+// command handles "/agent", "/agent <id>" and "/agent auto" and returns
+// the reply.
+func (r *sessionRouter) command(e *session.Entry, text string) string {
+	args := strings.Fields(text)
+	if len(args) < 2 {
+		var b strings.Builder
+		if id := e.GetMeta(metaAgent); id != "" {
+			fmt.Fprintf(&b, "This conversation is with %s.\n", id)
+		} else {
+			b.WriteString("This conversation has no agent chosen yet.\n")
+		}
+		b.WriteString("Agents:\n")
+		for _, a := range r.agents {
+			fmt.Fprintf(&b, "- %s", a.ID)
+			if a.Description != "" {
+				fmt.Fprintf(&b, ": %s", a.Description)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("Use /agent <id> to switch, or /agent auto to let me choose.")
+		return b.String()
+	}
+	id := args[1]
+	switch {
+	case id == "auto":
+		r.switchTo(e, "")
+		return "I'll pick the agent for your next message."
+	case !r.known(id):
+		return fmt.Sprintf("There is no agent %q. Send /agent for the list.", id)
+	}
+	r.switchTo(e, id)
+	return fmt.Sprintf("Switched to %s.", id)
+}
+
+// switchTo sticks the session to an agent; "" unsticks it.
+func (r *sessionRouter) switchTo(e *session.Entry, agentID string) {
+	e.SetMeta(metaAgent, agentID)
+	r.sessions.Save(e)
+}
+
+func (r *sessionRouter) known(agentID string) bool {
+	for _, a := range r.agents {
+		if a.ID == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// routeExplanation is the result of routing.explain: the binding trace,
+// then what the session and the classifier make of it.
+type routeExplanation struct {
+	routing.Explanation
+	SessionAgent string          `json:"session_agent,omitempty"` // agent the session is switched to
+	Classifier   bool            `json:"classifier"`              // whether the classifier would be asked
+	Intent       *routing.Intent `json:"intent,omitempty"`        // its answer, when asked to classify
+	AgentID      string          `json:"agent_id"`                // the agent that would run
+}
+
+// explain traces how a message with params would be routed. A session ID
+// adds the session's agent; classify runs the classifier without storing
+// its pick.
+func (r *sessionRouter) explain(ctx context.Context, resolver *routing.Resolver, p routing.ResolveParams, sessionID string, classify bool) (routeExplanation, error) {
+	ex := routeExplanation{Explanation: resolver.Explain(p)}
+	ex.AgentID = ex.Route.AgentID
+	if step, ok := ex.Chosen(); ok && (step.Content || step.Binding.Reply != "") {
+		return ex, nil
+	}
+	if sessionID != "" {
+		if e, ok := r.sessions.Get(sessionID); ok {
+			if id := r.sessionAgent(e); id != "" {
+				ex.SessionAgent, ex.AgentID = id, id
+				return ex, nil
+			}
+		}
+	}
+	ex.Classifier = r.classifies(p.Text)
+	if ex.Classifier && classify {
+		intent, err := r.classify(ctx, p.Text)
+		if err != nil {
+			return ex, err
+		}
+		ex.Intent = &intent
+		if intent.Confidence >= r.minConfidence {
+			ex.AgentID = intent.AgentID
+		}
+	}
+	return ex, nil
+}
+
+// registerRoutingMethods adds the routing methods.
+func registerRoutingMethods(gw *gateway.Server, r *sessionRouter, resolver *routing.Resolver) {
+	gw.Handle(protocol.MethodRoutingExplain, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			routing.ResolveParams
+			SessionID string `json:"session_id"`
+			Classify  bool   `json:"classify"`
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.Channel == "" {
+			return nil, gateway.Errorf(400, "invalid routing.explain params")
+		}
+		ex, err := r.explain(ctx, resolver, params.ResolveParams, params.SessionID, params.Classify)
+		if err != nil {
+			return nil, gateway.Errorf(502, "%s", err.Error())
+		}
+		return ex, nil
+	})
+
+	gw.Handle(protocol.MethodSessionAgent, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			SessionID string `json:"session_id"`
+			AgentID   string `json:"agent_id"` // "" or "auto" lets the classifier choose again
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.SessionID == "" {
+			return nil, gateway.Errorf(400, "invalid session.agent params")
+		}
+		e, ok := r.sessions.Get(params.SessionID)
+		if !ok {
+			return nil, gateway.Errorf(404, "session not found: %s", params.SessionID)
+		}
+		if params.AgentID == "auto" {
+			params.AgentID = ""
+		}
+		if params.AgentID != "" && !r.known(params.AgentID) {
+			return nil, gateway.Errorf(400, "unknown agent: %s", params.AgentID)
+		}
+		r.switchTo(e, params.AgentID)
+		return map[string]string{"session_id": e.Key, "agent_id": params.AgentID}, nil
+	})
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/harshadpatil/dhaavak/internal/config"
+	"github.com/harshadpatil/dhaavak/internal/gateway"
+	"github.com/harshadpatil/dhaavak/internal/identity"
+	"github.com/harshadpatil/dhaavak/internal/session"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// sessionScopes builds the session scope table from config.
+func sessionScopes(cfg config.SessionConfig) (session.Scopes, error) {
+	def, err := session.ParseScope(cfg.Scope)
+	if err != nil {
+		return session.Scopes{}, fmt.Errorf("session.scope: %w", err)
+	}
+	scopes := session.Scopes{
+		Default:  def,
+		Channels: make(map[string]session.Scope, len(cfg.ChannelScopes)),
+		Agents:   make(map[string]session.Scope, len(cfg.AgentScopes)),
+	}
+	for ch, name := range cfg.ChannelScopes {
+		if scopes.Channels[ch], err = session.ParseScope(name); err != nil {
+			return session.Scopes{}, fmt.Errorf("session.channel_scopes.%s: %w", ch, err)
+		}
+	}
+	for id, name := range cfg.AgentScopes {
+		if scopes.Agents[id], err = session.ParseScope(name); err != nil {
+			return session.Scopes{}, fmt.Errorf("session.agent_scopes.%s: %w", id, err)
+		}
+	}
+	return scopes, nil
+}
+
+// sessionInfo is the response shape for session management methods.
+type sessionInfo struct {
+	SessionID string            `json:"session_id"`
+	AgentID   string            `json:"agent_id"`
+	Messages  int               `json:"messages"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+func toSessionInfo(e *session.Entry) sessionInfo {
+	rec := e.Snapshot()
+	return sessionInfo{
+		SessionID: rec.Key,
+		AgentID:   rec.AgentID,
+		Messages:  len(rec.History),
+		Metadata:  rec.Metadata,
+	}
+}
+
+// ownsSession reports whether key is a session of the client's own peer:
+// its WebSocket peer, or the identity it is linked to. Shared sessions
+// (e.g. agent:{id}:main) belong to no single client. identities may be nil.
+func ownsSession(gw *gateway.Server, identities *identity.Directory, clientID, key string) bool {
+	peer, ok := gw.ClientPeer(clientID)
+	if !ok {
+		return false
+	}
+	pk, err := session.ParseKey(key)
+	if err != nil || pk.PeerID == "" {
+		return false
+	}
+	switch pk.Channel {
+	case "websocket":
+		return pk.PeerID == peer
+	case "identity":
+		if identities == nil {
+			return false
+		}
+		userID, ok := identities.Resolve("websocket", peer)
+		return ok && pk.PeerID == userID
+	}
+	return false
+}
+
+// registerSessionMethods wires the session.* gateway methods to the session
+// manager. identities may be nil when identity linking is disabled.
+func registerSessionMethods(gw *gateway.Server, mgr *session.Manager, identities *identity.Directory) {
+	gw.Handle(protocol.MethodSessionReset, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			SessionID string `json:"session_id"`
+			AgentID   string `json:"agent_id"`
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.SessionID == "" {
+			return nil, gateway.Errorf(400, "invalid session.reset params")
+		}
+		e := mgr.Reset(params.SessionID, params.AgentID)
+		gw.BroadcastSession(params.SessionID, protocol.EventFrame{
+			Event:     protocol.EventSessionReset,
+			SessionID: params.SessionID,
+		})
+		return toSessionInfo(e), nil
+	})
+
+	gw.Handle(protocol.MethodSessionFork, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			SessionID string `json:"session_id"`
+			TargetID  string `json:"target_id"`
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.SessionID == "" {
+			return nil, gateway.Errorf(400, "invalid session.fork params")
+		}
+		e, err := mgr.Fork(params.SessionID, params.TargetID)
+		if err != nil {
+			return nil, sessionError(err)
+		}
+		return toSessionInfo(e), nil
+	})
+
+	gw.Handle(protocol.MethodSessionBranch, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			SessionID string `json:"session_id"`
+			TargetID  string `json:"target_id"`
+			Index     *int   `json:"index"`
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.SessionID == "" || params.Index == nil || *params.Index < 0 {
+			return nil, gateway.Errorf(400, "invalid session.branch params")
+		}
+		e, err := mgr.Branch(params.SessionID, params.TargetID, *params.Index)
+		if err != nil {
+			return nil, sessionError(err)
+		}
+		return toSessionInfo(e), nil
+	})
+
+	gw.HandleAdmin(protocol.MethodSessionSearch, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			Query   string    `json:"query"`
+			AgentID string    `json:"agent_id"`
+			Channel string    `json:"channel"`
+			PeerID  string    `json:"peer_id"`
+			Since   time.Time `json:"since"`
+			Until   time.Time `json:"until"`
+			Limit   int       `json:"limit"`
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.Query == "" {
+			return nil, gateway.Errorf(400, "invalid session.search params")
+		}
+		hits := mgr.Search(session.SearchQuery{
+			Text:    params.Query,
+			AgentID: params.AgentID,
+			Channel: params.Channel,
+			PeerID:  params.PeerID,
+			Since:   params.Since,
+			Until:   params.Until,
+			Limit:   params.Limit,
+		})
+		if hits == nil {
+			hits = []session.SearchHit{}
+		}
+		return map[string]interface{}{"hits": hits}, nil
+	})
+
+	gw.Handle(protocol.MethodSessionExport, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			SessionID string `json:"session_id"`
+			Format    string `json:"format"` // "jsonl" (default) or "markdown"
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.SessionID == "" {
+			return nil, gateway.Errorf(400, "invalid session.export params")
+		}
+		if _, admin := gw.ClientAdmin(clientID); !admin && !ownsSession(gw, identities, clientID, params.SessionID) {
+			return nil, gateway.Errorf(403, "session.export of another peer's session requires an admin token")
+		}
+		if params.Format == "" {
+			params.Format = session.FormatJSONL
+		}
+		rec, err := mgr.Export(params.SessionID)
+		if err != nil {
+			return nil, sessionError(err)
+		}
+		var b strings.Builder
+		if err := session.Export(&b, rec, params.Format); err != nil {
+			return nil, gateway.Errorf(400, "%s", err.Error())
+		}
+		return map[string]string{"format": params.Format, "content": b.String()}, nil
+	})
+
+	gw.Handle(protocol.MethodSessionImport, func(ctx context.Context, clientID string, raw json.RawMessage) (interface{}, error) {
+		var params struct {
+			Content   string `json:"content"`    // JSONL export
+			SessionID string `json:"session_id"` // optional new key
+			Replace   bool   `json:"replace"`
+		}
+		if err := json.Unmarshal(raw, &params); err != nil || params.Content == "" {
+			return nil, gateway.Errorf(400, "invalid session.import params")
+		}
+		rec, err := session.ImportJSONL(strings.NewReader(params.Content))
+		if err != nil {
+			return nil, gateway.Errorf(400, "%s", err.Error())
+		}
+		e, err := mgr.Import(rec, params.SessionID, params.Replace)
+		if err != nil {
+			return nil, sessionError(err)
+		}
+		return toSessionInfo(e), nil
+	})
+}
+
+// sessionError maps session manager errors to gateway error codes.
+func sessionError(err error) error {
+	switch {
+	case errors.Is(err, session.ErrNotFound):
+		return gateway.Errorf(404, "%s", err.Error())
+	case errors.Is(err, session.ErrExists):
+		return gateway.Errorf(409, "%s", err.Error())
+	default:
+		return gateway.Errorf(400, "%s", err.Error())
+	}
+}
@@ -57,6 +57,8 @@ func RunLoop(
 				return nil, messages, fmt.Errorf("stream error: %w", evt.Err)
 			case "complete":
 				result.StopReason = evt.StopReason
+				result.InputTokens += evt.InputTokens
+				result.OutputTokens += evt.OutputTokens
 			}
 		}
 
@@ -75,7 +77,8 @@ func RunLoop(
 			Content: assistantBlocks,
 		})
 
-		// If no tool calls, we're done.
+		// If no tool calls, we're done. Input steered in after the final
+		// answer is left for the caller to run as a followup.
 		if len(toolCalls) == 0 {
 			result.Text = textBuf.String()
 			return result, messages, nil
@@ -96,6 +99,11 @@ func RunLoop(
 			})
 		}
 
+		// Steered input rides along with the tool results.
+		if text := steered(ctx); text != "" {
+			toolResults = append(toolResults, llm.ContentBlock{Type: "text", Text: text})
+		}
+
 		messages = append(messages, llm.Message{
 			Role:    llm.RoleUser,
 			Content: toolResults,
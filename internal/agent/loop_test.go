@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harshadpatil/dhaavak/internal/llm"
+	"github.com/harshadpatil/dhaavak/internal/queue"
+)
+
+// scriptedProvider answers each Stream call with the next reply. before, if
+// set, runs at the start of every call.
+type scriptedProvider struct {
+	mu      sync.Mutex
+	replies []string
+	calls   int
+	before  func(call int)
+}
+
+func (p *scriptedProvider) Stream(_ context.Context, _ string, _ []llm.Message, _ []llm.ToolDef) (<-chan llm.StreamEvent, error) {
+	p.mu.Lock()
+	call := p.calls
+	p.calls++
+	p.mu.Unlock()
+	if p.before != nil {
+		p.before(call)
+	}
+
+	ch := make(chan llm.StreamEvent, 2)
+	ch <- llm.StreamEvent{Type: "delta", Text: p.replies[call]}
+	ch <- llm.StreamEvent{Type: "complete", StopReason: "end_turn"}
+	close(ch)
+	return ch, nil
+}
+
+func (p *scriptedProvider) Complete(context.Context, string, []llm.Message, []llm.ToolDef) (*llm.CompletionResult, error) {
+	return nil, nil
+}
+
+func TestSteerAfterFinalAnswerRunsAsFollowup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := queue.NewManager(ctx, 64, time.Minute)
+	provider := &scriptedProvider{replies: []string{"reply one", "reply two"}}
+
+	var mu sync.Mutex
+	var history []llm.Message
+	var wg sync.WaitGroup
+
+	// run mirrors how the gateway stores a run: the user message, any text
+	// steered in, then the assistant reply.
+	var run func(ctx context.Context, text string) error
+	run = func(ctx context.Context, text string) error {
+		turn := []llm.Message{userText(text)}
+		runCtx := WithSteering(ctx, func() string {
+			steered := queue.Steered(ctx)
+			if steered != "" {
+				turn = append(turn, userText(steered))
+			}
+			return steered
+		})
+		result, _, err := RunLoop(runCtx, provider, "", []llm.Message{userText(text)}, nil, nil, nil, "s", 1, 2)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		history = append(history, turn...)
+		history = append(history, llm.Message{
+			Role:    llm.RoleAssistant,
+			Content: []llm.ContentBlock{{Type: "text", Text: result.Text}},
+		})
+		mu.Unlock()
+		return nil
+	}
+
+	// The steered message arrives while the first answer is streaming.
+	provider.before = func(call int) {
+		if call == 0 {
+			wg.Add(1)
+			mgr.Enqueue(queue.Task{SessionID: "s", Mode: queue.ModeSteer, Text: "and another", Fn: run, OnDone: func(error) { wg.Done() }})
+		}
+	}
+	wg.Add(1)
+	mgr.Enqueue(queue.Task{SessionID: "s", Mode: queue.ModeSteer, Text: "hello", Fn: run, OnDone: func(error) { wg.Done() }})
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []struct{ role, text string }{
+		{llm.RoleUser, "hello"},
+		{llm.RoleAssistant, "reply one"},
+		{llm.RoleUser, "and another"},
+		{llm.RoleAssistant, "reply two"},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("history has %d messages, want %d: %+v", len(history), len(want), history)
+	}
+	for i, w := range want {
+		if history[i].Role != w.role || history[i].Content[0].Text != w.text {
+			t.Errorf("history[%d] = %s %q, want %s %q", i, history[i].Role, history[i].Content[0].Text, w.role, w.text)
+		}
+	}
+}
+
+func userText(text string) llm.Message {
+	return llm.Message{Role: llm.RoleUser, Content: []llm.ContentBlock{{Type: "text", Text: text}}}
+}
@@ -16,6 +16,7 @@ type Runtime struct {
 	maxTurns  int
 	toolExec  ToolExecutor
 	eventSink EventSink
+	filter    PromptFilter
 }
 
 // NewRuntime creates an agent runtime.
@@ -42,6 +43,12 @@ func (rt *Runtime) SetEventSink(sink EventSink) {
 	rt.eventSink = sink
 }
 
+// SetPromptFilter configures a filter applied to history and the user
+// message before they are sent to the LLM.
+func (rt *Runtime) SetPromptFilter(fn PromptFilter) {
+	rt.filter = fn
+}
+
 // Run executes an agent for a given session and user message.
 func (rt *Runtime) Run(ctx context.Context, agentID string, entry *session.Entry, userText string, runSeq int) (*RunResult, error) {
 	def, ok := rt.agents[agentID]
@@ -52,6 +59,13 @@ func (rt *Runtime) Run(ctx context.Context, agentID string, entry *session.Entry
 	slog.Info("agent run start", "agent", agentID, "session", entry.Key, "run_seq", runSeq)
 
 	messages := BuildMessages(entry, userText)
+	if rt.filter != nil {
+		for i := range messages {
+			for j := range messages[i].Content {
+				messages[i].Content[j].Text = rt.filter(entry, messages[i].Content[j].Text)
+			}
+		}
+	}
 
 	toolExec := rt.toolExec
 	if toolExec == nil {
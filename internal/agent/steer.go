@@ -0,0 +1,22 @@
+package agent
+
+import "context"
+
+type steerKey struct{}
+
+// WithSteering returns a context that lets RunLoop pick up user input that
+// arrives while it runs. fn is called after each round of tool results and
+// returns the text received since the previous call, or "". Text that is
+// never picked up stays with the caller.
+func WithSteering(ctx context.Context, fn func() string) context.Context {
+	return context.WithValue(ctx, steerKey{}, fn)
+}
+
+// steered returns new steering text for the run executing with ctx.
+func steered(ctx context.Context) string {
+	fn, ok := ctx.Value(steerKey{}).(func() string)
+	if !ok {
+		return ""
+	}
+	return fn()
+}
@@ -1,6 +1,9 @@
 package agent
 
-import "github.com/harshadpatil/dhaavak/internal/llm"
+import (
+	"github.com/harshadpatil/dhaavak/internal/llm"
+	"github.com/harshadpatil/dhaavak/internal/session"
+)
 
 // Event represents an agent runtime event broadcast to observers.
 type Event struct {
@@ -16,9 +19,11 @@ type Event struct {
 
 // RunResult is the final outcome of an agent run.
 type RunResult struct {
-	Text       string
-	ToolCalls  int
-	StopReason string
+	Text         string
+	ToolCalls    int
+	StopReason   string
+	InputTokens  int64
+	OutputTokens int64
 }
 
 // ToolExecutor runs a tool and returns its output.
@@ -27,6 +32,9 @@ type ToolExecutor func(name, input string) (string, error)
 // EventSink receives agent events for broadcasting.
 type EventSink func(Event)
 
+// PromptFilter rewrites the text of each message before it is sent to the LLM.
+type PromptFilter func(entry *session.Entry, text string) string
+
 // AgentDef holds the static definition of an agent.
 type AgentDef struct {
 	ID           string
@@ -24,3 +24,8 @@ type Adapter interface {
 // MessageSink is called by adapters when they receive a message.
 // It decouples the adapter from gateway internals.
 type MessageSink func(ctx context.Context, msg protocol.InboundMessage) error
+
+// MessageAcceptor records a message durably before an adapter acknowledges
+// it to the platform, so a crash between the two does not lose it. It
+// reports false for a message that was already accepted.
+type MessageAcceptor func(ctx context.Context, msg protocol.InboundMessage) (bool, error)
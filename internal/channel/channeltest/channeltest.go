@@ -0,0 +1,65 @@
+// Package channeltest provides helpers for testing channel adapters
+// against fake servers.
+package channeltest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/harshadpatil/dhaavak/internal/channel"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// receiveTimeout is how long Receive waits for a message.
+const receiveTimeout = 5 * time.Second
+
+// Sink collects the messages an adapter passes to its message sink.
+type Sink struct {
+	ch chan protocol.InboundMessage
+}
+
+// NewSink returns an empty sink.
+func NewSink() *Sink {
+	return &Sink{ch: make(chan protocol.InboundMessage, 10)}
+}
+
+// Func returns the message sink to give the adapter.
+func (s *Sink) Func() channel.MessageSink {
+	return func(_ context.Context, msg protocol.InboundMessage) error {
+		s.ch <- msg
+		return nil
+	}
+}
+
+// Receive returns the next message, failing t if none arrives in time.
+func (s *Sink) Receive(t testing.TB) protocol.InboundMessage {
+	t.Helper()
+	select {
+	case msg := <-s.ch:
+		return msg
+	case <-time.After(receiveTimeout):
+		t.Fatal("no message received")
+		return protocol.InboundMessage{}
+	}
+}
+
+// None fails t if a message arrives within wait.
+func (s *Sink) None(t testing.TB, wait time.Duration) {
+	t.Helper()
+	select {
+	case msg := <-s.ch:
+		t.Errorf("unexpected message %+v", msg)
+	case <-time.After(wait):
+	}
+}
+
+// Start starts a, failing t if it cannot, and stops it when the test ends.
+func Start(t testing.TB, a channel.Adapter) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := a.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+}
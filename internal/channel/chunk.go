@@ -0,0 +1,44 @@
+package channel
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// fence opens and closes a markdown code block.
+const fence = "```"
+
+// ChunkText splits text into chunks of at most maxSize characters,
+// trying to break at newlines, then spaces. A code block split across
+// chunks is closed at the end of one and reopened in the next.
+func ChunkText(text string, maxSize int) []string {
+	if utf8.RuneCountInString(text) <= maxSize {
+		return []string{text}
+	}
+
+	var chunks []string
+	for text != "" {
+		runes := []rune(text)
+		if len(runes) <= maxSize {
+			chunks = append(chunks, text)
+			break
+		}
+
+		// Leave room to close a code block.
+		cut := string(runes[:maxSize-len("\n"+fence)])
+		if idx := strings.LastIndex(cut, "\n"); idx > len(cut)/2 {
+			cut = cut[:idx+1]
+		} else if idx := strings.LastIndex(cut, " "); idx > len(cut)/2 {
+			cut = cut[:idx+1]
+		}
+		text = text[len(cut):]
+
+		if strings.Count(cut, fence)%2 == 1 {
+			cut = strings.TrimRight(cut, "\n") + "\n" + fence
+			text = fence + "\n" + text
+		}
+		chunks = append(chunks, cut)
+	}
+
+	return chunks
+}
@@ -0,0 +1,37 @@
+package channel
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkText(t *testing.T) {
+	if got := ChunkText("short", 100); len(got) != 1 || got[0] != "short" {
+		t.Errorf("short text = %q", got)
+	}
+
+	// Multi-byte text is split on characters, not bytes.
+	for _, text := range []string{strings.Repeat("नमस्ते ", 30), strings.Repeat("héllo wörld ", 40)} {
+		for _, c := range ChunkText(text, 50) {
+			if !utf8.ValidString(c) || utf8.RuneCountInString(c) > 50 {
+				t.Errorf("chunk %q: invalid or longer than 50 characters", c)
+			}
+		}
+	}
+
+	// A code block split between chunks is closed and reopened.
+	code := "intro\n```go\n" + strings.Repeat("fmt.Println(42)\n", 10) + "```\nafter"
+	chunks := ChunkText(code, 60)
+	if len(chunks) < 2 {
+		t.Fatalf("chunks = %d, want several", len(chunks))
+	}
+	for i, c := range chunks {
+		if strings.Count(c, fence)%2 != 0 {
+			t.Errorf("chunk %d has an unbalanced fence: %q", i, c)
+		}
+		if utf8.RuneCountInString(c) > 60 {
+			t.Errorf("chunk %d is %d characters", i, utf8.RuneCountInString(c))
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxRetries bounds how often a rate-limited REST call is retried.
+const maxRetries = 3
+
+// userAgent is the User-Agent Discord requires of bots.
+const userAgent = "DiscordBot (https://github.com/harshadpatil/dhaavak, 1.0)"
+
+// apiClient is a minimal Discord REST client that respects the rate
+// limits Discord reports, see rateLimiter.
+type apiClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+	limits  *rateLimiter
+}
+
+func newAPIClient(baseURL, token string) *apiClient {
+	return &apiClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+		limits:  newRateLimiter(),
+	}
+}
+
+// apiError is an error reply of the REST API.
+type apiError struct {
+	Status  int
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s (code %d)", e.Status, e.Message, e.Code)
+}
+
+// user is a Discord user.
+type user struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Bot      bool   `json:"bot"`
+}
+
+// channelInfo is the part of a Discord channel the adapter needs.
+type channelInfo struct {
+	ID       string `json:"id"`
+	Type     int    `json:"type"`
+	GuildID  string `json:"guild_id"`
+	ParentID string `json:"parent_id"`
+}
+
+// Thread channel types.
+const (
+	typeAnnouncementThread = 10
+	typePublicThread       = 11
+	typePrivateThread      = 12
+)
+
+func (c channelInfo) isThread() bool {
+	return c.Type == typeAnnouncementThread || c.Type == typePublicThread || c.Type == typePrivateThread
+}
+
+// createMessage is the body of POST /channels/{id}/messages.
+type createMessage struct {
+	Content         string          `json:"content"`
+	AllowedMentions allowedMentions `json:"allowed_mentions"`
+}
+
+// allowedMentions controls who a message pings. Replies ping nobody, so
+// agent output cannot mention @everyone or roles.
+type allowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+// call sends a REST request and decodes the reply into out. Requests
+// wait for their rate-limit bucket; HTTP 429 replies are retried after
+// the delay Discord gives.
+func (c *apiClient) call(ctx context.Context, method, path string, body, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("discord %s %s: %w", method, path, err)
+		}
+	}
+	route, major := routeKey(method, path)
+
+	for attempt := 0; ; attempt++ {
+		b, err := c.limits.acquire(ctx, route, major)
+		if err != nil {
+			return err
+		}
+		resp, respBody, err := c.do(ctx, method, path, data)
+		if err != nil {
+			c.limits.release(b, route, major, nil, 0, false)
+			return fmt.Errorf("discord %s %s: %w", method, path, err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			var limit struct {
+				RetryAfter float64 `json:"retry_after"`
+				Global     bool    `json:"global"`
+			}
+			json.Unmarshal(respBody, &limit)
+			wait := time.Duration(limit.RetryAfter * float64(time.Second))
+			c.limits.release(b, route, major, resp, max(wait, time.Millisecond), limit.Global)
+			if attempt < maxRetries {
+				slog.Warn("discord rate limited", "route", route, "retry_after", wait, "global", limit.Global)
+				continue
+			}
+			return fmt.Errorf("discord %s %s: rate limited", method, path)
+		}
+		c.limits.release(b, route, major, resp, 0, false)
+
+		if resp.StatusCode >= 300 {
+			e := &apiError{Status: resp.StatusCode}
+			json.Unmarshal(respBody, e)
+			return fmt.Errorf("discord %s %s: %w", method, path, e)
+		}
+		if out != nil {
+			if err := json.Unmarshal(respBody, out); err != nil {
+				return fmt.Errorf("discord %s %s: %w", method, path, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *apiClient) do(ctx context.Context, method, path string, data []byte) (*http.Response, []byte, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", "Bot "+c.token)
+	req.Header.Set("User-Agent", userAgent)
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return resp, respBody, err
+}
@@ -0,0 +1,112 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/harshadpatil/dhaavak/internal/channel"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// Bot is the Discord adapter.
+type Bot struct {
+	cfg    BotConfig
+	api    *apiClient
+	sink   channel.MessageSink
+	cancel context.CancelFunc
+
+	userID string // the bot user, from /users/@me
+
+	// Gateway session, kept to resume after a reconnect.
+	sessionID string
+	resumeURL string
+	seq       atomic.Int64
+
+	mu       sync.Mutex
+	channels map[string]channelInfo // known guild channels and threads
+	dms      map[string]string      // user ID -> DM channel ID
+}
+
+// NewBot creates a Discord bot adapter. The token is checked in Start.
+func NewBot(cfg BotConfig) *Bot {
+	return &Bot{
+		cfg:      cfg,
+		api:      newAPIClient(cfg.APIURL, cfg.Token),
+		channels: make(map[string]channelInfo),
+		dms:      make(map[string]string),
+	}
+}
+
+func (b *Bot) ID() string { return "discord" }
+
+// Start checks the token and connects to the Discord Gateway.
+func (b *Bot) Start(ctx context.Context) error {
+	var me user
+	if err := b.api.call(ctx, "GET", "/users/@me", nil, &me); err != nil {
+		return fmt.Errorf("discord bot init: %w", err)
+	}
+	b.userID = me.ID
+	slog.Info("discord bot authorized", "username", me.Username)
+
+	ctx, b.cancel = context.WithCancel(ctx)
+	go b.runGateway(ctx)
+
+	slog.Info("discord gateway started")
+	return nil
+}
+
+func (b *Bot) Stop(_ context.Context) error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	slog.Info("discord bot stopped")
+	return nil
+}
+
+// maxChunkSize is the longest message content Discord accepts, in
+// characters.
+const maxChunkSize = 2000
+
+// SendMessage posts a reply. Guild messages go to ThreadID, the channel
+// or thread the message came from; direct messages go to the DM channel
+// of the user in PeerID. Discord renders markdown itself.
+func (b *Bot) SendMessage(ctx context.Context, msg protocol.OutboundMessage) error {
+	target := msg.ThreadID
+	if target == "" {
+		var err error
+		if target, err = b.dmChannel(ctx, msg.PeerID); err != nil {
+			return err
+		}
+	}
+
+	for _, chunk := range channel.ChunkText(msg.Text, maxChunkSize) {
+		req := createMessage{Content: chunk, AllowedMentions: allowedMentions{Parse: []string{}}}
+		if err := b.api.call(ctx, "POST", "/channels/"+target+"/messages", req, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dmChannel returns the DM channel with a user, opening it if no message
+// from the user was seen yet.
+func (b *Bot) dmChannel(ctx context.Context, userID string) (string, error) {
+	b.mu.Lock()
+	id, ok := b.dms[userID]
+	b.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	var ch channelInfo
+	if err := b.api.call(ctx, "POST", "/users/@me/channels", map[string]string{"recipient_id": userID}, &ch); err != nil {
+		return "", err
+	}
+	b.mu.Lock()
+	b.dms[userID] = ch.ID
+	b.mu.Unlock()
+	return ch.ID, nil
+}
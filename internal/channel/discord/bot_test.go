@@ -0,0 +1,279 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/harshadpatil/dhaavak/internal/channel/channeltest"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// fakeDiscord is a local stand-in for the Discord REST API and Gateway.
+type fakeDiscord struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu        sync.Mutex
+	posts     []post
+	limitNext bool // answer the next message post with 429
+	conns     int
+
+	// script runs on each Gateway connection after hello.
+	script func(ctx context.Context, n int, conn *websocket.Conn)
+}
+
+type post struct {
+	channel string
+	at      time.Time
+	body    createMessage
+}
+
+func newFakeDiscord(t *testing.T) *fakeDiscord {
+	f := &fakeDiscord{t: t}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users/@me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(user{ID: "BOT", Username: "dhaavak", Bot: true})
+	})
+	mux.HandleFunc("GET /api/gateway/bot", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"url": f.wsURL()})
+	})
+	mux.HandleFunc("GET /api/channels/TH2", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(channelInfo{ID: "TH2", Type: typePublicThread, GuildID: "G1", ParentID: "C2"})
+	})
+	mux.HandleFunc("POST /api/users/@me/channels", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(channelInfo{ID: "DM9", Type: 1})
+	})
+	mux.HandleFunc("POST /api/channels/{id}/messages", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.limitNext {
+			f.limitNext = false
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message":"You are being rate limited.","retry_after":0.05,"global":false}`))
+			return
+		}
+		var body createMessage
+		json.NewDecoder(r.Body).Decode(&body)
+		f.posts = append(f.posts, post{channel: r.PathValue("id"), at: time.Now(), body: body})
+		// Every post uses up the bucket for 200ms.
+		w.Header().Set("X-RateLimit-Bucket", "msgs")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset-After", "0.2")
+		w.Write([]byte(`{"id":"M1"}`))
+	})
+	mux.HandleFunc("/gateway/", f.serveGateway)
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeDiscord) wsURL() string {
+	return "ws" + strings.TrimPrefix(f.server.URL, "http") + "/gateway"
+}
+
+func (f *fakeDiscord) serveGateway(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+	f.mu.Lock()
+	f.conns++
+	n := f.conns
+	f.mu.Unlock()
+
+	ctx := r.Context()
+	writeJSON(ctx, conn, map[string]any{"op": opHello, "d": map[string]int{"heartbeat_interval": 60000}})
+	if f.script != nil {
+		f.script(ctx, n, conn)
+	}
+	// Keep the connection open until the bot closes it.
+	for {
+		if _, _, err := conn.Read(ctx); err != nil {
+			return
+		}
+	}
+}
+
+func writeJSON(ctx context.Context, conn *websocket.Conn, v any) {
+	data, _ := json.Marshal(v)
+	conn.Write(ctx, websocket.MessageText, data)
+}
+
+// readOp reads Gateway payloads until one with the given opcode,
+// skipping heartbeats.
+func readOp(ctx context.Context, conn *websocket.Conn, op int) (map[string]any, bool) {
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return nil, false
+		}
+		var p struct {
+			Op int            `json:"op"`
+			D  map[string]any `json:"d"`
+		}
+		json.Unmarshal(data, &p)
+		if p.Op == op {
+			return p.D, true
+		}
+	}
+}
+
+func dispatchEvent(seq int, event string, d any) map[string]any {
+	return map[string]any{"op": opDispatch, "s": seq, "t": event, "d": d}
+}
+
+func newTestBot(t *testing.T, f *fakeDiscord) (*Bot, *channeltest.Sink) {
+	t.Helper()
+	b := NewBot(BotConfig{Token: "tok", APIURL: f.server.URL + "/api", DMPolicy: "open", GroupPolicy: "mention"})
+	got := channeltest.NewSink()
+	b.SetSink(got.Func())
+	channeltest.Start(t, b)
+	return b, got
+}
+
+func TestGateway(t *testing.T) {
+	f := newFakeDiscord(t)
+	identify := make(chan map[string]any, 1)
+	resume := make(chan map[string]any, 1)
+	f.script = func(ctx context.Context, n int, conn *websocket.Conn) {
+		if n > 1 {
+			d, _ := readOp(ctx, conn, opResume)
+			resume <- d
+			return
+		}
+		d, _ := readOp(ctx, conn, opIdentify)
+		identify <- d
+
+		author := map[string]any{"id": "U1", "username": "alice"}
+		mention := []map[string]any{{"id": "BOT"}}
+		events := []map[string]any{
+			dispatchEvent(1, "READY", map[string]any{"session_id": "sess1", "resume_gateway_url": f.wsURL(), "user": map[string]any{"id": "BOT"}}),
+			dispatchEvent(2, "GUILD_CREATE", map[string]any{
+				"id":       "G1",
+				"channels": []map[string]any{{"id": "C1", "type": 0}},
+				"threads":  []map[string]any{{"id": "TH1", "type": typePublicThread, "parent_id": "C1"}},
+			}),
+			// Ignored: no mention, and a bot author.
+			dispatchEvent(3, "MESSAGE_CREATE", map[string]any{"id": "1", "channel_id": "C1", "guild_id": "G1", "author": author, "content": "hello all"}),
+			dispatchEvent(4, "MESSAGE_CREATE", map[string]any{"id": "2", "channel_id": "C1", "guild_id": "G1", "author": map[string]any{"id": "U2", "bot": true}, "content": "<@BOT> hi", "mentions": mention}),
+			dispatchEvent(5, "MESSAGE_CREATE", map[string]any{"id": "3", "channel_id": "C1", "guild_id": "G1", "author": author, "content": "<@BOT> deploy", "mentions": mention}),
+			dispatchEvent(6, "MESSAGE_CREATE", map[string]any{"id": "4", "channel_id": "TH1", "guild_id": "G1", "author": author, "content": "<@!BOT> /reset", "mentions": mention}),
+			dispatchEvent(7, "MESSAGE_CREATE", map[string]any{"id": "5", "channel_id": "TH2", "guild_id": "G1", "author": author, "content": "<@BOT> status", "mentions": mention}),
+			dispatchEvent(8, "MESSAGE_CREATE", map[string]any{"id": "6", "channel_id": "DM1", "author": author, "content": "private",
+				"attachments": []map[string]any{{"content_type": "image/png"}}}),
+			{"op": opReconnect, "d": nil},
+		}
+		for _, e := range events {
+			writeJSON(ctx, conn, e)
+		}
+	}
+
+	_, got := newTestBot(t, f)
+
+	select {
+	case d := <-identify:
+		if d["token"] != "tok" || int(d["intents"].(float64))&intentMessageContent != 0 {
+			t.Errorf("identify = %v", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no identify")
+	}
+
+	tests := []struct {
+		name                                  string
+		peerKind, peerID, guild, thread, text string
+	}{
+		{"channel mention", "group", "C1", "G1", "C1", "deploy"},
+		{"known thread", "group", "C1", "G1", "TH1", "/reset"},
+		{"fetched thread", "group", "C2", "G1", "TH2", "status"},
+		{"dm", "user", "U1", "", "", "private"},
+	}
+	for _, tt := range tests {
+		msg := got.Receive(t)
+		if msg.Channel != "discord" || msg.PeerKind != tt.peerKind || msg.PeerID != tt.peerID ||
+			msg.GuildID != tt.guild || msg.ThreadID != tt.thread || msg.Text != tt.text || msg.SenderID != "U1" {
+			t.Errorf("%s: message = %+v", tt.name, msg)
+		}
+	}
+
+	select {
+	case d := <-resume:
+		if d["session_id"] != "sess1" || d["seq"] != float64(8) {
+			t.Errorf("resume = %v", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no resume after reconnect")
+	}
+	got.None(t, 50*time.Millisecond)
+}
+
+func TestSendMessage(t *testing.T) {
+	f := newFakeDiscord(t)
+	b, _ := newTestBot(t, f)
+
+	long := strings.Repeat("word ", maxChunkSize/5+10)
+	f.limitNext = true
+	err := b.SendMessage(context.Background(), protocol.OutboundMessage{Channel: "discord", PeerID: "C1", ThreadID: "TH1", Text: long})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if err := b.SendMessage(context.Background(), protocol.OutboundMessage{Channel: "discord", PeerID: "U9", Text: "hi"}); err != nil {
+		t.Fatalf("SendMessage DM: %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.posts) != 3 {
+		t.Fatalf("posts = %d, want 3", len(f.posts))
+	}
+	if f.posts[0].channel != "TH1" || f.posts[1].channel != "TH1" || f.posts[2].channel != "DM9" {
+		t.Errorf("channels = %s, %s, %s", f.posts[0].channel, f.posts[1].channel, f.posts[2].channel)
+	}
+	for _, p := range f.posts[:2] {
+		if n := len([]rune(p.body.Content)); n > maxChunkSize {
+			t.Errorf("chunk of %d characters", n)
+		}
+		if p.body.AllowedMentions.Parse == nil || len(p.body.AllowedMentions.Parse) != 0 {
+			t.Errorf("allowed_mentions = %+v", p.body.AllowedMentions)
+		}
+	}
+	// The bucket of TH1 was empty for 200ms after the first chunk.
+	if d := f.posts[1].at.Sub(f.posts[0].at); d < 150*time.Millisecond {
+		t.Errorf("second chunk sent %v after the first, want to wait for the bucket", d)
+	}
+	// DM9 is a different bucket: no wait.
+	if d := f.posts[2].at.Sub(f.posts[1].at); d > 150*time.Millisecond {
+		t.Errorf("DM sent %v after the channel post, want no wait", d)
+	}
+}
+
+func TestRouteKey(t *testing.T) {
+	tests := []struct {
+		method, path string
+		route, major string
+	}{
+		{"POST", "/channels/123/messages", "POST /channels/123/messages", "channels/123"},
+		{"DELETE", "/channels/123/messages/456", "DELETE /channels/123/messages/:id", "channels/123"},
+		{"GET", "/users/@me", "GET /users/@me", ""},
+		{"POST", "/users/@me/channels", "POST /users/@me/channels", ""},
+		{"GET", "/guilds/9/members/8", "GET /guilds/9/members/:id", "guilds/9"},
+	}
+	for _, tt := range tests {
+		route, major := routeKey(tt.method, tt.path)
+		if route != tt.route || major != tt.major {
+			t.Errorf("routeKey(%s %s) = %q, %q, want %q, %q", tt.method, tt.path, route, major, tt.route, tt.major)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package discord
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// message is a MESSAGE_CREATE event.
+type message struct {
+	ID           string       `json:"id"`
+	Type         int          `json:"type"` // 0 default, 19 reply
+	ChannelID    string       `json:"channel_id"`
+	GuildID      string       `json:"guild_id"`
+	Author       user         `json:"author"`
+	WebhookID    string       `json:"webhook_id"`
+	Content      string       `json:"content"`
+	Mentions     []user       `json:"mentions"`
+	Attachments  []attachment `json:"attachments"`
+	StickerItems []struct{}   `json:"sticker_items"`
+}
+
+type attachment struct {
+	ContentType string `json:"content_type"`
+}
+
+// Message types that carry user text; the others are system notices
+// like joins and pins.
+const (
+	typeDefault = 0
+	typeReply   = 19
+)
+
+// messageContext extracts routing information from a Discord message.
+type messageContext struct {
+	MessageID string
+	ChannelID string
+	UserID    string
+	Text      string
+	PeerKind  string // "user" or "group"
+	PeerID    string
+	GuildID   string
+	ThreadID  string
+	IsMention bool
+	Command   string // command without the slash, e.g. "reset"
+	Media     []string
+}
+
+// extractContext builds the context of a message. selfID is the bot's
+// own user.
+//
+// In a guild, ThreadID is the channel or thread the message was sent in,
+// so each of them is its own conversation and replies go back to it.
+// PeerID is the channel too; for threads, dispatch replaces it with the
+// thread's parent channel.
+func extractContext(m message, selfID string) *messageContext {
+	if m.Type != typeDefault && m.Type != typeReply {
+		return nil
+	}
+	if m.Author.Bot || m.WebhookID != "" || m.Author.ID == "" || m.Author.ID == selfID {
+		return nil
+	}
+
+	mc := &messageContext{
+		MessageID: "discord:" + m.ChannelID + ":" + m.ID,
+		ChannelID: m.ChannelID,
+		UserID:    m.Author.ID,
+		Media:     mediaTypes(m),
+	}
+	for _, u := range m.Mentions {
+		if u.ID == selfID {
+			mc.IsMention = true
+		}
+	}
+	mc.Text = stripMention(m.Content, selfID)
+	if mc.Text == "" && len(mc.Media) == 0 {
+		return nil
+	}
+
+	if m.GuildID == "" {
+		mc.PeerKind = "user"
+		mc.PeerID = m.Author.ID
+	} else {
+		mc.PeerKind = "group"
+		mc.GuildID = m.GuildID
+		mc.PeerID = m.ChannelID
+		mc.ThreadID = m.ChannelID
+	}
+
+	mc.Command = parseCommand(mc.Text)
+	return mc
+}
+
+// stripMention removes mentions of the bot user ("<@id>" or "<@!id>")
+// from text.
+func stripMention(text, userID string) string {
+	if userID != "" {
+		text = strings.ReplaceAll(text, "<@"+userID+">", "")
+		text = strings.ReplaceAll(text, "<@!"+userID+">", "")
+	}
+	return strings.TrimSpace(text)
+}
+
+// mediaTypes lists the attachment types of a message.
+func mediaTypes(m message) []string {
+	var types []string
+	add := func(t string) {
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	for _, a := range m.Attachments {
+		switch {
+		case a.ContentType == "image/gif":
+			add("animation")
+		case strings.HasPrefix(a.ContentType, "image/"):
+			add("photo")
+		case strings.HasPrefix(a.ContentType, "video/"):
+			add("video")
+		case strings.HasPrefix(a.ContentType, "audio/"):
+			add("audio")
+		default:
+			add("document")
+		}
+	}
+	if len(m.StickerItems) > 0 {
+		add("sticker")
+	}
+	return types
+}
+
+// parseCommand returns the command at the start of text ("/reset" ->
+// "reset"), or "" if text is not a command.
+func parseCommand(text string) string {
+	if !strings.HasPrefix(text, "/") {
+		return ""
+	}
+	return strings.ToLower(strings.Fields(text)[0][1:])
+}
+
+// checkAccess verifies whether this message should be processed.
+func checkAccess(mc *messageContext, cfg BotConfig) bool {
+	if mc.PeerKind == "user" {
+		switch cfg.DMPolicy {
+		case "open":
+			return true
+		case "allowlist":
+			return slices.Contains(cfg.AllowedUsers, mc.UserID)
+		default:
+			return false
+		}
+	}
+	switch cfg.GroupPolicy {
+	case "all", "mention":
+	default:
+		return false
+	}
+	if len(cfg.AllowedGuilds) > 0 && !slices.Contains(cfg.AllowedGuilds, mc.GuildID) {
+		return false
+	}
+	return cfg.GroupPolicy != "mention" || mc.IsMention
+}
+
+// toInboundMessage converts a message context to a protocol message.
+func toInboundMessage(mc *messageContext) protocol.InboundMessage {
+	return protocol.InboundMessage{
+		MessageID: mc.MessageID,
+		Channel:   "discord",
+		PeerKind:  mc.PeerKind,
+		PeerID:    mc.PeerID,
+		GuildID:   mc.GuildID,
+		ThreadID:  mc.ThreadID,
+		SenderID:  mc.UserID,
+		Text:      mc.Text,
+		Command:   mc.Command,
+
+		Attachments: mc.Media,
+	}
+}
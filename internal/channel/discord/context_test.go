@@ -0,0 +1,31 @@
+package discord
+
+import "testing"
+
+func TestExtractContext(t *testing.T) {
+	m := message{
+		ID:        "9",
+		ChannelID: "C1",
+		GuildID:   "G1",
+		Author:    user{ID: "U1"},
+		Content:   "<@BOT> look",
+		Mentions:  []user{{ID: "BOT"}},
+	}
+	mc := extractContext(m, "BOT")
+	if mc == nil || !mc.IsMention || mc.Text != "look" || mc.MessageID != "discord:C1:9" {
+		t.Fatalf("context = %+v", mc)
+	}
+
+	cfg := BotConfig{DMPolicy: "allowlist", AllowedUsers: []string{"U1"}, GroupPolicy: "mention", AllowedGuilds: []string{"G2"}}
+	if checkAccess(mc, cfg) {
+		t.Error("message from a guild outside allowed_guilds was allowed")
+	}
+	m.GuildID = ""
+	if mc := extractContext(m, "BOT"); !checkAccess(mc, cfg) {
+		t.Error("DM from an allowed user was denied")
+	}
+	m.Type = 7 // member join
+	if mc := extractContext(m, "BOT"); mc != nil {
+		t.Errorf("system message = %+v", mc)
+	}
+}
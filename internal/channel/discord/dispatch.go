@@ -0,0 +1,122 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/harshadpatil/dhaavak/internal/channel"
+)
+
+// handleDispatch processes a Gateway event.
+func (b *Bot) handleDispatch(ctx context.Context, event string, data json.RawMessage) {
+	switch event {
+	case "READY":
+		var ready struct {
+			SessionID string `json:"session_id"`
+			ResumeURL string `json:"resume_gateway_url"`
+			User      user   `json:"user"`
+		}
+		if err := json.Unmarshal(data, &ready); err != nil {
+			slog.Warn("discord: bad READY", "err", err)
+			return
+		}
+		b.sessionID, b.resumeURL = ready.SessionID, ready.ResumeURL
+		slog.Info("discord gateway ready", "username", ready.User.Username)
+	case "GUILD_CREATE":
+		var guild struct {
+			ID       string        `json:"id"`
+			Channels []channelInfo `json:"channels"`
+			Threads  []channelInfo `json:"threads"`
+		}
+		if err := json.Unmarshal(data, &guild); err != nil {
+			slog.Warn("discord: bad GUILD_CREATE", "err", err)
+			return
+		}
+		b.cacheChannels(guild.ID, append(guild.Channels, guild.Threads...))
+	case "THREAD_LIST_SYNC":
+		var sync struct {
+			GuildID string        `json:"guild_id"`
+			Threads []channelInfo `json:"threads"`
+		}
+		if err := json.Unmarshal(data, &sync); err == nil {
+			b.cacheChannels(sync.GuildID, sync.Threads)
+		}
+	case "CHANNEL_CREATE", "CHANNEL_UPDATE", "THREAD_CREATE", "THREAD_UPDATE":
+		var ch channelInfo
+		if err := json.Unmarshal(data, &ch); err == nil && ch.GuildID != "" {
+			b.cacheChannels(ch.GuildID, []channelInfo{ch})
+		}
+	case "MESSAGE_CREATE":
+		var m message
+		if err := json.Unmarshal(data, &m); err != nil {
+			slog.Warn("discord: bad MESSAGE_CREATE", "err", err)
+			return
+		}
+		b.dispatch(ctx, m)
+	}
+}
+
+// dispatch processes an incoming Discord message.
+func (b *Bot) dispatch(ctx context.Context, m message) {
+	mc := extractContext(m, b.userID)
+	if mc == nil {
+		return
+	}
+
+	if !checkAccess(mc, b.cfg) {
+		slog.Debug("discord access denied", "user", mc.UserID, "guild", mc.GuildID)
+		return
+	}
+
+	if mc.GuildID != "" {
+		if ch, ok := b.channel(ctx, mc.ChannelID); ok && ch.isThread() && ch.ParentID != "" {
+			mc.PeerID = ch.ParentID
+		}
+	} else {
+		b.mu.Lock()
+		b.dms[mc.UserID] = mc.ChannelID
+		b.mu.Unlock()
+	}
+
+	msg := toInboundMessage(mc)
+
+	if b.sink != nil {
+		if err := b.sink(ctx, msg); err != nil {
+			slog.Error("discord message sink error", "err", err)
+		}
+	}
+}
+
+// channel returns a guild channel, fetching it if the Gateway has not
+// announced it, e.g. an archived thread that was just reopened.
+func (b *Bot) channel(ctx context.Context, id string) (channelInfo, bool) {
+	b.mu.Lock()
+	ch, ok := b.channels[id]
+	b.mu.Unlock()
+	if ok {
+		return ch, true
+	}
+	if err := b.api.call(ctx, "GET", "/channels/"+id, nil, &ch); err != nil {
+		slog.Warn("discord channel lookup failed", "channel", id, "err", err)
+		return ch, false
+	}
+	b.cacheChannels(ch.GuildID, []channelInfo{ch})
+	return ch, true
+}
+
+func (b *Bot) cacheChannels(guildID string, channels []channelInfo) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range channels {
+		if ch.GuildID == "" {
+			ch.GuildID = guildID
+		}
+		b.channels[ch.ID] = ch
+	}
+}
+
+// SetSink sets the message sink callback.
+func (b *Bot) SetSink(sink channel.MessageSink) {
+	b.sink = sink
+}
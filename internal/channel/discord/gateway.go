@@ -0,0 +1,264 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// Gateway opcodes.
+const (
+	opDispatch       = 0
+	opHeartbeat      = 1
+	opIdentify       = 2
+	opResume         = 6
+	opReconnect      = 7
+	opInvalidSession = 9
+	opHello          = 10
+	opHeartbeatACK   = 11
+)
+
+// Gateway intents.
+const (
+	intentGuilds         = 1 << 0
+	intentGuildMessages  = 1 << 9
+	intentDirectMessages = 1 << 12
+	intentMessageContent = 1 << 15
+)
+
+// statusResume closes a connection without ending the session, so it
+// can be resumed. Closing with 1000 or 1001 would end it.
+const statusResume websocket.StatusCode = 4000
+
+// payload is a Gateway message.
+type payload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  int64           `json:"s"`
+	T  string          `json:"t"`
+}
+
+// fatalError is a Gateway close that reconnecting cannot fix, such as an
+// invalid token or disallowed intents.
+type fatalError struct {
+	status websocket.StatusCode
+}
+
+func (e *fatalError) Error() string {
+	return fmt.Sprintf("discord gateway closed the connection with %d", int(e.status))
+}
+
+// intents returns the Gateway intents to request. Message content is a
+// privileged intent; without it Discord still sends the content of DMs
+// and of messages that mention the bot, which is all the mention policy
+// needs.
+func (b *Bot) intents() int {
+	intents := intentGuilds | intentGuildMessages | intentDirectMessages
+	if b.cfg.GroupPolicy == "all" {
+		intents |= intentMessageContent
+	}
+	return intents
+}
+
+// runGateway keeps a Gateway connection open until ctx is done,
+// reconnecting with backoff and resuming the session where possible.
+func (b *Bot) runGateway(ctx context.Context) {
+	backoff := time.Second
+	for {
+		ready, err := b.connectGateway(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		var fatal *fatalError
+		if errors.As(err, &fatal) {
+			slog.Error("discord gateway stopped", "err", err)
+			return
+		}
+		if ready {
+			backoff = time.Second
+		}
+		if err != nil {
+			slog.Warn("discord gateway disconnected", "err", err, "retry_in", backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, time.Minute)
+		}
+	}
+}
+
+// connectGateway runs one Gateway connection until it ends. It reports
+// whether the session became ready; a nil error means Discord asked for
+// a reconnect.
+func (b *Bot) connectGateway(ctx context.Context) (bool, error) {
+	url := b.resumeURL
+	if b.sessionID == "" || url == "" {
+		var gw struct {
+			URL string `json:"url"`
+		}
+		if err := b.api.call(ctx, "GET", "/gateway/bot", nil, &gw); err != nil {
+			return false, err
+		}
+		url = gw.URL
+	}
+
+	conn, _, err := websocket.Dial(ctx, url+"/?v=10&encoding=json", nil)
+	if err != nil {
+		return false, fmt.Errorf("discord gateway dial: %w", err)
+	}
+	defer conn.CloseNow()
+	// GUILD_CREATE lists every channel of a guild and can be large.
+	conn.SetReadLimit(8 << 20)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	p, err := readPayload(ctx, conn)
+	if err != nil {
+		return false, err
+	}
+	if p.Op != opHello {
+		return false, fmt.Errorf("discord gateway: expected hello, got op %d", p.Op)
+	}
+	var hello struct {
+		HeartbeatInterval int64 `json:"heartbeat_interval"`
+	}
+	if err := json.Unmarshal(p.D, &hello); err != nil || hello.HeartbeatInterval <= 0 {
+		return false, fmt.Errorf("discord gateway: bad hello")
+	}
+	var acked atomic.Bool
+	acked.Store(true)
+	go b.heartbeat(ctx, conn, time.Duration(hello.HeartbeatInterval)*time.Millisecond, &acked)
+
+	if b.sessionID != "" {
+		err = send(ctx, conn, opResume, map[string]any{
+			"token":      b.cfg.Token,
+			"session_id": b.sessionID,
+			"seq":        b.seq.Load(),
+		})
+	} else {
+		err = send(ctx, conn, opIdentify, map[string]any{
+			"token":   b.cfg.Token,
+			"intents": b.intents(),
+			"properties": map[string]string{
+				"os":      runtime.GOOS,
+				"browser": "dhaavak",
+				"device":  "dhaavak",
+			},
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ready := false
+	for {
+		p, err := readPayload(ctx, conn)
+		if err != nil {
+			switch status := websocket.CloseStatus(err); status {
+			case 4004, 4010, 4011, 4012, 4013, 4014:
+				return ready, &fatalError{status: status}
+			case 4007, 4009:
+				b.resetSession()
+			}
+			return ready, err
+		}
+		if p.S != 0 {
+			b.seq.Store(p.S)
+		}
+
+		switch p.Op {
+		case opDispatch:
+			if p.T == "READY" || p.T == "RESUMED" {
+				ready = true
+			}
+			b.handleDispatch(ctx, p.T, p.D)
+		case opHeartbeat:
+			if err := send(ctx, conn, opHeartbeat, b.lastSeq()); err != nil {
+				return ready, err
+			}
+		case opHeartbeatACK:
+			acked.Store(true)
+		case opReconnect:
+			conn.Close(statusResume, "reconnect requested")
+			return ready, nil
+		case opInvalidSession:
+			var resumable bool
+			json.Unmarshal(p.D, &resumable)
+			if !resumable {
+				b.resetSession()
+			}
+			conn.Close(statusResume, "invalid session")
+			return ready, errors.New("discord gateway: invalid session")
+		}
+	}
+}
+
+// heartbeat sends heartbeats at the interval Discord asked for. A
+// heartbeat that was not acknowledged by the next one means the
+// connection is dead; it is closed so runGateway resumes.
+func (b *Bot) heartbeat(ctx context.Context, conn *websocket.Conn, interval time.Duration, acked *atomic.Bool) {
+	wait := time.Duration(rand.Float64() * float64(interval))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = interval
+		if !acked.Swap(false) {
+			slog.Warn("discord heartbeat not acknowledged, reconnecting")
+			conn.Close(statusResume, "heartbeat timeout")
+			return
+		}
+		if err := send(ctx, conn, opHeartbeat, b.lastSeq()); err != nil {
+			return
+		}
+	}
+}
+
+// lastSeq returns the sequence number heartbeats carry, null before the
+// first dispatch.
+func (b *Bot) lastSeq() any {
+	if s := b.seq.Load(); s != 0 {
+		return s
+	}
+	return nil
+}
+
+// resetSession forgets the Gateway session, so the next connection
+// identifies again.
+func (b *Bot) resetSession() {
+	b.sessionID, b.resumeURL = "", ""
+	b.seq.Store(0)
+}
+
+func readPayload(ctx context.Context, conn *websocket.Conn) (payload, error) {
+	var p payload
+	_, data, err := conn.Read(ctx)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("discord gateway: %w", err)
+	}
+	return p, nil
+}
+
+func send(ctx context.Context, conn *websocket.Conn, op int, d any) error {
+	data, err := json.Marshal(map[string]any{"op": op, "d": d})
+	if err != nil {
+		return err
+	}
+	return conn.Write(ctx, websocket.MessageText, data)
+}
@@ -0,0 +1,132 @@
+package discord
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter tracks Discord's REST rate limits. Each route maps to a
+// bucket, named by the X-RateLimit-Bucket header and scoped to the route's
+// major parameter (the channel, guild or webhook ID), so replies to
+// different channels are limited separately. Requests in one bucket run
+// one at a time.
+type rateLimiter struct {
+	mu      sync.Mutex
+	routes  map[string]string  // route -> bucket hash, once Discord named it
+	buckets map[string]*bucket // "hash:major", or the route until then
+	global  time.Time          // no requests before this, after a global 429
+}
+
+type bucket struct {
+	mu        sync.Mutex // held for the whole request
+	remaining int        // -1 until Discord reported it
+	reset     time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		routes:  make(map[string]string),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// routeKey returns the rate-limit route of a request ("POST
+// /channels/123/messages") and its major parameter ("channels/123").
+// IDs other than the major parameter are replaced, since they share the
+// route's limit.
+func routeKey(method, path string) (route, major string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) >= 2 {
+		switch parts[0] {
+		case "channels", "guilds", "webhooks":
+			major = parts[0] + "/" + parts[1]
+		}
+	}
+	for i := range parts {
+		if i == 1 && major != "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(parts[i], 10, 64); err == nil {
+			parts[i] = ":id"
+		}
+	}
+	return method + " /" + strings.Join(parts, "/"), major
+}
+
+// acquire waits until a request on route may be sent and returns its
+// bucket, locked. The caller must pass it to release.
+func (l *rateLimiter) acquire(ctx context.Context, route, major string) (*bucket, error) {
+	l.mu.Lock()
+	key := route
+	if hash, ok := l.routes[route]; ok {
+		key = hash + ":" + major
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{remaining: -1}
+		l.buckets[key] = b
+	}
+	l.mu.Unlock()
+
+	b.mu.Lock()
+	for {
+		l.mu.Lock()
+		wait := time.Until(l.global)
+		l.mu.Unlock()
+		if b.remaining == 0 {
+			wait = max(wait, time.Until(b.reset))
+		}
+		if wait <= 0 {
+			return b, nil
+		}
+		select {
+		case <-ctx.Done():
+			b.mu.Unlock()
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if b.remaining == 0 && !time.Now().Before(b.reset) {
+			b.remaining = -1
+		}
+	}
+}
+
+// release records the limits a response reported and unlocks the bucket.
+func (l *rateLimiter) release(b *bucket, route, major string, resp *http.Response, retryAfter time.Duration, global bool) {
+	defer b.mu.Unlock()
+	now := time.Now()
+
+	if global {
+		l.mu.Lock()
+		l.global = now.Add(retryAfter)
+		l.mu.Unlock()
+		return
+	}
+	if resp == nil {
+		return
+	}
+	if hash := resp.Header.Get("X-RateLimit-Bucket"); hash != "" {
+		l.mu.Lock()
+		if l.routes[route] != hash {
+			l.routes[route] = hash
+			if _, ok := l.buckets[hash+":"+major]; !ok {
+				l.buckets[hash+":"+major] = b
+			}
+		}
+		l.mu.Unlock()
+	}
+	if retryAfter > 0 {
+		b.remaining, b.reset = 0, now.Add(retryAfter)
+		return
+	}
+	if v, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		b.remaining = v
+	}
+	if v, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Reset-After"), 64); err == nil {
+		b.reset = now.Add(time.Duration(v * float64(time.Second)))
+	}
+}
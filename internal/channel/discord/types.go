@@ -0,0 +1,31 @@
+package discord
+
+import (
+	"github.com/harshadpatil/dhaavak/internal/channel"
+	"github.com/harshadpatil/dhaavak/internal/config"
+)
+
+// BotConfig holds Discord adapter configuration.
+type BotConfig struct {
+	Token         string
+	APIURL        string
+	DMPolicy      string
+	GroupPolicy   string
+	AllowedUsers  []string
+	AllowedGuilds []string
+}
+
+// ConfigFromApp extracts Discord config from the app config.
+func ConfigFromApp(cfg config.DiscordConfig) BotConfig {
+	return BotConfig{
+		Token:         cfg.BotToken,
+		APIURL:        cfg.APIURL,
+		DMPolicy:      cfg.DMPolicy,
+		GroupPolicy:   cfg.GroupPolicy,
+		AllowedUsers:  cfg.AllowedUsers,
+		AllowedGuilds: cfg.AllowedGuilds,
+	}
+}
+
+// ensure Bot implements Adapter.
+var _ channel.Adapter = (*Bot)(nil)
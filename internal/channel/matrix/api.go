@@ -0,0 +1,114 @@
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxRetries bounds how often a rate-limited request is retried.
+const maxRetries = 3
+
+// apiPrefix is the path of the client-server API on the homeserver.
+const apiPrefix = "/_matrix/client/v3"
+
+// apiClient is a minimal client for the Matrix client-server API.
+type apiClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// newAPIClient creates a client whose requests may take up to timeout
+// longer than a /sync long-poll of syncTimeout.
+func newAPIClient(baseURL, token string, syncTimeout time.Duration) *apiClient {
+	return &apiClient{
+		baseURL: strings.TrimSuffix(baseURL, "/") + apiPrefix,
+		token:   token,
+		http:    &http.Client{Timeout: syncTimeout + 30*time.Second},
+	}
+}
+
+// apiError is an error reply of the homeserver.
+type apiError struct {
+	Status       int
+	ErrCode      string `json:"errcode"`
+	Message      string `json:"error"`
+	RetryAfterMS int64  `json:"retry_after_ms"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s (%s)", e.Status, e.Message, e.ErrCode)
+}
+
+// call sends a request to path, which may include a query, and decodes
+// the reply into out. Rate-limited requests are retried after the delay
+// the homeserver gives.
+func (c *apiClient) call(ctx context.Context, method, path string, body, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("matrix %s %s: %w", method, path, err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, respBody, err := c.do(ctx, method, path, data)
+		if err != nil {
+			return fmt.Errorf("matrix %s %s: %w", method, path, err)
+		}
+		if resp.StatusCode < 300 {
+			if out != nil {
+				if err := json.Unmarshal(respBody, out); err != nil {
+					return fmt.Errorf("matrix %s %s: %w", method, path, err)
+				}
+			}
+			return nil
+		}
+
+		e := &apiError{Status: resp.StatusCode}
+		json.Unmarshal(respBody, e)
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= maxRetries {
+			return fmt.Errorf("matrix %s %s: %w", method, path, e)
+		}
+		wait := time.Duration(e.RetryAfterMS) * time.Millisecond
+		if wait <= 0 {
+			wait = time.Second
+		}
+		slog.Warn("matrix rate limited", "path", path, "retry_after", wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *apiClient) do(ctx context.Context, method, path string, data []byte) (*http.Response, []byte, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	return resp, respBody, err
+}
@@ -0,0 +1,138 @@
+package matrix
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/harshadpatil/dhaavak/internal/channel"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// Bot is the Matrix adapter.
+type Bot struct {
+	cfg    BotConfig
+	api    *apiClient
+	sink   channel.MessageSink
+	cancel context.CancelFunc
+
+	userID      string // the bot user, from /account/whoami
+	displayName string
+
+	// Transaction IDs of sent events are unique per access token.
+	txnPrefix string
+	txn       atomic.Int64
+
+	mu    sync.Mutex
+	rooms map[string]*room  // joined rooms
+	dms   map[string]string // user ID -> direct room ID
+	own   map[string]bool   // recent events sent by the bot, see markOwn
+	old   map[string]bool
+}
+
+// room is what the adapter knows about a joined room.
+type room struct {
+	direct  bool // invited as a direct chat or listed in m.direct
+	members int  // joined members, from the sync summary
+}
+
+// NewBot creates a Matrix bot adapter. The token is checked in Start.
+func NewBot(cfg BotConfig) *Bot {
+	return &Bot{
+		cfg:       cfg,
+		api:       newAPIClient(cfg.Homeserver, cfg.AccessToken, cfg.SyncTimeout),
+		txnPrefix: fmt.Sprintf("dhaavak%d", time.Now().UnixNano()),
+		rooms:     make(map[string]*room),
+		dms:       make(map[string]string),
+		own:       make(map[string]bool),
+		old:       make(map[string]bool),
+	}
+}
+
+func (b *Bot) ID() string { return "matrix" }
+
+// Start checks the token and starts the /sync loop.
+func (b *Bot) Start(ctx context.Context) error {
+	var me struct {
+		UserID string `json:"user_id"`
+	}
+	if err := b.api.call(ctx, "GET", "/account/whoami", nil, &me); err != nil {
+		return fmt.Errorf("matrix bot init: %w", err)
+	}
+	b.userID = me.UserID
+
+	var profile struct {
+		DisplayName string `json:"displayname"`
+	}
+	if err := b.api.call(ctx, "GET", "/profile/"+url.PathEscape(me.UserID)+"/displayname", nil, &profile); err != nil {
+		slog.Warn("matrix display name lookup failed", "err", err)
+	}
+	b.displayName = profile.DisplayName
+	slog.Info("matrix bot authorized", "user_id", me.UserID)
+
+	ctx, b.cancel = context.WithCancel(ctx)
+	go b.runSync(ctx)
+
+	slog.Info("matrix sync started")
+	return nil
+}
+
+func (b *Bot) Stop(_ context.Context) error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	slog.Info("matrix bot stopped")
+	return nil
+}
+
+// SendMessage posts a reply. PeerID is the room, or for direct chats the
+// user, whose room is known from their messages or from m.direct.
+// ThreadID, when set, is the root event of the thread to reply in.
+// Markdown is sent as HTML, with the source text as the plain body.
+func (b *Bot) SendMessage(ctx context.Context, msg protocol.OutboundMessage) error {
+	roomID := msg.PeerID
+	if strings.HasPrefix(roomID, "@") {
+		b.mu.Lock()
+		id, ok := b.dms[msg.PeerID]
+		b.mu.Unlock()
+		if !ok {
+			return fmt.Errorf("matrix: no direct room with %s", msg.PeerID)
+		}
+		roomID = id
+	}
+
+	for _, chunk := range channel.ChunkText(msg.Text, maxChunkSize) {
+		content := messageContent{
+			MsgType:  "m.text",
+			Body:     chunk,
+			Mentions: &mentions{},
+		}
+		switch msg.Format {
+		case "markdown":
+			content.Format, content.FormattedBody = formatHTML, markdownToHTML(chunk)
+		case "html":
+			content.Format, content.FormattedBody = formatHTML, chunk
+			content.Body = htmlToText(chunk)
+		}
+		if msg.ThreadID != "" {
+			content.RelatesTo = &relatesTo{
+				RelType:       relThread,
+				EventID:       msg.ThreadID,
+				InReplyTo:     &inReplyTo{EventID: msg.ThreadID},
+				IsFallingBack: true,
+			}
+		}
+
+		txnID := fmt.Sprintf("%s.%d", b.txnPrefix, b.txn.Add(1))
+		path := "/rooms/" + url.PathEscape(roomID) + "/send/m.room.message/" + txnID
+		if err := b.api.call(ctx, "PUT", path, content, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
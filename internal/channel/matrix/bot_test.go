@@ -0,0 +1,300 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harshadpatil/dhaavak/internal/channel/channeltest"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+const botID = "@bot:example.org"
+
+// fakeHomeserver is a local stand-in for a Matrix homeserver.
+type fakeHomeserver struct {
+	t      *testing.T
+	server *httptest.Server
+
+	initial map[string]any      // reply to the first /sync
+	batches chan map[string]any // replies to later /syncs, in order
+
+	mu        sync.Mutex
+	joins     []string
+	sends     []send
+	limitNext bool // answer the next send with 429
+}
+
+type send struct {
+	room, txn string
+	content   messageContent
+}
+
+func newFakeHomeserver(t *testing.T) *fakeHomeserver {
+	f := &fakeHomeserver{t: t, initial: map[string]any{}, batches: make(chan map[string]any, 10)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /_matrix/client/v3/account/whoami", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"Unknown token"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"user_id": botID})
+	})
+	mux.HandleFunc("GET /_matrix/client/v3/profile/{user}/displayname", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"displayname": "Dhaavak"})
+	})
+	mux.HandleFunc("GET /_matrix/client/v3/sync", f.serveSync)
+	mux.HandleFunc("POST /_matrix/client/v3/join/{room}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.joins = append(f.joins, r.PathValue("room"))
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"room_id": r.PathValue("room")})
+	})
+	mux.HandleFunc("PUT /_matrix/client/v3/rooms/{room}/send/m.room.message/{txn}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.limitNext {
+			f.limitNext = false
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":50}`))
+			return
+		}
+		var c messageContent
+		json.NewDecoder(r.Body).Decode(&c)
+		f.sends = append(f.sends, send{room: r.PathValue("room"), txn: r.PathValue("txn"), content: c})
+		json.NewEncoder(w).Encode(map[string]string{"event_id": "$sent" + strconv.Itoa(len(f.sends))})
+	})
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// serveSync answers the first /sync with f.initial and later ones with
+// the next batch, or an empty reply once the long-poll times out.
+func (f *fakeHomeserver) serveSync(w http.ResponseWriter, r *http.Request) {
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		reply := map[string]any{"next_batch": "s0"}
+		for k, v := range f.initial {
+			reply[k] = v
+		}
+		json.NewEncoder(w).Encode(reply)
+		return
+	}
+	timeout, _ := strconv.Atoi(r.URL.Query().Get("timeout"))
+	n, _ := strconv.Atoi(strings.TrimPrefix(since, "s"))
+	select {
+	case batch := <-f.batches:
+		batch["next_batch"] = "s" + strconv.Itoa(n+1)
+		json.NewEncoder(w).Encode(batch)
+	case <-time.After(time.Duration(timeout) * time.Millisecond):
+		json.NewEncoder(w).Encode(map[string]any{"next_batch": since})
+	case <-r.Context().Done():
+	}
+}
+
+func message(id, sender string, content map[string]any) map[string]any {
+	if content["msgtype"] == nil {
+		content["msgtype"] = "m.text"
+	}
+	return map[string]any{"type": "m.room.message", "event_id": id, "sender": sender, "content": content}
+}
+
+func member(userID, sender, membership string, extra map[string]any) map[string]any {
+	content := map[string]any{"membership": membership}
+	for k, v := range extra {
+		content[k] = v
+	}
+	return map[string]any{"type": "m.room.member", "state_key": userID, "sender": sender, "content": content}
+}
+
+func joined(members int, events ...map[string]any) map[string]any {
+	return map[string]any{
+		"summary":  map[string]any{"m.joined_member_count": members},
+		"timeline": map[string]any{"events": events},
+	}
+}
+
+func newTestBot(t *testing.T, f *fakeHomeserver, cfg BotConfig) (*Bot, *channeltest.Sink) {
+	t.Helper()
+	cfg.Homeserver = f.server.URL
+	cfg.AccessToken = "tok"
+	cfg.SyncTimeout = 100 * time.Millisecond
+	if cfg.DMPolicy == "" {
+		cfg.DMPolicy, cfg.GroupPolicy, cfg.InvitePolicy = "open", "mention", "allowlist"
+	}
+	b := NewBot(cfg)
+	got := channeltest.NewSink()
+	b.SetSink(got.Func())
+	channeltest.Start(t, b)
+	return b, got
+}
+
+func TestSync(t *testing.T) {
+	f := newFakeHomeserver(t)
+	alice := "@alice:example.org"
+	mention := map[string]any{"user_ids": []string{botID}}
+	f.initial = map[string]any{
+		"account_data": map[string]any{"events": []map[string]any{
+			{"type": "m.direct", "content": map[string]any{"@dave:example.org": []string{"!dm2:example.org"}}},
+		}},
+		"rooms": map[string]any{
+			"join": map[string]any{
+				// History is not answered, but the bot's own message is
+				// remembered for replies.
+				"!room:example.org": joined(5,
+					message("$old", alice, map[string]any{"body": "Dhaavak: old", "m.mentions": mention}),
+					message("$botmsg", botID, map[string]any{"body": "earlier answer"}),
+				),
+				"!dm:example.org": joined(2),
+			},
+			"invite": map[string]any{
+				"!inv1:example.org": map[string]any{"invite_state": map[string]any{"events": []map[string]any{
+					member(botID, alice, "invite", map[string]any{"is_direct": true}),
+				}}},
+				"!inv2:example.org": map[string]any{"invite_state": map[string]any{"events": []map[string]any{
+					member(botID, "@mallory:evil.example", "invite", nil),
+				}}},
+			},
+		},
+	}
+	b, got := newTestBot(t, f, BotConfig{AllowedUsers: []string{alice}})
+
+	f.batches <- map[string]any{"rooms": map[string]any{"join": map[string]any{
+		"!room:example.org": joined(5,
+			// Ignored: no mention, an edit, a notice.
+			message("$1", alice, map[string]any{"body": "hello all", "m.mentions": map[string]any{}}),
+			message("$2", alice, map[string]any{"body": "Dhaavak: deploy", "m.mentions": mention}),
+			message("$3", alice, map[string]any{"body": "status?", "m.relates_to": map[string]any{
+				"rel_type": "m.thread", "event_id": "$botmsg",
+				"is_falling_back": true, "m.in_reply_to": map[string]any{"event_id": "$botmsg"},
+			}}),
+			message("$4", alice, map[string]any{"body": "> <@bot:example.org> earlier answer\n\n!reset", "m.relates_to": map[string]any{
+				"m.in_reply_to": map[string]any{"event_id": "$botmsg"},
+			}}),
+			message("$5", alice, map[string]any{"body": "* Dhaavak: deploy", "m.mentions": mention, "m.relates_to": map[string]any{
+				"rel_type": "m.replace", "event_id": "$2",
+			}}),
+			message("$6", "@otherbot:example.org", map[string]any{"msgtype": "m.notice", "body": "Dhaavak: beep", "m.mentions": mention}),
+		),
+		"!dm:example.org": joined(2,
+			message("$7", "@carol:example.org", map[string]any{"msgtype": "m.image", "body": "cat.gif", "info": map[string]any{"mimetype": "image/gif"}}),
+		),
+		"!new:example.org": joined(3,
+			message("$8", alice, map[string]any{"body": "Dhaavak: before", "m.mentions": mention}),
+			member(botID, botID, "join", nil),
+			message("$9", alice, map[string]any{"body": "Dhaavak: after", "m.mentions": mention}),
+		),
+	}}}
+
+	want := map[string]struct {
+		peerKind, peerID, guild, thread, text, command string
+	}{
+		"matrix:!room:example.org:$2": {"group", "!room:example.org", "!room:example.org", "", "deploy", ""},
+		"matrix:!room:example.org:$3": {"group", "!room:example.org", "!room:example.org", "$botmsg", "status?", ""},
+		"matrix:!room:example.org:$4": {"group", "!room:example.org", "!room:example.org", "", "!reset", "reset"},
+		"matrix:!dm:example.org:$7":   {"user", "@carol:example.org", "", "", "", ""},
+		"matrix:!new:example.org:$9":  {"group", "!new:example.org", "!new:example.org", "", "after", ""},
+	}
+	for range len(want) {
+		msg := got.Receive(t)
+		w, ok := want[msg.MessageID]
+		if !ok {
+			t.Errorf("unexpected message %+v", msg)
+			continue
+		}
+		delete(want, msg.MessageID)
+		if msg.Channel != "matrix" || msg.PeerKind != w.peerKind || msg.PeerID != w.peerID || msg.GuildID != w.guild ||
+			msg.ThreadID != w.thread || msg.Text != w.text || msg.Command != w.command {
+			t.Errorf("%s: message = %+v", msg.MessageID, msg)
+		}
+		if msg.MessageID == "matrix:!dm:example.org:$7" && !slices.Equal(msg.Attachments, []string{"animation"}) {
+			t.Errorf("attachments = %v", msg.Attachments)
+		}
+	}
+	got.None(t, 200*time.Millisecond)
+
+	f.mu.Lock()
+	if !slices.Equal(f.joins, []string{"!inv1:example.org"}) {
+		t.Errorf("joins = %v, want only the invite from an allowed user", f.joins)
+	}
+	f.mu.Unlock()
+
+	// Direct rooms are known from the invite, m.direct and messages.
+	for _, peer := range []string{alice, "@dave:example.org", "@carol:example.org"} {
+		if err := b.SendMessage(context.Background(), protocol.OutboundMessage{Channel: "matrix", PeerID: peer, Text: "hi"}); err != nil {
+			t.Fatalf("SendMessage to %s: %v", peer, err)
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var rooms []string
+	for _, s := range f.sends {
+		rooms = append(rooms, s.room)
+	}
+	if !slices.Equal(rooms, []string{"!inv1:example.org", "!dm2:example.org", "!dm:example.org"}) {
+		t.Errorf("DM rooms = %v", rooms)
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	f := newFakeHomeserver(t)
+	b, _ := newTestBot(t, f, BotConfig{})
+
+	f.limitNext = true
+	err := b.SendMessage(context.Background(), protocol.OutboundMessage{
+		Channel: "matrix", PeerID: "!room:example.org", ThreadID: "$root", Format: "markdown", Text: "**done**",
+	})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	long := strings.Repeat("word ", maxChunkSize/5+10)
+	if err := b.SendMessage(context.Background(), protocol.OutboundMessage{Channel: "matrix", PeerID: "!room:example.org", Format: "text", Text: long}); err != nil {
+		t.Fatalf("SendMessage long: %v", err)
+	}
+	if err := b.SendMessage(context.Background(), protocol.OutboundMessage{Channel: "matrix", PeerID: "@stranger:example.org", Text: "hi"}); err == nil {
+		t.Error("SendMessage to a user without a direct room: no error")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.sends) != 3 {
+		t.Fatalf("sends = %d, want 3", len(f.sends))
+	}
+	c := f.sends[0].content
+	if c.MsgType != "m.text" || c.Body != "**done**" || c.Format != formatHTML || c.FormattedBody != "<p><strong>done</strong></p>" {
+		t.Errorf("content = %+v", c)
+	}
+	if r := c.RelatesTo; r == nil || r.RelType != relThread || r.EventID != "$root" || !r.IsFallingBack ||
+		r.InReplyTo == nil || r.InReplyTo.EventID != "$root" {
+		t.Errorf("relates_to = %+v", c.RelatesTo)
+	}
+	if c.Mentions == nil || len(c.Mentions.UserIDs) != 0 {
+		t.Errorf("mentions = %+v, want empty", c.Mentions)
+	}
+	for _, s := range f.sends[1:] {
+		if s.content.Format != "" || s.content.RelatesTo != nil || len([]rune(s.content.Body)) > maxChunkSize {
+			t.Errorf("chunk = %+v", s.content)
+		}
+	}
+	if f.sends[0].txn == f.sends[1].txn || f.sends[1].txn == f.sends[2].txn {
+		t.Errorf("transaction IDs reused: %s, %s, %s", f.sends[0].txn, f.sends[1].txn, f.sends[2].txn)
+	}
+}
+
+func TestStartBadToken(t *testing.T) {
+	f := newFakeHomeserver(t)
+	b := NewBot(BotConfig{Homeserver: f.server.URL, AccessToken: "wrong", SyncTimeout: time.Second})
+	err := b.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "M_UNKNOWN_TOKEN") {
+		t.Errorf("Start = %v, want M_UNKNOWN_TOKEN", err)
+	}
+}
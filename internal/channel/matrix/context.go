@@ -0,0 +1,260 @@
+package matrix
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// event is a room or account data event.
+type event struct {
+	Type     string          `json:"type"`
+	EventID  string          `json:"event_id"`
+	Sender   string          `json:"sender"`
+	StateKey *string         `json:"state_key"`
+	Content  json.RawMessage `json:"content"`
+	Unsigned struct {
+		PrevContent json.RawMessage `json:"prev_content"`
+	} `json:"unsigned"`
+}
+
+// messageContent is the content of an m.room.message event, received or
+// sent.
+type messageContent struct {
+	MsgType       string     `json:"msgtype"`
+	Body          string     `json:"body"`
+	Format        string     `json:"format,omitempty"`
+	FormattedBody string     `json:"formatted_body,omitempty"`
+	FileName      string     `json:"filename,omitempty"`
+	Info          *fileInfo  `json:"info,omitempty"`
+	Voice         *struct{}  `json:"org.matrix.msc3245.voice,omitempty"`
+	RelatesTo     *relatesTo `json:"m.relates_to,omitempty"`
+	Mentions      *mentions  `json:"m.mentions,omitempty"`
+}
+
+type fileInfo struct {
+	MimeType string `json:"mimetype"`
+}
+
+// relatesTo links an event to another: a thread root, a replaced event
+// for edits, or the event replied to.
+type relatesTo struct {
+	RelType       string     `json:"rel_type,omitempty"`
+	EventID       string     `json:"event_id,omitempty"`
+	InReplyTo     *inReplyTo `json:"m.in_reply_to,omitempty"`
+	IsFallingBack bool       `json:"is_falling_back,omitempty"`
+}
+
+type inReplyTo struct {
+	EventID string `json:"event_id"`
+}
+
+// mentions lists who an event intentionally mentions. Clients that
+// support it notify only those users, so replies carry an empty one.
+type mentions struct {
+	UserIDs []string `json:"user_ids,omitempty"`
+}
+
+// Relation types and formats.
+const (
+	relThread  = "m.thread"
+	relReplace = "m.replace"
+	formatHTML = "org.matrix.custom.html"
+)
+
+// self identifies the bot account.
+type self struct {
+	UserID      string
+	DisplayName string
+}
+
+// messageContext extracts routing information from a Matrix message.
+type messageContext struct {
+	MessageID string
+	RoomID    string
+	UserID    string
+	Text      string
+	PeerKind  string // "user" or "group"
+	PeerID    string
+	GuildID   string
+	ThreadID  string // thread root event
+	ReplyTo   string // event replied to
+	IsMention bool
+	Command   string // command without the prefix, e.g. "reset"
+	Media     []string
+}
+
+// extractContext builds the context of an m.room.message event in a
+// joined room. direct reports whether the room is a direct chat.
+//
+// In a room, PeerID and GuildID are the room and ThreadID is the thread
+// the message is in, if any. In a direct chat, PeerID is the sender.
+func extractContext(roomID string, ev event, me self, direct bool) *messageContext {
+	if ev.Type != "m.room.message" || ev.Sender == "" || ev.Sender == me.UserID {
+		return nil
+	}
+	var c messageContent
+	if err := json.Unmarshal(ev.Content, &c); err != nil {
+		return nil
+	}
+	if c.RelatesTo != nil && c.RelatesTo.RelType == relReplace {
+		return nil // edits
+	}
+
+	mc := &messageContext{
+		MessageID: "matrix:" + roomID + ":" + ev.EventID,
+		RoomID:    roomID,
+		UserID:    ev.Sender,
+	}
+	text := c.Body
+	switch c.MsgType {
+	case "m.text", "m.emote":
+	case "m.image", "m.video", "m.audio", "m.file":
+		mc.Media = []string{mediaType(c)}
+		// The body is the file name, or a caption if the name is separate.
+		if c.FileName == "" || c.FileName == c.Body {
+			text = ""
+		}
+	default:
+		return nil // notices from other bots, locations, verification
+	}
+
+	if r := c.RelatesTo; r != nil {
+		if r.RelType == relThread {
+			mc.ThreadID = r.EventID
+		}
+		if r.InReplyTo != nil {
+			mc.ReplyTo = r.InReplyTo.EventID
+			text = stripReplyFallback(text)
+		}
+	}
+
+	if c.Mentions != nil {
+		mc.IsMention = slices.Contains(c.Mentions.UserIDs, me.UserID)
+	} else {
+		mc.IsMention = strings.Contains(text, me.UserID) ||
+			strings.Contains(c.FormattedBody, "https://matrix.to/#/"+me.UserID) ||
+			me.DisplayName != "" && strings.HasPrefix(text, me.DisplayName)
+	}
+	mc.Text = stripMention(text, me)
+	if mc.Text == "" && len(mc.Media) == 0 {
+		return nil
+	}
+
+	if direct {
+		mc.PeerKind = "user"
+		mc.PeerID = ev.Sender
+	} else {
+		mc.PeerKind = "group"
+		mc.PeerID = roomID
+		mc.GuildID = roomID
+	}
+
+	mc.Command = parseCommand(mc.Text)
+	return mc
+}
+
+// stripReplyFallback removes the quote of the replied-to message that
+// older clients put at the start of a reply's body.
+func stripReplyFallback(body string) string {
+	if !strings.HasPrefix(body, "> ") {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, ">") {
+			return strings.Join(lines[i:], "\n")
+		}
+	}
+	return ""
+}
+
+// stripMention removes mentions of the bot from text: its user ID
+// anywhere, or its display name leading the text as clients insert it
+// ("Dhaavak: hi").
+func stripMention(text string, me self) string {
+	if me.UserID != "" {
+		text = strings.ReplaceAll(text, me.UserID, "")
+	}
+	text = strings.TrimSpace(text)
+	if me.DisplayName != "" && strings.HasPrefix(text, me.DisplayName) {
+		text = strings.TrimLeft(text[len(me.DisplayName):], ":, ")
+	}
+	return strings.TrimSpace(text)
+}
+
+// mediaType returns the attachment type of a file message.
+func mediaType(c messageContent) string {
+	var mime string
+	if c.Info != nil {
+		mime = c.Info.MimeType
+	}
+	switch c.MsgType {
+	case "m.image":
+		if mime == "image/gif" {
+			return "animation"
+		}
+		return "photo"
+	case "m.video":
+		return "video"
+	case "m.audio":
+		if c.Voice != nil {
+			return "voice"
+		}
+		return "audio"
+	default:
+		return "document"
+	}
+}
+
+// parseCommand returns the command at the start of text ("/reset" or
+// "!reset" -> "reset"), or "" if text is not a command. Matrix clients
+// keep "/" for their own commands, so "!" works as well.
+func parseCommand(text string) string {
+	if !strings.HasPrefix(text, "/") && !strings.HasPrefix(text, "!") {
+		return ""
+	}
+	return strings.ToLower(strings.Fields(text)[0][1:])
+}
+
+// checkAccess verifies whether this message should be processed.
+func checkAccess(mc *messageContext, cfg BotConfig) bool {
+	if mc.PeerKind == "user" {
+		switch cfg.DMPolicy {
+		case "open":
+			return true
+		case "allowlist":
+			return slices.Contains(cfg.AllowedUsers, mc.UserID)
+		default:
+			return false
+		}
+	}
+	switch cfg.GroupPolicy {
+	case "all", "mention":
+	default:
+		return false
+	}
+	if len(cfg.AllowedRooms) > 0 && !slices.Contains(cfg.AllowedRooms, mc.RoomID) {
+		return false
+	}
+	return cfg.GroupPolicy != "mention" || mc.IsMention
+}
+
+// toInboundMessage converts a message context to a protocol message.
+func toInboundMessage(mc *messageContext) protocol.InboundMessage {
+	return protocol.InboundMessage{
+		MessageID: mc.MessageID,
+		Channel:   "matrix",
+		PeerKind:  mc.PeerKind,
+		PeerID:    mc.PeerID,
+		GuildID:   mc.GuildID,
+		ThreadID:  mc.ThreadID,
+		SenderID:  mc.UserID,
+		Text:      mc.Text,
+		Command:   mc.Command,
+
+		Attachments: mc.Media,
+	}
+}
@@ -0,0 +1,89 @@
+package matrix
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExtractContext(t *testing.T) {
+	me := self{UserID: botID, DisplayName: "Dhaavak"}
+	tests := []struct {
+		name      string
+		content   string
+		direct    bool
+		nilResult bool
+		text      string
+		mention   bool
+		thread    string
+		replyTo   string
+		media     string
+	}{
+		{name: "plain", content: `{"msgtype":"m.text","body":"hello"}`, text: "hello"},
+		{name: "intentional mention", content: `{"msgtype":"m.text","body":"Dhaavak: hi","m.mentions":{"user_ids":["@bot:example.org"]}}`, text: "hi", mention: true},
+		{name: "mentions someone else", content: `{"msgtype":"m.text","body":"Dhaavak is great","m.mentions":{"user_ids":["@x:example.org"]}}`, text: "is great"},
+		{name: "legacy pill", content: `{"msgtype":"m.text","body":"Dhaavak, hi","formatted_body":"<a href=\"https://matrix.to/#/@bot:example.org\">Dhaavak</a>, hi"}`, text: "hi", mention: true},
+		{name: "user ID in body", content: `{"msgtype":"m.text","body":"ping @bot:example.org now"}`, text: "ping  now", mention: true},
+		{name: "emote", content: `{"msgtype":"m.emote","body":"waves"}`, text: "waves"},
+		{name: "thread", content: `{"msgtype":"m.text","body":"more","m.relates_to":{"rel_type":"m.thread","event_id":"$root","is_falling_back":true,"m.in_reply_to":{"event_id":"$last"}}}`, text: "more", thread: "$root", replyTo: "$last"},
+		{name: "reply fallback", content: `{"msgtype":"m.text","body":"> <@a:x> q\n> more\n\nanswer","m.relates_to":{"m.in_reply_to":{"event_id":"$q"}}}`, text: "answer", replyTo: "$q"},
+		{name: "image with caption", content: `{"msgtype":"m.image","body":"look","filename":"a.png","info":{"mimetype":"image/png"}}`, text: "look", media: "photo"},
+		{name: "file without caption", content: `{"msgtype":"m.file","body":"report.pdf"}`, media: "document"},
+		{name: "voice", content: `{"msgtype":"m.audio","body":"voice.ogg","org.matrix.msc3245.voice":{}}`, media: "voice"},
+		{name: "notice", content: `{"msgtype":"m.notice","body":"beep"}`, nilResult: true},
+		{name: "edit", content: `{"msgtype":"m.text","body":"* x","m.relates_to":{"rel_type":"m.replace","event_id":"$e"}}`, nilResult: true},
+		{name: "empty", content: `{"msgtype":"m.text","body":"  "}`, nilResult: true},
+		{name: "direct", content: `{"msgtype":"m.text","body":"/new"}`, direct: true, text: "/new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := event{Type: "m.room.message", EventID: "$e1", Sender: "@alice:example.org", Content: json.RawMessage(tt.content)}
+			mc := extractContext("!r:example.org", ev, me, tt.direct)
+			if tt.nilResult {
+				if mc != nil {
+					t.Errorf("context = %+v, want nil", mc)
+				}
+				return
+			}
+			if mc == nil {
+				t.Fatal("context = nil")
+			}
+			if mc.Text != tt.text || mc.IsMention != tt.mention || mc.ThreadID != tt.thread || mc.ReplyTo != tt.replyTo {
+				t.Errorf("context = %+v", mc)
+			}
+			if tt.media != "" && (len(mc.Media) != 1 || mc.Media[0] != tt.media) {
+				t.Errorf("media = %v, want %s", mc.Media, tt.media)
+			}
+			if tt.direct && (mc.PeerKind != "user" || mc.PeerID != "@alice:example.org" || mc.GuildID != "" || mc.Command != "new") {
+				t.Errorf("direct context = %+v", mc)
+			}
+			if !tt.direct && (mc.PeerKind != "group" || mc.PeerID != "!r:example.org" || mc.GuildID != "!r:example.org") {
+				t.Errorf("room context = %+v", mc)
+			}
+		})
+	}
+
+	own := event{Type: "m.room.message", Sender: botID, Content: json.RawMessage(`{"msgtype":"m.text","body":"x"}`)}
+	if mc := extractContext("!r:example.org", own, me, false); mc != nil {
+		t.Errorf("own message context = %+v, want nil", mc)
+	}
+}
+
+func TestAllowInvite(t *testing.T) {
+	cfg := BotConfig{AllowedUsers: []string{"@alice:example.org"}, AllowedRooms: []string{"!ok:example.org"}}
+	tests := []struct {
+		policy, room, inviter string
+		want                  bool
+	}{
+		{"open", "!any:x", "@eve:x", true},
+		{"allowlist", "!any:x", "@alice:example.org", true},
+		{"allowlist", "!ok:example.org", "@eve:x", true},
+		{"allowlist", "!any:x", "@eve:x", false},
+		{"disabled", "!ok:example.org", "@alice:example.org", false},
+	}
+	for _, tt := range tests {
+		cfg.InvitePolicy = tt.policy
+		if got := allowInvite(cfg, tt.room, tt.inviter); got != tt.want {
+			t.Errorf("allowInvite(%s, %s, %s) = %v, want %v", tt.policy, tt.room, tt.inviter, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package matrix
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/harshadpatil/dhaavak/internal/channel"
+)
+
+// ownLimit is how many event IDs markOwn remembers per generation.
+const ownLimit = 1000
+
+// dispatch processes a timeline event of a joined room.
+func (b *Bot) dispatch(ctx context.Context, roomID string, ev event) {
+	mc := extractContext(roomID, ev, self{UserID: b.userID, DisplayName: b.displayName}, b.isDirect(roomID))
+	if mc == nil {
+		return
+	}
+	// Replying to the bot, or in a thread it started, addresses it too.
+	if b.isOwn(mc.ReplyTo) || b.isOwn(mc.ThreadID) {
+		mc.IsMention = true
+	}
+
+	if !checkAccess(mc, b.cfg) {
+		slog.Debug("matrix access denied", "user", mc.UserID, "room", mc.RoomID)
+		return
+	}
+
+	if mc.PeerKind == "user" {
+		b.mu.Lock()
+		b.dms[mc.UserID] = roomID
+		b.mu.Unlock()
+	}
+
+	msg := toInboundMessage(mc)
+
+	if b.sink != nil {
+		if err := b.sink(ctx, msg); err != nil {
+			slog.Error("matrix message sink error", "err", err)
+		}
+	}
+}
+
+// isDirect reports whether a room is a direct chat: marked as one, or
+// with only the bot and one other member.
+func (b *Bot) isDirect(roomID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rm := b.rooms[roomID]
+	return rm != nil && (rm.direct || rm.members == 2)
+}
+
+// markOwn records an event sent by the bot. IDs are kept for two
+// generations of ownLimit events.
+func (b *Bot) markOwn(eventID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.own) >= ownLimit {
+		b.old, b.own = b.own, make(map[string]bool)
+	}
+	b.own[eventID] = true
+}
+
+// isOwn reports whether an event was recently sent by the bot.
+func (b *Bot) isOwn(eventID string) bool {
+	if eventID == "" {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.own[eventID] || b.old[eventID]
+}
+
+// SetSink sets the message sink callback.
+func (b *Bot) SetSink(sink channel.MessageSink) {
+	b.sink = sink
+}
@@ -0,0 +1,130 @@
+package matrix
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// maxChunkSize is the longest message text sent in one event, in
+// characters. It keeps the body and its HTML well under the 64 KiB limit
+// of a Matrix event.
+const maxChunkSize = 6000
+
+// fence opens and closes a code block.
+const fence = "```"
+
+var (
+	headingRe = regexp.MustCompile(`^(#{1,6})\s+(.+)$`)
+	bulletRe  = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	orderedRe = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+	codeRe    = regexp.MustCompile("`([^`]+)`")
+	linkRe    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldRe    = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	italicRe  = regexp.MustCompile(`\*([^*\s](?:[^*]*[^*\s])?)\*`)
+	strikeRe  = regexp.MustCompile(`~~(.+?)~~`)
+	tagRe     = regexp.MustCompile(`<[^>]*>`)
+	breakRe   = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</li>|</h[1-6]>|</pre>|</blockquote>`)
+)
+
+// markdownToHTML converts the markdown agents write to the HTML subset
+// Matrix clients render: paragraphs, headings, lists, quotes, code and
+// inline emphasis. Everything else is escaped.
+func markdownToHTML(md string) string {
+	var out strings.Builder
+	block := "" // open block element: "p", "ul", "ol" or "blockquote"
+	open := func(kind string) {
+		if block == kind {
+			if kind == "p" || kind == "blockquote" {
+				out.WriteString("<br>")
+			}
+			return
+		}
+		if block != "" {
+			out.WriteString("</" + block + ">")
+		}
+		if kind != "" {
+			out.WriteString("<" + kind + ">")
+		}
+		block = kind
+	}
+
+	lines := strings.Split(md, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case strings.HasPrefix(line, fence):
+			open("")
+			lang := strings.TrimSpace(line[len(fence):])
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			if lang != "" {
+				out.WriteString(`<pre><code class="language-` + html.EscapeString(lang) + `">`)
+			} else {
+				out.WriteString("<pre><code>")
+			}
+			for _, c := range code {
+				out.WriteString(html.EscapeString(c) + "\n")
+			}
+			out.WriteString("</code></pre>")
+		case line == "":
+			open("")
+		case headingRe.MatchString(line):
+			open("")
+			m := headingRe.FindStringSubmatch(line)
+			fmt.Fprintf(&out, "<h%d>%s</h%d>", len(m[1]), formatInline(m[2]), len(m[1]))
+		case bulletRe.MatchString(line):
+			open("ul")
+			out.WriteString("<li>" + formatInline(bulletRe.FindStringSubmatch(line)[1]) + "</li>")
+		case orderedRe.MatchString(line):
+			open("ol")
+			out.WriteString("<li>" + formatInline(orderedRe.FindStringSubmatch(line)[1]) + "</li>")
+		case strings.HasPrefix(line, ">"):
+			open("blockquote")
+			out.WriteString(formatInline(strings.TrimSpace(line[1:])))
+		default:
+			open("p")
+			out.WriteString(formatInline(line))
+		}
+	}
+	open("")
+	return out.String()
+}
+
+// formatInline escapes a line and converts inline markdown. Code spans
+// are escaped but not formatted.
+func formatInline(s string) string {
+	var out strings.Builder
+	for {
+		loc := codeRe.FindStringSubmatchIndex(s)
+		if loc == nil {
+			out.WriteString(formatText(s))
+			return out.String()
+		}
+		out.WriteString(formatText(s[:loc[0]]))
+		out.WriteString("<code>" + html.EscapeString(s[loc[2]:loc[3]]) + "</code>")
+		s = s[loc[1]:]
+	}
+}
+
+func formatText(s string) string {
+	s = html.EscapeString(s)
+	s = linkRe.ReplaceAllString(s, `<a href="$2">$1</a>`)
+	// Bold is marked with \x00 until italics are converted, since both
+	// use asterisks.
+	s = boldRe.ReplaceAllString(s, "\x00$1$2\x01")
+	s = italicRe.ReplaceAllString(s, "<em>$1</em>")
+	s = strikeRe.ReplaceAllString(s, "<del>$1</del>")
+	return strings.NewReplacer("\x00", "<strong>", "\x01", "</strong>").Replace(s)
+}
+
+// htmlToText returns the plain text of HTML, for the body that clients
+// without HTML support show.
+func htmlToText(s string) string {
+	s = breakRe.ReplaceAllString(s, "$0\n")
+	s = tagRe.ReplaceAllString(s, "")
+	return strings.TrimSpace(html.UnescapeString(s))
+}
@@ -0,0 +1,38 @@
+package matrix
+
+import "testing"
+
+func TestMarkdownToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"paragraphs", "one\ntwo\n\nthree", "<p>one<br>two</p><p>three</p>"},
+		{"inline", "**bold**, *italic*, ~~gone~~ and [docs](https://example.com/?a=1&b=2)",
+			`<p><strong>bold</strong>, <em>italic</em>, <del>gone</del> and <a href="https://example.com/?a=1&amp;b=2">docs</a></p>`},
+		{"escaping", "a < b && <script>", "<p>a &lt; b &amp;&amp; &lt;script&gt;</p>"},
+		{"inline code", "run `**x** <y>` now", "<p>run <code>**x** &lt;y&gt;</code> now</p>"},
+		{"heading", "## Status\ntext", "<h2>Status</h2><p>text</p>"},
+		{"lists", "- one\n* two\n1. first\n2. second", "<ul><li>one</li><li>two</li></ul><ol><li>first</li><li>second</li></ol>"},
+		{"quote", "> a\n> b", "<blockquote>a<br>b</blockquote>"},
+		{"code block", "intro\n```go\nif a < b {\n\t**x**\n}\n```\n**y**",
+			"<p>intro</p><pre><code class=\"language-go\">if a &lt; b {\n\t**x**\n}\n</code></pre><p><strong>y</strong></p>"},
+		{"unclosed code block", "```\nx", "<pre><code>x\n</code></pre>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownToHTML(tt.in); got != tt.want {
+				t.Errorf("markdownToHTML(%q) =\n%q\nwant\n%q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	in := "<p>Hello <b>world</b> &amp; co</p><ul><li>one</li><li>two</li></ul>line<br>break"
+	want := "Hello world & co\none\ntwo\nline\nbreak"
+	if got := htmlToText(in); got != want {
+		t.Errorf("htmlToText(%q) = %q, want %q", in, got, want)
+	}
+}
@@ -0,0 +1,236 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// syncFilter limits /sync to what the adapter uses: messages, members,
+// invites and the m.direct list of direct chats.
+const syncFilter = `{"presence":{"types":[]},"account_data":{"types":["m.direct"]},` +
+	`"room":{"timeline":{"types":["m.room.message","m.room.member"],"limit":50},` +
+	`"state":{"types":["m.room.member"],"lazy_load_members":true},` +
+	`"ephemeral":{"types":[]},"account_data":{"types":[]}}}`
+
+// syncResponse is the part of a /sync reply the adapter reads.
+type syncResponse struct {
+	NextBatch   string `json:"next_batch"`
+	AccountData struct {
+		Events []event `json:"events"`
+	} `json:"account_data"`
+	Rooms struct {
+		Join   map[string]joinedRoom  `json:"join"`
+		Invite map[string]invitedRoom `json:"invite"`
+		Leave  map[string]struct{}    `json:"leave"`
+	} `json:"rooms"`
+}
+
+type joinedRoom struct {
+	Summary struct {
+		JoinedMemberCount *int `json:"m.joined_member_count"`
+	} `json:"summary"`
+	Timeline struct {
+		Events []event `json:"events"`
+	} `json:"timeline"`
+}
+
+type invitedRoom struct {
+	InviteState struct {
+		Events []event `json:"events"`
+	} `json:"invite_state"`
+}
+
+// runSync long-polls /sync until ctx is done. The first sync only
+// catches up on rooms and invites: messages sent before the start are
+// not answered.
+func (b *Bot) runSync(ctx context.Context) {
+	since := ""
+	backoff := time.Second
+	for {
+		q := url.Values{"filter": {syncFilter}}
+		if since != "" {
+			q.Set("since", since)
+			q.Set("timeout", strconv.FormatInt(b.cfg.SyncTimeout.Milliseconds(), 10))
+		}
+		var resp syncResponse
+		err := b.api.call(ctx, "GET", "/sync?"+q.Encode(), nil, &resp)
+		if ctx.Err() != nil {
+			return
+		}
+		var apiErr *apiError
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized {
+			slog.Error("matrix sync stopped", "err", err)
+			return
+		}
+		if err != nil {
+			slog.Warn("matrix sync failed", "err", err, "retry_in", backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, time.Minute)
+			continue
+		}
+		backoff = time.Second
+
+		b.handleSync(ctx, &resp, since == "")
+		since = resp.NextBatch
+	}
+}
+
+// handleSync applies a /sync reply. Messages are dispatched unless this
+// is the initial sync.
+func (b *Bot) handleSync(ctx context.Context, resp *syncResponse, initial bool) {
+	for _, ev := range resp.AccountData.Events {
+		if ev.Type == "m.direct" {
+			b.updateDirect(ev.Content)
+		}
+	}
+
+	b.mu.Lock()
+	for id := range resp.Rooms.Leave {
+		delete(b.rooms, id)
+	}
+	for id, r := range resp.Rooms.Join {
+		rm := b.rooms[id]
+		if rm == nil {
+			rm = &room{}
+			b.rooms[id] = rm
+		}
+		if n := r.Summary.JoinedMemberCount; n != nil {
+			rm.members = *n
+		}
+	}
+	b.mu.Unlock()
+
+	for id, r := range resp.Rooms.Invite {
+		b.handleInvite(ctx, id, r)
+	}
+
+	for id, r := range resp.Rooms.Join {
+		events := r.Timeline.Events
+		// Messages from before the bot joined are room history.
+		if i := joinIndex(events, b.userID); i >= 0 {
+			events = events[i+1:]
+		}
+		for _, ev := range events {
+			if ev.Sender == b.userID {
+				b.markOwn(ev.EventID)
+				continue
+			}
+			if !initial {
+				b.dispatch(ctx, id, ev)
+			}
+		}
+	}
+}
+
+// joinIndex returns the index of the bot's own join in a timeline, or -1.
+// Profile changes are member events too, so only a membership that was
+// not "join" before counts.
+func joinIndex(events []event, userID string) int {
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := events[i]
+		if ev.Type != "m.room.member" || ev.StateKey == nil || *ev.StateKey != userID {
+			continue
+		}
+		var member, prev struct {
+			Membership string `json:"membership"`
+		}
+		json.Unmarshal(ev.Content, &member)
+		json.Unmarshal(ev.Unsigned.PrevContent, &prev)
+		if member.Membership == "join" && prev.Membership != "join" {
+			return i
+		}
+	}
+	return -1
+}
+
+// updateDirect records the direct chats listed in m.direct account data,
+// a map of user IDs to room IDs.
+func (b *Bot) updateDirect(content json.RawMessage) {
+	var direct map[string][]string
+	if err := json.Unmarshal(content, &direct); err != nil {
+		slog.Warn("matrix: bad m.direct", "err", err)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for userID, rooms := range direct {
+		for _, id := range rooms {
+			rm := b.rooms[id]
+			if rm == nil {
+				rm = &room{}
+				b.rooms[id] = rm
+			}
+			rm.direct = true
+			b.dms[userID] = id
+		}
+	}
+}
+
+// handleInvite joins a room the bot was invited to if the invite policy
+// allows it. Other invites are left pending, to be accepted or declined
+// by hand.
+func (b *Bot) handleInvite(ctx context.Context, roomID string, r invitedRoom) {
+	var inviter string
+	var direct bool
+	for _, ev := range r.InviteState.Events {
+		if ev.Type != "m.room.member" || ev.StateKey == nil || *ev.StateKey != b.userID {
+			continue
+		}
+		var member struct {
+			Membership string `json:"membership"`
+			IsDirect   bool   `json:"is_direct"`
+		}
+		if json.Unmarshal(ev.Content, &member) == nil && member.Membership == "invite" {
+			inviter, direct = ev.Sender, member.IsDirect
+		}
+	}
+	if inviter == "" {
+		return
+	}
+	if !allowInvite(b.cfg, roomID, inviter) {
+		slog.Info("matrix invite ignored", "room", roomID, "inviter", inviter)
+		return
+	}
+
+	if err := b.api.call(ctx, "POST", "/join/"+url.PathEscape(roomID), struct{}{}, nil); err != nil {
+		slog.Warn("matrix join failed", "room", roomID, "err", err)
+		return
+	}
+	slog.Info("matrix room joined", "room", roomID, "inviter", inviter, "direct", direct)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rm := b.rooms[roomID]
+	if rm == nil {
+		rm = &room{}
+		b.rooms[roomID] = rm
+	}
+	if direct {
+		rm.direct = true
+		b.dms[inviter] = roomID
+	}
+}
+
+// allowInvite reports whether the invite policy accepts an invite.
+// With "allowlist", the inviter or the room must be allowed.
+func allowInvite(cfg BotConfig, roomID, inviter string) bool {
+	switch cfg.InvitePolicy {
+	case "open":
+		return true
+	case "allowlist":
+		return slices.Contains(cfg.AllowedUsers, inviter) || slices.Contains(cfg.AllowedRooms, roomID)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,37 @@
+package matrix
+
+import (
+	"time"
+
+	"github.com/harshadpatil/dhaavak/internal/channel"
+	"github.com/harshadpatil/dhaavak/internal/config"
+)
+
+// BotConfig holds Matrix adapter configuration.
+type BotConfig struct {
+	Homeserver   string
+	AccessToken  string
+	SyncTimeout  time.Duration
+	DMPolicy     string
+	GroupPolicy  string
+	InvitePolicy string
+	AllowedUsers []string
+	AllowedRooms []string
+}
+
+// ConfigFromApp extracts Matrix config from the app config.
+func ConfigFromApp(cfg config.MatrixConfig) BotConfig {
+	return BotConfig{
+		Homeserver:   cfg.Homeserver,
+		AccessToken:  cfg.AccessToken,
+		SyncTimeout:  cfg.SyncTimeout,
+		DMPolicy:     cfg.DMPolicy,
+		GroupPolicy:  cfg.GroupPolicy,
+		InvitePolicy: cfg.InvitePolicy,
+		AllowedUsers: cfg.AllowedUsers,
+		AllowedRooms: cfg.AllowedRooms,
+	}
+}
+
+// ensure Bot implements Adapter.
+var _ channel.Adapter = (*Bot)(nil)
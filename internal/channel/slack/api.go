@@ -0,0 +1,122 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRetries bounds how often a rate-limited Web API call is retried.
+const maxRetries = 3
+
+// apiClient is a minimal Slack Web API client for the few methods the
+// adapter uses.
+type apiClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+func newAPIClient(baseURL string) *apiClient {
+	return &apiClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// authTestResponse is the reply of auth.test.
+type authTestResponse struct {
+	UserID string `json:"user_id"`
+	BotID  string `json:"bot_id"`
+	TeamID string `json:"team_id"`
+	Team   string `json:"team"`
+}
+
+// postMessage is the body of chat.postMessage.
+type postMessage struct {
+	Channel  string `json:"channel"`
+	Text     string `json:"text"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+	Mrkdwn   bool   `json:"mrkdwn"`
+}
+
+// connectionsOpenResponse is the reply of apps.connections.open.
+type connectionsOpenResponse struct {
+	URL string `json:"url"`
+}
+
+// call invokes a Web API method with a JSON body and decodes the reply into
+// out. Replies with "ok": false are returned as errors; HTTP 429 replies are
+// retried after the Retry-After delay.
+func (c *apiClient) call(ctx context.Context, method, token string, params, out any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("slack %s: %w", method, err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/"+method, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("slack %s: %w", method, err)
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return fmt.Errorf("slack %s: %w", method, err)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("slack %s: %w", method, err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRetries {
+			wait := retryAfter(resp.Header.Get("Retry-After"))
+			slog.Warn("slack rate limited", "method", method, "retry_after", wait)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("slack %s: HTTP %d", method, resp.StatusCode)
+		}
+
+		var status struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(data, &status); err != nil {
+			return fmt.Errorf("slack %s: %w", method, err)
+		}
+		if !status.OK {
+			return fmt.Errorf("slack %s: %s", method, status.Error)
+		}
+		if out != nil {
+			if err := json.Unmarshal(data, out); err != nil {
+				return fmt.Errorf("slack %s: %w", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+// retryAfter parses a Retry-After header in seconds, defaulting to one
+// when it is missing.
+func retryAfter(v string) time.Duration {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		n = 1
+	}
+	return time.Duration(n) * time.Second
+}
@@ -0,0 +1,87 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/harshadpatil/dhaavak/internal/channel"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// Bot is the Slack adapter.
+type Bot struct {
+	cfg    BotConfig
+	api    *apiClient
+	sink   channel.MessageSink
+	accept channel.MessageAcceptor
+	inbox  chan protocol.InboundMessage // accepted messages waiting for the sink
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	userID string // the bot user, from auth.test
+
+	mu   sync.Mutex
+	seen map[string]bool // channel:ts of recent messages, see markSeen
+	old  map[string]bool
+}
+
+// NewBot creates a Slack bot adapter. The bot token is checked in Start.
+func NewBot(cfg BotConfig) *Bot {
+	return &Bot{
+		cfg:   cfg,
+		api:   newAPIClient(cfg.APIURL),
+		inbox: make(chan protocol.InboundMessage, inboxSize),
+		seen:  make(map[string]bool),
+	}
+}
+
+func (b *Bot) ID() string { return "slack" }
+
+// Start authenticates the bot token and, in socket mode, connects to Slack.
+// In events mode, events arrive through the handler returned by
+// EventsHandler.
+func (b *Bot) Start(ctx context.Context) error {
+	var auth authTestResponse
+	if err := b.api.call(ctx, "auth.test", b.cfg.BotToken, struct{}{}, &auth); err != nil {
+		return fmt.Errorf("slack bot init: %w", err)
+	}
+	b.userID = auth.UserID
+	slog.Info("slack bot authorized", "user", auth.UserID, "team", auth.Team)
+
+	b.ctx, b.cancel = context.WithCancel(ctx)
+	go b.deliver(b.ctx)
+	if b.cfg.Mode == "socket" {
+		go b.runSocket(b.ctx)
+		slog.Info("slack socket mode started")
+	}
+	return nil
+}
+
+func (b *Bot) Stop(_ context.Context) error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	slog.Info("slack bot stopped")
+	return nil
+}
+
+// SendMessage posts a reply with chat.postMessage. PeerID is the channel
+// or, for direct messages, the user; ThreadID is the parent's thread_ts.
+func (b *Bot) SendMessage(ctx context.Context, msg protocol.OutboundMessage) error {
+	text := msg.Text
+	if msg.Format == "markdown" {
+		text = markdownToMrkdwn(text)
+	} else {
+		text = escapeText(text)
+	}
+
+	for _, chunk := range channel.ChunkText(text, maxChunkSize) {
+		req := postMessage{Channel: msg.PeerID, Text: chunk, ThreadTS: msg.ThreadID, Mrkdwn: true}
+		if err := b.api.call(ctx, "chat.postMessage", b.cfg.BotToken, req, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
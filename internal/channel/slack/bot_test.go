@@ -0,0 +1,379 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/harshadpatil/dhaavak/internal/channel"
+	"github.com/harshadpatil/dhaavak/internal/channel/channeltest"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// fakeSlack is a local stand-in for the Slack Web API and Socket Mode.
+type fakeSlack struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu        sync.Mutex
+	posts     []postMessage
+	limitNext bool            // answer the next chat.postMessage with 429
+	envelopes []string        // sent to Socket Mode connections after hello
+	acks      chan string     // envelope IDs acknowledged by the bot
+	tokens    map[string]bool // tokens seen per method, "method token"
+}
+
+func newFakeSlack(t *testing.T) *fakeSlack {
+	f := &fakeSlack{t: t, acks: make(chan string, 10), tokens: make(map[string]bool)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth.test", func(w http.ResponseWriter, r *http.Request) {
+		f.record("auth.test", r)
+		f.reply(w, map[string]any{"ok": true, "user_id": "UBOT", "bot_id": "BBOT", "team_id": "T1", "team": "acme"})
+	})
+	mux.HandleFunc("/api/chat.postMessage", func(w http.ResponseWriter, r *http.Request) {
+		f.record("chat.postMessage", r)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.limitNext {
+			f.limitNext = false
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		var p postMessage
+		json.NewDecoder(r.Body).Decode(&p)
+		f.posts = append(f.posts, p)
+		f.reply(w, map[string]any{"ok": true, "ts": "2.0"})
+	})
+	mux.HandleFunc("/api/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		f.record("apps.connections.open", r)
+		f.reply(w, map[string]any{"ok": true, "url": "ws" + strings.TrimPrefix(f.server.URL, "http") + "/socket"})
+	})
+	mux.HandleFunc("/socket", f.serveSocket)
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeSlack) record(method string, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens[method+" "+strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] = true
+}
+
+func (f *fakeSlack) reply(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeSlack) serveSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+	ctx := r.Context()
+	conn.Write(ctx, websocket.MessageText, []byte(`{"type":"hello"}`))
+	f.mu.Lock()
+	envelopes := f.envelopes
+	f.mu.Unlock()
+	for _, env := range envelopes {
+		conn.Write(ctx, websocket.MessageText, []byte(env))
+	}
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		var ack struct {
+			EnvelopeID string `json:"envelope_id"`
+		}
+		json.Unmarshal(data, &ack)
+		f.acks <- ack.EnvelopeID
+	}
+}
+
+func (f *fakeSlack) postsSnapshot() []postMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]postMessage(nil), f.posts...)
+}
+
+// newTestBot starts a bot against the fake whose sink sends to the
+// returned channel. setup, if given, runs before the bot starts.
+func newTestBot(t *testing.T, f *fakeSlack, cfg BotConfig, setup ...func(*Bot)) (*Bot, *channeltest.Sink) {
+	t.Helper()
+	cfg.BotToken, cfg.APIURL = "xoxb-test", f.server.URL+"/api"
+	if cfg.DMPolicy == "" {
+		cfg.DMPolicy = "open"
+	}
+	if cfg.GroupPolicy == "" {
+		cfg.GroupPolicy = "mention"
+	}
+	b := NewBot(cfg)
+	got := channeltest.NewSink()
+	b.SetSink(got.Func())
+	for _, fn := range setup {
+		fn(b)
+	}
+	channeltest.Start(t, b)
+	return b, got
+}
+
+func TestEventsAPI(t *testing.T) {
+	f := newFakeSlack(t)
+	b, got := newTestBot(t, f, BotConfig{Mode: "events", SigningSecret: "shh", ReplyInThread: true})
+	h := b.EventsHandler()
+
+	post := func(body, contentType string, signed bool) *httptest.ResponseRecorder {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req := httptest.NewRequest(http.MethodPost, "/slack/events", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-Slack-Request-Timestamp", ts)
+		sig := sign("shh", ts, []byte(body))
+		if !signed {
+			sig = sign("wrong", ts, []byte(body))
+		}
+		req.Header.Set("X-Slack-Signature", sig)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(`{"type":"url_verification","challenge":"abc"}`, "application/json", true)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"challenge":"abc"`) {
+		t.Errorf("url_verification = %d %q", rec.Code, rec.Body.String())
+	}
+
+	mention := `{"type":"event_callback","team_id":"T1","event":{"type":"app_mention","user":"U1",` +
+		`"text":"<@UBOT> deploy &lt;prod&gt;","channel":"C1","ts":"100.1"}}`
+	if rec := post(mention, "application/json", false); rec.Code != http.StatusUnauthorized {
+		t.Errorf("bad signature = %d, want 401", rec.Code)
+	}
+	if rec := post(mention, "application/json", true); rec.Code != http.StatusOK {
+		t.Fatalf("event_callback = %d", rec.Code)
+	}
+	msg := got.Receive(t)
+	want := protocol.InboundMessage{
+		MessageID: "slack:C1:100.1",
+		Channel:   "slack",
+		PeerKind:  "group",
+		PeerID:    "C1",
+		GuildID:   "C1",
+		TeamID:    "T1",
+		ThreadID:  "100.1",
+		SenderID:  "U1",
+		Text:      "deploy <prod>",
+	}
+	if msg.MessageID != want.MessageID || msg.PeerKind != want.PeerKind || msg.GuildID != want.GuildID ||
+		msg.TeamID != want.TeamID || msg.ThreadID != want.ThreadID || msg.SenderID != want.SenderID || msg.Text != want.Text {
+		t.Errorf("message = %+v, want %+v", msg, want)
+	}
+
+	// The message event for the same mention is a duplicate; a channel
+	// message without a mention is ignored under the mention policy.
+	post(`{"type":"event_callback","team_id":"T1","event":{"type":"message","channel_type":"channel","user":"U1",`+
+		`"text":"<@UBOT> deploy &lt;prod&gt;","channel":"C1","ts":"100.1"}}`, "application/json", true)
+	post(`{"type":"event_callback","team_id":"T1","event":{"type":"message","channel_type":"channel","user":"U1",`+
+		`"text":"just chatting","channel":"C1","ts":"100.2"}}`, "application/json", true)
+	post(`{"type":"event_callback","team_id":"T1","event":{"type":"message","channel_type":"im","user":"U2",`+
+		`"text":"hi","channel":"D9","ts":"100.3","thread_ts":"99.0"}}`, "application/json", true)
+	msg = got.Receive(t)
+	if msg.MessageID != "slack:D9:100.3" || msg.PeerKind != "user" || msg.PeerID != "U2" || msg.ThreadID != "99.0" {
+		t.Errorf("dm = %+v", msg)
+	}
+
+	rec = post("command=%2Freset&text=now&user_id=U3&channel_id=C2&team_id=T1&trigger_id=tr1",
+		"application/x-www-form-urlencoded", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("slash command = %d", rec.Code)
+	}
+	msg = got.Receive(t)
+	if msg.Command != "reset" || msg.Text != "/reset now" || msg.PeerID != "C2" || msg.SenderID != "U3" {
+		t.Errorf("slash command = %+v", msg)
+	}
+	got.None(t, 50*time.Millisecond)
+}
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	body := []byte("payload")
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		ok        bool
+	}{
+		{"valid", ts, sign("secret", ts, body), true},
+		{"wrong secret", ts, sign("other", ts, body), false},
+		{"missing", "", "", false},
+		{"stale", "1699999000", sign("secret", "1699999000", body), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature("secret", tt.timestamp, tt.signature, body, now)
+			if (err == nil) != tt.ok {
+				t.Errorf("verifySignature() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSocketMode(t *testing.T) {
+	f := newFakeSlack(t)
+	f.envelopes = []string{
+		`{"envelope_id":"e1","type":"events_api","payload":{"type":"event_callback","team_id":"T1",` +
+			`"event":{"type":"message","channel_type":"im","user":"U1","text":"hello","channel":"D1","ts":"1.0"}}}`,
+		`{"envelope_id":"e2","type":"slash_commands","payload":{"command":"/status","text":"",` +
+			`"user_id":"U1","channel_id":"D1","team_id":"T1","trigger_id":"tr2"}}`,
+	}
+	_, got := newTestBot(t, f, BotConfig{Mode: "socket", AppToken: "xapp-test"})
+
+	for _, want := range []string{"e1", "e2"} {
+		select {
+		case id := <-f.acks:
+			if id != want {
+				t.Errorf("ack = %q, want %q", id, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("envelope %s not acknowledged", want)
+		}
+	}
+	if msg := got.Receive(t); msg.Text != "hello" || msg.PeerID != "U1" || msg.TeamID != "T1" {
+		t.Errorf("event = %+v", msg)
+	}
+	if msg := got.Receive(t); msg.Command != "status" || msg.PeerKind != "user" {
+		t.Errorf("command = %+v", msg)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.tokens["apps.connections.open xapp-test"] || !f.tokens["auth.test xoxb-test"] {
+		t.Errorf("tokens used = %v", f.tokens)
+	}
+}
+
+// failFirst is an acceptor that refuses the first message it sees.
+func failFirst() channel.MessageAcceptor {
+	var calls atomic.Int32
+	return func(ctx context.Context, msg protocol.InboundMessage) (bool, error) {
+		if calls.Add(1) == 1 {
+			return false, errors.New("journal unavailable")
+		}
+		return true, nil
+	}
+}
+
+func TestEventNotAcceptedIsRetried(t *testing.T) {
+	f := newFakeSlack(t)
+	b, got := newTestBot(t, f, BotConfig{Mode: "events", SigningSecret: "shh"}, func(b *Bot) { b.SetAccept(failFirst()) })
+	h := b.EventsHandler()
+
+	body := `{"type":"event_callback","team_id":"T1","event":{"type":"message","channel_type":"im","user":"U1",` +
+		`"text":"hello","channel":"D1","ts":"5.0"}}`
+	post := func() int {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req := httptest.NewRequest(http.MethodPost, "/slack/events", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Slack-Request-Timestamp", ts)
+		req.Header.Set("X-Slack-Signature", sign("shh", ts, []byte(body)))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post(); code != http.StatusServiceUnavailable {
+		t.Fatalf("refused event = %d, want 503", code)
+	}
+	got.None(t, 50*time.Millisecond)
+
+	// Slack's retry of the same event goes through.
+	if code := post(); code != http.StatusOK {
+		t.Fatalf("retried event = %d, want 200", code)
+	}
+	if msg := got.Receive(t); msg.MessageID != "slack:D1:5.0" {
+		t.Errorf("message = %+v", msg)
+	}
+}
+
+func TestSocketModeAcksOnlyAccepted(t *testing.T) {
+	f := newFakeSlack(t)
+	f.envelopes = []string{
+		`{"envelope_id":"e1","type":"events_api","payload":{"type":"event_callback","team_id":"T1",` +
+			`"event":{"type":"message","channel_type":"im","user":"U1","text":"lost","channel":"D1","ts":"1.0"}}}`,
+		`{"envelope_id":"e2","type":"events_api","payload":{"type":"event_callback","team_id":"T1",` +
+			`"event":{"type":"message","channel_type":"im","user":"U1","text":"kept","channel":"D1","ts":"2.0"}}}`,
+	}
+	_, got := newTestBot(t, f, BotConfig{Mode: "socket", AppToken: "xapp-test"}, func(b *Bot) { b.SetAccept(failFirst()) })
+
+	select {
+	case id := <-f.acks:
+		if id != "e2" {
+			t.Errorf("ack = %q, want only e2", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("envelope e2 not acknowledged")
+	}
+	if msg := got.Receive(t); msg.Text != "kept" {
+		t.Errorf("event = %+v", msg)
+	}
+}
+
+func TestFullInboxDeliversInline(t *testing.T) {
+	b := NewBot(BotConfig{DMPolicy: "open"})
+	b.inbox = make(chan protocol.InboundMessage, 1)
+	b.inbox <- protocol.InboundMessage{Text: "queued"} // no worker, so the inbox stays full
+
+	var delivered []string
+	b.SetSink(func(ctx context.Context, msg protocol.InboundMessage) error {
+		delivered = append(delivered, msg.Text)
+		return nil
+	})
+	cb := eventCallback{Type: "event_callback", TeamID: "T1",
+		Event: messageEvent{Type: "message", ChannelType: "im", User: "U1", Text: "hello", Channel: "D1", TS: "1.0"}}
+	if !b.handleEvent(context.Background(), cb) {
+		t.Error("inline delivery not acknowledged")
+	}
+	if len(delivered) != 1 || delivered[0] != "hello" {
+		t.Errorf("delivered = %v, want the message passed to the sink inline", delivered)
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	f := newFakeSlack(t)
+	b, _ := newTestBot(t, f, BotConfig{Mode: "events"})
+	f.limitNext = true
+
+	long := strings.Repeat("word ", maxChunkSize/5+10)
+	err := b.SendMessage(context.Background(), protocol.OutboundMessage{
+		Channel:  "slack",
+		PeerID:   "C1",
+		ThreadID: "100.1",
+		Text:     "**Done** & " + long,
+		Format:   "markdown",
+	})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	posts := f.postsSnapshot()
+	if len(posts) != 2 {
+		t.Fatalf("posts = %d, want 2", len(posts))
+	}
+	for _, p := range posts {
+		if p.Channel != "C1" || p.ThreadTS != "100.1" {
+			t.Errorf("post = %+v", p)
+		}
+	}
+	if !strings.HasPrefix(posts[0].Text, "*Done* &amp; word") {
+		t.Errorf("first chunk starts %q", posts[0].Text[:20])
+	}
+}
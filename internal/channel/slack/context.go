@@ -0,0 +1,299 @@
+package slack
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// eventCallback is the body of an Events API request, also carried as
+// the payload of Socket Mode "events_api" envelopes.
+type eventCallback struct {
+	Type      string       `json:"type"` // "event_callback", "url_verification"
+	Challenge string       `json:"challenge"`
+	TeamID    string       `json:"team_id"`
+	EventID   string       `json:"event_id"`
+	Event     messageEvent `json:"event"`
+}
+
+// messageEvent is a "message" or "app_mention" event. Other event types
+// decode into it too and are dropped by extractContext.
+type messageEvent struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype"`
+	User        string `json:"user"`
+	BotID       string `json:"bot_id"`
+	Text        string `json:"text"`
+	Channel     string `json:"channel"`
+	ChannelType string `json:"channel_type"` // "im", "mpim", "channel", "group"
+	TS          string `json:"ts"`
+	ThreadTS    string `json:"thread_ts"`
+	Team        string `json:"team"`
+	Files       []file `json:"files"`
+}
+
+type file struct {
+	Mimetype string `json:"mimetype"`
+}
+
+// slashCommand is a slash command invocation, form-encoded over the
+// Events API and JSON in Socket Mode.
+type slashCommand struct {
+	Command   string `json:"command"`
+	Text      string `json:"text"`
+	UserID    string `json:"user_id"`
+	ChannelID string `json:"channel_id"`
+	TeamID    string `json:"team_id"`
+	TriggerID string `json:"trigger_id"`
+}
+
+// messageContext extracts routing information from a Slack event.
+type messageContext struct {
+	MessageID string
+	TeamID    string
+	ChannelID string
+	UserID    string
+	Text      string
+	PeerKind  string // "user" or "group"
+	PeerID    string
+	GuildID   string
+	ThreadID  string
+	IsMention bool
+	Command   string // slash command without the slash, e.g. "reset"
+	Media     []string
+}
+
+// extractContext builds the context of a message event. selfID is the
+// bot's own user, whose messages are skipped and whose mentions are
+// stripped.
+func extractContext(cb eventCallback, selfID string, replyInThread bool) *messageContext {
+	ev := cb.Event
+	if ev.Type != "message" && ev.Type != "app_mention" {
+		return nil
+	}
+	// Edits, deletions, joins and the like carry a subtype; plain messages
+	// with files or broadcast thread replies are the only ones answered.
+	if ev.Subtype != "" && ev.Subtype != "file_share" && ev.Subtype != "thread_broadcast" {
+		return nil
+	}
+	if ev.BotID != "" || ev.User == "" || ev.User == selfID {
+		return nil
+	}
+
+	team := ev.Team
+	if team == "" {
+		team = cb.TeamID
+	}
+	mc := &messageContext{
+		MessageID: "slack:" + ev.Channel + ":" + ev.TS,
+		TeamID:    team,
+		ChannelID: ev.Channel,
+		UserID:    ev.User,
+		ThreadID:  ev.ThreadTS,
+		Media:     mediaTypes(ev.Files),
+	}
+
+	text, mentioned := stripMention(ev.Text, selfID)
+	mc.Text = decodeText(text)
+	mc.IsMention = mentioned || ev.Type == "app_mention"
+	if mc.Text == "" && len(mc.Media) == 0 {
+		return nil
+	}
+
+	if isDM(ev.ChannelType, ev.Channel) {
+		mc.PeerKind = "user"
+		mc.PeerID = ev.User
+	} else {
+		mc.PeerKind = "group"
+		mc.PeerID = ev.Channel
+		mc.GuildID = ev.Channel
+		if mc.ThreadID == "" && replyInThread {
+			mc.ThreadID = ev.TS
+		}
+	}
+
+	mc.Command = parseCommand(mc.Text)
+	return mc
+}
+
+// commandContext builds the context of a slash command. Slash commands
+// are addressed to the bot, so they count as mentions.
+func commandContext(sc slashCommand) *messageContext {
+	if sc.Command == "" || sc.UserID == "" {
+		return nil
+	}
+	text := strings.TrimSpace(sc.Command + " " + decodeText(sc.Text))
+	mc := &messageContext{
+		MessageID: "slack:" + sc.ChannelID + ":cmd:" + sc.TriggerID,
+		TeamID:    sc.TeamID,
+		ChannelID: sc.ChannelID,
+		UserID:    sc.UserID,
+		Text:      text,
+		IsMention: true,
+		Command:   parseCommand(text),
+	}
+	if isDM("", sc.ChannelID) {
+		mc.PeerKind = "user"
+		mc.PeerID = sc.UserID
+	} else {
+		mc.PeerKind = "group"
+		mc.PeerID = sc.ChannelID
+		mc.GuildID = sc.ChannelID
+	}
+	return mc
+}
+
+// isDM reports whether a channel is a direct message with the bot.
+// app_mention events and slash commands carry no channel type; DM
+// channel IDs start with "D".
+func isDM(channelType, channelID string) bool {
+	if channelType != "" {
+		return channelType == "im"
+	}
+	return strings.HasPrefix(channelID, "D")
+}
+
+// stripMention removes mentions of the bot user from text and reports
+// whether there were any.
+func stripMention(text, userID string) (string, bool) {
+	if userID == "" {
+		return strings.TrimSpace(text), false
+	}
+	prefix := "<@" + userID
+	var out strings.Builder
+	mentioned := false
+	for {
+		i := strings.Index(text, prefix)
+		if i < 0 {
+			break
+		}
+		rest := text[i+len(prefix):]
+		end := -1
+		switch {
+		case strings.HasPrefix(rest, ">"):
+			end = 1
+		case strings.HasPrefix(rest, "|"):
+			if j := strings.IndexByte(rest, '>'); j >= 0 {
+				end = j + 1
+			}
+		}
+		if end < 0 {
+			// Another user whose ID starts with the bot's, or no closing '>'.
+			out.WriteString(text[:i+len(prefix)])
+			text = rest
+			continue
+		}
+		out.WriteString(text[:i])
+		text = rest[end:]
+		mentioned = true
+	}
+	out.WriteString(text)
+	return strings.TrimSpace(out.String()), mentioned
+}
+
+var (
+	markupRe       = regexp.MustCompile(`<([^<>]*)>`)
+	entityReplacer = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+)
+
+// decodeText turns Slack's message markup into plain text: user and
+// channel references become "@U123" and "#general", links become their
+// URL or "label (url)", and the escaped &, < and > are restored.
+func decodeText(text string) string {
+	text = markupRe.ReplaceAllStringFunc(text, func(m string) string {
+		ref, label, _ := strings.Cut(m[1:len(m)-1], "|")
+		switch {
+		case strings.HasPrefix(ref, "@"), strings.HasPrefix(ref, "#"):
+			if label != "" {
+				return ref[:1] + strings.TrimPrefix(label, ref[:1])
+			}
+			return ref
+		case strings.HasPrefix(ref, "!"):
+			if label != "" {
+				return label
+			}
+			return "@" + ref[1:]
+		case label != "" && label != ref:
+			return label + " (" + ref + ")"
+		default:
+			return ref
+		}
+	})
+	return strings.TrimSpace(entityReplacer.Replace(text))
+}
+
+// mediaTypes lists the attachment types of a message's files.
+func mediaTypes(files []file) []string {
+	var types []string
+	for _, f := range files {
+		var t string
+		switch {
+		case f.Mimetype == "image/gif":
+			t = "animation"
+		case strings.HasPrefix(f.Mimetype, "image/"):
+			t = "photo"
+		case strings.HasPrefix(f.Mimetype, "video/"):
+			t = "video"
+		case strings.HasPrefix(f.Mimetype, "audio/"):
+			t = "audio"
+		default:
+			t = "document"
+		}
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// parseCommand returns the command at the start of text ("/reset" ->
+// "reset"), or "" if text is not a command.
+func parseCommand(text string) string {
+	if !strings.HasPrefix(text, "/") {
+		return ""
+	}
+	return strings.ToLower(strings.Fields(text)[0][1:])
+}
+
+// checkAccess verifies whether this message should be processed.
+func checkAccess(mc *messageContext, cfg BotConfig) bool {
+	if mc.PeerKind == "user" {
+		switch cfg.DMPolicy {
+		case "open":
+			return true
+		case "allowlist":
+			return slices.Contains(cfg.AllowedUsers, mc.UserID)
+		default:
+			return false
+		}
+	}
+	switch cfg.GroupPolicy {
+	case "all", "mention":
+	default:
+		return false
+	}
+	if len(cfg.AllowedChannels) > 0 && !slices.Contains(cfg.AllowedChannels, mc.ChannelID) {
+		return false
+	}
+	return cfg.GroupPolicy != "mention" || mc.IsMention
+}
+
+// toInboundMessage converts a message context to a protocol message.
+func toInboundMessage(mc *messageContext) protocol.InboundMessage {
+	return protocol.InboundMessage{
+		MessageID: mc.MessageID,
+		Channel:   "slack",
+		PeerKind:  mc.PeerKind,
+		PeerID:    mc.PeerID,
+		GuildID:   mc.GuildID,
+		TeamID:    mc.TeamID,
+		ThreadID:  mc.ThreadID,
+		SenderID:  mc.UserID,
+		Text:      mc.Text,
+		Command:   mc.Command,
+
+		Attachments: mc.Media,
+	}
+}
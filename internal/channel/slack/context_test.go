@@ -0,0 +1,23 @@
+package slack
+
+import "testing"
+
+func TestStripMention(t *testing.T) {
+	tests := []struct {
+		text, want string
+		mentioned  bool
+	}{
+		{"<@UBOT> hello", "hello", true},
+		{"hi <@UBOT|dhaavak> there", "hi  there", true},
+		{"<@UBOT> and <@UBOT>", "and", true},
+		{"<@UBOTX> hello", "<@UBOTX> hello", false},
+		{"<@UOTHER> hello", "<@UOTHER> hello", false},
+		{"<@UBOT|unclosed", "<@UBOT|unclosed", false},
+	}
+	for _, tt := range tests {
+		got, mentioned := stripMention(tt.text, "UBOT")
+		if got != tt.want || mentioned != tt.mentioned {
+			t.Errorf("stripMention(%q) = %q, %v; want %q, %v", tt.text, got, mentioned, tt.want, tt.mentioned)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package slack
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/harshadpatil/dhaavak/internal/channel"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+const (
+	// seenLimit is how many message IDs markSeen remembers per generation.
+	seenLimit = 1000
+
+	// inboxSize is how many accepted messages may wait for the sink.
+	inboxSize = 256
+)
+
+// handleEvent processes an Events API event, from either transport, and
+// reports whether it may be acknowledged.
+func (b *Bot) handleEvent(ctx context.Context, cb eventCallback) bool {
+	return b.dispatch(ctx, extractContext(cb, b.userID, b.cfg.ReplyInThread))
+}
+
+// handleCommand processes a slash command, from either transport, and
+// reports whether it may be acknowledged.
+func (b *Bot) handleCommand(ctx context.Context, sc slashCommand) bool {
+	return b.dispatch(ctx, commandContext(sc))
+}
+
+// dispatch accepts a message and hands it to the sink. It reports false
+// when the message could not be accepted, so the transport leaves it
+// unacknowledged and Slack delivers it again.
+func (b *Bot) dispatch(ctx context.Context, mc *messageContext) bool {
+	if mc == nil {
+		return true
+	}
+	if !checkAccess(mc, b.cfg) {
+		slog.Debug("slack access denied", "user", mc.UserID, "channel", mc.ChannelID)
+		return true
+	}
+	// A mention in a channel arrives as both a message and an app_mention
+	// event; only the first is passed on.
+	if b.markSeen(mc.MessageID) {
+		return true
+	}
+
+	msg := toInboundMessage(mc)
+	if b.accept != nil {
+		added, err := b.accept(ctx, msg)
+		if err != nil {
+			slog.Error("slack message not accepted", "channel", mc.ChannelID, "err", err)
+			b.forgetSeen(mc.MessageID)
+			return false
+		}
+		if !added {
+			return true
+		}
+	}
+
+	// The sink runs on the inbox worker, so routing never holds up the
+	// transport. When the inbox is full the sink runs here instead, and the
+	// queue's overflow policy decides what happens to the message.
+	select {
+	case b.inbox <- msg:
+	default:
+		slog.Warn("slack inbox full, delivering inline", "channel", mc.ChannelID)
+		b.deliverOne(ctx, msg)
+	}
+	return true
+}
+
+// deliver passes queued messages to the sink, in the order they arrived,
+// until ctx is done.
+func (b *Bot) deliver(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-b.inbox:
+			b.deliverOne(ctx, msg)
+		}
+	}
+}
+
+func (b *Bot) deliverOne(ctx context.Context, msg protocol.InboundMessage) {
+	if b.sink == nil {
+		return
+	}
+	if err := b.sink(ctx, msg); err != nil {
+		slog.Error("slack message sink error", "err", err)
+	}
+}
+
+// markSeen records a message ID and reports whether it was already seen.
+// IDs are kept for two generations of seenLimit messages.
+func (b *Bot) markSeen(id string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.seen[id] || b.old[id] {
+		return true
+	}
+	if len(b.seen) >= seenLimit {
+		b.old, b.seen = b.seen, make(map[string]bool)
+	}
+	b.seen[id] = true
+	return false
+}
+
+// forgetSeen drops a message ID, so a redelivery is processed again.
+func (b *Bot) forgetSeen(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.seen, id)
+	delete(b.old, id)
+}
+
+// SetSink sets the message sink callback.
+func (b *Bot) SetSink(sink channel.MessageSink) {
+	b.sink = sink
+}
+
+// SetAccept sets the callback that records a message before it is
+// acknowledged. Without one, messages are acknowledged once queued for
+// the sink.
+func (b *Bot) SetAccept(accept channel.MessageAcceptor) {
+	b.accept = accept
+}
@@ -0,0 +1,124 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxClockSkew is how old a signed request may be before it is rejected
+// as a possible replay.
+const maxClockSkew = 5 * time.Minute
+
+// EventsHandler returns the Events API endpoint to mount on the gateway
+// in events mode. Requests must carry a valid Slack signature; events
+// are acknowledged once accepted, and answered with 503 otherwise so
+// Slack retries them.
+func (b *Bot) EventsHandler() http.Handler {
+	return http.HandlerFunc(b.serveEvents)
+}
+
+func (b *Bot) serveEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	err = verifySignature(b.cfg.SigningSecret, r.Header.Get("X-Slack-Request-Timestamp"),
+		r.Header.Get("X-Slack-Signature"), body, time.Now())
+	if err != nil {
+		slog.Warn("slack events: rejected request", "err", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if b.ctx == nil {
+		http.Error(w, "not started", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Slash commands are form-encoded, events are JSON.
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		accepted := b.handleCommand(b.ctx, slashCommand{
+			Command:   form.Get("command"),
+			Text:      form.Get("text"),
+			UserID:    form.Get("user_id"),
+			ChannelID: form.Get("channel_id"),
+			TeamID:    form.Get("team_id"),
+			TriggerID: form.Get("trigger_id"),
+		})
+		ackEvent(w, accepted)
+		return
+	}
+
+	var cb eventCallback
+	if err := json.Unmarshal(body, &cb); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	switch cb.Type {
+	case "url_verification":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"challenge": cb.Challenge})
+	case "event_callback":
+		ackEvent(w, b.handleEvent(b.ctx, cb))
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// ackEvent answers the request once the event is accepted, before it is
+// processed, so slow processing does not make Slack retry it. An event
+// that was not accepted gets a 503, which Slack retries.
+func ackEvent(w http.ResponseWriter, accepted bool) {
+	if !accepted {
+		http.Error(w, "not accepted", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	http.NewResponseController(w).Flush()
+}
+
+// verifySignature checks a request's X-Slack-Signature, the hex
+// HMAC-SHA256 of "v0:<timestamp>:<body>" keyed with the signing secret.
+func verifySignature(secret, timestamp, signature string, body []byte, now time.Time) error {
+	if timestamp == "" || signature == "" {
+		return errors.New("missing signature headers")
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("bad request timestamp")
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > maxClockSkew || d < -maxClockSkew {
+		return errors.New("request timestamp too far from now")
+	}
+	if !hmac.Equal([]byte(signature), []byte(sign(secret, timestamp, body))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// sign computes the v0 signature of a request body.
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
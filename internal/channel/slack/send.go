@@ -0,0 +1,86 @@
+package slack
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxChunkSize is the longest message text Slack displays in full, in
+// characters.
+const maxChunkSize = 4000
+
+// fence opens and closes a code block in both markdown and mrkdwn.
+const fence = "```"
+
+var (
+	headingRe = regexp.MustCompile(`^#{1,6}\s+(.+)$`)
+	bulletRe  = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	linkRe    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldRe    = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	italicRe  = regexp.MustCompile(`\*([^*\s](?:[^*]*[^*\s])?)\*`)
+	strikeRe  = regexp.MustCompile(`~~(.+?)~~`)
+
+	escapeReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// escapeText escapes the characters Slack treats as markup in any text.
+func escapeText(text string) string {
+	return escapeReplacer.Replace(text)
+}
+
+// markdownToMrkdwn converts the markdown agents write to Slack's mrkdwn:
+// **bold** becomes *bold*, *italic* becomes _italic_, ~~strike~~ becomes
+// ~strike~, [label](url) becomes <url|label>, headings become bold lines
+// and list items get bullets. Code is only escaped.
+func markdownToMrkdwn(text string) string {
+	lines := strings.Split(text, "\n")
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), fence) {
+			inFence = !inFence
+			lines[i] = escapeText(line)
+			continue
+		}
+		if inFence {
+			lines[i] = escapeText(line)
+			continue
+		}
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			lines[i] = "*" + strings.Trim(formatInline(m[1]), "*") + "*"
+			continue
+		}
+		if m := bulletRe.FindStringSubmatch(line); m != nil {
+			lines[i] = m[1] + "• " + formatInline(line[len(m[0]):])
+			continue
+		}
+		lines[i] = formatInline(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatInline converts the inline markup of one line, leaving `code`
+// spans unformatted.
+func formatInline(line string) string {
+	parts := strings.Split(line, "`")
+	for i := range parts {
+		// Odd parts are inside a code span, unless the last backtick is
+		// unpaired.
+		if i%2 == 1 && i < len(parts)-1 {
+			parts[i] = escapeText(parts[i])
+			continue
+		}
+		parts[i] = formatText(parts[i])
+	}
+	return strings.Join(parts, "`")
+}
+
+func formatText(s string) string {
+	s = escapeText(s)
+	s = linkRe.ReplaceAllString(s, "<$2|$1>")
+	// Bold is marked with \x00 until italics are converted, since both
+	// use asterisks.
+	s = boldRe.ReplaceAllString(s, "\x00$1$2\x00")
+	s = italicRe.ReplaceAllString(s, "_${1}_")
+	s = strikeRe.ReplaceAllString(s, "~$1~")
+	return strings.ReplaceAll(s, "\x00", "*")
+}
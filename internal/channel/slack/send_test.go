@@ -0,0 +1,46 @@
+package slack
+
+import "testing"
+
+func TestMarkdownToMrkdwn(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"bold and italic", "**bold** and *italic*", "*bold* and _italic_"},
+		{"underscore bold", "__bold__", "*bold*"},
+		{"strike", "~~gone~~", "~gone~"},
+		{"link", "see [docs](https://example.com/a?b=1)", "see <https://example.com/a?b=1|docs>"},
+		{"heading", "## Status", "*Status*"},
+		{"bullets", "- one\n* two", "• one\n• two"},
+		{"escaping", "a < b && c > d", "a &lt; b &amp;&amp; c &gt; d"},
+		{"inline code", "run `**x** <y>` now", "run `**x** &lt;y&gt;` now"},
+		{"code block", "```\n**x**\n```\n**y**", "```\n**x**\n```\n*y*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownToMrkdwn(tt.in); got != tt.want {
+				t.Errorf("markdownToMrkdwn(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"hi <@U123>", "hi @U123"},
+		{"in <#C1|general>", "in #general"},
+		{"<!here> look", "@here look"},
+		{"<https://example.com|the site> and <https://x.io>", "the site (https://example.com) and https://x.io"},
+		{"a &lt;b&gt; &amp; c", "a <b> & c"},
+	}
+	for _, tt := range tests {
+		if got := decodeText(tt.in); got != tt.want {
+			t.Errorf("decodeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// envelope is a Socket Mode message. Every envelope with an ID must be
+// acknowledged within three seconds or Slack delivers it again.
+type envelope struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Type       string          `json:"type"` // "hello", "events_api", "slash_commands", "disconnect"
+	Payload    json.RawMessage `json:"payload"`
+	Reason     string          `json:"reason"`
+}
+
+// runSocket keeps a Socket Mode connection open until ctx is done,
+// reconnecting with backoff when it drops.
+func (b *Bot) runSocket(ctx context.Context) {
+	backoff := time.Second
+	for {
+		connected, err := b.connectSocket(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = time.Second
+		}
+		if err != nil {
+			slog.Warn("slack socket mode disconnected", "err", err, "retry_in", backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, 30*time.Second)
+		}
+	}
+}
+
+// connectSocket opens one Socket Mode connection and reads envelopes
+// until it ends. It reports whether Slack greeted the connection; a nil
+// error means Slack asked for a reconnect.
+func (b *Bot) connectSocket(ctx context.Context) (bool, error) {
+	var open connectionsOpenResponse
+	if err := b.api.call(ctx, "apps.connections.open", b.cfg.AppToken, struct{}{}, &open); err != nil {
+		return false, err
+	}
+	conn, _, err := websocket.Dial(ctx, open.URL, nil)
+	if err != nil {
+		return false, fmt.Errorf("slack socket dial: %w", err)
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(1 << 20)
+
+	connected := false
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return connected, fmt.Errorf("slack socket read: %w", err)
+		}
+		var env envelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			slog.Warn("slack socket: bad envelope", "err", err)
+			continue
+		}
+		// An envelope is acknowledged once its message is accepted; one
+		// that was not is left for Slack to deliver again.
+		accepted := true
+		switch env.Type {
+		case "hello":
+			connected = true
+			slog.Debug("slack socket mode connected")
+		case "disconnect":
+			slog.Debug("slack socket mode reconnect requested", "reason", env.Reason)
+			conn.Close(websocket.StatusNormalClosure, "")
+			return connected, nil
+		case "events_api":
+			var cb eventCallback
+			if err := json.Unmarshal(env.Payload, &cb); err != nil {
+				slog.Warn("slack socket: bad event", "err", err)
+				break
+			}
+			accepted = b.handleEvent(ctx, cb)
+		case "slash_commands":
+			var sc slashCommand
+			if err := json.Unmarshal(env.Payload, &sc); err != nil {
+				slog.Warn("slack socket: bad slash command", "err", err)
+				break
+			}
+			accepted = b.handleCommand(ctx, sc)
+		}
+
+		if env.EnvelopeID != "" && accepted {
+			ack, _ := json.Marshal(map[string]string{"envelope_id": env.EnvelopeID})
+			if err := conn.Write(ctx, websocket.MessageText, ack); err != nil {
+				return connected, fmt.Errorf("slack socket ack: %w", err)
+			}
+		}
+	}
+}
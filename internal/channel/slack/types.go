@@ -0,0 +1,39 @@
+package slack
+
+import (
+	"github.com/harshadpatil/dhaavak/internal/channel"
+	"github.com/harshadpatil/dhaavak/internal/config"
+)
+
+// BotConfig holds Slack adapter configuration.
+type BotConfig struct {
+	Mode            string // "socket" or "events"
+	BotToken        string
+	AppToken        string
+	SigningSecret   string
+	APIURL          string
+	DMPolicy        string
+	GroupPolicy     string
+	AllowedUsers    []string
+	AllowedChannels []string
+	ReplyInThread   bool
+}
+
+// ConfigFromApp extracts Slack config from the app config.
+func ConfigFromApp(cfg config.SlackConfig) BotConfig {
+	return BotConfig{
+		Mode:            cfg.Mode,
+		BotToken:        cfg.BotToken,
+		AppToken:        cfg.AppToken,
+		SigningSecret:   cfg.SigningSecret,
+		APIURL:          cfg.APIURL,
+		DMPolicy:        cfg.DMPolicy,
+		GroupPolicy:     cfg.GroupPolicy,
+		AllowedUsers:    cfg.AllowedUsers,
+		AllowedChannels: cfg.AllowedChannels,
+		ReplyInThread:   cfg.ReplyInThread,
+	}
+}
+
+// ensure Bot implements Adapter.
+var _ channel.Adapter = (*Bot)(nil)
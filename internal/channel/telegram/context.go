@@ -11,6 +11,7 @@ import (
 
 // messageContext extracts routing information from a Telegram update.
 type messageContext struct {
+	MessageID int
 	ChatID    int64
 	ThreadID  int
 	UserID    int64
@@ -19,6 +20,8 @@ type messageContext struct {
 	PeerID    string
 	GuildID   string
 	IsMention bool
+	Command   string // bot command without the slash, e.g. "reset"
+	Media     []string
 }
 
 func extractContext(update tgbotapi.Update, botUsername string) *messageContext {
@@ -36,9 +39,11 @@ func extractContext(update tgbotapi.Update, botUsername string) *messageContext
 	}
 
 	mc := &messageContext{
-		ChatID: msg.Chat.ID,
-		UserID: msg.From.ID,
-		Text:   text,
+		MessageID: msg.MessageID,
+		ChatID:    msg.Chat.ID,
+		UserID:    msg.From.ID,
+		Text:      text,
+		Media:     mediaTypes(msg),
 	}
 
 	if msg.Chat.IsPrivate() {
@@ -71,9 +76,56 @@ func extractContext(update tgbotapi.Update, botUsername string) *messageContext
 		}
 	}
 
+	mc.Command = parseCommand(mc.Text, botUsername)
+
 	return mc
 }
 
+// mediaTypes lists the attachment types of a message.
+func mediaTypes(msg *tgbotapi.Message) []string {
+	var types []string
+	if len(msg.Photo) > 0 {
+		types = append(types, "photo")
+	}
+	// Telegram sends animations as documents too; report only the former.
+	switch {
+	case msg.Animation != nil:
+		types = append(types, "animation")
+	case msg.Document != nil:
+		types = append(types, "document")
+	}
+	if msg.Video != nil {
+		types = append(types, "video")
+	}
+	if msg.Audio != nil {
+		types = append(types, "audio")
+	}
+	if msg.Voice != nil {
+		types = append(types, "voice")
+	}
+	if msg.Sticker != nil {
+		types = append(types, "sticker")
+	}
+	return types
+}
+
+// parseCommand returns the bot command at the start of text ("/reset" or
+// "/reset@botname" -> "reset"), or "" if text is not a command. Commands
+// addressed to a different bot are ignored.
+func parseCommand(text, botUsername string) string {
+	if !strings.HasPrefix(text, "/") {
+		return ""
+	}
+	cmd := strings.Fields(text)[0][1:]
+	if at := strings.IndexByte(cmd, '@'); at >= 0 {
+		if !strings.EqualFold(cmd[at+1:], botUsername) {
+			return ""
+		}
+		cmd = cmd[:at]
+	}
+	return strings.ToLower(cmd)
+}
+
 // checkAccess verifies whether this message should be processed.
 func checkAccess(mc *messageContext, policy *session.SendPolicy, groupPolicy string) bool {
 	if mc.PeerKind == "user" {
@@ -96,11 +148,16 @@ func toInboundMessage(mc *messageContext) protocol.InboundMessage {
 		threadID = fmt.Sprintf("%d", mc.ThreadID)
 	}
 	return protocol.InboundMessage{
-		Channel:  "telegram",
-		PeerKind: mc.PeerKind,
-		PeerID:   mc.PeerID,
-		GuildID:  mc.GuildID,
-		ThreadID: threadID,
-		Text:     mc.Text,
+		MessageID: fmt.Sprintf("telegram:%d:%d", mc.ChatID, mc.MessageID),
+		Channel:   "telegram",
+		PeerKind:  mc.PeerKind,
+		PeerID:    mc.PeerID,
+		GuildID:   mc.GuildID,
+		ThreadID:  threadID,
+		SenderID:  fmt.Sprintf("%d", mc.UserID),
+		Text:      mc.Text,
+		Command:   mc.Command,
+
+		Attachments: mc.Media,
 	}
 }
@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/harshadpatil/dhaavak/internal/redact"
+	"github.com/harshadpatil/dhaavak/internal/routing"
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
@@ -57,9 +61,25 @@ func Load(path string) (*Config, error) {
 	if k.Exists("server.host") {
 		cfg.Server.Host = k.String("server.host")
 	}
+	if k.Exists("server.send_overflow") {
+		cfg.Server.SendOverflow = k.String("server.send_overflow")
+	}
+	if k.Exists("server.send_timeout") {
+		cfg.Server.SendTimeout = k.Duration("server.send_timeout")
+	}
 
 	// Auth
 	cfg.Auth.Token = k.String("auth.token")
+	if k.Exists("auth.admins") {
+		var admins []AdminToken
+		for _, raw := range k.Slices("auth.admins") {
+			admins = append(admins, AdminToken{
+				Name:  raw.String("name"),
+				Token: raw.String("token"),
+			})
+		}
+		cfg.Auth.Admins = admins
+	}
 
 	// LLM
 	if k.Exists("llm.provider") {
@@ -82,13 +102,31 @@ func Load(path string) (*Config, error) {
 			agents = append(agents, AgentConfig{
 				ID:           raw.String("id"),
 				Name:         raw.String("name"),
+				Description:  raw.String("description"),
 				SystemPrompt: raw.String("system_prompt"),
 				Model:        raw.String("model"),
+				Timeout:      raw.Duration("timeout"),
 			})
 		}
 		cfg.Agents = agents
 	}
 
+	// Bindings
+	if k.Exists("bindings") {
+		cfg.Bindings = loadBindings(k.Slices("bindings"), "")
+	}
+
+	// Channels - default agents and bindings
+	for _, r := range cfg.Channels.routes() {
+		prefix := "channels." + r.channel
+		if k.Exists(prefix + ".default_agent") {
+			*r.defaultAgent = k.String(prefix + ".default_agent")
+		}
+		if k.Exists(prefix + ".bindings") {
+			*r.bindings = loadBindings(k.Slices(prefix+".bindings"), r.channel)
+		}
+	}
+
 	// Channels - Telegram
 	if k.Exists("channels.telegram") {
 		tg := &cfg.Channels.Telegram
@@ -96,9 +134,6 @@ func Load(path string) (*Config, error) {
 			tg.Enabled = k.Bool("channels.telegram.enabled")
 		}
 		tg.BotToken = k.String("channels.telegram.bot_token")
-		if k.Exists("channels.telegram.default_agent") {
-			tg.DefaultAgent = k.String("channels.telegram.default_agent")
-		}
 		if k.Exists("channels.telegram.dm_policy") {
 			tg.DMPolicy = k.String("channels.telegram.dm_policy")
 		}
@@ -113,6 +148,93 @@ func Load(path string) (*Config, error) {
 		}
 	}
 
+	// Channels - Slack
+	if k.Exists("channels.slack") {
+		sl := &cfg.Channels.Slack
+		if k.Exists("channels.slack.enabled") {
+			sl.Enabled = k.Bool("channels.slack.enabled")
+		}
+		if k.Exists("channels.slack.mode") {
+			sl.Mode = k.String("channels.slack.mode")
+		}
+		sl.BotToken = k.String("channels.slack.bot_token")
+		sl.AppToken = k.String("channels.slack.app_token")
+		sl.SigningSecret = k.String("channels.slack.signing_secret")
+		if k.Exists("channels.slack.events_path") {
+			sl.EventsPath = k.String("channels.slack.events_path")
+		}
+		if k.Exists("channels.slack.api_url") {
+			sl.APIURL = k.String("channels.slack.api_url")
+		}
+		if k.Exists("channels.slack.dm_policy") {
+			sl.DMPolicy = k.String("channels.slack.dm_policy")
+		}
+		if k.Exists("channels.slack.group_policy") {
+			sl.GroupPolicy = k.String("channels.slack.group_policy")
+		}
+		if k.Exists("channels.slack.allowed_users") {
+			sl.AllowedUsers = k.Strings("channels.slack.allowed_users")
+		}
+		if k.Exists("channels.slack.allowed_channels") {
+			sl.AllowedChannels = k.Strings("channels.slack.allowed_channels")
+		}
+		if k.Exists("channels.slack.reply_in_thread") {
+			sl.ReplyInThread = k.Bool("channels.slack.reply_in_thread")
+		}
+	}
+
+	// Channels - Discord
+	if k.Exists("channels.discord") {
+		dc := &cfg.Channels.Discord
+		if k.Exists("channels.discord.enabled") {
+			dc.Enabled = k.Bool("channels.discord.enabled")
+		}
+		dc.BotToken = k.String("channels.discord.bot_token")
+		if k.Exists("channels.discord.api_url") {
+			dc.APIURL = k.String("channels.discord.api_url")
+		}
+		if k.Exists("channels.discord.dm_policy") {
+			dc.DMPolicy = k.String("channels.discord.dm_policy")
+		}
+		if k.Exists("channels.discord.group_policy") {
+			dc.GroupPolicy = k.String("channels.discord.group_policy")
+		}
+		if k.Exists("channels.discord.allowed_users") {
+			dc.AllowedUsers = k.Strings("channels.discord.allowed_users")
+		}
+		if k.Exists("channels.discord.allowed_guilds") {
+			dc.AllowedGuilds = k.Strings("channels.discord.allowed_guilds")
+		}
+	}
+
+	// Channels - Matrix
+	if k.Exists("channels.matrix") {
+		mx := &cfg.Channels.Matrix
+		if k.Exists("channels.matrix.enabled") {
+			mx.Enabled = k.Bool("channels.matrix.enabled")
+		}
+		mx.Homeserver = k.String("channels.matrix.homeserver")
+		mx.AccessToken = k.String("channels.matrix.access_token")
+		if k.Exists("channels.matrix.sync_timeout") {
+			mx.SyncTimeout = k.Duration("channels.matrix.sync_timeout")
+		}
+		if k.Exists("channels.matrix.dm_policy") {
+			mx.DMPolicy = k.String("channels.matrix.dm_policy")
+		}
+		if k.Exists("channels.matrix.group_policy") {
+			mx.GroupPolicy = k.String("channels.matrix.group_policy")
+		}
+		if k.Exists("channels.matrix.invite_policy") {
+			mx.InvitePolicy = k.String("channels.matrix.invite_policy")
+		}
+		if k.Exists("channels.matrix.allowed_users") {
+			mx.AllowedUsers = k.Strings("channels.matrix.allowed_users")
+		}
+		if k.Exists("channels.matrix.allowed_rooms") {
+			mx.AllowedRooms = k.Strings("channels.matrix.allowed_rooms")
+		}
+	}
+
 	// Session
 	if k.Exists("session.ttl") {
 		cfg.Session.TTL = k.Duration("session.ttl")
@@ -123,6 +245,30 @@ func Load(path string) (*Config, error) {
 	if k.Exists("session.max_history") {
 		cfg.Session.MaxHistory = k.Int("session.max_history")
 	}
+	if k.Exists("session.store") {
+		cfg.Session.Store = k.String("session.store")
+	}
+	if k.Exists("session.path") {
+		cfg.Session.Path = k.String("session.path")
+	}
+	if k.Exists("session.scope") {
+		cfg.Session.Scope = k.String("session.scope")
+	}
+	if k.Exists("session.channel_scopes") {
+		cfg.Session.ChannelScopes = k.StringMap("session.channel_scopes")
+	}
+	if k.Exists("session.agent_scopes") {
+		cfg.Session.AgentScopes = k.StringMap("session.agent_scopes")
+	}
+	if k.Exists("session.timezone") {
+		cfg.Session.Timezone = k.String("session.timezone")
+	}
+	if k.Exists("session.encryption.key_file") {
+		cfg.Session.Encryption.KeyFile = k.String("session.encryption.key_file")
+	}
+	if k.Exists("session.encryption.key_env") {
+		cfg.Session.Encryption.KeyEnv = k.String("session.encryption.key_env")
+	}
 
 	// Queue
 	if k.Exists("queue.buffer_size") {
@@ -134,6 +280,130 @@ func Load(path string) (*Config, error) {
 	if k.Exists("queue.cleanup_interval") {
 		cfg.Queue.CleanupInterval = k.Duration("queue.cleanup_interval")
 	}
+	if k.Exists("queue.mode") {
+		cfg.Queue.Mode = k.String("queue.mode")
+	}
+	if k.Exists("queue.agent_modes") {
+		cfg.Queue.AgentModes = k.StringMap("queue.agent_modes")
+	}
+	if k.Exists("queue.debounce") {
+		cfg.Queue.Debounce = k.Duration("queue.debounce")
+	}
+	if k.Exists("queue.limits.global") {
+		cfg.Queue.Limits.Global = k.Int("queue.limits.global")
+	}
+	if k.Exists("queue.limits.agents") {
+		cfg.Queue.Limits.Agents = k.IntMap("queue.limits.agents")
+	}
+	if k.Exists("queue.limits.providers") {
+		cfg.Queue.Limits.Providers = k.IntMap("queue.limits.providers")
+	}
+	if k.Exists("queue.limits.weights") {
+		cfg.Queue.Limits.Weights = k.IntMap("queue.limits.weights")
+	}
+	if k.Exists("queue.journal.enabled") {
+		cfg.Queue.Journal.Enabled = k.Bool("queue.journal.enabled")
+	}
+	if k.Exists("queue.journal.path") {
+		cfg.Queue.Journal.Path = k.String("queue.journal.path")
+	}
+	if k.Exists("queue.journal.retention") {
+		cfg.Queue.Journal.Retention = k.Duration("queue.journal.retention")
+	}
+	if k.Exists("queue.overflow.policy") {
+		cfg.Queue.Overflow.Policy = k.String("queue.overflow.policy")
+	}
+	if k.Exists("queue.overflow.timeout") {
+		cfg.Queue.Overflow.Timeout = k.Duration("queue.overflow.timeout")
+	}
+	if k.Exists("queue.overflow.spill_path") {
+		cfg.Queue.Overflow.SpillPath = k.String("queue.overflow.spill_path")
+	}
+	if k.Exists("queue.overflow.busy_reply") {
+		cfg.Queue.Overflow.BusyReply = k.String("queue.overflow.busy_reply")
+	}
+	if k.Exists("queue.run_timeout") {
+		cfg.Queue.RunTimeout = k.Duration("queue.run_timeout")
+	}
+	if k.Exists("queue.failure_reply") {
+		cfg.Queue.FailureReply = k.String("queue.failure_reply")
+	}
+	if k.Exists("queue.drain_timeout") {
+		cfg.Queue.DrainTimeout = k.Duration("queue.drain_timeout")
+	}
+	if k.Exists("queue.dead_letter.enabled") {
+		cfg.Queue.DeadLetter.Enabled = k.Bool("queue.dead_letter.enabled")
+	}
+	if k.Exists("queue.dead_letter.path") {
+		cfg.Queue.DeadLetter.Path = k.String("queue.dead_letter.path")
+	}
+	if k.Exists("queue.priority.senders") {
+		cfg.Queue.Priority.Senders = k.StringMap("queue.priority.senders")
+	}
+
+	// Routing
+	if k.Exists("routing.classifier.enabled") {
+		cfg.Routing.Classifier.Enabled = k.Bool("routing.classifier.enabled")
+	}
+	if k.Exists("routing.classifier.model") {
+		cfg.Routing.Classifier.Model = k.String("routing.classifier.model")
+	}
+	if k.Exists("routing.classifier.min_confidence") {
+		cfg.Routing.Classifier.MinConfidence = k.Float64("routing.classifier.min_confidence")
+	}
+	if k.Exists("routing.classifier.timeout") {
+		cfg.Routing.Classifier.Timeout = k.Duration("routing.classifier.timeout")
+	}
+	if k.Exists("routing.classifier.agents") {
+		cfg.Routing.Classifier.Agents = k.Strings("routing.classifier.agents")
+	}
+	if k.Exists("routing.store.path") {
+		cfg.Routing.Store.Path = k.String("routing.store.path")
+	}
+	if k.Exists("routing.store.audit_path") {
+		cfg.Routing.Store.AuditPath = k.String("routing.store.audit_path")
+	}
+	if k.Exists("routing.admins") {
+		cfg.Routing.Admins = k.Strings("routing.admins")
+	}
+
+	// Identity
+	if k.Exists("identity.enabled") {
+		cfg.Identity.Enabled = k.Bool("identity.enabled")
+	}
+	if k.Exists("identity.path") {
+		cfg.Identity.Path = k.String("identity.path")
+	}
+	if k.Exists("identity.code_ttl") {
+		cfg.Identity.CodeTTL = k.Duration("identity.code_ttl")
+	}
+
+	// Redaction
+	if k.Exists("redaction.enabled") {
+		cfg.Redact.Enabled = k.Bool("redaction.enabled")
+	}
+	if k.Exists("redaction.mode") {
+		cfg.Redact.Mode = k.String("redaction.mode")
+	}
+	if k.Exists("redaction.detectors") {
+		cfg.Redact.Detectors = k.Strings("redaction.detectors")
+	}
+	if k.Exists("redaction.custom") {
+		var rules []RedactRule
+		for _, raw := range k.Slices("redaction.custom") {
+			rules = append(rules, RedactRule{
+				Name:    raw.String("name"),
+				Pattern: raw.String("pattern"),
+			})
+		}
+		cfg.Redact.Custom = rules
+	}
+	if k.Exists("redaction.llm") {
+		cfg.Redact.LLM = k.Bool("redaction.llm")
+	}
+	if k.Exists("redaction.logs") {
+		cfg.Redact.Logs = k.Bool("redaction.logs")
+	}
 
 	if err := validate(&cfg); err != nil {
 		return nil, err
@@ -146,6 +416,19 @@ func validate(cfg *Config) error {
 	if cfg.Server.Port < 1 || cfg.Server.Port > 65535 {
 		return fmt.Errorf("config: server.port must be 1-65535, got %d", cfg.Server.Port)
 	}
+	names, tokens := make(map[string]bool), make(map[string]bool)
+	for i, a := range cfg.Auth.Admins {
+		if a.Name == "" || a.Token == "" {
+			return fmt.Errorf("config: auth.admins[%d]: name and token are required", i)
+		}
+		if names[a.Name] || tokens[a.Token] {
+			return fmt.Errorf("config: auth.admins[%d]: name and token must be unique", i)
+		}
+		if a.Token == cfg.Auth.Token {
+			return fmt.Errorf("config: auth.admins[%d]: token must differ from auth.token", i)
+		}
+		names[a.Name], tokens[a.Token] = true, true
+	}
 	if cfg.LLM.APIKey == "" {
 		return fmt.Errorf("config: llm.api_key is required")
 	}
@@ -155,9 +438,419 @@ func validate(cfg *Config) error {
 	if cfg.Channels.Telegram.Enabled && cfg.Channels.Telegram.BotToken == "" {
 		return fmt.Errorf("config: channels.telegram.bot_token is required when telegram is enabled")
 	}
+	if err := validateSlack(&cfg.Channels.Slack); err != nil {
+		return err
+	}
+	if err := validateDiscord(&cfg.Channels.Discord); err != nil {
+		return err
+	}
+	if err := validateMatrix(&cfg.Channels.Matrix); err != nil {
+		return err
+	}
+	agents := make(map[string]bool, len(cfg.Agents))
+	for i, a := range cfg.Agents {
+		if a.ID == "" {
+			return fmt.Errorf("config: agents[%d]: id is required", i)
+		}
+		if agents[a.ID] {
+			return fmt.Errorf("config: agent %s is defined twice", a.ID)
+		}
+		agents[a.ID] = true
+	}
+	for _, r := range cfg.Channels.Routes() {
+		if r.DefaultAgent != "" && !agents[r.DefaultAgent] {
+			return fmt.Errorf("config: channels.%s.default_agent: unknown agent %q", r.Channel, r.DefaultAgent)
+		}
+	}
+	if c := cfg.Routing.Classifier; c.Enabled {
+		if c.Model == "" {
+			return fmt.Errorf("config: routing.classifier.model is required when the classifier is enabled")
+		}
+		if c.MinConfidence < 0 || c.MinConfidence > 1 {
+			return fmt.Errorf("config: routing.classifier.min_confidence must be between 0 and 1")
+		}
+		if c.Timeout <= 0 {
+			return fmt.Errorf("config: routing.classifier.timeout must be positive")
+		}
+		for _, id := range c.Agents {
+			if !agents[id] {
+				return fmt.Errorf("config: routing.classifier.agents: unknown agent %q", id)
+			}
+		}
+		if len(cfg.ClassifierAgents()) < 2 {
+			return fmt.Errorf("config: routing.classifier needs at least two candidate agents; list them in routing.classifier.agents or give them a description")
+		}
+	}
+	if cfg.Routing.Store.Path == "" {
+		return fmt.Errorf("config: routing.store.path is required")
+	}
+	for i, b := range cfg.Bindings {
+		if err := validateBinding(b, agents); err != nil {
+			return fmt.Errorf("config: bindings[%d]: %w", i, err)
+		}
+	}
+	for _, r := range cfg.Channels.Routes() {
+		for i, b := range r.Bindings {
+			if b.Channel != r.Channel {
+				return fmt.Errorf("config: channels.%s.bindings[%d]: channel must be %s, got %q", r.Channel, i, r.Channel, b.Channel)
+			}
+			if err := validateBinding(b, agents); err != nil {
+				return fmt.Errorf("config: channels.%s.bindings[%d]: %w", r.Channel, i, err)
+			}
+		}
+	}
+	switch cfg.Session.Store {
+	case "memory":
+	case "bolt":
+		if cfg.Session.Path == "" {
+			return fmt.Errorf("config: session.path is required for the bolt store")
+		}
+	default:
+		return fmt.Errorf("config: session.store must be memory or bolt, got %q", cfg.Session.Store)
+	}
+	if enc := cfg.Session.Encryption; enc.Enabled() {
+		if enc.KeyFile != "" && enc.KeyEnv != "" {
+			return fmt.Errorf("config: set only one of session.encryption.key_file and key_env")
+		}
+		if cfg.Session.Store != "bolt" {
+			return fmt.Errorf("config: session.encryption requires session.store: bolt")
+		}
+	}
+	if _, err := time.LoadLocation(cfg.Session.Timezone); err != nil {
+		return fmt.Errorf("config: session.timezone: %w", err)
+	}
+	if cfg.Identity.Enabled && cfg.Identity.Path == "" {
+		return fmt.Errorf("config: identity.path is required when identity is enabled")
+	}
+	if cfg.Queue.Limits.Global < 0 {
+		return fmt.Errorf("config: queue.limits.global must not be negative")
+	}
+	for id, w := range cfg.Queue.Limits.Weights {
+		if w < 1 {
+			return fmt.Errorf("config: queue.limits.weights.%s must be at least 1", id)
+		}
+	}
+	switch cfg.Server.SendOverflow {
+	case "reject", "drop_oldest":
+	case "block":
+		if cfg.Server.SendTimeout <= 0 {
+			return fmt.Errorf("config: server.send_timeout must be positive")
+		}
+	default:
+		return fmt.Errorf("config: server.send_overflow must be reject, drop_oldest or block, got %q", cfg.Server.SendOverflow)
+	}
+	if cfg.Queue.BufferSize < 1 {
+		return fmt.Errorf("config: queue.buffer_size must be at least 1")
+	}
+	switch cfg.Queue.Overflow.Policy {
+	case "reject", "drop_oldest":
+	case "block":
+		if cfg.Queue.Overflow.Timeout <= 0 {
+			return fmt.Errorf("config: queue.overflow.timeout must be positive")
+		}
+	case "spill":
+		if cfg.Queue.Overflow.SpillPath == "" {
+			return fmt.Errorf("config: queue.overflow.spill_path is required for the spill policy")
+		}
+	default:
+		return fmt.Errorf("config: queue.overflow.policy must be reject, block, drop_oldest or spill, got %q", cfg.Queue.Overflow.Policy)
+	}
+	if cfg.Queue.RunTimeout < 0 {
+		return fmt.Errorf("config: queue.run_timeout must not be negative")
+	}
+	if cfg.Queue.DrainTimeout < 0 {
+		return fmt.Errorf("config: queue.drain_timeout must not be negative")
+	}
+	for _, a := range cfg.Agents {
+		if a.Timeout < 0 {
+			return fmt.Errorf("config: agent %s: timeout must not be negative", a.ID)
+		}
+	}
+	for sender, p := range cfg.Queue.Priority.Senders {
+		if p != "normal" && p != "high" {
+			return fmt.Errorf("config: queue.priority.senders.%s must be normal or high, got %q", sender, p)
+		}
+	}
+	if cfg.Queue.DeadLetter.Enabled && cfg.Queue.DeadLetter.Path == "" {
+		return fmt.Errorf("config: queue.dead_letter.path is required when dead letters are enabled")
+	}
+	if cfg.Queue.Journal.Enabled {
+		if cfg.Queue.Journal.Path == "" {
+			return fmt.Errorf("config: queue.journal.path is required when the journal is enabled")
+		}
+		if cfg.Queue.Journal.Retention <= 0 {
+			return fmt.Errorf("config: queue.journal.retention must be positive")
+		}
+	}
+	switch cfg.Redact.Mode {
+	case "mask", "tokenize":
+	default:
+		return fmt.Errorf("config: redaction.mode must be mask or tokenize, got %q", cfg.Redact.Mode)
+	}
+	// Tokenize keeps the originals in the session record.
+	if cfg.Redact.Enabled && cfg.Redact.Mode == "tokenize" && cfg.Session.Store == "bolt" && !cfg.Session.Encryption.Enabled() {
+		return fmt.Errorf("config: redaction.mode tokenize requires session.encryption with the bolt session store")
+	}
+	// The journal and spill keep whole inbound messages for replay, so with
+	// redaction they must not hold them in plaintext.
+	if cfg.Redact.Enabled && !cfg.Session.Encryption.Enabled() {
+		if cfg.Queue.Journal.Enabled {
+			return fmt.Errorf("config: queue.journal with redaction requires session.encryption")
+		}
+		if cfg.Queue.Overflow.Policy == "spill" {
+			return fmt.Errorf("config: queue.overflow.policy spill with redaction requires session.encryption")
+		}
+	}
+	for _, d := range cfg.Redact.Detectors {
+		if _, ok := redact.Builtin(d); !ok {
+			return fmt.Errorf("config: unknown redaction detector %q", d)
+		}
+	}
+	for i, r := range cfg.Redact.Custom {
+		if r.Name == "" {
+			return fmt.Errorf("config: redaction.custom[%d]: name is required", i)
+		}
+		if _, err := redact.Custom(r.Name, r.Pattern); err != nil {
+			return fmt.Errorf("config: redaction.custom[%d]: %w", i, err)
+		}
+	}
 	return nil
 }
 
+// ValidateBinding checks a binding rule added at runtime the way Load
+// checks the configured ones.
+func (c *Config) ValidateBinding(b BindingRule) error {
+	agents := make(map[string]bool, len(c.Agents))
+	for _, a := range c.Agents {
+		agents[a.ID] = true
+	}
+	return validateBinding(b, agents)
+}
+
+// ClassifierAgents returns the agents the intent classifier picks from:
+// routing.classifier.agents, or else every agent with a description.
+func (c *Config) ClassifierAgents() []AgentConfig {
+	var out []AgentConfig
+	for _, a := range c.Agents {
+		if len(c.Routing.Classifier.Agents) == 0 && a.Description != "" {
+			out = append(out, a)
+			continue
+		}
+		for _, id := range c.Routing.Classifier.Agents {
+			if a.ID == id {
+				out = append(out, a)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// loadBindings reads binding rules. Rules without a channel get channel.
+func loadBindings(raws []*koanf.Koanf, channel string) []BindingRule {
+	rules := make([]BindingRule, 0, len(raws))
+	for _, raw := range raws {
+		b := BindingRule{
+			Channel:  raw.String("channel"),
+			PeerKind: raw.String("peer_kind"),
+			PeerID:   raw.String("peer_id"),
+			GuildID:  raw.String("guild_id"),
+			TeamID:   raw.String("team_id"),
+			AgentID:  raw.String("agent_id"),
+			Priority: raw.String("priority"),
+
+			Command:    raw.String("command"),
+			Pattern:    raw.String("pattern"),
+			Keywords:   raw.Strings("keywords"),
+			Language:   raw.String("language"),
+			Attachment: raw.String("attachment"),
+
+			Experiment: raw.String("experiment"),
+			Sticky:     raw.String("sticky"),
+
+			Days:     raw.Strings("days"),
+			Hours:    raw.String("hours"),
+			Timezone: raw.String("timezone"),
+			From:     raw.String("from"),
+			Until:    raw.String("until"),
+
+			Reply: raw.String("reply"),
+		}
+		for _, v := range raw.Slices("variants") {
+			b.Variants = append(b.Variants, BindingVariant{AgentID: v.String("agent_id"), Weight: v.Int("weight")})
+		}
+		if b.AgentID == "" && len(b.Variants) > 0 {
+			b.AgentID = b.Variants[0].AgentID
+		}
+		if b.Channel == "" {
+			b.Channel = channel
+		}
+		rules = append(rules, b)
+	}
+	return rules
+}
+
+// validateSlack checks the Slack adapter settings when it is enabled.
+func validateSlack(sl *SlackConfig) error {
+	if !sl.Enabled {
+		return nil
+	}
+	if sl.BotToken == "" {
+		return fmt.Errorf("config: channels.slack.bot_token is required when slack is enabled")
+	}
+	switch sl.Mode {
+	case "socket":
+		if sl.AppToken == "" {
+			return fmt.Errorf("config: channels.slack.app_token is required in socket mode")
+		}
+	case "events":
+		if sl.SigningSecret == "" {
+			return fmt.Errorf("config: channels.slack.signing_secret is required in events mode")
+		}
+		if !strings.HasPrefix(sl.EventsPath, "/") {
+			return fmt.Errorf("config: channels.slack.events_path must start with /, got %q", sl.EventsPath)
+		}
+	default:
+		return fmt.Errorf("config: channels.slack.mode must be socket or events, got %q", sl.Mode)
+	}
+	switch sl.DMPolicy {
+	case "open", "allowlist", "disabled":
+	default:
+		return fmt.Errorf("config: channels.slack.dm_policy must be open, allowlist or disabled, got %q", sl.DMPolicy)
+	}
+	switch sl.GroupPolicy {
+	case "mention", "all", "disabled":
+	default:
+		return fmt.Errorf("config: channels.slack.group_policy must be mention, all or disabled, got %q", sl.GroupPolicy)
+	}
+	return nil
+}
+
+// validateDiscord checks the Discord adapter settings when it is enabled.
+func validateDiscord(dc *DiscordConfig) error {
+	if !dc.Enabled {
+		return nil
+	}
+	if dc.BotToken == "" {
+		return fmt.Errorf("config: channels.discord.bot_token is required when discord is enabled")
+	}
+	switch dc.DMPolicy {
+	case "open", "allowlist", "disabled":
+	default:
+		return fmt.Errorf("config: channels.discord.dm_policy must be open, allowlist or disabled, got %q", dc.DMPolicy)
+	}
+	switch dc.GroupPolicy {
+	case "mention", "all", "disabled":
+	default:
+		return fmt.Errorf("config: channels.discord.group_policy must be mention, all or disabled, got %q", dc.GroupPolicy)
+	}
+	return nil
+}
+
+// validateMatrix checks the Matrix adapter settings when it is enabled.
+func validateMatrix(mx *MatrixConfig) error {
+	if !mx.Enabled {
+		return nil
+	}
+	if !strings.HasPrefix(mx.Homeserver, "https://") && !strings.HasPrefix(mx.Homeserver, "http://") {
+		return fmt.Errorf("config: channels.matrix.homeserver must be an http(s) URL when matrix is enabled, got %q", mx.Homeserver)
+	}
+	if mx.AccessToken == "" {
+		return fmt.Errorf("config: channels.matrix.access_token is required when matrix is enabled")
+	}
+	if mx.SyncTimeout <= 0 {
+		return fmt.Errorf("config: channels.matrix.sync_timeout must be positive")
+	}
+	switch mx.DMPolicy {
+	case "open", "allowlist", "disabled":
+	default:
+		return fmt.Errorf("config: channels.matrix.dm_policy must be open, allowlist or disabled, got %q", mx.DMPolicy)
+	}
+	switch mx.GroupPolicy {
+	case "mention", "all", "disabled":
+	default:
+		return fmt.Errorf("config: channels.matrix.group_policy must be mention, all or disabled, got %q", mx.GroupPolicy)
+	}
+	switch mx.InvitePolicy {
+	case "open", "allowlist", "disabled":
+	default:
+		return fmt.Errorf("config: channels.matrix.invite_policy must be open, allowlist or disabled, got %q", mx.InvitePolicy)
+	}
+	return nil
+}
+
+// validateBinding checks that a rule names a known agent and is a shape
+// the resolver can match.
+func validateBinding(b BindingRule, agents map[string]bool) error {
+	if b.AgentID == "" && b.Reply == "" {
+		return fmt.Errorf("agent_id or reply is required")
+	}
+	if b.AgentID != "" && !agents[b.AgentID] {
+		return fmt.Errorf("unknown agent %q", b.AgentID)
+	}
+	if b.Reply != "" && len(b.Variants) > 0 {
+		return fmt.Errorf("set only one of reply and variants")
+	}
+	switch b.PeerKind {
+	case "", "user", "group", "channel":
+	default:
+		return fmt.Errorf("peer_kind must be user, group or channel, got %q", b.PeerKind)
+	}
+	switch b.Priority {
+	case "", "normal", "high":
+	default:
+		return fmt.Errorf("priority must be normal or high, got %q", b.Priority)
+	}
+	switch {
+	case b.PeerID != "" && b.PeerKind == "":
+		return fmt.Errorf("peer_id needs a peer_kind")
+	case (b.PeerKind != "" || b.GuildID != "") && b.Channel == "":
+		return fmt.Errorf("peer and guild rules need a channel")
+	case b.GuildID != "" && b.PeerKind != "":
+		return fmt.Errorf("set only one of peer_kind and guild_id")
+	case b.TeamID != "" && (b.Channel != "" || b.PeerKind != "" || b.GuildID != ""):
+		return fmt.Errorf("team_id cannot be combined with channel, peer or guild")
+	}
+	if strings.ContainsAny(strings.TrimPrefix(b.Command, "/"), "/ \t") {
+		return fmt.Errorf("command must be a single word like /ops, got %q", b.Command)
+	}
+	if _, err := regexp.Compile(b.Pattern); err != nil {
+		return fmt.Errorf("pattern: %w", err)
+	}
+	if b.Language != "" && !slices.Contains(routing.Languages, b.Language) {
+		return fmt.Errorf("unsupported language %q", b.Language)
+	}
+	if b.Attachment != "" && !slices.Contains(routing.AttachmentTypes, b.Attachment) {
+		return fmt.Errorf("attachment must be one of %s, got %q", strings.Join(routing.AttachmentTypes, ", "), b.Attachment)
+	}
+	if len(b.Variants) == 1 {
+		return fmt.Errorf("variants needs at least two agents")
+	}
+	for i, v := range b.Variants {
+		if !agents[v.AgentID] {
+			return fmt.Errorf("variants[%d]: unknown agent %q", i, v.AgentID)
+		}
+		if v.Weight < 1 {
+			return fmt.Errorf("variants[%d]: weight must be at least 1", i)
+		}
+	}
+	switch b.Sticky {
+	case "", "session", "user":
+	default:
+		return fmt.Errorf("sticky must be session or user, got %q", b.Sticky)
+	}
+	if len(b.Variants) == 0 && (b.Experiment != "" || b.Sticky != "") {
+		return fmt.Errorf("experiment and sticky need variants")
+	}
+	return routing.ValidateSchedule(routing.Binding{
+		Days:     b.Days,
+		Hours:    b.Hours,
+		Timezone: b.Timezone,
+		From:     b.From,
+		Until:    b.Until,
+	})
+}
+
 // rawBytesProvider implements koanf.Provider for raw bytes.
 type rawBytesProvider []byte
 
@@ -6,8 +6,10 @@ import "time"
 func Default() Config {
 	return Config{
 		Server: ServerConfig{
-			Port: 18789,
-			Host: "127.0.0.1",
+			Port:         18789,
+			Host:         "127.0.0.1",
+			SendOverflow: "reject",
+			SendTimeout:  2 * time.Second,
 		},
 		LLM: LLMConfig{
 			Provider: "anthropic",
@@ -19,16 +21,76 @@ func Default() Config {
 				DMPolicy:    "open",
 				GroupPolicy: "mention",
 			},
+			Slack: SlackConfig{
+				Mode:          "socket",
+				EventsPath:    "/slack/events",
+				APIURL:        "https://slack.com/api",
+				DMPolicy:      "open",
+				GroupPolicy:   "mention",
+				ReplyInThread: true,
+			},
+			Discord: DiscordConfig{
+				APIURL:      "https://discord.com/api/v10",
+				DMPolicy:    "open",
+				GroupPolicy: "mention",
+			},
+			Matrix: MatrixConfig{
+				SyncTimeout:  30 * time.Second,
+				DMPolicy:     "open",
+				GroupPolicy:  "mention",
+				InvitePolicy: "allowlist",
+			},
 		},
 		Session: SessionConfig{
 			TTL:             30 * time.Minute,
 			CleanupInterval: 5 * time.Minute,
 			MaxHistory:      100,
+			Store:           "memory",
+			Path:            "data/sessions.db",
+			Scope:           "default",
+			Timezone:        "UTC",
 		},
 		Queue: QueueConfig{
 			BufferSize:      64,
 			IdleTimeout:     10 * time.Minute,
 			CleanupInterval: 2 * time.Minute,
+			Mode:            "followup",
+			Debounce:        1500 * time.Millisecond,
+			Journal: QueueJournal{
+				Path:      "data/queue.db",
+				Retention: 24 * time.Hour,
+			},
+			Overflow: QueueOverflow{
+				Policy:    "reject",
+				Timeout:   5 * time.Second,
+				SpillPath: "data/spill.db",
+				BusyReply: "I'm busy right now, please try again in a moment.",
+			},
+			RunTimeout:   5 * time.Minute,
+			FailureReply: "Sorry, something went wrong and I couldn't finish that. Please try again.",
+			DeadLetter: QueueDeadLetter{
+				Path: "data/deadletter.db",
+			},
+		},
+		Routing: RoutingConfig{
+			Classifier: RoutingClassifier{
+				Model:         "claude-haiku-4-5",
+				MinConfidence: 0.6,
+				Timeout:       3 * time.Second,
+			},
+			Store: RoutingStore{
+				Path:      "data/bindings.json",
+				AuditPath: "data/bindings-audit.jsonl",
+			},
+		},
+		Identity: IdentityConfig{
+			Path:    "data/identities.json",
+			CodeTTL: 10 * time.Minute,
+		},
+		Redact: RedactConfig{
+			Mode: "mask",
+			LLM:  true,
+			Logs: true,
 		},
 	}
 }
@@ -8,17 +8,31 @@ type Config struct {
 	Auth     AuthConfig     `json:"auth"     yaml:"auth"`
 	LLM      LLMConfig      `json:"llm"      yaml:"llm"`
 	Agents   []AgentConfig  `json:"agents"   yaml:"agents"`
+	Bindings []BindingRule  `json:"bindings" yaml:"bindings"` // routing rules for every channel
+	Routing  RoutingConfig  `json:"routing"  yaml:"routing"`
 	Channels ChannelsConfig `json:"channels" yaml:"channels"`
 	Session  SessionConfig  `json:"session"  yaml:"session"`
 	Queue    QueueConfig    `json:"queue"    yaml:"queue"`
+	Identity IdentityConfig `json:"identity" yaml:"identity"`
+	Redact   RedactConfig   `json:"redaction" yaml:"redaction"`
 }
 
 type ServerConfig struct {
-	Port int    `json:"port" yaml:"port"`
-	Host string `json:"host" yaml:"host"`
+	Port         int           `json:"port"          yaml:"port"`
+	Host         string        `json:"host"          yaml:"host"`
+	SendOverflow string        `json:"send_overflow" yaml:"send_overflow"` // full client buffer: "reject", "drop_oldest", "block"
+	SendTimeout  time.Duration `json:"send_timeout"  yaml:"send_timeout"`  // how long "block" lets a client stay backed up
 }
 
 type AuthConfig struct {
+	Token  string       `json:"token"  yaml:"token"`
+	Admins []AdminToken `json:"admins" yaml:"admins"` // tokens that may call admin-only gateway methods
+}
+
+// AdminToken names a gateway admin. The name is the admin's principal in
+// audit logs.
+type AdminToken struct {
+	Name  string `json:"name"  yaml:"name"`
 	Token string `json:"token" yaml:"token"`
 }
 
@@ -30,11 +44,13 @@ type LLMConfig struct {
 }
 
 type AgentConfig struct {
-	ID           string       `json:"id"            yaml:"id"`
-	Name         string       `json:"name"          yaml:"name"`
-	SystemPrompt string       `json:"system_prompt" yaml:"system_prompt"`
-	Model        string       `json:"model,omitempty" yaml:"model,omitempty"`
-	Tools        []ToolConfig `json:"tools,omitempty" yaml:"tools,omitempty"`
+	ID           string        `json:"id"            yaml:"id"`
+	Name         string        `json:"name"          yaml:"name"`
+	Description  string        `json:"description"   yaml:"description"` // what the agent handles, for the intent classifier
+	SystemPrompt string        `json:"system_prompt" yaml:"system_prompt"`
+	Model        string        `json:"model,omitempty" yaml:"model,omitempty"`
+	Tools        []ToolConfig  `json:"tools,omitempty" yaml:"tools,omitempty"`
+	Timeout      time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"` // run deadline, default queue.run_timeout
 }
 
 type ToolConfig struct {
@@ -44,6 +60,43 @@ type ToolConfig struct {
 
 type ChannelsConfig struct {
 	Telegram TelegramConfig `json:"telegram" yaml:"telegram"`
+	Slack    SlackConfig    `json:"slack"    yaml:"slack"`
+	Discord  DiscordConfig  `json:"discord"  yaml:"discord"`
+	Matrix   MatrixConfig   `json:"matrix"   yaml:"matrix"`
+}
+
+// ChannelRoute is the routing config every channel has: a default agent
+// and bindings scoped to the channel.
+type ChannelRoute struct {
+	Channel      string
+	DefaultAgent string
+	Bindings     []BindingRule
+}
+
+// Routes returns the routing config of each channel.
+func (c ChannelsConfig) Routes() []ChannelRoute {
+	var out []ChannelRoute
+	for _, r := range c.routes() {
+		out = append(out, ChannelRoute{Channel: r.channel, DefaultAgent: *r.defaultAgent, Bindings: *r.bindings})
+	}
+	return out
+}
+
+// channelRoute points at one channel's routing fields, so they are loaded
+// and validated in one place. A new channel adds its entry to routes.
+type channelRoute struct {
+	channel      string
+	defaultAgent *string
+	bindings     *[]BindingRule
+}
+
+func (c *ChannelsConfig) routes() []channelRoute {
+	return []channelRoute{
+		{"telegram", &c.Telegram.DefaultAgent, &c.Telegram.Bindings},
+		{"slack", &c.Slack.DefaultAgent, &c.Slack.Bindings},
+		{"discord", &c.Discord.DefaultAgent, &c.Discord.Bindings},
+		{"matrix", &c.Matrix.DefaultAgent, &c.Matrix.Bindings},
+	}
 }
 
 type TelegramConfig struct {
@@ -57,20 +110,213 @@ type TelegramConfig struct {
 	Bindings      []BindingRule `json:"bindings"       yaml:"bindings"`
 }
 
+// SlackConfig configures the Slack adapter. Events arrive over Socket Mode
+// (mode "socket", needs AppToken) or as Events API requests to EventsPath on
+// the gateway (mode "events", needs SigningSecret).
+type SlackConfig struct {
+	Enabled         bool          `json:"enabled"          yaml:"enabled"`
+	Mode            string        `json:"mode"             yaml:"mode"`           // "socket", "events"
+	BotToken        string        `json:"bot_token"        yaml:"bot_token"`      // xoxb-...
+	AppToken        string        `json:"app_token"        yaml:"app_token"`      // xapp-..., for socket mode
+	SigningSecret   string        `json:"signing_secret"   yaml:"signing_secret"` // for events mode
+	EventsPath      string        `json:"events_path"      yaml:"events_path"`
+	APIURL          string        `json:"api_url"          yaml:"api_url"` // Web API base URL
+	DefaultAgent    string        `json:"default_agent"    yaml:"default_agent"`
+	DMPolicy        string        `json:"dm_policy"        yaml:"dm_policy"`    // "open", "allowlist", "disabled"
+	GroupPolicy     string        `json:"group_policy"     yaml:"group_policy"` // "mention", "all", "disabled"
+	AllowedUsers    []string      `json:"allowed_users"    yaml:"allowed_users"`
+	AllowedChannels []string      `json:"allowed_channels" yaml:"allowed_channels"`
+	ReplyInThread   bool          `json:"reply_in_thread"  yaml:"reply_in_thread"` // answer channel messages in a thread
+	Bindings        []BindingRule `json:"bindings"         yaml:"bindings"`
+}
+
+// DiscordConfig configures the Discord adapter, which receives messages
+// over the Discord Gateway and replies through the REST API.
+type DiscordConfig struct {
+	Enabled       bool          `json:"enabled"        yaml:"enabled"`
+	BotToken      string        `json:"bot_token"      yaml:"bot_token"`
+	APIURL        string        `json:"api_url"        yaml:"api_url"` // REST base URL
+	DefaultAgent  string        `json:"default_agent"  yaml:"default_agent"`
+	DMPolicy      string        `json:"dm_policy"      yaml:"dm_policy"`    // "open", "allowlist", "disabled"
+	GroupPolicy   string        `json:"group_policy"   yaml:"group_policy"` // "mention", "all", "disabled"
+	AllowedUsers  []string      `json:"allowed_users"  yaml:"allowed_users"`
+	AllowedGuilds []string      `json:"allowed_guilds" yaml:"allowed_guilds"`
+	Bindings      []BindingRule `json:"bindings"       yaml:"bindings"`
+}
+
+// MatrixConfig configures the Matrix adapter, which long-polls /sync on
+// the homeserver with the access token of a bot account. InvitePolicy
+// decides which room invites the bot accepts.
+type MatrixConfig struct {
+	Enabled      bool          `json:"enabled"       yaml:"enabled"`
+	Homeserver   string        `json:"homeserver"    yaml:"homeserver"` // e.g. https://matrix.example.org
+	AccessToken  string        `json:"access_token"  yaml:"access_token"`
+	SyncTimeout  time.Duration `json:"sync_timeout"  yaml:"sync_timeout"` // long-poll timeout
+	DefaultAgent string        `json:"default_agent" yaml:"default_agent"`
+	DMPolicy     string        `json:"dm_policy"     yaml:"dm_policy"`     // "open", "allowlist", "disabled"
+	GroupPolicy  string        `json:"group_policy"  yaml:"group_policy"`  // "mention", "all", "disabled"
+	InvitePolicy string        `json:"invite_policy" yaml:"invite_policy"` // "open", "allowlist", "disabled"
+	AllowedUsers []string      `json:"allowed_users" yaml:"allowed_users"` // e.g. @alice:example.org
+	AllowedRooms []string      `json:"allowed_rooms" yaml:"allowed_rooms"` // room IDs, e.g. !abc:example.org
+	Bindings     []BindingRule `json:"bindings"      yaml:"bindings"`
+}
+
+// BindingRule routes matching messages to an agent, see routing.Binding.
+// Rules under channels.telegram.bindings have Channel set to "telegram".
 type BindingRule struct {
-	PeerKind string `json:"peer_kind" yaml:"peer_kind"` // "user", "group"
+	Channel  string `json:"channel"   yaml:"channel"`   // "" matches every channel
+	PeerKind string `json:"peer_kind" yaml:"peer_kind"` // "user", "group", "channel"
 	PeerID   string `json:"peer_id"   yaml:"peer_id"`
+	GuildID  string `json:"guild_id"  yaml:"guild_id"`
+	TeamID   string `json:"team_id"   yaml:"team_id"`
 	AgentID  string `json:"agent_id"  yaml:"agent_id"`
+	Priority string `json:"priority"  yaml:"priority"` // queue priority: "normal", "high"
+
+	// Content predicates; a rule with any of them only matches messages
+	// that satisfy all of them.
+	Command    string   `json:"command"    yaml:"command"` // e.g. "/ops"
+	Pattern    string   `json:"pattern"    yaml:"pattern"` // regular expression
+	Keywords   []string `json:"keywords"   yaml:"keywords"`
+	Language   string   `json:"language"   yaml:"language"`   // ISO 639-1 code
+	Attachment string   `json:"attachment" yaml:"attachment"` // "photo", "document", ...
+
+	// Weighted split between agents; agent_id defaults to the first.
+	Variants   []BindingVariant `json:"variants"   yaml:"variants"`
+	Experiment string           `json:"experiment" yaml:"experiment"` // tag for usage and metrics
+	Sticky     string           `json:"sticky"     yaml:"sticky"`     // "session" (default) or "user"
+
+	// Time conditions; a rule with any of them only matches inside them.
+	Days     []string `json:"days"     yaml:"days"`     // "mon".."sun" or ranges like "mon-fri"
+	Hours    string   `json:"hours"    yaml:"hours"`    // "09:00-17:30", may wrap past midnight
+	Timezone string   `json:"timezone" yaml:"timezone"` // IANA zone, default session.timezone
+	From     string   `json:"from"     yaml:"from"`     // RFC 3339
+	Until    string   `json:"until"    yaml:"until"`    // RFC 3339, exclusive
+
+	Reply string `json:"reply" yaml:"reply"` // static reply instead of an agent
+}
+
+// BindingVariant is one agent of a binding's weighted split.
+type BindingVariant struct {
+	AgentID string `json:"agent_id" yaml:"agent_id"`
+	Weight  int    `json:"weight"   yaml:"weight"`
 }
 
 type SessionConfig struct {
-	TTL             time.Duration `json:"ttl"               yaml:"ttl"`
-	CleanupInterval time.Duration `json:"cleanup_interval"  yaml:"cleanup_interval"`
-	MaxHistory      int           `json:"max_history"       yaml:"max_history"`
+	TTL             time.Duration     `json:"ttl"               yaml:"ttl"`
+	CleanupInterval time.Duration     `json:"cleanup_interval"  yaml:"cleanup_interval"`
+	MaxHistory      int               `json:"max_history"       yaml:"max_history"`
+	Store           string            `json:"store"             yaml:"store"` // "memory", "bolt"
+	Path            string            `json:"path"              yaml:"path"`  // database file for "bolt"
+	Scope           string            `json:"scope"             yaml:"scope"` // default scope, see session.Scope
+	ChannelScopes   map[string]string `json:"channel_scopes"    yaml:"channel_scopes"`
+	AgentScopes     map[string]string `json:"agent_scopes"      yaml:"agent_scopes"`
+	Timezone        string            `json:"timezone"          yaml:"timezone"` // day boundary for the "daily" scope
+	Encryption      EncryptionConfig  `json:"encryption"        yaml:"encryption"`
+}
+
+// EncryptionConfig selects where session encryption keys come from. Keys are
+// base64 or hex, one per line (file) or comma separated (env); the first key
+// encrypts and the rest are only used to decrypt during rotation.
+type EncryptionConfig struct {
+	KeyFile string `json:"key_file" yaml:"key_file"`
+	KeyEnv  string `json:"key_env"  yaml:"key_env"`
+}
+
+// Enabled reports whether a key source is configured.
+func (e EncryptionConfig) Enabled() bool {
+	return e.KeyFile != "" || e.KeyEnv != ""
 }
 
 type QueueConfig struct {
-	BufferSize      int           `json:"buffer_size"       yaml:"buffer_size"`
-	IdleTimeout     time.Duration `json:"idle_timeout"      yaml:"idle_timeout"`
-	CleanupInterval time.Duration `json:"cleanup_interval"  yaml:"cleanup_interval"`
+	BufferSize      int               `json:"buffer_size"       yaml:"buffer_size"`
+	IdleTimeout     time.Duration     `json:"idle_timeout"      yaml:"idle_timeout"`
+	CleanupInterval time.Duration     `json:"cleanup_interval"  yaml:"cleanup_interval"`
+	Mode            string            `json:"mode"              yaml:"mode"` // default queue mode, see queue.Mode
+	AgentModes      map[string]string `json:"agent_modes"       yaml:"agent_modes"`
+	Debounce        time.Duration     `json:"debounce"          yaml:"debounce"` // quiet window for the "collect" mode
+	Limits          QueueLimits       `json:"limits"            yaml:"limits"`
+	Journal         QueueJournal      `json:"journal"           yaml:"journal"`
+	Overflow        QueueOverflow     `json:"overflow"          yaml:"overflow"`
+	RunTimeout      time.Duration     `json:"run_timeout"       yaml:"run_timeout"`   // deadline for one agent run, 0 = none
+	FailureReply    string            `json:"failure_reply"     yaml:"failure_reply"` // sent when a run fails or times out
+	DrainTimeout    time.Duration     `json:"drain_timeout"     yaml:"drain_timeout"` // wait for queued work on shutdown, 0 = don't
+	DeadLetter      QueueDeadLetter   `json:"dead_letter"       yaml:"dead_letter"`
+	Priority        QueuePriority     `json:"priority"          yaml:"priority"`
+}
+
+// QueuePriority assigns queue priorities by sender. Bindings and the
+// message itself can raise a message's priority too; the highest wins.
+type QueuePriority struct {
+	Senders map[string]string `json:"senders" yaml:"senders"` // "channel:peer" or identity user ID -> "normal", "high"
+}
+
+// QueueDeadLetter keeps failed and timed-out runs for inspection and retry.
+type QueueDeadLetter struct {
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+	Path    string `json:"path"    yaml:"path"` // bbolt file
+}
+
+// QueueOverflow decides what happens when a session's lane buffer is full.
+type QueueOverflow struct {
+	Policy    string        `json:"policy"     yaml:"policy"`     // "reject", "block", "drop_oldest", "spill"
+	Timeout   time.Duration `json:"timeout"    yaml:"timeout"`    // how long "block" lets a client stay backed up
+	SpillPath string        `json:"spill_path" yaml:"spill_path"` // bbolt file for "spill"
+	BusyReply string        `json:"busy_reply" yaml:"busy_reply"` // sent to users whose message was refused or dropped
+}
+
+// QueueJournal persists inbound messages so unfinished work survives a restart.
+type QueueJournal struct {
+	Enabled   bool          `json:"enabled"   yaml:"enabled"`
+	Path      string        `json:"path"      yaml:"path"`      // bbolt file
+	Retention time.Duration `json:"retention" yaml:"retention"` // how long handled message IDs are remembered
+}
+
+// QueueLimits caps concurrent agent runs across all lanes. Zero means unlimited.
+type QueueLimits struct {
+	Global    int            `json:"global"    yaml:"global"`
+	Agents    map[string]int `json:"agents"    yaml:"agents"`    // per agent ID
+	Providers map[string]int `json:"providers" yaml:"providers"` // per LLM provider
+	Weights   map[string]int `json:"weights"   yaml:"weights"`   // slots one run of an agent takes, default 1
+}
+
+type RoutingConfig struct {
+	Classifier RoutingClassifier `json:"classifier" yaml:"classifier"`
+	Store      RoutingStore      `json:"store"      yaml:"store"`
+	Admins     []string          `json:"admins"     yaml:"admins"` // "channel:peer" or identity user IDs allowed to /bind and /unbind
+}
+
+// RoutingStore configures where bindings added at runtime are kept.
+type RoutingStore struct {
+	Path      string `json:"path"       yaml:"path"`       // JSON file holding runtime bindings
+	AuditPath string `json:"audit_path" yaml:"audit_path"` // JSON Lines log of binding changes
+}
+
+// RoutingClassifier configures the LLM intent classifier, which picks an
+// agent for a session's first message when no content binding matched.
+type RoutingClassifier struct {
+	Enabled       bool          `json:"enabled"        yaml:"enabled"`
+	Model         string        `json:"model"          yaml:"model"`          // small, fast model
+	MinConfidence float64       `json:"min_confidence" yaml:"min_confidence"` // below it, the resolver's agent is used
+	Timeout       time.Duration `json:"timeout"        yaml:"timeout"`
+	Agents        []string      `json:"agents"         yaml:"agents"` // candidates, default every agent with a description
+}
+
+type IdentityConfig struct {
+	Enabled bool          `json:"enabled"  yaml:"enabled"`
+	Path    string        `json:"path"     yaml:"path"`     // JSON file holding peer -> user links
+	CodeTTL time.Duration `json:"code_ttl" yaml:"code_ttl"` // lifetime of a link code
+}
+
+type RedactConfig struct {
+	Enabled   bool         `json:"enabled"   yaml:"enabled"`
+	Mode      string       `json:"mode"      yaml:"mode"`      // "mask", "tokenize"
+	Detectors []string     `json:"detectors" yaml:"detectors"` // built-in detectors; empty means all
+	Custom    []RedactRule `json:"custom"    yaml:"custom"`
+	LLM       bool         `json:"llm"       yaml:"llm"`  // also redact text sent to the LLM
+	Logs      bool         `json:"logs"      yaml:"logs"` // mask log output
+}
+
+type RedactRule struct {
+	Name    string `json:"name"    yaml:"name"`
+	Pattern string `json:"pattern" yaml:"pattern"`
 }
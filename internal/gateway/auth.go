@@ -1,25 +1,70 @@
 package gateway
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
+	"encoding/base64"
 	"net/http"
+	"strings"
+
+	"github.com/harshadpatil/dhaavak/internal/config"
 )
 
-// Authenticator validates connection tokens.
+// Authenticator validates connection tokens. An admin token also lets the
+// connection call the methods registered with HandleAdmin.
 type Authenticator struct {
-	token string
+	token     string
+	admins    []config.AdminToken
+	clientKey []byte // signs client tokens
+}
+
+// NewAuthenticator creates a token authenticator. If no token is
+// configured, all connections are allowed; admin tokens are always checked.
+//
+// Client tokens are signed with a key derived from the gateway token, so
+// they stay valid across restarts. Without a gateway token the key is
+// random and tokens last until the process exits.
+func NewAuthenticator(cfg config.AuthConfig) *Authenticator {
+	key := make([]byte, sha256.Size)
+	if cfg.Token != "" {
+		mac := hmac.New(sha256.New, []byte(cfg.Token))
+		mac.Write([]byte("dhaavak client token"))
+		key = mac.Sum(nil)
+	} else {
+		rand.Read(key)
+	}
+	return &Authenticator{token: cfg.Token, admins: cfg.Admins, clientKey: key}
 }
 
-// NewAuthenticator creates a token authenticator. If token is empty, all connections are allowed.
-func NewAuthenticator(token string) *Authenticator {
-	return &Authenticator{token: token}
+// SignClient returns a client token for peerID, of the form "{peerID}.{sig}".
+func (a *Authenticator) SignClient(peerID string) string {
+	return peerID + "." + a.clientSig(peerID)
 }
 
-// Check validates the token from the request. Returns true if auth passes.
-func (a *Authenticator) Check(r *http.Request) bool {
-	if a.token == "" {
-		return true
+// VerifyClient returns the peer ID of a token issued by SignClient.
+func (a *Authenticator) VerifyClient(token string) (string, bool) {
+	i := strings.LastIndexByte(token, '.')
+	if i <= 0 {
+		return "", false
+	}
+	peerID, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(a.clientSig(peerID))) {
+		return "", false
 	}
+	return peerID, true
+}
+
+func (a *Authenticator) clientSig(peerID string) string {
+	mac := hmac.New(sha256.New, a.clientKey)
+	mac.Write([]byte(peerID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Check validates the token from the request. It reports whether the
+// connection is allowed and, for an admin token, the admin's name.
+func (a *Authenticator) Check(r *http.Request) (ok bool, admin string) {
 	tok := r.URL.Query().Get("token")
 	if tok == "" {
 		tok = r.Header.Get("Authorization")
@@ -27,5 +72,13 @@ func (a *Authenticator) Check(r *http.Request) bool {
 			tok = tok[7:]
 		}
 	}
-	return subtle.ConstantTimeCompare([]byte(tok), []byte(a.token)) == 1
+	for _, adm := range a.admins {
+		if subtle.ConstantTimeCompare([]byte(tok), []byte(adm.Token)) == 1 {
+			admin = adm.Name
+		}
+	}
+	if admin != "" || a.token == "" {
+		return true, admin
+	}
+	return subtle.ConstantTimeCompare([]byte(tok), []byte(a.token)) == 1, ""
 }
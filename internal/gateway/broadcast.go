@@ -14,9 +14,7 @@ func (s *Server) BroadcastAll(event protocol.EventFrame) {
 		slog.Error("broadcast marshal error", "err", err)
 		return
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	for _, c := range s.clients {
+	for _, c := range s.snapshot() {
 		c.Send(data)
 	}
 }
@@ -28,9 +26,7 @@ func (s *Server) BroadcastSession(sessionID string, event protocol.EventFrame) {
 		slog.Error("broadcast marshal error", "err", err)
 		return
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	for _, c := range s.clients {
+	for _, c := range s.snapshot() {
 		if c.IsSubscribed(sessionID) {
 			c.Send(data)
 		}
@@ -51,3 +47,15 @@ func (s *Server) BroadcastClient(clientID string, event protocol.EventFrame) {
 		c.Send(data)
 	}
 }
+
+// snapshot returns the connected clients, so sends happen without holding
+// the server lock.
+func (s *Server) snapshot() []*Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	clients := make([]*Client, 0, len(s.clients))
+	for _, c := range s.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/coder/websocket"
 	"github.com/google/uuid"
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
 )
 
 const (
@@ -20,17 +21,29 @@ const (
 // Client represents a single WebSocket connection.
 type Client struct {
 	ID        string
+	PeerID    string // peer ID of a verified client token, otherwise ID
+	Admin     string // admin name, if the connection used an admin token
 	conn      *websocket.Conn
 	sendCh    chan []byte
 	server    *Server
 	sessions  map[string]bool // subscribed session IDs
 	mu        sync.RWMutex
 	cancelCtx context.CancelFunc
+	sendMu    sync.Mutex
+	dropped   int64     // frames dropped since the last frames.dropped notice, guarded by sendMu
+	backlog   [][]byte  // frames waiting for buffer room under "block", guarded by sendMu
+	stalled   time.Time // when the backlog started, guarded by sendMu
+	closed    bool      // sendCh is closed, guarded by sendMu
 }
 
-func newClient(conn *websocket.Conn, srv *Server) *Client {
+func newClient(conn *websocket.Conn, srv *Server, peerID string) *Client {
+	id := uuid.New().String()
+	if peerID == "" {
+		peerID = id
+	}
 	return &Client{
-		ID:       uuid.New().String(),
+		ID:       id,
+		PeerID:   peerID,
 		conn:     conn,
 		sendCh:   make(chan []byte, sendChCap),
 		server:   srv,
@@ -52,13 +65,106 @@ func (c *Client) IsSubscribed(sessionID string) bool {
 	return c.sessions[sessionID]
 }
 
-// Send enqueues a message for delivery. Drops if buffer is full.
+// Send enqueues a message for delivery. When the buffer is full the
+// server's send overflow policy decides which frame is lost, if any. The
+// client is told how many frames it missed once there is room again.
+//
+// Send never waits for the client: under "block" frames that do not fit
+// are held in a backlog the writer drains, and a client that stays backed
+// up for longer than the send timeout is disconnected.
 func (c *Client) Send(data []byte) {
-	select {
-	case c.sendCh <- data:
-	default:
-		slog.Warn("client send buffer full, dropping message", "client", c.ID)
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if c.closed {
+		return
+	}
+
+	if n := c.dropped; n > 0 {
+		notice := mustJSON(protocol.EventFrame{
+			Event: protocol.EventFramesDropped,
+			Data:  mustJSON(map[string]int64{"count": n}),
+		})
+		select {
+		case c.sendCh <- notice:
+			c.dropped = 0
+		default:
+		}
+	}
+
+	if len(c.backlog) == 0 {
+		select {
+		case c.sendCh <- data:
+			return
+		default:
+		}
+	}
+
+	switch c.server.cfg.SendOverflow {
+	case "drop_oldest":
+		for {
+			select {
+			case <-c.sendCh:
+				c.drop("client send buffer full, dropped oldest frame")
+			default:
+			}
+			select {
+			case c.sendCh <- data:
+				return
+			default:
+			}
+		}
+	case "block":
+		if len(c.backlog) == 0 {
+			c.stalled = time.Now()
+		} else if time.Since(c.stalled) > c.server.cfg.SendTimeout {
+			c.server.framesDropped.Add(int64(len(c.backlog)) + 1)
+			c.backlog = nil
+			slog.Warn("client send buffer full past timeout, disconnecting", "client", c.ID)
+			if c.cancelCtx != nil {
+				c.cancelCtx()
+			}
+			return
+		}
+		c.backlog = append(c.backlog, data)
+		return
 	}
+	c.drop("client send buffer full, dropping message")
+}
+
+// refill moves backlogged frames into the send buffer while it has room.
+func (c *Client) refill() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if c.closed {
+		return
+	}
+	for len(c.backlog) > 0 {
+		select {
+		case c.sendCh <- c.backlog[0]:
+			c.backlog[0] = nil
+			c.backlog = c.backlog[1:]
+		default:
+			return
+		}
+	}
+	c.backlog = nil
+}
+
+// close closes the send buffer; later sends are discarded.
+func (c *Client) close() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if !c.closed {
+		c.closed = true
+		c.backlog = nil
+		close(c.sendCh)
+	}
+}
+
+func (c *Client) drop(msg string) {
+	c.dropped++
+	c.server.framesDropped.Add(1)
+	slog.Warn(msg, "client", c.ID)
 }
 
 // readPump reads frames from the WebSocket and dispatches them.
@@ -99,6 +205,7 @@ func (c *Client) writePump(ctx context.Context) {
 				slog.Debug("client write error", "client", c.ID, "err", err)
 				return
 			}
+			c.refill()
 		}
 	}
 }
@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/harshadpatil/dhaavak/pkg/protocol"
+)
+
+// MethodHandler serves a request method registered with Handle.
+// The returned value is marshalled as the response result.
+type MethodHandler func(ctx context.Context, clientID string, params json.RawMessage) (interface{}, error)
+
+// Error is returned by a MethodHandler to choose the response error code.
+// Any other error is reported as a 500.
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Errorf builds an *Error with a formatted message.
+func Errorf(code int, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Handle registers a handler for a request method. It must be called before Start.
+func (s *Server) Handle(method string, h MethodHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.methods == nil {
+		s.methods = make(map[string]MethodHandler)
+	}
+	s.methods[method] = h
+}
+
+// HandleAdmin registers a handler that only clients connected with an admin
+// token may call; others get a 403. It must be called before Start.
+func (s *Server) HandleAdmin(method string, h MethodHandler) {
+	s.Handle(method, func(ctx context.Context, clientID string, params json.RawMessage) (interface{}, error) {
+		if _, ok := s.ClientAdmin(clientID); !ok {
+			return nil, Errorf(403, "%s requires an admin token", method)
+		}
+		return h(ctx, clientID, params)
+	})
+}
+
+func (s *Server) method(name string) (MethodHandler, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h, ok := s.methods[name]
+	return h, ok
+}
+
+// serveMethod runs a registered handler and replies with its result.
+func (s *Server) serveMethod(c *Client, req protocol.RequestFrame, h MethodHandler) {
+	result, err := h(context.Background(), c.ID, req.Params)
+	if err != nil {
+		code, msg := 500, err.Error()
+		var gerr *Error
+		if errors.As(err, &gerr) {
+			code, msg = gerr.Code, gerr.Message
+		} else {
+			slog.Error("method handler error", "method", req.Method, "err", err)
+		}
+		c.sendJSON(protocol.ResponseFrame{
+			ID:    req.ID,
+			Error: &protocol.ErrorDetail{Code: code, Message: msg},
+		})
+		return
+	}
+	c.sendJSON(protocol.ResponseFrame{
+		ID:     req.ID,
+		Result: mustJSON(result),
+	})
+}
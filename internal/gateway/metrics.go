@@ -0,0 +1,84 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// counter is a monotonically increasing value exposed on /metrics. A
+// counter with samples is a labeled family instead of a single value.
+type counter struct {
+	name    string
+	help    string
+	value   func() int64
+	samples func() []Sample
+}
+
+// Sample is one labeled value of a counter family.
+type Sample struct {
+	Labels map[string]string
+	Value  int64
+}
+
+// Counter registers a counter served on /metrics in the Prometheus text
+// format. It must be called before Start.
+func (s *Server) Counter(name, help string, value func() int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters = append(s.counters, counter{name: name, help: help, value: value})
+}
+
+// CounterVec registers a family of labeled counters, one per sample. It
+// must be called before Start.
+func (s *Server) CounterVec(name, help string, samples func() []Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters = append(s.counters, counter{name: name, help: help, samples: samples})
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if ok, _ := s.auth.Check(r); !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.mu.RLock()
+	counters := append([]counter{{
+		name:  "dhaavak_ws_frames_dropped_total",
+		help:  "WebSocket frames dropped because a client's send buffer was full.",
+		value: s.framesDropped.Load,
+	}}, s.counters...)
+	s.mu.RUnlock()
+	sort.Slice(counters, func(i, j int) bool { return counters[i].name < counters[j].name })
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		if c.samples == nil {
+			fmt.Fprintf(w, "%s %d\n", c.name, c.value())
+			continue
+		}
+		for _, sm := range c.samples() {
+			fmt.Fprintf(w, "%s%s %d\n", c.name, formatLabels(sm.Labels), sm.Value)
+		}
+	}
+}
+
+// formatLabels renders labels as {a="1",b="2"}, sorted by name.
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strconv.Quote(labels[name])
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
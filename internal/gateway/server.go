@@ -3,14 +3,17 @@ package gateway
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/coder/websocket"
+	"github.com/google/uuid"
 	"github.com/harshadpatil/dhaavak/internal/config"
 	"github.com/harshadpatil/dhaavak/pkg/protocol"
 )
@@ -20,21 +23,26 @@ type MessageHandler func(ctx context.Context, clientID string, msg protocol.Inbo
 
 // Server is the WebSocket gateway.
 type Server struct {
-	cfg          config.ServerConfig
-	auth         *Authenticator
-	clients      map[string]*Client
-	mu           sync.RWMutex
-	httpServer   *http.Server
-	RunState     *RunState
-	ChatState    *ChatRunState
-	OnChatSend   MessageHandler
+	cfg        config.ServerConfig
+	auth       *Authenticator
+	clients    map[string]*Client
+	mu         sync.RWMutex
+	httpServer *http.Server
+	RunState   *RunState
+	ChatState  *ChatRunState
+	OnChatSend MessageHandler
+	methods    map[string]MethodHandler
+	counters   []counter
+	handlers   map[string]http.Handler
+
+	framesDropped atomic.Int64
 }
 
 // New creates a new gateway server.
-func New(cfg config.ServerConfig, authToken string) *Server {
+func New(cfg config.ServerConfig, auth config.AuthConfig) *Server {
 	s := &Server{
 		cfg:      cfg,
-		auth:     NewAuthenticator(authToken),
+		auth:     NewAuthenticator(auth),
 		clients:  make(map[string]*Client),
 		RunState: NewRunState(),
 	}
@@ -47,6 +55,10 @@ func (s *Server) Start(ctx context.Context) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", s.handleWebSocket)
 	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	for pattern, h := range s.handlers {
+		mux.Handle(pattern, h)
+	}
 
 	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.Port)
 	s.httpServer = &http.Server{
@@ -63,11 +75,23 @@ func (s *Server) Start(ctx context.Context) error {
 	return nil
 }
 
+// HandleHTTP mounts an HTTP handler on the gateway mux, e.g. a channel's
+// webhook. The gateway token is not checked; the handler must authenticate
+// its requests itself. It must be called before Start.
+func (s *Server) HandleHTTP(pattern string, h http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handlers == nil {
+		s.handlers = make(map[string]http.Handler)
+	}
+	s.handlers[pattern] = h
+}
+
 // Stop gracefully shuts down the gateway.
 func (s *Server) Stop(ctx context.Context) error {
 	s.mu.Lock()
 	for _, c := range s.clients {
-		close(c.sendCh)
+		c.close()
 	}
 	s.clients = make(map[string]*Client)
 	s.mu.Unlock()
@@ -85,7 +109,8 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	if !s.auth.Check(r) {
+	ok, admin := s.auth.Check(r)
+	if !ok {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -98,22 +123,77 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A client token from an earlier connected event gives a client a stable
+	// peer ID across reconnects, so sessions and identity links survive them.
+	// Tokens this gateway did not sign are ignored, so a client cannot claim
+	// another client's peer ID.
+	token := r.URL.Query().Get("client_token")
+	if token == "" {
+		token = r.Header.Get("X-Client-Token")
+	}
+	var peerID string
+	if token != "" {
+		var valid bool
+		if peerID, valid = s.auth.VerifyClient(token); !valid {
+			slog.Warn("invalid client token ignored", "remote", r.RemoteAddr)
+		}
+	}
+
 	ctx, cancel := context.WithCancel(r.Context())
-	client := newClient(conn, s)
+	client := newClient(conn, s, peerID)
 	client.cancelCtx = cancel
+	client.Admin = admin
 
 	s.register(client)
 
 	// Send connected event.
 	client.sendJSON(protocol.EventFrame{
 		Event: protocol.EventConnected,
-		Data:  mustJSON(map[string]string{"client_id": client.ID}),
+		Data: mustJSON(map[string]string{
+			"client_id":    client.ID,
+			"client_token": s.auth.SignClient(client.PeerID),
+		}),
 	})
 
 	go client.writePump(ctx)
 	client.readPump(ctx) // blocks until disconnect
 }
 
+// SubscribePeer subscribes every client connected as peerID to events for a
+// session. It is used when the session key is only known after routing.
+func (s *Server) SubscribePeer(peerID, sessionID string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, c := range s.clients {
+		if c.PeerID == peerID {
+			c.Subscribe(sessionID)
+		}
+	}
+}
+
+// ClientPeer returns the peer ID of a connected client.
+func (s *Server) ClientPeer(clientID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.clients[clientID]
+	if !ok {
+		return "", false
+	}
+	return c.PeerID, true
+}
+
+// ClientAdmin returns the admin name a client connected with, if it used
+// an admin token.
+func (s *Server) ClientAdmin(clientID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.clients[clientID]
+	if !ok || c.Admin == "" {
+		return "", false
+	}
+	return c.Admin, true
+}
+
 func (s *Server) register(c *Client) {
 	s.mu.Lock()
 	s.clients[c.ID] = c
@@ -124,7 +204,7 @@ func (s *Server) register(c *Client) {
 func (s *Server) unregister(c *Client) {
 	s.mu.Lock()
 	if _, ok := s.clients[c.ID]; ok {
-		close(c.sendCh)
+		c.close()
 		delete(s.clients, c.ID)
 	}
 	s.mu.Unlock()
@@ -154,6 +234,10 @@ func (s *Server) handleMessage(c *Client, data []byte) {
 		s.handleChatSend(c, req)
 
 	default:
+		if h, ok := s.method(req.Method); ok {
+			go s.serveMethod(c, req, h)
+			return
+		}
 		c.sendJSON(protocol.ResponseFrame{
 			ID:    req.ID,
 			Error: &protocol.ErrorDetail{Code: 404, Message: "unknown method: " + req.Method},
@@ -166,6 +250,8 @@ func (s *Server) handleChatSend(c *Client, req protocol.RequestFrame) {
 		SessionID string `json:"session_id"`
 		Text      string `json:"text"`
 		AgentID   string `json:"agent_id"`
+		MessageID string `json:"message_id"` // optional client-side ID; resends with the same ID are dropped
+		Priority  string `json:"priority"`   // optional queue priority: "normal", "high"; admin connections only
 	}
 	if err := json.Unmarshal(req.Params, &params); err != nil {
 		c.sendJSON(protocol.ResponseFrame{
@@ -175,30 +261,56 @@ func (s *Server) handleChatSend(c *Client, req protocol.RequestFrame) {
 		return
 	}
 
-	c.Subscribe(params.SessionID)
+	if params.SessionID != "" {
+		c.Subscribe(params.SessionID)
+	}
 
-	// Acknowledge the request.
-	c.sendJSON(protocol.ResponseFrame{
-		ID:     req.ID,
-		Result: mustJSON(map[string]string{"status": "queued"}),
-	})
+	// Any client could otherwise put its messages ahead of everyone else's
+	// in the limiter; only admin connections may raise their priority.
+	if c.Admin == "" {
+		params.Priority = ""
+	}
 
+	msgID := params.MessageID
+	if msgID == "" {
+		msgID = uuid.New().String()
+	}
 	msg := protocol.InboundMessage{
+		MessageID: "websocket:" + c.PeerID + ":" + msgID,
 		SessionID: params.SessionID,
 		Channel:   "websocket",
 		PeerKind:  "user",
-		PeerID:    c.ID,
+		PeerID:    c.PeerID,
+		SenderID:  c.PeerID,
 		Text:      params.Text,
 		AgentID:   params.AgentID,
+		Priority:  params.Priority,
 	}
 
-	if s.OnChatSend != nil {
-		go func() {
+	// The request is acknowledged once the handler has accepted the message,
+	// so a client that sees "queued" knows it will not be lost.
+	go func() {
+		if s.OnChatSend != nil {
 			if err := s.OnChatSend(context.Background(), c.ID, msg); err != nil {
-				slog.Error("chat.send handler error", "err", err)
+				code, text := 500, "chat.send failed"
+				var gerr *Error
+				if errors.As(err, &gerr) {
+					code, text = gerr.Code, gerr.Message
+				} else {
+					slog.Error("chat.send handler error", "err", err)
+				}
+				c.sendJSON(protocol.ResponseFrame{
+					ID:    req.ID,
+					Error: &protocol.ErrorDetail{Code: code, Message: text},
+				})
+				return
 			}
-		}()
-	}
+		}
+		c.sendJSON(protocol.ResponseFrame{
+			ID:     req.ID,
+			Result: mustJSON(map[string]string{"status": "queued"}),
+		})
+	}()
 }
 
 func mustJSON(v interface{}) json.RawMessage {
@@ -0,0 +1,319 @@
+package identity
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidCode is returned when a link code is unknown or expired.
+	ErrInvalidCode = errors.New("invalid or expired link code")
+
+	// ErrNameTaken is returned when a requested canonical name belongs to someone else.
+	ErrNameTaken = errors.New("identity name already taken")
+
+	// ErrSamePeer is returned when a peer tries to confirm its own link code.
+	ErrSamePeer = errors.New("link code must be confirmed from a different peer")
+
+	// ErrTooManyAttempts is returned when a peer has submitted too many wrong codes.
+	ErrTooManyAttempts = errors.New("too many failed link attempts, try again later")
+)
+
+// maxFailedConfirms bounds wrong codes per peer within one code TTL, so the
+// 6-digit code space cannot be brute-forced.
+const maxFailedConfirms = 5
+
+// maxTotalFailedConfirms bounds wrong codes across all peers within one code
+// TTL. Reaching it burns every pending code, so spreading guesses over many
+// peer IDs does not help either.
+const maxTotalFailedConfirms = 20
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.-]{0,63}$`)
+
+// Peer identifies a user on one channel.
+type Peer struct {
+	Channel string `json:"channel"`
+	PeerID  string `json:"peer_id"`
+}
+
+func (p Peer) String() string { return p.Channel + "/" + p.PeerID }
+
+// pendingLink is a link code waiting to be confirmed from a second peer.
+type pendingLink struct {
+	from      Peer
+	name      string
+	expiresAt time.Time
+}
+
+// failedConfirms counts wrong codes submitted by one peer.
+type failedConfirms struct {
+	count int
+	since time.Time
+}
+
+// add records one more failure, starting the window on the first.
+func (f failedConfirms) add() failedConfirms {
+	if f.count == 0 {
+		f.since = time.Now()
+	}
+	f.count++
+	return f
+}
+
+// Directory maps channel peers to canonical user identities.
+//
+// Linking is a two-step flow: a peer starts a link and receives a short code,
+// then confirms the code from another peer. Both peers then resolve to the
+// same canonical identity.
+type Directory struct {
+	mu      sync.Mutex
+	peers   map[Peer]string // peer -> canonical user ID
+	pending map[string]pendingLink
+	failed  map[Peer]failedConfirms
+	total   failedConfirms // wrong codes from all peers
+	codeTTL time.Duration
+	store   Store
+}
+
+// NewDirectory creates a directory backed by store. A nil store keeps links in memory only.
+func NewDirectory(store Store, codeTTL time.Duration) (*Directory, error) {
+	d := &Directory{
+		peers:   make(map[Peer]string),
+		pending: make(map[string]pendingLink),
+		failed:  make(map[Peer]failedConfirms),
+		codeTTL: codeTTL,
+		store:   store,
+	}
+	if store != nil {
+		links, err := store.Load()
+		if err != nil {
+			return nil, fmt.Errorf("load identities: %w", err)
+		}
+		for _, l := range links {
+			d.peers[l.Peer] = l.UserID
+		}
+	}
+	return d, nil
+}
+
+// Resolve returns the canonical user ID for a peer, if it is linked.
+func (d *Directory) Resolve(channel, peerID string) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id, ok := d.peers[Peer{Channel: channel, PeerID: peerID}]
+	return id, ok
+}
+
+// Peers returns every peer linked to a canonical user ID.
+func (d *Directory) Peers(userID string) []Peer {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []Peer
+	for p, id := range d.peers {
+		if id == userID {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].String() < out[j].String() })
+	return out
+}
+
+// StartLink issues a link code for a peer. If name is set, it becomes the
+// canonical user ID when the link is confirmed.
+func (d *Directory) StartLink(channel, peerID, name string) (string, time.Time, error) {
+	from := Peer{Channel: channel, PeerID: peerID}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if name != "" {
+		if !namePattern.MatchString(name) {
+			return "", time.Time{}, fmt.Errorf("invalid identity name %q", name)
+		}
+		if d.nameTaken(name, from) {
+			return "", time.Time{}, ErrNameTaken
+		}
+	}
+
+	d.expire()
+	code, err := d.newCode()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expires := time.Now().Add(d.codeTTL)
+	d.pending[code] = pendingLink{from: from, name: name, expiresAt: expires}
+	slog.Info("identity link started", "peer", from.String())
+	return code, expires, nil
+}
+
+// ConfirmLink completes a link started from another peer and returns the
+// canonical user ID both peers now resolve to. The name requested in
+// StartLink wins; otherwise the starting peer's identity is kept and the
+// confirming peer's identity, if any, is merged into it.
+func (d *Directory) ConfirmLink(code, channel, peerID string) (string, error) {
+	to := Peer{Channel: channel, PeerID: peerID}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.expire()
+	if f := d.failed[to]; f.count >= maxFailedConfirms {
+		return "", ErrTooManyAttempts
+	}
+	pl, ok := d.pending[code]
+	if !ok {
+		d.failed[to] = d.failed[to].add()
+		d.total = d.total.add()
+		if d.total.count >= maxTotalFailedConfirms {
+			slog.Warn("identity link codes burned after repeated failures", "pending", len(d.pending), "failures", d.total.count)
+			clear(d.pending)
+			d.total = failedConfirms{}
+		}
+		return "", ErrInvalidCode
+	}
+	if pl.from == to {
+		return "", ErrSamePeer
+	}
+	delete(d.pending, code)
+	delete(d.failed, to)
+
+	fromID, fromOK := d.peers[pl.from]
+	toID, toOK := d.peers[to]
+
+	var userID string
+	switch {
+	case pl.name != "":
+		if d.nameTaken(pl.name, pl.from, to) {
+			return "", ErrNameTaken
+		}
+		userID = pl.name
+	case fromOK:
+		userID = fromID
+	case toOK:
+		userID = toID
+	default:
+		userID = "user-" + randomHex(4)
+	}
+
+	prev := make(map[Peer]string, len(d.peers))
+	for p, id := range d.peers {
+		prev[p] = id
+	}
+
+	// Move every peer of either side's previous identity to the new one.
+	for p, id := range d.peers {
+		if (fromOK && id == fromID) || (toOK && id == toID) {
+			d.peers[p] = userID
+		}
+	}
+	d.peers[pl.from] = userID
+	d.peers[to] = userID
+
+	if err := d.save(); err != nil {
+		d.peers = prev
+		return "", err
+	}
+	slog.Info("identity linked", "user", userID, "from", pl.from.String(), "to", to.String())
+	return userID, nil
+}
+
+// Unlink removes a peer's identity mapping.
+func (d *Directory) Unlink(channel, peerID string) error {
+	p := Peer{Channel: channel, PeerID: peerID}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id, ok := d.peers[p]
+	if !ok {
+		return nil
+	}
+	delete(d.peers, p)
+	if err := d.save(); err != nil {
+		d.peers[p] = id
+		return err
+	}
+	slog.Info("identity unlinked", "user", id, "peer", p.String())
+	return nil
+}
+
+// nameTaken reports whether name is in use by an identity other than the
+// current identity of one of the given peers. Callers must hold d.mu.
+func (d *Directory) nameTaken(name string, peers ...Peer) bool {
+	used := false
+	for _, id := range d.peers {
+		if id == name {
+			used = true
+			break
+		}
+	}
+	if !used {
+		return false
+	}
+	for _, p := range peers {
+		if d.peers[p] == name {
+			return false
+		}
+	}
+	return true
+}
+
+// expire drops stale link codes and failure counters. Callers must hold d.mu.
+func (d *Directory) expire() {
+	now := time.Now()
+	for code, pl := range d.pending {
+		if now.After(pl.expiresAt) {
+			delete(d.pending, code)
+		}
+	}
+	for p, f := range d.failed {
+		if now.Sub(f.since) > d.codeTTL {
+			delete(d.failed, p)
+		}
+	}
+	if now.Sub(d.total.since) > d.codeTTL {
+		d.total = failedConfirms{}
+	}
+}
+
+// newCode returns an unused 6-digit code. Callers must hold d.mu.
+func (d *Directory) newCode() (string, error) {
+	for range 10 {
+		n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+		if err != nil {
+			return "", fmt.Errorf("generate link code: %w", err)
+		}
+		code := fmt.Sprintf("%06d", n.Int64())
+		if _, used := d.pending[code]; !used {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("generate link code: too many pending links")
+}
+
+// save writes all links to the store. Callers must hold d.mu.
+func (d *Directory) save() error {
+	if d.store == nil {
+		return nil
+	}
+	links := make([]Link, 0, len(d.peers))
+	for p, id := range d.peers {
+		links = append(links, Link{Peer: p, UserID: id})
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Peer.String() < links[j].Peer.String() })
+	return d.store.Save(links)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,150 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLinkFlow(t *testing.T) {
+	d, err := NewDirectory(nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, err := d.StartLink("telegram", "42", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ConfirmLink(code, "telegram", "42"); !errors.Is(err, ErrSamePeer) {
+		t.Fatalf("self-confirm: got %v, want ErrSamePeer", err)
+	}
+
+	userID, err := d.ConfirmLink(code, "websocket", "tok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []Peer{{"telegram", "42"}, {"websocket", "tok"}} {
+		if got, ok := d.Resolve(p.Channel, p.PeerID); !ok || got != userID {
+			t.Errorf("Resolve(%s) = %q, %v; want %q", p, got, ok, userID)
+		}
+	}
+	if _, err := d.ConfirmLink(code, "websocket", "other"); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("reused code: got %v, want ErrInvalidCode", err)
+	}
+
+	if err := d.Unlink("websocket", "tok"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.Resolve("websocket", "tok"); ok {
+		t.Error("unlinked peer still resolves")
+	}
+}
+
+func TestLinkMerge(t *testing.T) {
+	d, _ := NewDirectory(nil, time.Minute)
+
+	link := func(fromCh, from, toCh, to, name string) string {
+		t.Helper()
+		code, _, err := d.StartLink(fromCh, from, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := d.ConfirmLink(code, toCh, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	alice := link("telegram", "1", "websocket", "a", "alice")
+	if alice != "alice" {
+		t.Fatalf("named link = %q, want alice", alice)
+	}
+	other := link("slack", "U1", "discord", "D1", "")
+
+	// Linking a peer of each identity merges them into the starter's.
+	if got := link("telegram", "1", "slack", "U1", ""); got != "alice" {
+		t.Fatalf("merge = %q, want alice", got)
+	}
+	if got := d.Peers("alice"); len(got) != 4 {
+		t.Errorf("Peers(alice) = %v, want 4 peers", got)
+	}
+	if got := d.Peers(other); len(got) != 0 {
+		t.Errorf("Peers(%s) = %v, want none", other, got)
+	}
+
+	if _, _, err := d.StartLink("matrix", "@bob", "alice"); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("taken name: got %v, want ErrNameTaken", err)
+	}
+	if _, _, err := d.StartLink("telegram", "1", "alice"); err != nil {
+		t.Errorf("owner reusing own name: %v", err)
+	}
+}
+
+func TestConfirmAttemptLimit(t *testing.T) {
+	d, _ := NewDirectory(nil, time.Minute)
+	code, _, _ := d.StartLink("telegram", "42", "")
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "000001"
+	}
+	for range maxFailedConfirms {
+		d.ConfirmLink(wrong, "websocket", "tok")
+	}
+	if _, err := d.ConfirmLink(code, "websocket", "tok"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("after %d failures: got %v, want ErrTooManyAttempts", maxFailedConfirms, err)
+	}
+	// Other peers are unaffected.
+	if _, err := d.ConfirmLink(code, "websocket", "other"); err != nil {
+		t.Errorf("other peer: %v", err)
+	}
+}
+
+func TestConfirmFailuresBurnCodes(t *testing.T) {
+	d, _ := NewDirectory(nil, time.Minute)
+	code, _, _ := d.StartLink("telegram", "42", "")
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "000001"
+	}
+	// Each peer stays under its own limit, but together they exhaust the
+	// directory-wide one.
+	for i := range maxTotalFailedConfirms {
+		d.ConfirmLink(wrong, "websocket", fmt.Sprintf("peer-%d", i))
+	}
+	if _, err := d.ConfirmLink(code, "websocket", "tok"); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("after %d failures: got %v, want ErrInvalidCode", maxTotalFailedConfirms, err)
+	}
+
+	// A new code works again.
+	code, _, _ = d.StartLink("telegram", "42", "")
+	if _, err := d.ConfirmLink(code, "websocket", "tok"); err != nil {
+		t.Errorf("new code: %v", err)
+	}
+}
+
+func TestFileStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids", "identities.json")
+
+	d, err := NewDirectory(NewFileStore(path), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, _ := d.StartLink("telegram", "42", "carol")
+	if _, err := d.ConfirmLink(code, "websocket", "tok"); err != nil {
+		t.Fatal(err)
+	}
+
+	d2, err := NewDirectory(NewFileStore(path), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := d2.Resolve("websocket", "tok"); !ok || got != "carol" {
+		t.Errorf("after reload Resolve = %q, %v; want carol", got, ok)
+	}
+}
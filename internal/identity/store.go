@@ -0,0 +1,64 @@
+package identity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Link maps one peer to a canonical user ID.
+type Link struct {
+	Peer
+	UserID string `json:"user_id"`
+}
+
+// Store persists identity links.
+type Store interface {
+	Load() ([]Link, error)
+	Save(links []Link) error
+}
+
+// FileStore keeps identity links in a JSON file, rewritten atomically on every change.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore creates a file store at path. The file is created on first save.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) Load() ([]Link, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var links []Link
+	if err := json.Unmarshal(data, &links); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", s.path, err)
+	}
+	return links, nil
+}
+
+func (s *FileStore) Save(links []Link) error {
+	data, err := json.MarshalIndent(links, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("identity store dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write identities: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("write identities: %w", err)
+	}
+	return nil
+}
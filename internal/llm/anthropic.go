@@ -48,8 +48,10 @@ func (a *AnthropicProvider) Complete(ctx context.Context, systemPrompt string, m
 	}
 
 	return &CompletionResult{
-		Content:    blocks,
-		StopReason: string(resp.StopReason),
+		Content:      blocks,
+		StopReason:   string(resp.StopReason),
+		InputTokens:  resp.Usage.InputTokens,
+		OutputTokens: resp.Usage.OutputTokens,
 	}, nil
 }
 
@@ -107,8 +109,10 @@ func (a *AnthropicProvider) Stream(ctx context.Context, systemPrompt string, mes
 
 			case "message_stop":
 				ch <- StreamEvent{
-					Type:       "complete",
-					StopReason: string(accumulated.StopReason),
+					Type:         "complete",
+					StopReason:   string(accumulated.StopReason),
+					InputTokens:  accumulated.Usage.InputTokens,
+					OutputTokens: accumulated.Usage.OutputTokens,
 				}
 			}
 		}
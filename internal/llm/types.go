@@ -41,7 +41,9 @@ type StreamEvent struct {
 	ToolInput string
 
 	// Complete fields
-	StopReason string
+	StopReason   string
+	InputTokens  int64
+	OutputTokens int64
 
 	// Error fields
 	Err error
@@ -49,6 +51,8 @@ type StreamEvent struct {
 
 // CompletionResult is the outcome of a non-streaming call.
 type CompletionResult struct {
-	Content    []ContentBlock
-	StopReason string
+	Content      []ContentBlock
+	StopReason   string
+	InputTokens  int64
+	OutputTokens int64
 }
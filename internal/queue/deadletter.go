@@ -0,0 +1,178 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucketDead = []byte("dead")
+
+// ErrNotFound is returned for an unknown dead letter ID.
+var ErrNotFound = errors.New("queue: dead letter not found")
+
+// DeadLetter is a task that failed or timed out, kept for inspection and retry.
+type DeadLetter struct {
+	ID        string          `json:"id"`
+	SessionID string          `json:"session_id"`
+	AgentID   string          `json:"agent_id,omitempty"`
+	Text      string          `json:"text"`              // the task's input text
+	Payload   json.RawMessage `json:"payload,omitempty"` // what a retry is rebuilt from
+	Error     string          `json:"error"`
+	Attempts  int             `json:"attempts"`
+	FailedAt  time.Time       `json:"failed_at,omitzero"`
+}
+
+// DeadLetters is a bbolt store of failed tasks.
+type DeadLetters struct {
+	db     *bolt.DB
+	sealer Sealer
+}
+
+// OpenDeadLetters opens (or creates) a dead letter store at path. A nil
+// sealer stores records as plain JSON.
+func OpenDeadLetters(path string, sealer Sealer) (*DeadLetters, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("dead letter dir: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open dead letters %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketDead)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init dead letters: %w", err)
+	}
+	return &DeadLetters{db: db, sealer: sealer}, nil
+}
+
+// Add records a failure. A letter with an empty ID gets a new one and an
+// attempt count of 1; a letter whose ID is already stored, from a failed
+// retry, replaces it. FailedAt is set to now. The stored letter is returned.
+func (d *DeadLetters) Add(dl DeadLetter) (DeadLetter, error) {
+	dl.FailedAt = time.Now()
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketDead)
+		if dl.ID == "" {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			dl.ID = fmt.Sprintf("dl-%d", seq)
+			dl.Attempts = 1
+		}
+		data, err := d.encode(dl)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(dl.ID), data)
+	})
+	return dl, err
+}
+
+// Get returns one dead letter.
+func (d *DeadLetters) Get(id string) (DeadLetter, error) {
+	var dl DeadLetter
+	err := d.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketDead).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		var err error
+		dl, err = d.decode([]byte(id), data)
+		return err
+	})
+	return dl, err
+}
+
+// List returns the dead letters of a session, or all of them if sessionID
+// is empty, most recent failure first.
+func (d *DeadLetters) List(sessionID string) ([]DeadLetter, error) {
+	var out []DeadLetter
+	err := d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketDead).ForEach(func(k, v []byte) error {
+			dl, err := d.decode(k, v)
+			if err != nil {
+				return fmt.Errorf("dead letter %s: %w", k, err)
+			}
+			if sessionID == "" || dl.SessionID == sessionID {
+				out = append(out, dl)
+			}
+			return nil
+		})
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].FailedAt.After(out[j].FailedAt) })
+	return out, err
+}
+
+// Retry counts a new attempt for a dead letter and returns it. The letter
+// stays in the store until the retry succeeds and Remove is called.
+func (d *DeadLetters) Retry(id string) (DeadLetter, error) {
+	var dl DeadLetter
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketDead)
+		data := b.Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		var err error
+		if dl, err = d.decode([]byte(id), data); err != nil {
+			return err
+		}
+		dl.Attempts++
+		if data, err = d.encode(dl); err != nil {
+			return err
+		}
+		return b.Put([]byte(id), data)
+	})
+	return dl, err
+}
+
+// Remove deletes a dead letter. Unknown IDs are not an error.
+func (d *DeadLetters) Remove(id string) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketDead).Delete([]byte(id))
+	})
+}
+
+// Close closes the store.
+func (d *DeadLetters) Close() error {
+	return d.db.Close()
+}
+
+func (d *DeadLetters) encode(dl DeadLetter) ([]byte, error) {
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return nil, fmt.Errorf("marshal dead letter %s: %w", dl.ID, err)
+	}
+	if d.sealer == nil {
+		return data, nil
+	}
+	return d.sealer.Seal(data, []byte(dl.ID))
+}
+
+func (d *DeadLetters) decode(key, data []byte) (DeadLetter, error) {
+	var dl DeadLetter
+	if len(data) > 0 && data[0] != '{' {
+		if d.sealer == nil {
+			return dl, fmt.Errorf("dead letter is encrypted and no key is configured")
+		}
+		plain, err := d.sealer.Open(data, key)
+		if err != nil {
+			return dl, err
+		}
+		data = plain
+	}
+	err := json.Unmarshal(data, &dl)
+	return dl, err
+}
@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeadLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.db")
+	d, err := OpenDeadLetters(path, xorSealer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := d.Add(DeadLetter{SessionID: "s1", Text: "first", Payload: []byte(`{"text":"first"}`), Error: "boom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ID == "" || a.Attempts != 1 {
+		t.Fatalf("added letter = %+v", a)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := d.Add(DeadLetter{SessionID: "s2", Text: "second", Error: "timeout"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A retry counts an attempt; a failed retry replaces the letter.
+	r, err := d.Retry(a.ID)
+	if err != nil || r.Attempts != 2 {
+		t.Fatalf("Retry = %+v, %v", r, err)
+	}
+	r.Error = "boom again"
+	if _, err := d.Add(r); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	d, err = OpenDeadLetters(path, xorSealer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	all, err := d.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].ID != a.ID {
+		t.Fatalf("List = %+v, want the retried letter first", all)
+	}
+	if got := all[0]; got.Attempts != 2 || got.Error != "boom again" || string(got.Payload) != `{"text":"first"}` {
+		t.Errorf("retried letter = %+v", got)
+	}
+	if only, _ := d.List("s2"); len(only) != 1 || only[0].Text != "second" {
+		t.Errorf("List(s2) = %+v", only)
+	}
+
+	if err := d.Remove(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Get(a.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Remove err = %v", err)
+	}
+	if _, err := d.Retry("dl-missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Retry(missing) err = %v", err)
+	}
+}
+
+func TestTaskTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewManager(ctx, 64, time.Minute)
+	done := make(chan error, 1)
+	mgr.Enqueue(Task{
+		SessionID: "s",
+		Timeout:   10 * time.Millisecond,
+		Fn: func(ctx context.Context, _ string) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		OnDone: func(err error) { done <- err },
+	})
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrTimeout) {
+			t.Errorf("err = %v, want ErrTimeout", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out task never finished")
+	}
+}
@@ -0,0 +1,113 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// ErrPurged is passed to the OnDone of a queued task removed by Manager.Purge.
+var ErrPurged = errors.New("queue: task purged")
+
+// drainPoll is how often Drain checks whether the lanes are empty.
+const drainPoll = 50 * time.Millisecond
+
+// LaneInfo describes a lane for operators.
+type LaneInfo struct {
+	SessionID    string    `json:"session_id"`
+	Depth        int       `json:"depth"`                   // tasks waiting, including spilled ones
+	HighPriority int       `json:"high_priority,omitempty"` // of Depth, tasks queued ahead of the rest
+	Spilled      int       `json:"spilled,omitempty"`       // of Depth, tasks parked on disk
+	Current      *RunInfo  `json:"current,omitempty"`       // run in progress, if any
+	LastActive   time.Time `json:"last_active"`             // last enqueue, run start or run end
+}
+
+// RunInfo describes the run in progress on a lane.
+type RunInfo struct {
+	AgentID   string    `json:"agent_id,omitempty"`
+	Mode      Mode      `json:"mode,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Waiting   bool      `json:"waiting,omitempty"` // for limiter slots
+}
+
+// Lanes returns a snapshot of every lane, ordered by session.
+func (m *Manager) Lanes() []LaneInfo {
+	m.mu.Lock()
+	lanes := make([]*Lane, 0, len(m.lanes))
+	for _, l := range m.lanes {
+		lanes = append(lanes, l)
+	}
+	m.mu.Unlock()
+
+	out := make([]LaneInfo, len(lanes))
+	for i, l := range lanes {
+		out[i] = l.Info()
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SessionID < out[j].SessionID })
+	return out
+}
+
+// Purge removes the queued tasks of a session's lane and returns how many
+// there were. Their OnDone is called with ErrPurged. The run in progress is
+// not affected; use Cancel to stop it.
+func (m *Manager) Purge(sessionID string) int {
+	m.mu.Lock()
+	l := m.lanes[sessionID]
+	m.mu.Unlock()
+	var purged []Task
+	if l != nil {
+		purged = l.Purge()
+	}
+	for _, t := range purged {
+		if t.OnDone != nil {
+			t.OnDone(ErrPurged)
+		}
+	}
+	if len(purged) > 0 {
+		slog.Info("lane purged", "session", sessionID, "tasks", len(purged))
+	}
+	return len(purged)
+}
+
+// Drain stops Enqueue from accepting tasks and waits until every lane has
+// finished its queued work. It returns ctx's error if the lanes are still
+// busy when ctx is done. Tasks are refused until Resume is called.
+func (m *Manager) Drain(ctx context.Context) error {
+	m.draining.Store(true)
+	slog.Info("queue draining")
+	ticker := time.NewTicker(drainPoll)
+	defer ticker.Stop()
+	for !m.idle() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Resume makes Enqueue accept tasks again after Drain.
+func (m *Manager) Resume() {
+	if m.draining.Swap(false) {
+		slog.Info("queue resumed")
+	}
+}
+
+// Draining reports whether Drain has stopped the queue from accepting tasks.
+func (m *Manager) Draining() bool {
+	return m.draining.Load()
+}
+
+func (m *Manager) idle() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, l := range m.lanes {
+		if !l.idle() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLanesAndPurge(t *testing.T) {
+	m, release := busyManager(t, Policy{Overflow: OverflowReject})
+	defer close(release)
+
+	var purgedErr error
+	m.Enqueue(Task{SessionID: "s", Fn: func(ctx context.Context, _ string) error { return nil },
+		OnDone: func(err error) { purgedErr = err }})
+
+	lanes := m.Lanes()
+	if len(lanes) != 1 {
+		t.Fatalf("Lanes() = %+v, want one lane", lanes)
+	}
+	if l := lanes[0]; l.SessionID != "s" || l.Depth != 1 || l.HighPriority != 0 || l.Current == nil {
+		t.Errorf("lane = %+v", l)
+	}
+
+	if n := m.Purge("s"); n != 1 {
+		t.Errorf("Purge() = %d, want 1", n)
+	}
+	if !errors.Is(purgedErr, ErrPurged) {
+		t.Errorf("purged task finished with %v", purgedErr)
+	}
+	if l := m.Lanes()[0]; l.Depth != 0 || l.Current == nil {
+		t.Errorf("after purge lane = %+v, want the run kept and nothing queued", l)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	m, release := busyManager(t, Policy{Overflow: OverflowReject})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := m.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Drain() with a busy lane = %v", err)
+	}
+	if m.Enqueue(Task{SessionID: "t", Fn: func(ctx context.Context, _ string) error { return nil }}) {
+		t.Fatal("task accepted while draining")
+	}
+
+	close(release)
+	if err := m.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() = %v", err)
+	}
+	m.Resume()
+	if !m.Enqueue(Task{SessionID: "t", Fn: func(ctx context.Context, _ string) error { return nil }}) {
+		t.Fatal("task refused after Resume")
+	}
+}
+
+func TestLaneNotIdleWhileTaskTaken(t *testing.T) {
+	// A lane without its goroutine, so the test can take the task itself.
+	l := &Lane{laneConfig: laneConfig{stats: new(counters)}, tasks: make(chan Task, 1)}
+	if !l.Enqueue(Task{SessionID: "s"}) {
+		t.Fatal("task refused")
+	}
+	<-l.tasks // taken by the lane goroutine, not yet executing
+	if l.idle() {
+		t.Error("lane idle with a task taken but not started")
+	}
+}
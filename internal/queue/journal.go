@@ -0,0 +1,256 @@
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketPending = []byte("pending")
+	bucketDone    = []byte("done")
+)
+
+// maxReplays bounds how often an unfinished item is replayed, so a message
+// that crashes the process cannot do so forever.
+const maxReplays = 3
+
+// Sealer encrypts journal payloads at rest. session.Cipher implements it.
+type Sealer interface {
+	Seal(plaintext, aad []byte) ([]byte, error)
+	Open(sealed, aad []byte) ([]byte, error)
+}
+
+// JournalItem is one inbound work item recorded in the journal.
+type JournalItem struct {
+	ID      string          `json:"id"`
+	Payload json.RawMessage `json:"payload"`
+	AddedAt time.Time       `json:"added_at"`
+	Replays int             `json:"replays"`
+}
+
+// Journal is a durable record of inbound work, kept in a bbolt file.
+//
+// Items are added before a message is acknowledged and marked done once it
+// has been handled. Unfinished items are replayed on startup. Done IDs are
+// remembered for a retention period so a redelivered message is dropped
+// instead of being handled twice.
+type Journal struct {
+	db     *bolt.DB
+	sealer Sealer
+}
+
+// OpenJournal opens (or creates) a journal at path. A nil sealer stores
+// payloads as plain JSON.
+func OpenJournal(path string, sealer Sealer) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("queue journal dir: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open queue journal %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketPending, bucketDone} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init queue journal: %w", err)
+	}
+	return &Journal{db: db, sealer: sealer}, nil
+}
+
+// Add records a new item. It returns false, without recording anything, if
+// an item with the same ID is already pending or was recently done.
+func (j *Journal) Add(id string, payload any) (bool, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return false, fmt.Errorf("marshal journal item %s: %w", id, err)
+	}
+	added := false
+	err = j.db.Update(func(tx *bolt.Tx) error {
+		pending := tx.Bucket(bucketPending)
+		if pending.Get([]byte(id)) != nil || tx.Bucket(bucketDone).Get([]byte(id)) != nil {
+			return nil
+		}
+		data, err := j.encode(JournalItem{ID: id, Payload: raw, AddedAt: time.Now()})
+		if err != nil {
+			return err
+		}
+		added = true
+		return pending.Put([]byte(id), data)
+	})
+	if err != nil {
+		return false, err
+	}
+	return added, nil
+}
+
+// Done marks an item as handled.
+func (j *Journal) Done(id string) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketPending).Delete([]byte(id)); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketDone).Put([]byte(id), unixNano(time.Now()))
+	})
+}
+
+// Forget removes a pending item without marking it done, so the same ID is
+// accepted again. It is used when a message was rejected rather than handled.
+func (j *Journal) Forget(id string) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketPending).Delete([]byte(id))
+	})
+}
+
+// Replay calls fn for every pending item, oldest first, counting the
+// attempt. Items that have already been replayed maxReplays times are
+// marked done and skipped. It returns the number of items passed to fn.
+func (j *Journal) Replay(fn func(JournalItem) error) (int, error) {
+	var items []JournalItem
+	err := j.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketPending)
+		var all []JournalItem
+		err := b.ForEach(func(k, v []byte) error {
+			item, err := j.decode(k, v)
+			if err != nil {
+				return fmt.Errorf("journal item %s: %w", k, err)
+			}
+			all = append(all, item)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, item := range all {
+			if item.Replays >= maxReplays {
+				slog.Warn("journal item dropped after repeated replays", "id", item.ID, "replays", item.Replays)
+				if err := b.Delete([]byte(item.ID)); err != nil {
+					return err
+				}
+				if err := tx.Bucket(bucketDone).Put([]byte(item.ID), unixNano(time.Now())); err != nil {
+					return err
+				}
+				continue
+			}
+			item.Replays++
+			data, err := j.encode(item)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(item.ID), data); err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sort.Slice(items, func(a, b int) bool { return items[a].AddedAt.Before(items[b].AddedAt) })
+	for _, item := range items {
+		if err := fn(item); err != nil {
+			slog.Error("journal replay error", "id", item.ID, "err", err)
+		}
+	}
+	return len(items), nil
+}
+
+// StartCleanup launches a goroutine that forgets done IDs older than retention.
+func (j *Journal) StartCleanup(ctx context.Context, interval, retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n, err := j.prune(time.Now().Add(-retention)); err != nil {
+					slog.Error("journal cleanup error", "err", err)
+				} else if n > 0 {
+					slog.Debug("journal pruned", "done_ids", n)
+				}
+			}
+		}
+	}()
+}
+
+func (j *Journal) prune(before time.Time) (int, error) {
+	n := 0
+	err := j.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketDone)
+		var stale [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			if len(v) == 8 && int64(binary.BigEndian.Uint64(v)) < before.UnixNano() {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		n = len(stale)
+		return nil
+	})
+	return n, err
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	return j.db.Close()
+}
+
+func (j *Journal) encode(item JournalItem) ([]byte, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("marshal journal item %s: %w", item.ID, err)
+	}
+	if j.sealer == nil {
+		return data, nil
+	}
+	return j.sealer.Seal(data, []byte(item.ID))
+}
+
+func (j *Journal) decode(key, data []byte) (JournalItem, error) {
+	var item JournalItem
+	if len(data) > 0 && data[0] != '{' {
+		if j.sealer == nil {
+			return item, fmt.Errorf("journal item is encrypted and no key is configured")
+		}
+		plain, err := j.sealer.Open(data, key)
+		if err != nil {
+			return item, err
+		}
+		data = plain
+	}
+	err := json.Unmarshal(data, &item)
+	return item, err
+}
+
+func unixNano(t time.Time) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(t.UnixNano()))
+	return b
+}
@@ -0,0 +1,154 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// xorSealer is a reversible stand-in for session.Cipher.
+type xorSealer struct{}
+
+func (xorSealer) Seal(plain, aad []byte) ([]byte, error) {
+	out := []byte{0x01}
+	for i, b := range plain {
+		out = append(out, b^aad[i%len(aad)])
+	}
+	return out, nil
+}
+
+func (xorSealer) Open(sealed, aad []byte) ([]byte, error) {
+	if len(sealed) == 0 || sealed[0] != 0x01 {
+		return nil, errors.New("not sealed")
+	}
+	out := make([]byte, 0, len(sealed)-1)
+	for i, b := range sealed[1:] {
+		out = append(out, b^aad[i%len(aad)])
+	}
+	return out, nil
+}
+
+func TestJournalAddDedupe(t *testing.T) {
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "queue.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	steps := []struct {
+		op   string
+		id   string
+		want bool
+	}{
+		{"add", "m1", true},
+		{"add", "m1", false}, // still pending
+		{"done", "m1", false},
+		{"add", "m1", false}, // recently done
+		{"add", "m2", true},
+		{"forget", "m2", false},
+		{"add", "m2", true}, // rejected messages may be redelivered
+	}
+	for i, s := range steps {
+		switch s.op {
+		case "add":
+			added, err := j.Add(s.id, map[string]string{"text": s.id})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if added != s.want {
+				t.Errorf("step %d: Add(%s) = %v, want %v", i, s.id, added, s.want)
+			}
+		case "done":
+			if err := j.Done(s.id); err != nil {
+				t.Fatal(err)
+			}
+		case "forget":
+			if err := j.Forget(s.id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Once pruned, a done ID is accepted again.
+	if n, err := j.prune(time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("prune = %d, %v; want 1", n, err)
+	}
+	if added, _ := j.Add("m1", nil); !added {
+		t.Error("Add after prune = false")
+	}
+}
+
+func TestJournalReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.db")
+	j, err := OpenJournal(path, xorSealer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"first", "second", "third"} {
+		if _, err := j.Add(id, map[string]string{"text": "secret " + id}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := j.Done("second"); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	// Reopen as after a restart; unfinished items come back oldest first.
+	j, err = OpenJournal(path, xorSealer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	for round := 1; round <= maxReplays+1; round++ {
+		var ids []string
+		n, err := j.Replay(func(item JournalItem) error {
+			ids = append(ids, item.ID)
+			if !bytes.Contains(item.Payload, []byte("secret "+item.ID)) {
+				t.Errorf("payload = %s", item.Payload)
+			}
+			if item.Replays != round {
+				t.Errorf("round %d: %s replays = %d", round, item.ID, item.Replays)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 2
+		if round > maxReplays {
+			want = 0 // given up on
+		}
+		if n != want || len(ids) != want {
+			t.Fatalf("round %d: replayed %v, want %d items", round, ids, want)
+		}
+		if want > 0 && (ids[0] != "first" || ids[1] != "third") {
+			t.Errorf("round %d: order = %v", round, ids)
+		}
+	}
+}
+
+func TestJournalSealedNeedsKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.db")
+	j, err := OpenJournal(path, xorSealer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Add("m1", map[string]string{"text": "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	j, err = OpenJournal(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if _, err := j.Replay(func(JournalItem) error { return nil }); err == nil {
+		t.Error("Replay of sealed items without a key succeeded")
+	}
+}
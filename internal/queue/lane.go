@@ -2,42 +2,343 @@ package queue
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
 // Lane is a per-session serial execution queue.
-// One goroutine processes tasks sequentially from a buffered channel.
+// One goroutine processes tasks sequentially from buffered channels:
+// high-priority tasks first, then the rest in arrival order.
 type Lane struct {
+	laneConfig
 	sessionID string
 	tasks     chan Task
+	urgent    chan Task    // tasks above PriorityNormal
 	lastUsed  atomic.Int64 // unix nanos
+	inflight  atomic.Int64 // tasks sent to the buffer and not yet finished
 	cancel    context.CancelFunc
+	stopped   <-chan struct{}
+
+	mu        sync.Mutex
+	active    *activeRun  // run in progress, if any
+	batch     []Task      // collect tasks waiting for the debounce window
+	carry     string      // text of an interrupted run, prepended to the next run
+	carryDone func(error) // OnDone of the interrupted run, called with the next run
+	spilled   int         // tasks parked in the spill store
+}
+
+// laneConfig holds the manager settings a lane is created with.
+type laneConfig struct {
+	bufferSize int
+	debounce   time.Duration
+	limiter    *Limiter
+	policy     Policy
+	spill      *Spill
+	restore    func(payload []byte) (Task, error)
+	stats      *counters
+}
+
+// activeRun is the task currently executing on a lane.
+type activeRun struct {
+	task        Task
+	started     time.Time
+	waiting     bool // for its limiter slots
+	cancel      context.CancelFunc
+	steered     []Task // steer tasks handed to this run
+	consumed    int    // how many of steered the run has picked up
+	interrupted bool
+	canceled    bool
 }
 
-func newLane(sessionID string, bufferSize int, ctx context.Context) *Lane {
+type activeRunKey struct{}
+
+func newLane(sessionID string, cfg laneConfig, ctx context.Context) *Lane {
 	ctx, cancel := context.WithCancel(ctx)
+	if cfg.stats == nil {
+		cfg.stats = new(counters)
+	}
 	l := &Lane{
-		sessionID: sessionID,
-		tasks:     make(chan Task, bufferSize),
-		cancel:    cancel,
+		laneConfig: cfg,
+		sessionID:  sessionID,
+		tasks:      make(chan Task, cfg.bufferSize),
+		urgent:     make(chan Task, cfg.bufferSize),
+		cancel:     cancel,
+		stopped:    ctx.Done(),
 	}
 	l.touch()
 	go l.run(ctx)
 	return l
 }
 
-// Enqueue adds a task to the lane. Returns false if the buffer is full.
+// Enqueue adds a task to the lane. When the buffer is full the lane's
+// overflow policy applies; Enqueue returns false if the task was refused.
+//
+// A steer task is handed straight to the run in progress instead of being
+// queued; an interrupt task cancels the run in progress before it is queued.
+// A task above PriorityNormal runs before the tasks already queued, but never
+// alongside the run in progress; once the high-priority buffer is full it
+// queues like any other task.
 func (l *Lane) Enqueue(t Task) bool {
 	l.touch()
+
+	l.mu.Lock()
+	if a := l.active; a != nil {
+		switch t.Mode {
+		case ModeSteer:
+			a.steered = append(a.steered, t)
+			l.mu.Unlock()
+			slog.Debug("lane task steered into active run", "session", l.sessionID)
+			return true
+		case ModeInterrupt:
+			a.interrupted = true
+			a.cancel()
+			slog.Debug("lane run interrupted", "session", l.sessionID)
+		}
+	}
+
+	if t.Priority > PriorityNormal && l.trySend(l.urgent, t) {
+		l.mu.Unlock()
+		return true
+	}
+
+	// Once tasks are spilled, later ones queue up behind them on disk.
+	if l.spilled == 0 && l.trySend(l.tasks, t) {
+		l.mu.Unlock()
+		return true
+	}
+
+	switch l.policy.Overflow {
+	case OverflowDropOldest:
+		dropped := l.dropOldest(t)
+		l.mu.Unlock()
+		for _, old := range dropped {
+			if old.OnDone != nil {
+				old.OnDone(ErrDropped)
+			}
+		}
+		return true
+	case OverflowSpill:
+		defer l.mu.Unlock()
+		return l.spillTask(t)
+	case OverflowBlock:
+		l.mu.Unlock()
+		return l.block(t)
+	default:
+		l.mu.Unlock()
+		return l.reject("lane queue full")
+	}
+}
+
+// trySend puts t in buffer ch if there is room. inflight is raised before
+// the send, so a task the lane goroutine has taken but not yet started is
+// never invisible to idle.
+func (l *Lane) trySend(ch chan Task, t Task) bool {
+	l.inflight.Add(1)
 	select {
-	case l.tasks <- t:
+	case ch <- t:
 		return true
 	default:
-		slog.Warn("lane queue full", "session", l.sessionID)
+		l.inflight.Add(-1)
+		return false
+	}
+}
+
+// dropOldest discards queued tasks until t fits and returns them. Callers
+// must hold l.mu.
+func (l *Lane) dropOldest(t Task) []Task {
+	var dropped []Task
+	for {
+		if l.trySend(l.tasks, t) {
+			return dropped
+		}
+		select {
+		case old := <-l.tasks:
+			l.inflight.Add(-1)
+			dropped = append(dropped, old)
+			l.stats.dropped.Add(1)
+			slog.Warn("lane queue full, dropped oldest task", "session", l.sessionID)
+		default:
+		}
+	}
+}
+
+// spillTask parks t on disk. Callers must hold l.mu.
+func (l *Lane) spillTask(t Task) bool {
+	if l.spill == nil || l.restore == nil || t.Payload == nil {
+		return l.reject("lane queue full, task cannot be spilled")
+	}
+	if err := l.spill.Push(l.sessionID, t.Payload); err != nil {
+		slog.Error("lane spill error", "session", l.sessionID, "err", err)
+		return l.reject("lane queue full, spill failed")
+	}
+	l.spilled++
+	l.stats.spilled.Add(1)
+	slog.Debug("lane task spilled", "session", l.sessionID, "spilled", l.spilled)
+	return true
+}
+
+// block waits up to the policy timeout for room.
+func (l *Lane) block(t Task) bool {
+	timer := time.NewTimer(l.policy.Timeout)
+	defer timer.Stop()
+	l.inflight.Add(1)
+	select {
+	case l.tasks <- t:
+		return true
+	case <-timer.C:
+		l.inflight.Add(-1)
+		l.stats.blockTimeouts.Add(1)
+		return l.reject("lane queue full, timed out waiting for room")
+	case <-l.stopped:
+		l.inflight.Add(-1)
+		return l.reject("lane stopped")
+	}
+}
+
+func (l *Lane) reject(reason string) bool {
+	l.stats.rejected.Add(1)
+	slog.Warn(reason, "session", l.sessionID)
+	return false
+}
+
+// unspill moves spilled tasks back into the buffer while it has room. It
+// runs on the lane goroutine, so the buffer only fills up further through
+// Enqueue, which holds l.mu to send while tasks are spilled.
+func (l *Lane) unspill() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.spilled > 0 && len(l.tasks) < cap(l.tasks) {
+		payload, ok, err := l.spill.Pop(l.sessionID)
+		if err != nil {
+			slog.Error("lane unspill error", "session", l.sessionID, "err", err)
+			return
+		}
+		if !ok {
+			l.spilled = 0
+			return
+		}
+		l.spilled--
+		t, err := l.restore(payload)
+		if err != nil {
+			slog.Error("lane unspill error", "session", l.sessionID, "err", err)
+			continue
+		}
+		l.inflight.Add(1)
+		l.tasks <- t
+	}
+}
+
+// Steered returns the text steered into the run executing with ctx since
+// the last call, joined with newlines, or "" if there is none. Runs call it
+// at turn boundaries; text they never pick up is run as a followup.
+func Steered(ctx context.Context) string {
+	l, ok := ctx.Value(activeRunKey{}).(*Lane)
+	if !ok {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a := l.active
+	if a == nil || a.consumed == len(a.steered) {
+		return ""
+	}
+	texts := taskTexts(a.steered[a.consumed:])
+	a.consumed = len(a.steered)
+	return strings.Join(texts, "\n")
+}
+
+// Cancel stops the run in progress, if any, and reports whether there was
+// one. The run's tasks, and any text steered into it, finish with
+// ErrCanceled; queued tasks still run.
+func (l *Lane) Cancel() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active == nil {
 		return false
 	}
+	l.active.canceled = true
+	l.active.cancel()
+	return true
+}
+
+// Purge removes the lane's queued tasks, including spilled ones and text
+// held from an interrupted run, and returns them. The run in progress is
+// left alone.
+func (l *Lane) Purge() []Task {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	purged := l.batch
+	l.batch = nil
+	if l.carryDone != nil {
+		purged = append(purged, Task{SessionID: l.sessionID, Text: l.carry, OnDone: l.carryDone})
+	}
+	l.carry, l.carryDone = "", nil
+	for _, ch := range []chan Task{l.urgent, l.tasks} {
+	drain:
+		for {
+			select {
+			case t := <-ch:
+				l.inflight.Add(-1)
+				purged = append(purged, t)
+			default:
+				break drain
+			}
+		}
+	}
+	for l.spilled > 0 {
+		payload, ok, err := l.spill.Pop(l.sessionID)
+		if err != nil {
+			slog.Error("lane purge error", "session", l.sessionID, "err", err)
+			break
+		}
+		if !ok {
+			l.spilled = 0
+			break
+		}
+		l.spilled--
+		t, err := l.restore(payload)
+		if err != nil {
+			slog.Error("lane purge error", "session", l.sessionID, "err", err)
+			continue
+		}
+		purged = append(purged, t)
+	}
+	return purged
+}
+
+// Info returns a snapshot of the lane.
+func (l *Lane) Info() LaneInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	info := LaneInfo{
+		SessionID:    l.sessionID,
+		Depth:        len(l.urgent) + len(l.tasks) + len(l.batch) + l.spilled,
+		HighPriority: len(l.urgent),
+		Spilled:      l.spilled,
+		LastActive:   l.idleSince(),
+	}
+	if a := l.active; a != nil {
+		info.Current = &RunInfo{
+			AgentID:   a.task.AgentID,
+			Mode:      a.task.Mode,
+			StartedAt: a.started,
+			Waiting:   a.waiting,
+		}
+		info.Depth += len(a.steered) - a.consumed
+	}
+	return info
+}
+
+// idle reports whether the lane has nothing running or queued.
+func (l *Lane) idle() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active == nil && l.inflight.Load() == 0 && len(l.batch) == 0 && l.spilled == 0 && l.carryDone == nil
 }
 
 // Stop signals the lane goroutine to exit.
@@ -54,19 +355,179 @@ func (l *Lane) idleSince() time.Time {
 }
 
 func (l *Lane) run(ctx context.Context) {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+
+	// A task taken from the buffer stays counted in inflight until it has
+	// run, or until it sits in the batch, so the lane never looks idle in
+	// between.
+	flush := func() {
+		l.mu.Lock()
+		batch := l.batch
+		l.batch = nil
+		if len(batch) > 0 {
+			l.inflight.Add(1)
+		}
+		l.mu.Unlock()
+		if len(batch) > 0 {
+			l.execute(ctx, merge(batch))
+			l.inflight.Add(-1)
+		}
+		timer.Stop()
+	}
+
+	handle := func(t Task) {
+		if t.Mode == ModeCollect {
+			l.mu.Lock()
+			l.batch = append(l.batch, t)
+			l.inflight.Add(-1)
+			l.mu.Unlock()
+			timer.Reset(l.debounce)
+			return
+		}
+		flush()
+		l.execute(ctx, t)
+		l.inflight.Add(-1)
+	}
+
 	for {
+		if l.spill != nil {
+			l.unspill()
+		}
+		// High-priority tasks jump the queue.
+		select {
+		case t := <-l.urgent:
+			handle(t)
+			continue
+		default:
+		}
 		select {
 		case <-ctx.Done():
 			return
-		case t, ok := <-l.tasks:
-			if !ok {
-				return
-			}
-			l.touch()
-			if err := t.Fn(ctx); err != nil {
-				slog.Error("lane task error", "session", l.sessionID, "err", err)
+		case t := <-l.urgent:
+			handle(t)
+		case t := <-l.tasks:
+			handle(t)
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// execute runs one task, then any steered text the run did not pick up.
+func (l *Lane) execute(ctx context.Context, t Task) {
+	for {
+		l.touch()
+		runCtx, cancel := context.WithCancel(ctx)
+		a := &activeRun{task: t, started: time.Now(), cancel: cancel}
+
+		l.mu.Lock()
+		text := joinText(l.carry, t.Text)
+		done := chainDone(l.carryDone, t.OnDone)
+		l.carry, l.carryDone = "", nil
+		l.active = a
+		l.mu.Unlock()
+
+		err := l.call(context.WithValue(runCtx, activeRunKey{}, l), a, t, text)
+		cancel()
+
+		l.mu.Lock()
+		l.active = nil
+		for _, st := range a.steered[:a.consumed] {
+			done = chainDone(done, st.OnDone)
+		}
+		leftover := a.steered[a.consumed:]
+		switch {
+		case ctx.Err() != nil || !errors.Is(err, context.Canceled):
+		case a.canceled:
+			err = ErrCanceled
+			for _, st := range leftover {
+				done = chainDone(done, st.OnDone)
 			}
-			l.touch()
+			leftover = nil
+		case a.interrupted:
+			l.carry, l.carryDone = text, done
+			err, done = nil, nil
+		}
+		l.mu.Unlock()
+		l.touch()
+
+		if ctx.Err() != nil {
+			return // lane stopped; the task was not handled
+		}
+		if err != nil && !errors.Is(err, ErrCanceled) {
+			slog.Error("lane task error", "session", l.sessionID, "err", err)
+		}
+		if done != nil {
+			done(err)
+		}
+		if len(leftover) == 0 {
+			return
+		}
+		t = merge(leftover)
+	}
+}
+
+// call runs t.Fn once the limiter grants the task its slots. The task's
+// deadline starts once it has its slots.
+func (l *Lane) call(ctx context.Context, a *activeRun, t Task, text string) error {
+	if l.limiter != nil {
+		l.setWaiting(a, true)
+		release, err := l.limiter.Acquire(ctx, t)
+		l.setWaiting(a, false)
+		if err != nil {
+			return err
 		}
+		defer release()
+	}
+	if t.Timeout <= 0 {
+		return t.Fn(ctx, text)
+	}
+	runCtx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+	err := t.Fn(runCtx, text)
+	if err != nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return fmt.Errorf("%w after %s", ErrTimeout, t.Timeout)
+	}
+	return err
+}
+
+func (l *Lane) setWaiting(a *activeRun, waiting bool) {
+	l.mu.Lock()
+	a.waiting = waiting
+	l.mu.Unlock()
+}
+
+// merge combines tasks into one run of the last task with all their texts.
+func merge(tasks []Task) Task {
+	t := tasks[len(tasks)-1]
+	t.Text = strings.Join(taskTexts(tasks), "\n")
+	dones := make([]func(error), len(tasks))
+	for i, x := range tasks {
+		dones[i] = x.OnDone
+	}
+	t.OnDone = chainDone(dones...)
+	return t
+}
+
+func taskTexts(tasks []Task) []string {
+	texts := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		if t.Text != "" {
+			texts = append(texts, t.Text)
+		}
+	}
+	return texts
+}
+
+func joinText(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	default:
+		return a + "\n" + b
 	}
 }
@@ -2,7 +2,9 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -22,7 +24,7 @@ func TestLaneSerialExecution(t *testing.T) {
 		wg.Add(1)
 		mgr.Enqueue(Task{
 			SessionID: "test-session",
-			Fn: func(ctx context.Context) error {
+			Fn: func(ctx context.Context, _ string) error {
 				// Simulate work.
 				time.Sleep(10 * time.Millisecond)
 				mu.Lock()
@@ -61,7 +63,7 @@ func TestDifferentSessionsParallel(t *testing.T) {
 		sid := string(rune('A' + s))
 		mgr.Enqueue(Task{
 			SessionID: sid,
-			Fn: func(ctx context.Context) error {
+			Fn: func(ctx context.Context, _ string) error {
 				time.Sleep(50 * time.Millisecond)
 				wg.Done()
 				return nil
@@ -77,3 +79,289 @@ func TestDifferentSessionsParallel(t *testing.T) {
 		t.Errorf("expected parallel execution, took %v", elapsed)
 	}
 }
+
+func TestCollectDebouncesBurst(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewManager(ctx, 64, time.Minute)
+	mgr.SetDebounce(30 * time.Millisecond)
+
+	runs := make(chan string, 4)
+	for _, text := range []string{"one", "two", "three"} {
+		mgr.Enqueue(Task{
+			SessionID: "s",
+			Mode:      ModeCollect,
+			Text:      text,
+			Fn: func(ctx context.Context, text string) error {
+				runs <- text
+				return nil
+			},
+		})
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	select {
+	case got := <-runs:
+		if got != "one\ntwo\nthree" {
+			t.Errorf("collected text = %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("collected run never started")
+	}
+	select {
+	case got := <-runs:
+		t.Errorf("unexpected extra run %q", got)
+	case <-time.After(60 * time.Millisecond):
+	}
+}
+
+func TestSteerIntoActiveRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewManager(ctx, 64, time.Minute)
+	started := make(chan struct{})
+	steered := make(chan string, 1)
+	runs := make(chan string, 4)
+
+	run := func(ctx context.Context, text string) error {
+		runs <- text
+		if text == "first" {
+			close(started)
+			time.Sleep(30 * time.Millisecond) // turn boundary
+			steered <- Steered(ctx)
+		}
+		return nil
+	}
+	mgr.Enqueue(Task{SessionID: "s", Mode: ModeSteer, Text: "first", Fn: run})
+	<-started
+	mgr.Enqueue(Task{SessionID: "s", Mode: ModeSteer, Text: "also this", Fn: run})
+
+	if got := <-steered; got != "also this" {
+		t.Errorf("Steered = %q, want %q", got, "also this")
+	}
+	<-runs
+	select {
+	case got := <-runs:
+		t.Errorf("steered text also ran as followup: %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSteerFallsBackToFollowup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewManager(ctx, 64, time.Minute)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	runs := make(chan string, 4)
+
+	run := func(ctx context.Context, text string) error {
+		runs <- text
+		if text == "first" {
+			close(started)
+			<-release // ends without picking up steered text
+		}
+		return nil
+	}
+	mgr.Enqueue(Task{SessionID: "s", Mode: ModeSteer, Text: "first", Fn: run})
+	<-started
+	mgr.Enqueue(Task{SessionID: "s", Mode: ModeSteer, Text: "late", Fn: run})
+	close(release)
+
+	<-runs
+	select {
+	case got := <-runs:
+		if got != "late" {
+			t.Errorf("followup text = %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("unconsumed steer text was dropped")
+	}
+}
+
+func TestInterruptRestarts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewManager(ctx, 64, time.Minute)
+	started := make(chan struct{})
+	runs := make(chan string, 4)
+
+	run := func(ctx context.Context, text string) error {
+		if text == "first" {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		runs <- text
+		return nil
+	}
+	mgr.Enqueue(Task{SessionID: "s", Mode: ModeInterrupt, Text: "first", Fn: run})
+	<-started
+	mgr.Enqueue(Task{SessionID: "s", Mode: ModeInterrupt, Text: "actually this", Fn: run})
+
+	select {
+	case got := <-runs:
+		if got != "first\nactually this" {
+			t.Errorf("restarted text = %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("interrupt did not cancel the active run")
+	}
+}
+
+func TestOnDoneFiresForMergedTasks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewManager(ctx, 64, time.Minute)
+	mgr.SetDebounce(20 * time.Millisecond)
+
+	done := make(chan string, 4)
+	fail := errors.New("boom")
+	for _, text := range []string{"a", "b", "c"} {
+		text := text
+		mgr.Enqueue(Task{
+			SessionID: "s",
+			Mode:      ModeCollect,
+			Text:      text,
+			Fn:        func(ctx context.Context, _ string) error { return fail },
+			OnDone: func(err error) {
+				if err != fail {
+					t.Errorf("OnDone(%s) err = %v", text, err)
+				}
+				done <- text
+			},
+		})
+	}
+
+	got := map[string]bool{}
+	for len(got) < 3 {
+		select {
+		case text := <-done:
+			got[text] = true
+		case <-time.After(time.Second):
+			t.Fatalf("OnDone called for %v, want a, b and c", got)
+		}
+	}
+}
+
+func TestPriorityTaskJumpsQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewManager(ctx, 64, time.Minute)
+	started, release := make(chan struct{}), make(chan struct{})
+	var running atomic.Bool
+	mgr.Enqueue(Task{SessionID: "s", Fn: func(ctx context.Context, _ string) error {
+		running.Store(true)
+		close(started)
+		<-release
+		running.Store(false)
+		return nil
+	}})
+
+	<-started
+
+	order := make(chan string, 2)
+	record := func(name string) func(context.Context, string) error {
+		return func(ctx context.Context, _ string) error {
+			if running.Load() {
+				t.Errorf("%s ran beside the run in progress", name)
+			}
+			order <- name
+			return nil
+		}
+	}
+	mgr.Enqueue(Task{SessionID: "s", Fn: record("normal")})
+	mgr.Enqueue(Task{SessionID: "s", Priority: PriorityHigh, Fn: record("high")})
+
+	if got := mgr.Lanes()[0]; got.Depth != 2 || got.HighPriority != 1 {
+		t.Errorf("lane = %+v, want depth 2 with 1 high-priority task", got)
+	}
+	select {
+	case name := <-order:
+		t.Fatalf("%s ran before the run in progress finished", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	for _, want := range []string{"high", "normal"} {
+		select {
+		case got := <-order:
+			if got != want {
+				t.Errorf("ran %s, want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+}
+
+func TestCancelStopsRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewManager(ctx, 64, time.Minute)
+	if mgr.Cancel("s") {
+		t.Error("Cancel reported a run on an unknown session")
+	}
+
+	started := make(chan struct{})
+	done := make(chan error, 2)
+	mgr.Enqueue(Task{
+		SessionID: "s",
+		Fn: func(ctx context.Context, _ string) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		OnDone: func(err error) { done <- err },
+	})
+	mgr.Enqueue(Task{
+		SessionID: "s",
+		Fn:        func(ctx context.Context, _ string) error { return nil },
+		OnDone:    func(err error) { done <- err },
+	})
+	<-started
+	if !mgr.Cancel("s") {
+		t.Fatal("Cancel found no run")
+	}
+	if err := <-done; !errors.Is(err, ErrCanceled) {
+		t.Errorf("canceled run err = %v, want ErrCanceled", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("queued task err = %v, want it to run", err)
+	}
+}
+
+func TestCleanupKeepsBusyLane(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgr := NewManager(ctx, 64, time.Millisecond)
+	started, release, done := make(chan struct{}), make(chan struct{}), make(chan error, 1)
+	mgr.Enqueue(Task{SessionID: "s", Fn: func(ctx context.Context, _ string) error {
+		close(started)
+		<-release
+		return nil
+	}, OnDone: func(err error) { done <- err }})
+	<-started
+
+	time.Sleep(5 * time.Millisecond)
+	mgr.cleanup()
+	if len(mgr.Lanes()) != 1 {
+		t.Fatal("cleanup removed a lane with a run in progress")
+	}
+
+	close(release)
+	<-done
+	time.Sleep(5 * time.Millisecond)
+	mgr.cleanup()
+	if n := len(mgr.Lanes()); n != 0 {
+		t.Errorf("lanes after cleanup = %d, want 0", n)
+	}
+}
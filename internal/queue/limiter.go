@@ -0,0 +1,226 @@
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// Limits caps how many task slots may be held at once. A zero or missing
+// cap means unlimited.
+type Limits struct {
+	Global    int64
+	Agents    map[string]int64 // per agent ID
+	Providers map[string]int64 // per LLM provider
+}
+
+// Limiter is a weighted semaphore shared by all lanes. A task holds Weight
+// slots of the global pool, its agent's pool and its provider's pool while
+// it runs.
+//
+// Waiting tasks are granted by priority, then in arrival order, so lanes
+// take turns: a lane whose task just finished joins the back of the line
+// with its next task, while a higher-priority task joins ahead of every
+// lower-priority one.
+// A waiter blocked only by its agent or provider cap is skipped so it does
+// not hold up other agents; a waiter blocked by the global cap is not, so a
+// heavy task cannot be starved by lighter ones behind it.
+type Limiter struct {
+	mu         sync.Mutex
+	limits     Limits
+	global     int64
+	agents     map[string]int64
+	providers  map[string]int64
+	waiters    []*waiter
+	onPosition func(sessionID string, position int)
+}
+
+type waiter struct {
+	task     Task
+	ready    chan struct{}
+	granted  bool
+	position int
+}
+
+// position is a queue position change to report once the lock is released.
+type position struct {
+	sessionID string
+	position  int
+}
+
+// NewLimiter creates a limiter with the given caps.
+func NewLimiter(limits Limits) *Limiter {
+	return &Limiter{
+		limits:    limits,
+		agents:    make(map[string]int64),
+		providers: make(map[string]int64),
+	}
+}
+
+// OnPosition registers a callback invoked with a waiting task's 1-based
+// position in line whenever it changes.
+func (l *Limiter) OnPosition(fn func(sessionID string, position int)) {
+	l.mu.Lock()
+	l.onPosition = fn
+	l.mu.Unlock()
+}
+
+// Acquire blocks until t's slots are available or ctx is done. The returned
+// release func must be called when the task finishes. Tasks with no weight
+// are not limited.
+func (l *Limiter) Acquire(ctx context.Context, t Task) (release func(), err error) {
+	if t.Weight <= 0 {
+		return func() {}, nil
+	}
+
+	w := &waiter{task: t, ready: make(chan struct{})}
+	l.mu.Lock()
+	l.enqueue(w)
+	changes := l.dispatch()
+	l.mu.Unlock()
+	l.report(changes)
+
+	select {
+	case <-w.ready:
+		return l.releaser(w), nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	if w.granted {
+		// Granted while we were giving up; hand the slots back.
+		l.give(w.task)
+		changes = l.dispatch()
+	} else {
+		l.remove(w)
+		changes = l.dispatch()
+	}
+	l.mu.Unlock()
+	l.report(changes)
+	return nil, ctx.Err()
+}
+
+// InUse returns the number of slots held globally.
+func (l *Limiter) InUse() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.global
+}
+
+// Waiting returns the number of tasks waiting for slots.
+func (l *Limiter) Waiting() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.waiters)
+}
+
+func (l *Limiter) releaser(w *waiter) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.give(w.task)
+			changes := l.dispatch()
+			l.mu.Unlock()
+			l.report(changes)
+		})
+	}
+}
+
+// dispatch grants waiters in order and returns the position changes of the
+// ones still waiting. Callers must hold l.mu.
+func (l *Limiter) dispatch() []position {
+	for i := 0; i < len(l.waiters); {
+		w := l.waiters[i]
+		fits, globalFull := l.fits(w.task)
+		if fits {
+			l.take(w.task)
+			w.granted = true
+			close(w.ready)
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			continue
+		}
+		if globalFull {
+			break
+		}
+		i++
+	}
+
+	var changes []position
+	for i, w := range l.waiters {
+		if w.position != i+1 {
+			w.position = i + 1
+			changes = append(changes, position{sessionID: w.task.SessionID, position: w.position})
+		}
+	}
+	return changes
+}
+
+func (l *Limiter) report(changes []position) {
+	l.mu.Lock()
+	fn := l.onPosition
+	l.mu.Unlock()
+	if fn == nil {
+		return
+	}
+	for _, c := range changes {
+		fn(c.sessionID, c.position)
+	}
+}
+
+// fits reports whether t's slots are free, and whether the global pool is
+// what stopped it. Callers must hold l.mu.
+func (l *Limiter) fits(t Task) (ok, globalFull bool) {
+	if !room(l.global, l.limits.Global, t.Weight) {
+		return false, true
+	}
+	if !room(l.agents[t.AgentID], l.limits.Agents[t.AgentID], t.Weight) {
+		return false, false
+	}
+	if !room(l.providers[t.Provider], l.limits.Providers[t.Provider], t.Weight) {
+		return false, false
+	}
+	return true, false
+}
+
+// take and give account for t's slots. Callers must hold l.mu.
+func (l *Limiter) take(t Task) {
+	l.global += t.Weight
+	l.agents[t.AgentID] += t.Weight
+	l.providers[t.Provider] += t.Weight
+}
+
+func (l *Limiter) give(t Task) {
+	l.global -= t.Weight
+	l.agents[t.AgentID] -= t.Weight
+	l.providers[t.Provider] -= t.Weight
+}
+
+// enqueue places w behind every waiter of the same or higher priority.
+// Callers must hold l.mu.
+func (l *Limiter) enqueue(w *waiter) {
+	i := len(l.waiters)
+	for i > 0 && l.waiters[i-1].task.Priority < w.task.Priority {
+		i--
+	}
+	l.waiters = append(l.waiters, nil)
+	copy(l.waiters[i+1:], l.waiters[i:])
+	l.waiters[i] = w
+}
+
+func (l *Limiter) remove(w *waiter) {
+	for i, x := range l.waiters {
+		if x == w {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// room reports whether weight more slots fit in a pool. A task heavier than
+// the whole pool runs once the pool is empty.
+func room(used, limit, weight int64) bool {
+	if limit <= 0 {
+		return true
+	}
+	return used == 0 || used+weight <= limit
+}
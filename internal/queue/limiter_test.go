@@ -0,0 +1,156 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterGlobalCap(t *testing.T) {
+	lim := NewLimiter(Limits{Global: 2})
+
+	var mu sync.Mutex
+	var running, peak int
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := lim.Acquire(context.Background(), Task{SessionID: "s", AgentID: "a", Weight: 1})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			release()
+		}()
+	}
+	wg.Wait()
+	if peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", peak)
+	}
+	if lim.InUse() != 0 {
+		t.Errorf("InUse after release = %d", lim.InUse())
+	}
+}
+
+func TestLimiterAgentCapDoesNotBlockOthers(t *testing.T) {
+	lim := NewLimiter(Limits{Global: 10, Agents: map[string]int64{"slow": 1}})
+	ctx := context.Background()
+
+	hold, _ := lim.Acquire(ctx, Task{SessionID: "s1", AgentID: "slow", Weight: 1})
+	defer hold()
+
+	blocked := make(chan struct{})
+	go func() {
+		release, _ := lim.Acquire(ctx, Task{SessionID: "s2", AgentID: "slow", Weight: 1})
+		close(blocked)
+		release()
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		release, _ := lim.Acquire(ctx, Task{SessionID: "s3", AgentID: "fast", Weight: 1})
+		release()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("other agent blocked behind a capped agent")
+	}
+	select {
+	case <-blocked:
+		t.Fatal("agent cap exceeded")
+	default:
+	}
+}
+
+func TestLimiterFairOrderAndPositions(t *testing.T) {
+	lim := NewLimiter(Limits{Global: 1})
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	positions := make(map[string][]int)
+	lim.OnPosition(func(sessionID string, position int) {
+		mu.Lock()
+		positions[sessionID] = append(positions[sessionID], position)
+		mu.Unlock()
+	})
+
+	hold, _ := lim.Acquire(ctx, Task{SessionID: "busy", Weight: 1})
+
+	order := make(chan string, 3)
+	for _, sid := range []string{"a", "b", "c"} {
+		go func() {
+			release, _ := lim.Acquire(ctx, Task{SessionID: sid, Weight: 1})
+			order <- sid
+			release()
+		}()
+		time.Sleep(10 * time.Millisecond) // arrive in order
+	}
+	hold()
+
+	for _, want := range []string{"a", "b", "c"} {
+		if got := <-order; got != want {
+			t.Errorf("granted %s, want %s", got, want)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := positions["c"]; len(got) != 3 || got[0] != 3 || got[2] != 1 {
+		t.Errorf("positions for c = %v, want [3 2 1]", got)
+	}
+}
+
+func TestLimiterCancelWhileWaiting(t *testing.T) {
+	lim := NewLimiter(Limits{Global: 1})
+	hold, _ := lim.Acquire(context.Background(), Task{SessionID: "busy", Weight: 1})
+	defer hold()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := lim.Acquire(ctx, Task{SessionID: "s", Weight: 1}); err == nil {
+		t.Fatal("Acquire succeeded past the cap")
+	}
+	if n := lim.Waiting(); n != 0 {
+		t.Errorf("Waiting after cancel = %d", n)
+	}
+}
+
+func TestLimiterPriorityJumpsAhead(t *testing.T) {
+	lim := NewLimiter(Limits{Global: 1})
+	ctx := context.Background()
+
+	hold, _ := lim.Acquire(ctx, Task{SessionID: "busy", Weight: 1})
+
+	order := make(chan string, 3)
+	for _, task := range []Task{
+		{SessionID: "chat-1", Weight: 1},
+		{SessionID: "chat-2", Weight: 1},
+		{SessionID: "alert", Weight: 1, Priority: PriorityHigh},
+	} {
+		go func() {
+			release, _ := lim.Acquire(ctx, task)
+			order <- task.SessionID
+			release()
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}
+	hold()
+
+	for _, want := range []string{"alert", "chat-1", "chat-2"} {
+		if got := <-order; got != want {
+			t.Errorf("granted %s, want %s", got, want)
+		}
+	}
+}
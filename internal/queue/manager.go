@@ -4,16 +4,24 @@ import (
 	"context"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Manager handles lane lifecycle: lazy creation, idle cleanup.
 type Manager struct {
-	lanes           map[string]*Lane
-	mu              sync.Mutex
-	bufferSize      int
-	idleTimeout     time.Duration
-	ctx             context.Context
+	lanes       map[string]*Lane
+	mu          sync.Mutex
+	bufferSize  int
+	idleTimeout time.Duration
+	debounce    time.Duration
+	limiter     *Limiter
+	policy      Policy
+	spill       *Spill
+	restore     func(payload []byte) (Task, error)
+	stats       counters
+	draining    atomic.Bool
+	ctx         context.Context
 }
 
 // NewManager creates a queue manager.
@@ -26,12 +34,63 @@ func NewManager(ctx context.Context, bufferSize int, idleTimeout time.Duration)
 	}
 }
 
-// Enqueue adds a task to the lane for the given session, creating it lazily if needed.
+// SetDebounce sets the quiet window ModeCollect waits for before running a
+// burst of tasks. It applies to lanes created afterwards.
+func (m *Manager) SetDebounce(d time.Duration) {
+	m.mu.Lock()
+	m.debounce = d
+	m.mu.Unlock()
+}
+
+// SetLimiter makes lanes created afterwards acquire their tasks' slots from
+// limiter before running them.
+func (m *Manager) SetLimiter(limiter *Limiter) {
+	m.mu.Lock()
+	m.limiter = limiter
+	m.mu.Unlock()
+}
+
+// SetOverflow sets what lanes created afterwards do when their buffer is full.
+func (m *Manager) SetOverflow(p Policy) {
+	m.mu.Lock()
+	m.policy = p
+	m.mu.Unlock()
+}
+
+// SetSpill sets the store OverflowSpill parks tasks in, and the func that
+// rebuilds a task from its Payload. restore runs with the lane locked and
+// must not enqueue. It applies to lanes created afterwards.
+func (m *Manager) SetSpill(s *Spill, restore func(payload []byte) (Task, error)) {
+	m.mu.Lock()
+	m.spill, m.restore = s, restore
+	m.mu.Unlock()
+}
+
+// Stats returns the overflow counters of all lanes.
+func (m *Manager) Stats() Stats {
+	return m.stats.snapshot()
+}
+
+// Enqueue adds a task to the lane for the given session, creating it lazily
+// if needed. While the manager is draining, tasks are refused.
 func (m *Manager) Enqueue(t Task) bool {
+	if m.draining.Load() {
+		m.stats.rejected.Add(1)
+		slog.Warn("queue draining, task refused", "session", t.SessionID)
+		return false
+	}
 	m.mu.Lock()
 	l, ok := m.lanes[t.SessionID]
 	if !ok {
-		l = newLane(t.SessionID, m.bufferSize, m.ctx)
+		l = newLane(t.SessionID, laneConfig{
+			bufferSize: m.bufferSize,
+			debounce:   m.debounce,
+			limiter:    m.limiter,
+			policy:     m.policy,
+			spill:      m.spill,
+			restore:    m.restore,
+			stats:      &m.stats,
+		}, m.ctx)
 		m.lanes[t.SessionID] = l
 		slog.Debug("lane created", "session", t.SessionID)
 	}
@@ -39,6 +98,15 @@ func (m *Manager) Enqueue(t Task) bool {
 	return l.Enqueue(t)
 }
 
+// Cancel stops the run in progress on a session's lane and reports whether
+// there was one. See Lane.Cancel.
+func (m *Manager) Cancel(sessionID string) bool {
+	m.mu.Lock()
+	l := m.lanes[sessionID]
+	m.mu.Unlock()
+	return l != nil && l.Cancel()
+}
+
 // StartCleanup launches a goroutine that removes idle lanes.
 func (m *Manager) StartCleanup(ctx context.Context, interval time.Duration) {
 	go func() {
@@ -59,9 +127,11 @@ func (m *Manager) cleanup() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// A lane untouched for the idle timeout may still be running a long task
+	// or holding queued work; only empty lanes are removed.
 	cutoff := time.Now().Add(-m.idleTimeout)
 	for id, l := range m.lanes {
-		if l.idleSince().Before(cutoff) {
+		if l.idleSince().Before(cutoff) && l.idle() {
 			l.Stop()
 			delete(m.lanes, id)
 			slog.Debug("lane removed (idle)", "session", id)
@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Overflow decides what a lane does with a task when its buffer is full.
+type Overflow string
+
+const (
+	OverflowReject     Overflow = "reject"      // refuse the new task; Enqueue returns false
+	OverflowBlock      Overflow = "block"       // wait up to Policy.Timeout for room, then reject
+	OverflowDropOldest Overflow = "drop_oldest" // discard the oldest queued task to make room
+	OverflowSpill      Overflow = "spill"       // park the task on disk until the lane has room
+)
+
+// ParseOverflow validates an overflow policy name. An empty name is OverflowReject.
+func ParseOverflow(s string) (Overflow, error) {
+	switch o := Overflow(s); o {
+	case "":
+		return OverflowReject, nil
+	case OverflowReject, OverflowBlock, OverflowDropOldest, OverflowSpill:
+		return o, nil
+	default:
+		return "", fmt.Errorf("unknown overflow policy %q", s)
+	}
+}
+
+// ErrDropped is passed to the OnDone of a task discarded by OverflowDropOldest.
+var ErrDropped = errors.New("queue: task dropped on overflow")
+
+// Policy configures lane overflow handling.
+type Policy struct {
+	Overflow Overflow
+	Timeout  time.Duration // how long OverflowBlock waits
+}
+
+// Stats counts overflow events across all lanes since start.
+type Stats struct {
+	Rejected      int64 `json:"rejected"`       // tasks refused, including block timeouts
+	BlockTimeouts int64 `json:"block_timeouts"` // OverflowBlock waits that ran out
+	Dropped       int64 `json:"dropped"`        // queued tasks discarded by OverflowDropOldest
+	Spilled       int64 `json:"spilled"`        // tasks parked on disk
+}
+
+type counters struct {
+	rejected      atomic.Int64
+	blockTimeouts atomic.Int64
+	dropped       atomic.Int64
+	spilled       atomic.Int64
+}
+
+func (c *counters) snapshot() Stats {
+	return Stats{
+		Rejected:      c.rejected.Load(),
+		BlockTimeouts: c.blockTimeouts.Load(),
+		Dropped:       c.dropped.Load(),
+		Spilled:       c.spilled.Load(),
+	}
+}
@@ -0,0 +1,170 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// busyManager returns a manager with a one-slot buffer whose lane "s" is
+// held by a running task until release is closed.
+func busyManager(t *testing.T, p Policy) (m *Manager, release chan struct{}) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	m = NewManager(ctx, 1, time.Minute)
+	m.SetOverflow(p)
+	release = make(chan struct{})
+	started := make(chan struct{})
+	m.Enqueue(Task{SessionID: "s", Fn: func(ctx context.Context, _ string) error {
+		close(started)
+		<-release
+		return nil
+	}})
+	<-started
+	return m, release
+}
+
+func TestOverflowReject(t *testing.T) {
+	m, release := busyManager(t, Policy{Overflow: OverflowReject})
+	defer close(release)
+
+	noop := func(ctx context.Context, _ string) error { return nil }
+	if !m.Enqueue(Task{SessionID: "s", Fn: noop}) {
+		t.Fatal("first queued task refused")
+	}
+	if m.Enqueue(Task{SessionID: "s", Fn: noop}) {
+		t.Fatal("task accepted into a full lane")
+	}
+	if got := m.Stats(); got.Rejected != 1 {
+		t.Errorf("stats = %+v, want 1 rejected", got)
+	}
+}
+
+func TestOverflowBlock(t *testing.T) {
+	m, release := busyManager(t, Policy{Overflow: OverflowBlock, Timeout: 50 * time.Millisecond})
+
+	noop := func(ctx context.Context, _ string) error { return nil }
+	m.Enqueue(Task{SessionID: "s", Fn: noop})
+	if m.Enqueue(Task{SessionID: "s", Fn: noop}) {
+		t.Fatal("blocked enqueue succeeded while the lane stayed full")
+	}
+	if got := m.Stats(); got.BlockTimeouts != 1 || got.Rejected != 1 {
+		t.Errorf("stats = %+v", got)
+	}
+
+	// Room that frees up within the timeout is taken.
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		close(release)
+	}()
+	if !m.Enqueue(Task{SessionID: "s", Fn: noop}) {
+		t.Error("enqueue refused although the lane drained")
+	}
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	m, release := busyManager(t, Policy{Overflow: OverflowDropOldest})
+
+	var mu sync.Mutex
+	var ran []string
+	results := make(chan string, 4)
+	for _, text := range []string{"old", "new"} {
+		m.Enqueue(Task{
+			SessionID: "s",
+			Text:      text,
+			Fn: func(ctx context.Context, text string) error {
+				mu.Lock()
+				ran = append(ran, text)
+				mu.Unlock()
+				return nil
+			},
+			OnDone: func(err error) {
+				if errors.Is(err, ErrDropped) {
+					results <- "dropped " + text
+				} else {
+					results <- "done " + text
+				}
+			},
+		})
+	}
+	if got := <-results; got != "dropped old" {
+		t.Fatalf("first result = %q, want the oldest task dropped", got)
+	}
+	close(release)
+	if got := <-results; got != "done new" {
+		t.Errorf("second result = %q", got)
+	}
+	if got := m.Stats(); got.Dropped != 1 {
+		t.Errorf("stats = %+v, want 1 dropped", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ran) != 1 || ran[0] != "new" {
+		t.Errorf("ran = %v", ran)
+	}
+}
+
+func TestOverflowSpillKeepsOrder(t *testing.T) {
+	spill, err := OpenSpill(filepath.Join(t.TempDir(), "spill.db"), xorSealer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spill.Close()
+
+	runs := make(chan string, 8)
+	task := func(text string) Task {
+		return Task{
+			SessionID: "s",
+			Text:      text,
+			Payload:   []byte(text),
+			Fn: func(ctx context.Context, text string) error {
+				runs <- text
+				return nil
+			},
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewManager(ctx, 1, time.Minute)
+	m.SetOverflow(Policy{Overflow: OverflowSpill})
+	m.SetSpill(spill, func(payload []byte) (Task, error) {
+		return task(string(payload)), nil
+	})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	m.Enqueue(Task{SessionID: "s", Fn: func(ctx context.Context, _ string) error {
+		close(started)
+		<-release
+		return nil
+	}})
+	<-started
+
+	want := []string{"a", "b", "c", "d"}
+	for _, text := range want {
+		if !m.Enqueue(task(text)) {
+			t.Fatalf("enqueue %s refused", text)
+		}
+	}
+	if got := m.Stats(); got.Spilled != 3 {
+		t.Errorf("stats = %+v, want 3 spilled", got)
+	}
+	close(release)
+
+	for _, w := range want {
+		select {
+		case got := <-runs:
+			if got != w {
+				t.Fatalf("ran %q, want %q", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("task %q never ran", w)
+		}
+	}
+}
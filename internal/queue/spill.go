@@ -0,0 +1,111 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucketSpill = []byte("spill")
+
+// Spill parks overflow tasks on disk, one FIFO per session, until their
+// lane has room. Only a task's Payload is stored; the manager's restore
+// func turns it back into a task.
+//
+// Spilled tasks are a capacity measure, not a durability one: the file is
+// emptied when it is opened. Enable the journal to survive restarts.
+type Spill struct {
+	db     *bolt.DB
+	sealer Sealer
+}
+
+// OpenSpill opens (or creates) a spill file at path and discards anything
+// left in it. A nil sealer stores payloads as they are.
+func OpenSpill(path string, sealer Sealer) (*Spill, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("queue spill dir: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open queue spill %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketSpill) != nil {
+			if err := tx.DeleteBucket(bucketSpill); err != nil {
+				return err
+			}
+		}
+		_, err := tx.CreateBucket(bucketSpill)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init queue spill: %w", err)
+	}
+	return &Spill{db: db, sealer: sealer}, nil
+}
+
+// Push appends a payload to the session's FIFO.
+func (s *Spill) Push(sessionID string, payload []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketSpill)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := spillKey(sessionID, seq)
+		data := payload
+		if s.sealer != nil {
+			if data, err = s.sealer.Seal(payload, key); err != nil {
+				return err
+			}
+		}
+		return b.Put(key, data)
+	})
+}
+
+// Pop removes and returns the oldest payload of the session's FIFO.
+func (s *Spill) Pop(sessionID string) ([]byte, bool, error) {
+	var payload []byte
+	found := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketSpill)
+		prefix := spillKey(sessionID, 0)[:len(sessionID)+1]
+		k, v := b.Cursor().Seek(prefix)
+		if k == nil || !bytes.HasPrefix(k, prefix) {
+			return nil
+		}
+		key := append([]byte(nil), k...)
+		payload, found = append([]byte(nil), v...), true
+		if s.sealer != nil {
+			plain, err := s.sealer.Open(payload, key)
+			if err != nil {
+				return fmt.Errorf("spilled task %q: %w", sessionID, err)
+			}
+			payload = plain
+		}
+		return b.Delete(key)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return payload, found, nil
+}
+
+// Close closes the spill file.
+func (s *Spill) Close() error {
+	return s.db.Close()
+}
+
+// spillKey orders a session's payloads by sequence: sessionID, 0x00, seq.
+func spillKey(sessionID string, seq uint64) []byte {
+	key := make([]byte, 0, len(sessionID)+9)
+	key = append(key, sessionID...)
+	key = append(key, 0)
+	return binary.BigEndian.AppendUint64(key, seq)
+}
@@ -1,9 +1,147 @@
 package queue
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimeout is passed to OnDone when a run exceeds its Task.Timeout.
+var ErrTimeout = errors.New("queue: run deadline exceeded")
+
+// ErrCanceled is passed to OnDone when a run was stopped with Manager.Cancel.
+var ErrCanceled = errors.New("queue: run canceled")
+
+// Mode controls how a task interacts with the run already in progress on
+// its lane and with other queued tasks.
+type Mode string
+
+const (
+	// ModeFollowup runs every task as its own run, after the current one.
+	ModeFollowup Mode = "followup"
+
+	// ModeCollect debounces a burst of tasks: tasks are held until no new
+	// one has arrived for the lane's debounce window, then run once with
+	// their texts joined.
+	ModeCollect Mode = "collect"
+
+	// ModeSteer hands the task's text to the run in progress, which picks it
+	// up with its next round of tool results (see Steered). Without a run in
+	// progress, or if the run ends before picking it up, the task runs as a
+	// followup.
+	ModeSteer Mode = "steer"
+
+	// ModeInterrupt cancels the run in progress and starts over with the
+	// interrupted run's text followed by the new task's text.
+	ModeInterrupt Mode = "interrupt"
+)
+
+// ParseMode validates a mode name. An empty name is ModeFollowup.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case "":
+		return ModeFollowup, nil
+	case ModeFollowup, ModeCollect, ModeSteer, ModeInterrupt:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown queue mode: %q", s)
+	}
+}
+
+// Priority orders work. Tasks above PriorityNormal run before the tasks
+// already queued on their session's lane, right after the run in progress;
+// the limiter also grants them slots before normal tasks.
+type Priority int
+
+const (
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// ParsePriority parses "normal" or "high". An empty name is PriorityNormal.
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	default:
+		return 0, fmt.Errorf("unknown priority: %q", s)
+	}
+}
+
+func (p Priority) String() string {
+	if p > PriorityNormal {
+		return "high"
+	}
+	return "normal"
+}
+
+// Modes resolves the queue mode for an agent.
+type Modes struct {
+	Default Mode
+	Agents  map[string]Mode
+}
+
+// For returns the mode that applies to agentID.
+func (m Modes) For(agentID string) Mode {
+	if mode, ok := m.Agents[agentID]; ok {
+		return mode
+	}
+	if m.Default == "" {
+		return ModeFollowup
+	}
+	return m.Default
+}
 
 // Task is a unit of work to be executed in a lane.
 type Task struct {
 	SessionID string
-	Fn        func(ctx context.Context) error
+	Mode      Mode     // zero value behaves as ModeFollowup
+	Text      string   // user text carried by the task; merged by collect and interrupt
+	Priority  Priority // PriorityHigh runs ahead of the session's queued tasks
+
+	// Concurrency accounting, see Limiter. Zero-weight tasks are not limited.
+	AgentID  string
+	Provider string
+	Weight   int64
+
+	// Fn runs the task. text is the task's Text, or the joined texts of
+	// every task merged into this run.
+	Fn func(ctx context.Context, text string) error
+
+	// Timeout, if positive, is the deadline for one run of Fn. A run that
+	// exceeds it fails with ErrTimeout.
+	Timeout time.Duration
+
+	// Payload is an opaque serialized form of the task. Tasks without one
+	// cannot be spilled to disk and are rejected instead.
+	Payload []byte
+
+	// OnDone, if set, is called with Fn's result once the task has been
+	// handled, including when its text was merged into another task's run.
+	// It is not called for tasks dropped because their lane was stopped.
+	OnDone func(err error)
+}
+
+// chainDone combines OnDone callbacks, skipping nil ones.
+func chainDone(fns ...func(error)) func(error) {
+	var set []func(error)
+	for _, fn := range fns {
+		if fn != nil {
+			set = append(set, fn)
+		}
+	}
+	switch len(set) {
+	case 0:
+		return nil
+	case 1:
+		return set[0]
+	}
+	return func(err error) {
+		for _, fn := range set {
+			fn(err)
+		}
+	}
 }
@@ -0,0 +1,115 @@
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// idKeys are attribute keys that hold session keys and platform IDs. Long
+// numeric IDs look like phone numbers, so the phone detector skips them;
+// the other detectors still apply.
+var idKeys = map[string]bool{
+	"session": true, "session_id": true, "key": true, "id": true,
+	"peer": true, "peer_id": true, "user": true, "user_id": true,
+	"chat": true, "chat_id": true, "room": true, "guild": true,
+	"client": true, "msg_id": true, "message_id": true, "run_seq": true,
+}
+
+// Handler is a slog.Handler that masks the log message and every attribute
+// before passing the record on. Values of other kinds are masked in their
+// JSON form.
+//
+// Logs are full of timestamps, build numbers and IDs, so in log output a
+// phone number only counts when written with separators or a leading +.
+type Handler struct {
+	inner slog.Handler
+	r     *Redactor // for the message and most attributes
+	ids   *Redactor // for idKeys attributes, without the phone detector
+}
+
+// NewHandler wraps inner so its output never contains text matched by r.
+func NewHandler(inner slog.Handler, r *Redactor) *Handler {
+	logs := &Redactor{mode: r.mode}
+	ids := &Redactor{mode: r.mode}
+	for _, rule := range r.rules {
+		if rule.Name == Phone {
+			valid := rule.Valid
+			rule.Valid = func(m string) bool {
+				return (valid == nil || valid(m)) && formattedPhone(m)
+			}
+		} else {
+			ids.rules = append(ids.rules, rule)
+		}
+		logs.rules = append(logs.rules, rule)
+	}
+	return &Handler{inner: inner, r: logs, ids: ids}
+}
+
+// formattedPhone reports whether m is written like a phone number rather
+// than a run of digits: with a leading +, parentheses or separators.
+func formattedPhone(m string) bool {
+	return strings.HasPrefix(m, "+") || strings.ContainsAny(m, " \t.-()")
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, h.r.Mask(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.attr(a))
+		return true
+	})
+	return h.inner.Handle(ctx, out)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		masked[i] = h.attr(a)
+	}
+	return &Handler{inner: h.inner.WithAttrs(masked), r: h.r, ids: h.ids}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{inner: h.inner.WithGroup(name), r: h.r, ids: h.ids}
+}
+
+func (h *Handler) attr(a slog.Attr) slog.Attr {
+	r := h.r
+	if idKeys[a.Key] {
+		r = h.ids
+	}
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.Mask(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		masked := make([]any, len(group))
+		for i, g := range group {
+			masked[i] = h.attr(g)
+		}
+		return slog.Group(a.Key, masked...)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, r.Mask(x.Error()))
+		case interface{ String() string }:
+			return slog.String(a.Key, r.Mask(x.String()))
+		case nil:
+			return slog.Attr{Key: a.Key, Value: v}
+		default:
+			data, err := json.Marshal(x)
+			if err != nil {
+				return slog.String(a.Key, r.Mask(fmt.Sprintf("%+v", x)))
+			}
+			return slog.String(a.Key, r.Mask(string(data)))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
@@ -0,0 +1,219 @@
+// Package redact detects and removes personal data and secrets from text.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Mode selects what redacted text is replaced with.
+type Mode string
+
+const (
+	// ModeMask replaces matches with a fixed label such as [EMAIL]. The
+	// original text is discarded.
+	ModeMask Mode = "mask"
+
+	// ModeTokenize replaces matches with a numbered token such as [EMAIL_1]
+	// and records the original in a token map so Restore can put it back.
+	ModeTokenize Mode = "tokenize"
+)
+
+// Built-in detector names.
+const (
+	Email      = "email"
+	Phone      = "phone"
+	CreditCard = "credit_card"
+	APIKey     = "api_key"
+)
+
+// Rule is one detector.
+type Rule struct {
+	Name    string
+	Label   string // placeholder label, e.g. "EMAIL"
+	Pattern *regexp.Regexp
+	Valid   func(match string) bool // optional check that filters false positives
+}
+
+// builtins lists the built-in detectors in the order they are applied.
+// API keys go first so a key containing digits is not partly taken for a
+// phone number; cards go before phones for the same reason.
+var builtins = []Rule{
+	{
+		Name:  APIKey,
+		Label: "API_KEY",
+		Pattern: regexp.MustCompile(`\b(?:sk-(?:ant-)?[A-Za-z0-9_-]{20,}` +
+			`|AKIA[0-9A-Z]{16}` +
+			`|gh[pousr]_[A-Za-z0-9]{36,}` +
+			`|xox[abposr]-[A-Za-z0-9-]{10,}` +
+			`|AIza[0-9A-Za-z_-]{35}` +
+			`|\d{8,10}:[A-Za-z0-9_-]{35})\b` +
+			`|(?i:bearer)\s+[A-Za-z0-9._~+/-]{20,}=*`),
+	},
+	{
+		Name:    Email,
+		Label:   "EMAIL",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	{
+		Name:    CreditCard,
+		Label:   "CARD",
+		Pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		Valid:   luhn,
+	},
+	{
+		Name:    Phone,
+		Label:   "PHONE",
+		Pattern: regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{2,4}\)|\b\d{2,4})[\s.-]?\d{3,4}[\s.-]?\d{3,4}\b`),
+		Valid: func(m string) bool {
+			n := countDigits(m)
+			return n >= 8 && n <= 15
+		},
+	},
+}
+
+// Builtin returns the built-in detector with the given name.
+func Builtin(name string) (Rule, bool) {
+	for _, r := range builtins {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// BuiltinNames returns the names of all built-in detectors.
+func BuiltinNames() []string {
+	names := make([]string, len(builtins))
+	for i, r := range builtins {
+		names[i] = r.Name
+	}
+	return names
+}
+
+// customName is what a custom rule may be called: its upper-cased name
+// must fit tokenPattern, or Restore could never find its tokens.
+var customName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// Custom compiles a user-defined detector. The label is the upper-cased
+// name, which may only hold letters, digits and underscores.
+func Custom(name, pattern string) (Rule, error) {
+	if !customName.MatchString(name) {
+		return Rule{}, fmt.Errorf("redaction rule %q: name may only contain letters, digits and underscores", name)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("redaction rule %s: %w", name, err)
+	}
+	return Rule{Name: name, Label: strings.ToUpper(name), Pattern: re}, nil
+}
+
+// Redactor applies a set of rules to text.
+type Redactor struct {
+	rules []Rule
+	mode  Mode
+}
+
+// New creates a redactor that applies rules in order.
+func New(mode Mode, rules ...Rule) *Redactor {
+	if mode == "" {
+		mode = ModeMask
+	}
+	return &Redactor{rules: rules, mode: mode}
+}
+
+// Mode returns the redactor's mode.
+func (r *Redactor) Mode() Mode { return r.mode }
+
+// Redact replaces every match in text. In ModeTokenize with a non-nil token
+// map, matches become tokens recorded in tokens (token -> original), and a
+// value seen before reuses its token. Otherwise matches are masked.
+func (r *Redactor) Redact(text string, tokens map[string]string) string {
+	if r.mode != ModeTokenize {
+		tokens = nil
+	}
+	for _, rule := range r.rules {
+		text = rule.Pattern.ReplaceAllStringFunc(text, func(m string) string {
+			if rule.Valid != nil && !rule.Valid(m) {
+				return m
+			}
+			if tokens == nil {
+				return "[" + rule.Label + "]"
+			}
+			return tokenFor(tokens, rule.Label, m)
+		})
+	}
+	return text
+}
+
+// Mask replaces every match with its label, regardless of mode.
+func (r *Redactor) Mask(text string) string {
+	return r.Redact(text, nil)
+}
+
+var tokenPattern = regexp.MustCompile(`\[[A-Z0-9_]+_\d+\]`)
+
+// Restore replaces tokens produced by Redact with their original text.
+// Unknown tokens are left as they are.
+func Restore(text string, tokens map[string]string) string {
+	if len(tokens) == 0 {
+		return text
+	}
+	return tokenPattern.ReplaceAllStringFunc(text, func(tok string) string {
+		if orig, ok := tokens[tok]; ok {
+			return orig
+		}
+		return tok
+	})
+}
+
+// tokenFor returns the token for value, adding one to tokens if needed.
+func tokenFor(tokens map[string]string, label, value string) string {
+	prefix := "[" + label + "_"
+	n := 0
+	for tok, v := range tokens {
+		if !strings.HasPrefix(tok, prefix) {
+			continue
+		}
+		if v == value {
+			return tok
+		}
+		n++
+	}
+	tok := prefix + strconv.Itoa(n+1) + "]"
+	tokens[tok] = value
+	return tok
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum.
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func countDigits(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,47 @@
+package redact
+
+import (
+	"strings"
+	"sync"
+)
+
+// maxTokenLen bounds how much streamed text is held back waiting for a
+// token's closing bracket.
+const maxTokenLen = 48
+
+// StreamRestorer restores tokens in text that arrives in chunks, such as
+// streamed LLM deltas. A token split across chunks is held back until the
+// rest of it arrives.
+type StreamRestorer struct {
+	mu      sync.Mutex
+	pending map[string]string // stream ID -> held-back text
+}
+
+// NewStreamRestorer creates an empty stream restorer.
+func NewStreamRestorer() *StreamRestorer {
+	return &StreamRestorer{pending: make(map[string]string)}
+}
+
+// Next returns the restored text that can be emitted after chunk arrives on
+// stream id. It may be empty if the whole chunk is held back.
+func (s *StreamRestorer) Next(id, chunk string, tokens map[string]string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	text := s.pending[id] + chunk
+	delete(s.pending, id)
+	if i := strings.LastIndexByte(text, '['); i >= 0 && len(text)-i <= maxTokenLen && !strings.Contains(text[i:], "]") {
+		s.pending[id] = text[i:]
+		text = text[:i]
+	}
+	return Restore(text, tokens)
+}
+
+// Flush returns any text still held back for stream id and forgets it.
+func (s *StreamRestorer) Flush(id string, tokens map[string]string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	text := s.pending[id]
+	delete(s.pending, id)
+	return Restore(text, tokens)
+}
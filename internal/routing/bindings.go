@@ -1,25 +1,180 @@
 package routing
 
-// BindingStore holds the configured agent-channel bindings.
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrBindingNotFound is returned for an unknown runtime binding ID.
+var ErrBindingNotFound = errors.New("routing: binding not found")
+
+// BindingStore holds the agent-channel bindings: those from config, then
+// those added at runtime. Changes replace the slices rather than modify
+// them, so a snapshot taken for resolution stays valid.
 type BindingStore struct {
+	mu           sync.RWMutex
 	bindings     []Binding
+	matchers     []matcher // compiled conditions, by index
 	defaultAgent string
+	store        Store     // persists runtime bindings; nil keeps them in memory
+	audit        *AuditLog // records runtime changes; may be nil
+	seq          int       // last runtime binding ID number
+}
+
+// matcher holds a binding's compiled Pattern and time conditions.
+type matcher struct {
+	pattern  *regexp.Regexp
+	schedule *schedule
+	invalid  bool // a condition did not compile; the binding never matches
 }
 
 // NewBindingStore creates a store from config bindings and a default agent ID.
+// A binding whose Pattern or schedule does not compile is logged and never
+// matches.
 func NewBindingStore(bindings []Binding, defaultAgent string) *BindingStore {
+	matchers := make([]matcher, len(bindings))
+	for i := range bindings {
+		m, err := compileMatcher(&bindings[i])
+		if err != nil {
+			slog.Warn("binding ignored", "agent", bindings[i].AgentID, "err", err)
+		}
+		matchers[i] = m
+	}
 	return &BindingStore{
 		bindings:     bindings,
+		matchers:     matchers,
 		defaultAgent: defaultAgent,
 	}
 }
 
-// Bindings returns all configured bindings.
+// Attach loads the runtime bindings saved in s, after the config bindings,
+// and saves later changes there. Changes are recorded in audit, if not nil.
+func (bs *BindingStore) Attach(s Store, audit *AuditLog) error {
+	saved, err := s.Load()
+	if err != nil {
+		return err
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.store, bs.audit, bs.seq = s, audit, saved.Seq
+	for _, b := range saved.Bindings {
+		m, err := compileMatcher(&b)
+		if err != nil {
+			slog.Warn("binding ignored", "id", b.ID, "err", err)
+		}
+		bs.bindings = append(slices.Clip(bs.bindings), b)
+		bs.matchers = append(slices.Clip(bs.matchers), m)
+	}
+	return nil
+}
+
+// Bindings returns all bindings, config bindings first. The slice must not
+// be modified.
 func (bs *BindingStore) Bindings() []Binding {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
 	return bs.bindings
 }
 
+// snapshot returns the bindings and their compiled conditions.
+func (bs *BindingStore) snapshot() ([]Binding, []matcher) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+	return bs.bindings, bs.matchers
+}
+
 // DefaultAgent returns the fallback agent ID.
 func (bs *BindingStore) DefaultAgent() string {
 	return bs.defaultAgent
 }
+
+// Add appends a runtime binding, which wins over config bindings at the
+// same level, and saves it. It gets a new ID; actor is recorded in the
+// audit log. The caller validates the binding's fields.
+func (bs *BindingStore) Add(b Binding, actor string) (Binding, error) {
+	m, err := compileMatcher(&b)
+	if err != nil {
+		return Binding{}, err
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b.ID = fmt.Sprintf("b-%d", bs.seq+1)
+	bindings := append(slices.Clip(bs.bindings), b)
+	if err := bs.save(bindings, bs.seq+1); err != nil {
+		return Binding{}, err
+	}
+	bs.seq++
+	bs.bindings = bindings
+	bs.matchers = append(slices.Clip(bs.matchers), m)
+	bs.record(actor, "add", b)
+	return b, nil
+}
+
+// Remove deletes the runtime binding with the given ID and returns it.
+// Config bindings have no ID and cannot be removed.
+func (bs *BindingStore) Remove(id, actor string) (Binding, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	i := slices.IndexFunc(bs.bindings, func(b Binding) bool { return id != "" && b.ID == id })
+	if i < 0 {
+		return Binding{}, ErrBindingNotFound
+	}
+	b := bs.bindings[i]
+	bindings := slices.Delete(slices.Clone(bs.bindings), i, i+1)
+	if err := bs.save(bindings, bs.seq); err != nil {
+		return Binding{}, err
+	}
+	bs.bindings = bindings
+	bs.matchers = slices.Delete(slices.Clone(bs.matchers), i, i+1)
+	bs.record(actor, "remove", b)
+	return b, nil
+}
+
+// save persists the runtime bindings among bindings.
+func (bs *BindingStore) save(bindings []Binding, seq int) error {
+	if bs.store == nil {
+		return nil
+	}
+	saved := Saved{Seq: seq}
+	for _, b := range bindings {
+		if b.ID != "" {
+			saved.Bindings = append(saved.Bindings, b)
+		}
+	}
+	return bs.store.Save(saved)
+}
+
+// record logs a change and appends it to the audit log. The change is
+// already saved, so an audit failure is only logged.
+func (bs *BindingStore) record(actor, action string, b Binding) {
+	slog.Info("routing binding changed", "action", action, "id", b.ID, "agent", b.AgentID, "actor", actor)
+	if bs.audit == nil {
+		return
+	}
+	e := AuditEntry{Time: time.Now(), Actor: actor, Action: action, Binding: b}
+	if err := bs.audit.Append(e); err != nil {
+		slog.Error("binding audit failed", "action", action, "id", b.ID, "err", err)
+	}
+}
+
+// compileMatcher compiles b's Pattern and schedule.
+func compileMatcher(b *Binding) (matcher, error) {
+	var m matcher
+	var err error
+	if b.Pattern != "" {
+		if m.pattern, err = regexp.Compile(b.Pattern); err != nil {
+			m.invalid = true
+			return m, fmt.Errorf("pattern: %w", err)
+		}
+	}
+	if m.schedule, err = compileSchedule(b); err != nil {
+		m.invalid = true
+		return m, err
+	}
+	return m, nil
+}
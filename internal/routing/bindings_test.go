@@ -0,0 +1,78 @@
+package routing
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestBindingStoreRuntime(t *testing.T) {
+	dir := t.TempDir()
+	config := []Binding{{Channel: "telegram", GuildID: "100", AgentID: "general"}}
+	open := func() *BindingStore {
+		t.Helper()
+		s := NewBindingStore(config, "fallback")
+		if err := s.Attach(NewFileStore(filepath.Join(dir, "bindings.json")), NewAuditLog(filepath.Join(dir, "audit.jsonl"))); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	group := ResolveParams{Channel: "telegram", PeerKind: "group", PeerID: "100", GuildID: "100", Text: "/ops deploy", Command: "ops"}
+
+	s := open()
+	ops, err := s.Add(Binding{Channel: "telegram", GuildID: "100", Command: "/ops", AgentID: "ops"}, "telegram:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	support, err := s.Add(Binding{Channel: "telegram", GuildID: "100", AgentID: "support"}, "gateway:c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ops.ID != "b-1" || support.ID != "b-2" {
+		t.Errorf("IDs = %q, %q; want b-1, b-2", ops.ID, support.ID)
+	}
+	if _, err := s.Add(Binding{Pattern: "(", AgentID: "ops"}, "telegram:1"); err == nil {
+		t.Error("Add with a bad pattern succeeded")
+	}
+
+	// A reloaded store has the runtime bindings after the config ones.
+	s = open()
+	r := NewResolver(s)
+	if got := len(s.Bindings()); got != 3 {
+		t.Fatalf("reloaded %d bindings, want 3", got)
+	}
+	if got := r.Resolve(group); got != "ops" {
+		t.Errorf("command = %q, want ops", got)
+	}
+	group.Text, group.Command = "hello", ""
+	if got := r.Resolve(group); got != "support" {
+		t.Errorf("plain = %q, want support (runtime wins over config)", got)
+	}
+
+	if _, err := s.Remove("b-2", "telegram:1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Resolve(group); got != "general" {
+		t.Errorf("after remove = %q, want general", got)
+	}
+	if _, err := s.Remove("b-2", "telegram:1"); !errors.Is(err, ErrBindingNotFound) {
+		t.Errorf("second remove err = %v, want ErrBindingNotFound", err)
+	}
+	if _, err := s.Remove("", "telegram:1"); !errors.Is(err, ErrBindingNotFound) {
+		t.Errorf("removing a config binding: err = %v, want ErrBindingNotFound", err)
+	}
+
+	// IDs are not reused after a restart.
+	s = open()
+	if b, err := s.Add(Binding{Channel: "websocket", AgentID: "general"}, "gateway:c1"); err != nil || b.ID != "b-3" {
+		t.Errorf("Add after reload = %q, %v; want b-3", b.ID, err)
+	}
+
+	entries, err := NewAuditLog(filepath.Join(dir, "audit.jsonl")).Recent(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Binding.ID != "b-3" || entries[1].Action != "remove" || entries[1].Actor != "telegram:1" {
+		t.Errorf("Recent(2) = %+v", entries)
+	}
+}
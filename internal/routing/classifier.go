@@ -0,0 +1,87 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/harshadpatil/dhaavak/internal/llm"
+)
+
+// Candidate is an agent the classifier can pick.
+type Candidate struct {
+	ID          string
+	Name        string
+	Description string
+}
+
+// Intent is a classifier's pick.
+type Intent struct {
+	AgentID    string  `json:"agent"`
+	Confidence float64 `json:"confidence"` // 0 to 1
+}
+
+// Classifier asks an LLM which agent should handle a message.
+type Classifier struct {
+	provider   llm.Provider
+	candidates []Candidate
+	prompt     string
+}
+
+// NewClassifier creates a classifier choosing among candidates. provider
+// should be a small, fast model; it is asked for a short JSON answer.
+func NewClassifier(provider llm.Provider, candidates []Candidate) *Classifier {
+	var b strings.Builder
+	b.WriteString("You route chat messages to the assistant best suited to answer them. The assistants are:\n\n")
+	for _, c := range candidates {
+		fmt.Fprintf(&b, "- id: %s\n", c.ID)
+		if c.Name != "" {
+			fmt.Fprintf(&b, "  name: %s\n", c.Name)
+		}
+		if c.Description != "" {
+			fmt.Fprintf(&b, "  handles: %s\n", c.Description)
+		}
+	}
+	b.WriteString("\nReply with only a JSON object: {\"agent\": \"<id>\", \"confidence\": <0 to 1>}. ")
+	b.WriteString("Use a low confidence when the message could go to several assistants or to none, such as a greeting.")
+	return &Classifier{provider: provider, candidates: candidates, prompt: b.String()}
+}
+
+// Classify picks an agent for text. An answer naming an unknown agent is
+// an error.
+func (c *Classifier) Classify(ctx context.Context, text string) (Intent, error) {
+	res, err := c.provider.Complete(ctx, c.prompt, []llm.Message{{
+		Role:    llm.RoleUser,
+		Content: []llm.ContentBlock{{Type: "text", Text: text}},
+	}}, nil)
+	if err != nil {
+		return Intent{}, fmt.Errorf("classify: %w", err)
+	}
+	var answer strings.Builder
+	for _, b := range res.Content {
+		if b.Type == "text" {
+			answer.WriteString(b.Text)
+		}
+	}
+	return c.parse(answer.String())
+}
+
+// parse reads the JSON object in a classifier answer, tolerating text
+// around it.
+func (c *Classifier) parse(answer string) (Intent, error) {
+	start, end := strings.IndexByte(answer, '{'), strings.LastIndexByte(answer, '}')
+	if start < 0 || end < start {
+		return Intent{}, fmt.Errorf("classify: no JSON in answer %q", answer)
+	}
+	var in Intent
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &in); err != nil {
+		return Intent{}, fmt.Errorf("classify: %w", err)
+	}
+	for _, cand := range c.candidates {
+		if cand.ID == in.AgentID {
+			return in, nil
+		}
+	}
+	return Intent{}, fmt.Errorf("classify: unknown agent %q", in.AgentID)
+}
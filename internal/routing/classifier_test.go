@@ -0,0 +1,38 @@
+package routing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/harshadpatil/dhaavak/internal/llm"
+)
+
+type stubProvider struct{ answer string }
+
+func (s stubProvider) Stream(ctx context.Context, systemPrompt string, messages []llm.Message, tools []llm.ToolDef) (<-chan llm.StreamEvent, error) {
+	return nil, nil
+}
+
+func (s stubProvider) Complete(ctx context.Context, systemPrompt string, messages []llm.Message, tools []llm.ToolDef) (*llm.CompletionResult, error) {
+	return &llm.CompletionResult{Content: []llm.ContentBlock{{Type: "text", Text: s.answer}}}, nil
+}
+
+func TestClassify(t *testing.T) {
+	candidates := []Candidate{{ID: "billing", Description: "invoices"}, {ID: "support"}}
+	tests := []struct {
+		answer  string
+		want    Intent
+		wantErr bool
+	}{
+		{`{"agent": "billing", "confidence": 0.9}`, Intent{AgentID: "billing", Confidence: 0.9}, false},
+		{"Sure:\n```json\n{\"agent\":\"support\",\"confidence\":0.4}\n```", Intent{AgentID: "support", Confidence: 0.4}, false},
+		{`{"agent": "sales", "confidence": 1}`, Intent{}, true},
+		{"billing", Intent{}, true},
+	}
+	for _, tt := range tests {
+		got, err := NewClassifier(stubProvider{tt.answer}, candidates).Classify(context.Background(), "hi")
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Classify() with answer %q = %+v, %v", tt.answer, got, err)
+		}
+	}
+}
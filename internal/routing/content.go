@@ -0,0 +1,91 @@
+package routing
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Attachment types adapters report in InboundMessage.Attachments.
+var AttachmentTypes = []string{"photo", "video", "audio", "voice", "document", "sticker", "animation"}
+
+// content holds the message content a resolution matches against.
+// The language is detected on first use.
+type content struct {
+	text        string
+	command     string
+	attachments []string
+	lang        string
+	detected    bool
+}
+
+func (c *content) language() string {
+	if !c.detected {
+		c.lang, c.detected = DetectLanguage(c.text), true
+	}
+	return c.lang
+}
+
+// matchContent reports whether every content predicate of b holds. re is
+// b's compiled Pattern.
+func matchContent(b *Binding, re *regexp.Regexp, c *content) bool {
+	if b.Command != "" && !matchCommand(b.Command, c) {
+		return false
+	}
+	if b.Pattern != "" && (re == nil || !re.MatchString(c.text)) {
+		return false
+	}
+	if len(b.Keywords) > 0 && !matchKeywords(b.Keywords, c.text) {
+		return false
+	}
+	if b.Language != "" && b.Language != c.language() {
+		return false
+	}
+	if b.Attachment != "" && !contains(c.attachments, b.Attachment) {
+		return false
+	}
+	return true
+}
+
+// matchCommand reports whether the message starts with cmd ("/ops" or
+// "ops"). Adapters that parse commands set ResolveParams.Command; for the
+// others the first word of the text is checked, ignoring a "@bot" suffix.
+func matchCommand(cmd string, c *content) bool {
+	cmd = strings.ToLower(strings.TrimPrefix(cmd, "/"))
+	if c.command != "" {
+		return c.command == cmd
+	}
+	fields := strings.Fields(c.text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return false
+	}
+	first, _, _ := strings.Cut(fields[0][1:], "@")
+	return strings.ToLower(first) == cmd
+}
+
+// matchKeywords reports whether text contains any of the keywords as a
+// whole word, ignoring case. A keyword may span several words.
+func matchKeywords(keywords []string, text string) bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	padded := " " + strings.Join(words, " ") + " "
+	for _, kw := range keywords {
+		kwWords := strings.FieldsFunc(strings.ToLower(kw), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		if len(kwWords) > 0 && strings.Contains(padded, " "+strings.Join(kwWords, " ")+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package routing
+
+import "testing"
+
+func TestResolveContent(t *testing.T) {
+	s := NewBindingStore([]Binding{
+		{Channel: "telegram", GuildID: "-100", AgentID: "general"},
+		{Channel: "telegram", GuildID: "-100", Command: "/ops", AgentID: "ops"},
+		{Channel: "telegram", PeerKind: "user", PeerID: "42", AgentID: "personal"},
+		{Keywords: []string{"invoice", "refund"}, AgentID: "billing"},
+		{Channel: "websocket", Pattern: `(?i)\bstack ?trace\b`, AgentID: "coder"},
+		{Language: "hi", AgentID: "hindi"},
+		{Attachment: "photo", AgentID: "vision"},
+	}, "fallback")
+	r := NewResolver(s)
+
+	group := ResolveParams{Channel: "telegram", PeerKind: "group", PeerID: "-100", GuildID: "-100"}
+	withText := func(p ResolveParams, text string) ResolveParams {
+		p.Text = text
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		params ResolveParams
+		want   string
+	}{
+		{"command in group", withText(group, "/ops restart the db"), "ops"},
+		{"command with bot suffix", withText(group, "/ops@dhaavak_bot status"), "ops"},
+		{"parsed command", ResolveParams{Channel: "telegram", PeerKind: "group", PeerID: "-100", GuildID: "-100", Command: "ops"}, "ops"},
+		{"other text in group", withText(group, "what's for lunch?"), "general"},
+		{"other command in group", withText(group, "/opsx"), "general"},
+		{"keyword beats peer binding", ResolveParams{Channel: "telegram", PeerKind: "user", PeerID: "42", Text: "Where is my REFUND?"}, "billing"},
+		{"keyword needs whole word", ResolveParams{Channel: "telegram", PeerKind: "user", PeerID: "42", Text: "refunded"}, "personal"},
+		{"pattern", ResolveParams{Channel: "websocket", Text: "here is the Stack trace"}, "coder"},
+		{"pattern scoped to channel", ResolveParams{Channel: "slack", Text: "stack trace"}, "fallback"},
+		{"language", ResolveParams{Channel: "slack", Text: "मुझे मदद चाहिए"}, "hindi"},
+		{"attachment", ResolveParams{Channel: "slack", Text: "look", Attachments: []string{"photo"}}, "vision"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Resolve(tt.params); got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"How do I reset my password?", "en"},
+		{"¿Cómo puedo cambiar la contraseña de mi cuenta?", "es"},
+		{"Je ne trouve pas le bouton pour les paramètres", "fr"},
+		{"Ich kann das Passwort nicht ändern", "de"},
+		{"Привет, как дела?", "ru"},
+		{"パスワードを忘れました", "ja"},
+		{"我忘记了密码", "zh"},
+		{"नमस्ते, आप कैसे हैं?", "hi"},
+		{"ok", ""},
+		{"12345", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectLanguage(tt.text); got != tt.want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
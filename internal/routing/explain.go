@@ -0,0 +1,71 @@
+package routing
+
+// Verdicts on a binding in an Explanation.
+const (
+	ResultChosen          = "chosen"           // decided the route
+	ResultMatched         = "matched"          // in scope, but a more specific or later binding won
+	ResultContentMismatch = "content_mismatch" // in scope, but its content predicates do not hold
+	ResultOffSchedule     = "off_schedule"     // in scope, but outside its days, hours or window
+	ResultInvalid         = "invalid"          // its pattern or schedule does not compile
+	ResultOutOfScope      = "out_of_scope"     // channel, peer, guild or team differ
+	ResultNoAgent         = "no_agent"         // has no agent and is ignored
+)
+
+// Step is the resolver's verdict on one binding.
+type Step struct {
+	Index    int     `json:"index"` // position in the binding store
+	Binding  Binding `json:"binding"`
+	Level    string  `json:"level,omitempty"`    // scope level it is in scope at: peer, parent_peer, guild, team, channel or account
+	Content  bool    `json:"content,omitempty"`  // has content predicates, which rank above plain bindings
+	Schedule bool    `json:"schedule,omitempty"` // has time conditions, which rank the same way
+	Result   string  `json:"result"`
+}
+
+// Explanation traces a resolution.
+type Explanation struct {
+	Params   ResolveParams `json:"params"`
+	Steps    []Step        `json:"steps"` // every binding, in store order
+	Route    Route         `json:"route"`
+	Language string        `json:"language,omitempty"` // detected language of the text
+}
+
+// Explain resolves p like Resolve and reports the verdict on every binding.
+// Route.Priority is the level that decided, 1 (peer) to 6 (account), with
+// content bindings ranked before plain ones, or 7 for the default agent.
+func (r *Resolver) Explain(p ResolveParams) Explanation {
+	ex := Explanation{Params: p, Steps: []Step{}}
+	if p.Text != "" {
+		ex.Language = DetectLanguage(p.Text)
+	}
+	bindings, matchers := r.store.snapshot()
+	i := evaluate(bindings, matchers, p, &ex.Steps)
+	if i < 0 {
+		ex.Route = Route{AgentID: r.store.DefaultAgent(), Priority: numLevels + 1, Source: "default"}
+		return ex
+	}
+	step := &ex.Steps[i]
+	step.Result = ResultChosen
+	for l, name := range levelNames {
+		if name == step.Level {
+			ex.Route = Route{AgentID: step.Binding.AgentID, Priority: l + 1, Source: name}
+		}
+	}
+	if step.Content {
+		ex.Route.Source += "+content"
+	}
+	if step.Schedule {
+		ex.Route.Source += "+schedule"
+	}
+	ex.Route.Reply = step.Binding.Reply
+	return ex
+}
+
+// Chosen returns the step of the binding that decided, if any.
+func (ex *Explanation) Chosen() (Step, bool) {
+	for _, s := range ex.Steps {
+		if s.Result == ResultChosen {
+			return s, true
+		}
+	}
+	return Step{}, false
+}
@@ -0,0 +1,120 @@
+package routing
+
+import (
+	"strings"
+	"unicode"
+)
+
+// scriptLanguages maps scripts used by essentially one language to it.
+// Latin script text is told apart by stopwords instead.
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Devanagari, "hi"},
+	{unicode.Bengali, "bn"},
+	{unicode.Gurmukhi, "pa"},
+	{unicode.Gujarati, "gu"},
+	{unicode.Tamil, "ta"},
+	{unicode.Telugu, "te"},
+	{unicode.Kannada, "kn"},
+	{unicode.Malayalam, "ml"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Thai, "th"},
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+}
+
+// latinStopwords are frequent short words of Latin script languages.
+var latinStopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "you", "what", "how", "this", "that", "with", "for", "can", "please", "my", "it", "to", "of"},
+	"es": {"el", "la", "los", "las", "que", "es", "por", "para", "con", "una", "cómo", "qué", "mi", "y", "de", "del"},
+	"fr": {"le", "la", "les", "est", "et", "je", "vous", "une", "des", "que", "pour", "avec", "comment", "mon", "du", "pas"},
+	"de": {"der", "die", "das", "und", "ist", "ich", "nicht", "ein", "eine", "mit", "wie", "was", "für", "mein", "zu", "sie"},
+	"pt": {"o", "os", "as", "que", "é", "não", "uma", "para", "com", "como", "meu", "você", "do", "da", "em"},
+	"it": {"il", "lo", "gli", "che", "è", "non", "una", "per", "con", "come", "mio", "sono", "di", "del", "della"},
+	"nl": {"de", "het", "een", "en", "is", "niet", "ik", "je", "met", "voor", "hoe", "wat", "mijn", "van", "dat"},
+}
+
+// Languages lists the codes DetectLanguage can return.
+var Languages = []string{"en", "es", "fr", "de", "pt", "it", "nl", "hi", "bn", "pa", "gu", "ta", "te", "kn", "ml", "ar", "he", "ru", "el", "th", "ko", "ja", "zh"}
+
+// DetectLanguage guesses the ISO 639-1 code of text, or returns "" if it
+// cannot tell. Non-Latin text is identified by its dominant script (any
+// kana makes CJK text Japanese); Latin text by counting stopwords of a few
+// European languages. It is meant for routing, not linguistics: short or
+// mixed messages often come back "".
+func DetectLanguage(text string) string {
+	counts := make(map[string]int)
+	latin, letters := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.table, r) {
+				counts[s.lang]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+	if counts["ja"] > 0 && counts["zh"] > 0 {
+		counts["ja"] += counts["zh"]
+		delete(counts, "zh")
+	}
+	best, bestN := "", 0
+	for lang, n := range counts {
+		if n > bestN || (n == bestN && lang < best) {
+			best, bestN = lang, n
+		}
+	}
+	if bestN*2 > letters {
+		return best
+	}
+	if latin*2 <= letters {
+		return ""
+	}
+	return latinLanguage(text)
+}
+
+// latinLanguage picks the language with the most stopwords in text. Ties
+// and texts without stopwords are "".
+func latinLanguage(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	scores := make(map[string]int)
+	for _, w := range words {
+		for lang, stop := range latinStopwords {
+			if contains(stop, w) {
+				scores[lang]++
+			}
+		}
+	}
+	best, bestN, tie := "", 0, false
+	for lang, n := range scores {
+		switch {
+		case n > bestN:
+			best, bestN, tie = lang, n, false
+		case n == bestN:
+			tie = true
+		}
+	}
+	if tie {
+		return ""
+	}
+	return best
+}
@@ -1,12 +1,23 @@
 package routing
 
+import "time"
+
 // ResolveParams are the inputs to route resolution.
 type ResolveParams struct {
-	Channel  string
-	PeerKind string // "user", "group"
-	PeerID   string
-	GuildID  string
-	TeamID   string
+	Channel  string `json:"channel"`
+	PeerKind string `json:"peer_kind,omitempty"` // "user", "group"
+	PeerID   string `json:"peer_id,omitempty"`
+	GuildID  string `json:"guild_id,omitempty"`
+	TeamID   string `json:"team_id,omitempty"`
+
+	// Message content, for bindings with content predicates.
+	Text        string   `json:"text,omitempty"`
+	Command     string   `json:"command,omitempty"`     // command parsed by the adapter, without the slash
+	Attachments []string `json:"attachments,omitempty"` // see AttachmentTypes
+
+	// Time is when the message was sent, for bindings with a schedule;
+	// zero means now.
+	Time time.Time `json:"time,omitzero"`
 }
 
 // Resolver determines which agent handles a given message context.
@@ -19,6 +30,19 @@ func NewResolver(store *BindingStore) *Resolver {
 	return &Resolver{store: store}
 }
 
+// Scope levels, most specific first.
+const (
+	levelPeer = iota
+	levelParentPeer
+	levelGuild
+	levelTeam
+	levelChannel
+	levelAccount
+	numLevels
+)
+
+var levelNames = [numLevels]string{"peer", "parent_peer", "guild", "team", "channel", "account"}
+
 // Resolve walks a 7-level priority chain and returns the best matching agent ID.
 //
 // Priority (lowest number wins):
@@ -26,60 +50,106 @@ func NewResolver(store *BindingStore) *Resolver {
 //  2. Parent peer binding (channel + peer_kind, no peer_id — e.g. "all users on telegram")
 //  3. Guild/group binding (channel + guild_id)
 //  4. Team binding (team_id)
-//  5. Account/global binding (no channel filter)
-//  6. Channel wildcard (channel only, no peer/guild)
+//  5. Channel wildcard (channel only, no peer/guild)
+//  6. Account/global binding (no channel filter)
 //  7. Default agent
+//
+// Bindings with content predicates (command, pattern, keywords, language,
+// attachment) or a schedule whose conditions hold are tried first, through
+// the same levels 1-6; only if none match are the plain bindings tried.
+// Reply bindings that match come before both, so a maintenance window
+// covers everything in its scope.
 func (r *Resolver) Resolve(p ResolveParams) string {
-	var (
-		peerMatch    string // priority 1
-		parentPeer   string // priority 2
-		guildMatch   string // priority 3
-		teamMatch    string // priority 4
-		accountMatch string // priority 5
-		channelWild  string // priority 6
-	)
+	if b, ok := r.Match(p); ok && b.AgentID != "" {
+		return b.AgentID
+	}
+	return r.store.DefaultAgent()
+}
 
-	for _, b := range r.store.Bindings() {
-		// Priority 1: exact peer
-		if b.Channel == p.Channel && b.PeerKind == p.PeerKind && b.PeerID == p.PeerID && b.PeerID != "" {
-			peerMatch = b.AgentID
-		}
-		// Priority 2: parent peer (same channel + kind, no specific peer)
-		if b.Channel == p.Channel && b.PeerKind == p.PeerKind && b.PeerID == "" && b.GuildID == "" {
-			parentPeer = b.AgentID
-		}
-		// Priority 3: guild
-		if b.Channel == p.Channel && b.GuildID == p.GuildID && p.GuildID != "" && b.PeerKind == "" {
-			guildMatch = b.AgentID
+// Match returns the binding Resolve would pick, or false if the message
+// falls through to the default agent. Within a level, the last binding wins.
+func (r *Resolver) Match(p ResolveParams) (Binding, bool) {
+	bindings, matchers := r.store.snapshot()
+	if i := evaluate(bindings, matchers, p, nil); i >= 0 {
+		return bindings[i], true
+	}
+	return Binding{}, false
+}
+
+// evaluate returns the index of the binding that decides p, or -1.
+// matchers holds the compiled conditions of each binding. If steps is not
+// nil, the verdict on every binding is appended to it.
+func evaluate(bindings []Binding, matchers []matcher, p ResolveParams, steps *[]Step) int {
+	var replies, matched, plain [numLevels]int
+	for l := range matched {
+		replies[l], matched[l], plain[l] = -1, -1, -1
+	}
+	c := &content{text: p.Text, command: p.Command, attachments: p.Attachments}
+	now := p.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	for i := range bindings {
+		b, m := &bindings[i], &matchers[i]
+		step := Step{Index: i, Binding: *b, Content: b.HasContent(), Schedule: b.HasSchedule()}
+		level, ok := scopeLevel(b, p)
+		if ok {
+			step.Level = levelNames[level]
 		}
-		// Priority 4: team
-		if b.TeamID == p.TeamID && p.TeamID != "" && b.Channel == "" {
-			teamMatch = b.AgentID
+		switch {
+		case b.AgentID == "" && b.Reply == "":
+			step.Result, step.Level = ResultNoAgent, ""
+		case !ok:
+			step.Result = ResultOutOfScope
+		case m.invalid:
+			step.Result = ResultInvalid
+		case b.HasContent() && !matchContent(b, m.pattern, c):
+			step.Result = ResultContentMismatch
+		case m.schedule != nil && !m.schedule.active(now):
+			step.Result = ResultOffSchedule
+		default:
+			step.Result = ResultMatched
+			switch {
+			case b.Reply != "":
+				replies[level] = i
+			case step.Content || step.Schedule:
+				matched[level] = i
+			default:
+				plain[level] = i
+			}
 		}
-		// Priority 5: account/global (no channel, no peer, no guild, no team)
-		if b.Channel == "" && b.PeerKind == "" && b.PeerID == "" && b.GuildID == "" && b.TeamID == "" && b.AgentID != "" {
-			accountMatch = b.AgentID
+		if steps != nil {
+			*steps = append(*steps, step)
 		}
-		// Priority 6: channel wildcard (channel only)
-		if b.Channel == p.Channel && b.PeerKind == "" && b.PeerID == "" && b.GuildID == "" && b.TeamID == "" {
-			channelWild = b.AgentID
+	}
+
+	for _, set := range [][numLevels]int{replies, matched, plain} {
+		for _, i := range set {
+			if i >= 0 {
+				return i
+			}
 		}
 	}
+	return -1
+}
 
+// scopeLevel returns the most specific level at which b's channel, peer,
+// guild and team fields match p.
+func scopeLevel(b *Binding, p ResolveParams) (int, bool) {
 	switch {
-	case peerMatch != "":
-		return peerMatch
-	case parentPeer != "":
-		return parentPeer
-	case guildMatch != "":
-		return guildMatch
-	case teamMatch != "":
-		return teamMatch
-	case channelWild != "":
-		return channelWild
-	case accountMatch != "":
-		return accountMatch
-	default:
-		return r.store.DefaultAgent()
+	case b.Channel == p.Channel && b.PeerKind == p.PeerKind && b.PeerID == p.PeerID && b.PeerID != "":
+		return levelPeer, true
+	case b.Channel == p.Channel && b.PeerKind == p.PeerKind && b.PeerID == "" && b.GuildID == "":
+		return levelParentPeer, true
+	case b.Channel == p.Channel && b.GuildID == p.GuildID && p.GuildID != "" && b.PeerKind == "":
+		return levelGuild, true
+	case b.TeamID == p.TeamID && p.TeamID != "" && b.Channel == "":
+		return levelTeam, true
+	case b.Channel == p.Channel && b.PeerKind == "" && b.PeerID == "" && b.GuildID == "" && b.TeamID == "":
+		return levelChannel, true
+	case b.Channel == "" && b.PeerKind == "" && b.PeerID == "" && b.GuildID == "" && b.TeamID == "":
+		return levelAccount, true
 	}
+	return 0, false
 }
@@ -47,3 +47,61 @@ func TestResolveDefaultFallback(t *testing.T) {
 		t.Errorf("Resolve() = %q, want %q", got, "fallback")
 	}
 }
+
+func TestMatchReturnsBinding(t *testing.T) {
+	s := NewBindingStore([]Binding{
+		{Channel: "telegram", GuildID: "-100", AgentID: "oncall", Priority: "high"},
+		{Channel: "telegram", AgentID: "chat"},
+	}, "fallback")
+	r := NewResolver(s)
+
+	b, ok := r.Match(ResolveParams{Channel: "telegram", PeerKind: "group", PeerID: "-100", GuildID: "-100"})
+	if !ok || b.AgentID != "oncall" || b.Priority != "high" {
+		t.Errorf("Match() = %+v, %v", b, ok)
+	}
+	if _, ok := r.Match(ResolveParams{Channel: "slack"}); ok {
+		t.Error("Match() found a binding for an unbound channel")
+	}
+}
+
+func TestResolveLaterBindingWins(t *testing.T) {
+	s := NewBindingStore([]Binding{
+		{Channel: "telegram", AgentID: "tg-default"},
+		{Channel: "telegram", AgentID: "override"},
+		{TeamID: "T1", AgentID: "team-bot"},
+	}, "fallback")
+	r := NewResolver(s)
+
+	if got := r.Resolve(ResolveParams{Channel: "telegram"}); got != "override" {
+		t.Errorf("Resolve() = %q, want %q", got, "override")
+	}
+	if got := r.Resolve(ResolveParams{Channel: "slack", TeamID: "T1"}); got != "team-bot" {
+		t.Errorf("Resolve() = %q, want %q", got, "team-bot")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	s := NewBindingStore([]Binding{
+		{Channel: "telegram", AgentID: "tg-default"},
+		{Channel: "telegram", GuildID: "-100", AgentID: "general"},
+		{Channel: "telegram", GuildID: "-100", Command: "/ops", AgentID: "ops"},
+		{Channel: "slack", AgentID: "slack-bot"},
+	}, "fallback")
+	r := NewResolver(s)
+
+	ex := r.Explain(ResolveParams{Channel: "telegram", PeerKind: "group", PeerID: "-100", GuildID: "-100", Text: "hello"})
+	want := []string{ResultMatched, ResultChosen, ResultContentMismatch, ResultOutOfScope}
+	for i, step := range ex.Steps {
+		if step.Result != want[i] {
+			t.Errorf("step %d = %s, want %s", i, step.Result, want[i])
+		}
+	}
+	if ex.Route != (Route{AgentID: "general", Priority: 3, Source: "guild"}) {
+		t.Errorf("route = %+v", ex.Route)
+	}
+
+	ex = r.Explain(ResolveParams{Channel: "discord"})
+	if ex.Route != (Route{AgentID: "fallback", Priority: 7, Source: "default"}) {
+		t.Errorf("default route = %+v", ex.Route)
+	}
+}
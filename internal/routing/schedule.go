@@ -0,0 +1,129 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weekdays maps day names to time.Weekday, for Binding.Days.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// schedule is the compiled form of a binding's time conditions.
+type schedule struct {
+	days       [7]bool // by time.Weekday; all false means every day
+	start, end int     // Hours in minutes since midnight; start < 0 means all day
+	loc        *time.Location
+	from       time.Time // zero: no start
+	until      time.Time // zero: no end
+}
+
+// HasSchedule reports whether the binding has time conditions.
+func (b *Binding) HasSchedule() bool {
+	return len(b.Days) > 0 || b.Hours != "" || b.From != "" || b.Until != ""
+}
+
+// compileSchedule parses b's time conditions, or returns nil if it has none.
+func compileSchedule(b *Binding) (*schedule, error) {
+	if !b.HasSchedule() {
+		return nil, nil
+	}
+	s := &schedule{start: -1, loc: time.UTC}
+	if b.Timezone != "" {
+		loc, err := time.LoadLocation(b.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone: %w", err)
+		}
+		s.loc = loc
+	}
+	for _, d := range b.Days {
+		first, last, ok := strings.Cut(strings.ToLower(d), "-")
+		if !ok {
+			last = first
+		}
+		from, ok1 := weekdays[first]
+		to, ok2 := weekdays[last]
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("days: %q is not a day like mon or a range like mon-fri", d)
+		}
+		for day := from; ; day = (day + 1) % 7 {
+			s.days[day] = true
+			if day == to {
+				break
+			}
+		}
+	}
+	if b.Hours != "" {
+		first, last, ok := strings.Cut(b.Hours, "-")
+		start, err1 := parseClock(first)
+		end, err2 := parseClock(last)
+		if !ok || err1 != nil || err2 != nil || start == end {
+			return nil, fmt.Errorf("hours: %q is not a range like 09:00-17:30", b.Hours)
+		}
+		s.start, s.end = start, end
+	}
+	var err error
+	if b.From != "" {
+		if s.from, err = time.Parse(time.RFC3339, b.From); err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+	}
+	if b.Until != "" {
+		if s.until, err = time.Parse(time.RFC3339, b.Until); err != nil {
+			return nil, fmt.Errorf("until: %w", err)
+		}
+	}
+	if !s.from.IsZero() && !s.until.IsZero() && !s.from.Before(s.until) {
+		return nil, fmt.Errorf("from must be before until")
+	}
+	return s, nil
+}
+
+// ValidateSchedule checks b's time conditions, for config validation.
+func ValidateSchedule(b Binding) error {
+	_, err := compileSchedule(&b)
+	return err
+}
+
+// parseClock parses "HH:MM" into minutes since midnight; "24:00" is the
+// end of the day.
+func parseClock(v string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(strings.TrimSpace(v), "%d:%d", &h, &m); err != nil {
+		return 0, err
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m > 0) {
+		return 0, fmt.Errorf("invalid time %q", v)
+	}
+	return h*60 + m, nil
+}
+
+// active reports whether t is inside the schedule. Hours that wrap past
+// midnight, like 22:00-06:00, belong to the day they start on.
+func (s *schedule) active(t time.Time) bool {
+	if !s.from.IsZero() && t.Before(s.from) {
+		return false
+	}
+	if !s.until.IsZero() && !t.Before(s.until) {
+		return false
+	}
+	t = t.In(s.loc)
+	day := t.Weekday()
+	if s.start >= 0 {
+		m := t.Hour()*60 + t.Minute()
+		switch {
+		case s.start < s.end:
+			if m < s.start || m >= s.end {
+				return false
+			}
+		case m < s.end:
+			day = (day + 6) % 7 // the window started yesterday
+		case m < s.start:
+			return false
+		}
+	}
+	return s.days == [7]bool{} || s.days[day]
+}
@@ -0,0 +1,104 @@
+package routing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleActive(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	// 2026-10-19 is a Monday.
+	at := func(day, hour, min int) time.Time { return time.Date(2026, 10, day, hour, min, 0, 0, kolkata) }
+
+	tests := []struct {
+		name string
+		b    Binding
+		t    time.Time
+		want bool
+	}{
+		{"weekday hours inside", Binding{Days: []string{"mon-fri"}, Hours: "09:00-17:30", Timezone: "Asia/Kolkata"}, at(19, 9, 0), true},
+		{"weekday hours end is exclusive", Binding{Days: []string{"mon-fri"}, Hours: "09:00-17:30", Timezone: "Asia/Kolkata"}, at(19, 17, 30), false},
+		{"weekend", Binding{Days: []string{"mon-fri"}, Hours: "09:00-17:30", Timezone: "Asia/Kolkata"}, at(18, 10, 0), false},
+		{"timezone applies", Binding{Hours: "09:00-17:30"}, at(19, 9, 0), false}, // 03:30 UTC
+		{"wrapping range", Binding{Days: []string{"sat-sun"}}, at(18, 23, 0), true},
+		{"single days", Binding{Days: []string{"tue", "Thu"}, Timezone: "Asia/Kolkata"}, at(22, 1, 0), true},
+		{"night shift after midnight belongs to the day before", Binding{Days: []string{"fri"}, Hours: "22:00-06:00", Timezone: "Asia/Kolkata"}, at(24, 2, 0), true},
+		{"night shift of another day", Binding{Days: []string{"fri"}, Hours: "22:00-06:00", Timezone: "Asia/Kolkata"}, at(23, 2, 0), false},
+		{"night shift evening", Binding{Days: []string{"fri"}, Hours: "22:00-06:00", Timezone: "Asia/Kolkata"}, at(23, 22, 0), true},
+		{"window inside", Binding{From: "2026-10-19T02:00:00Z", Until: "2026-10-19T04:00:00Z"}, time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC), true},
+		{"window over", Binding{From: "2026-10-19T02:00:00Z", Until: "2026-10-19T04:00:00Z"}, time.Date(2026, 10, 19, 4, 0, 0, 0, time.UTC), false},
+		{"open-ended window", Binding{From: "2026-10-19T02:00:00Z"}, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := compileSchedule(&tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.active(tt.t); got != tt.want {
+				t.Errorf("active(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []Binding{
+		{Days: []string{"someday"}},
+		{Hours: "9-5"},
+		{Hours: "09:00-09:00"},
+		{Hours: "25:00-26:00"},
+		{Hours: "09:00-17:00", Timezone: "Mars/Olympus"},
+		{From: "2026-10-19T04:00:00Z", Until: "2026-10-19T02:00:00Z"},
+	} {
+		if _, err := compileSchedule(&bad); err == nil {
+			t.Errorf("compileSchedule(%+v) succeeded", bad)
+		}
+	}
+}
+
+func TestResolveSchedule(t *testing.T) {
+	s := NewBindingStore([]Binding{
+		{Channel: "telegram", PeerKind: "user", AgentID: "after-hours"},
+		{Channel: "telegram", PeerKind: "user", AgentID: "business-hours", Days: []string{"mon-fri"}, Hours: "09:00-17:00"},
+		{Reply: "Down for maintenance.", From: "2026-10-20T02:00:00Z", Until: "2026-10-20T04:00:00Z"},
+		{Channel: "telegram", PeerKind: "user", PeerID: "7", AgentID: "vip"},
+	}, "fallback")
+	r := NewResolver(s)
+	dm := func(peer string, t time.Time) ResolveParams {
+		return ResolveParams{Channel: "telegram", PeerKind: "user", PeerID: peer, Time: t}
+	}
+	utc := func(day, hour int) time.Time { return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC) }
+
+	if got := r.Resolve(dm("1", utc(19, 10))); got != "business-hours" {
+		t.Errorf("Monday 10:00 = %q, want business-hours", got)
+	}
+	if got := r.Resolve(dm("1", utc(19, 20))); got != "after-hours" {
+		t.Errorf("Monday 20:00 = %q, want after-hours", got)
+	}
+	if got := r.Resolve(dm("1", utc(18, 10))); got != "after-hours" {
+		t.Errorf("Sunday 10:00 = %q, want after-hours", got)
+	}
+
+	// The maintenance reply wins over every agent binding, even a peer's.
+	b, ok := r.Match(dm("7", utc(20, 3)))
+	if !ok || b.Reply != "Down for maintenance." {
+		t.Errorf("during maintenance Match = %+v, %v; want the reply binding", b, ok)
+	}
+	if got := r.Resolve(dm("7", utc(20, 3))); got != "fallback" {
+		t.Errorf("during maintenance Resolve = %q, want the default agent", got)
+	}
+	if got := r.Resolve(dm("7", utc(20, 5))); got != "vip" {
+		t.Errorf("after maintenance = %q, want vip", got)
+	}
+
+	ex := r.Explain(dm("1", utc(19, 20)))
+	if got := ex.Steps[1].Result; got != ResultOffSchedule {
+		t.Errorf("business-hours step = %q, want %q", got, ResultOffSchedule)
+	}
+	ex = r.Explain(dm("1", utc(20, 3)))
+	if ex.Route.Reply == "" || ex.Route.Source != "account+schedule" {
+		t.Errorf("maintenance route = %+v", ex.Route)
+	}
+}
@@ -0,0 +1,148 @@
+package routing
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Saved is what a Store keeps: the runtime bindings and the last ID
+// number handed out, so IDs are not reused after a restart.
+type Saved struct {
+	Seq      int       `json:"seq"`
+	Bindings []Binding `json:"bindings"`
+}
+
+// Store persists the bindings added at runtime.
+type Store interface {
+	Load() (Saved, error)
+	Save(saved Saved) error
+}
+
+// FileStore keeps runtime bindings in a JSON file, rewritten atomically on every change.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore creates a file store at path. The file is created on first save.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) Load() (Saved, error) {
+	var saved Saved
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return saved, nil
+	}
+	if err != nil {
+		return saved, err
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return saved, fmt.Errorf("parse %s: %w", s.path, err)
+	}
+	return saved, nil
+}
+
+func (s *FileStore) Save(saved Saved) error {
+	if saved.Bindings == nil {
+		saved.Bindings = []Binding{}
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("binding store dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write bindings: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("write bindings: %w", err)
+	}
+	return nil
+}
+
+// AuditEntry records one runtime binding change.
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor"`  // who made it, e.g. "telegram:12345" or "gateway:<client>"
+	Action  string    `json:"action"` // "add" or "remove"
+	Binding Binding   `json:"binding"`
+}
+
+// AuditLog appends binding changes to a JSON Lines file.
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewAuditLog creates an audit log at path. The file is created on first append.
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+// Append writes e at the end of the log.
+func (l *AuditLog) Append(e AuditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return fmt.Errorf("audit log dir: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// Recent returns up to limit of the latest entries, newest first. A limit
+// of 0 or less returns all of them.
+func (l *AuditLog) Recent(limit int) ([]AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []AuditEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []AuditEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", l.path, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	out := make([]AuditEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		out = append(out, entries[i])
+	}
+	return out, nil
+}
@@ -2,17 +2,62 @@ package routing
 
 // Route holds a resolved agent binding.
 type Route struct {
-	AgentID  string
-	Priority int // lower = higher priority
-	Source   string
+	AgentID  string `json:"agent_id"`
+	Priority int    `json:"priority"` // lower = higher priority
+	Source   string `json:"source"`
+	Reply    string `json:"reply,omitempty"` // static reply of the binding, see Binding.Reply
 }
 
 // Binding maps a channel context to an agent.
+//
+// The content fields (Command, Pattern, Keywords, Language, Attachment)
+// narrow a binding to matching messages; all that are set must hold.
+// Bindings with content fields are preferred over those without, see
+// Resolver.Match.
+//
+// A binding with Variants splits its messages between several agents.
+// AgentID is then the agent the session key is built from.
+//
+// The time fields (Days, Hours, From, Until) narrow a binding to messages
+// sent inside its schedule, like content fields do. A binding with Reply
+// answers with that text instead of an agent, and wins over every agent
+// binding that matches, e.g. for a maintenance window.
 type Binding struct {
+	ID       string `json:"id,omitempty" yaml:"-"` // set on bindings added at runtime, see BindingStore.Add
 	Channel  string `json:"channel"   yaml:"channel"`
 	PeerKind string `json:"peer_kind" yaml:"peer_kind"` // "user", "group", ""
 	PeerID   string `json:"peer_id"   yaml:"peer_id"`
 	GuildID  string `json:"guild_id"  yaml:"guild_id"`
 	TeamID   string `json:"team_id"   yaml:"team_id"`
 	AgentID  string `json:"agent_id"  yaml:"agent_id"`
+	Priority string `json:"priority,omitempty" yaml:"priority,omitempty"` // queue priority of matched messages: "normal", "high"
+
+	Command    string   `json:"command,omitempty"    yaml:"command,omitempty"`    // leading command, e.g. "/ops"
+	Pattern    string   `json:"pattern,omitempty"    yaml:"pattern,omitempty"`    // regular expression the text must match
+	Keywords   []string `json:"keywords,omitempty"   yaml:"keywords,omitempty"`   // any of these words, case-insensitive
+	Language   string   `json:"language,omitempty"   yaml:"language,omitempty"`   // ISO 639-1 code, see DetectLanguage
+	Attachment string   `json:"attachment,omitempty" yaml:"attachment,omitempty"` // attachment type, e.g. "photo"
+
+	Variants   []Variant `json:"variants,omitempty"   yaml:"variants,omitempty"`   // weighted agents to split matched messages between, see Assign
+	Experiment string    `json:"experiment,omitempty" yaml:"experiment,omitempty"` // name the variants are tagged with
+	Sticky     string    `json:"sticky,omitempty"     yaml:"sticky,omitempty"`     // what keeps a variant: "session" (default) or "user"
+
+	Days     []string `json:"days,omitempty"     yaml:"days,omitempty"`     // "mon".."sun" or ranges like "mon-fri"
+	Hours    string   `json:"hours,omitempty"    yaml:"hours,omitempty"`    // "09:00-17:30"; may wrap past midnight
+	Timezone string   `json:"timezone,omitempty" yaml:"timezone,omitempty"` // IANA zone for Days and Hours, default UTC
+	From     string   `json:"from,omitempty"     yaml:"from,omitempty"`     // RFC 3339 start of a one-off window
+	Until    string   `json:"until,omitempty"    yaml:"until,omitempty"`    // RFC 3339 end of it, exclusive
+
+	Reply string `json:"reply,omitempty" yaml:"reply,omitempty"` // static reply sent instead of running an agent
+}
+
+// Variant is one arm of a weighted split, see Binding.Variants.
+type Variant struct {
+	AgentID string `json:"agent_id" yaml:"agent_id"`
+	Weight  int    `json:"weight"   yaml:"weight"`
+}
+
+// HasContent reports whether the binding has content predicates.
+func (b *Binding) HasContent() bool {
+	return b.Command != "" || b.Pattern != "" || len(b.Keywords) > 0 || b.Language != "" || b.Attachment != ""
 }
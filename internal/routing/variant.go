@@ -0,0 +1,53 @@
+package routing
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+// Stickiness of a binding's variant assignment.
+const (
+	StickySession = "session"
+	StickyUser    = "user"
+)
+
+// Assign returns the agent for a message matched by b: one of its
+// variants, picked by hashing key into the weighted ranges, or AgentID
+// when b has no variants. key is the session key or the user, see
+// Binding.Sticky. A key always gets the same variant while the weights
+// stay the same; changing a weight only moves the keys near the range
+// boundaries it shifts.
+func (b *Binding) Assign(key string) string {
+	total := 0
+	for _, v := range b.Variants {
+		total += max(v.Weight, 0)
+	}
+	if total == 0 {
+		return b.AgentID
+	}
+	h := fnv.New64a()
+	h.Write([]byte(b.ExperimentName()))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	point := float64(h.Sum64()>>11) / (1 << 53) * float64(total)
+	for _, v := range b.Variants {
+		point -= float64(max(v.Weight, 0))
+		if point < 0 {
+			return v.AgentID
+		}
+	}
+	return b.Variants[len(b.Variants)-1].AgentID
+}
+
+// ExperimentName returns the name b's variants are tagged with:
+// Experiment, or else the variants' agent IDs joined by "/".
+func (b *Binding) ExperimentName() string {
+	if b.Experiment != "" || len(b.Variants) == 0 {
+		return b.Experiment
+	}
+	ids := make([]string, len(b.Variants))
+	for i, v := range b.Variants {
+		ids[i] = v.AgentID
+	}
+	return strings.Join(ids, "/")
+}
@@ -0,0 +1,54 @@
+package routing
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAssign(t *testing.T) {
+	b := Binding{AgentID: "v1", Variants: []Variant{{AgentID: "v1", Weight: 90}, {AgentID: "v2", Weight: 10}}}
+
+	counts := map[string]int{}
+	for i := range 10000 {
+		key := fmt.Sprintf("agent:v1:telegram:user:%d", i)
+		got := b.Assign(key)
+		if again := b.Assign(key); again != got {
+			t.Fatalf("Assign(%q) = %q then %q", key, got, again)
+		}
+		counts[got]++
+	}
+	if n := counts["v2"]; n < 800 || n > 1200 {
+		t.Errorf("v2 got %d of 10000, want about 1000", n)
+	}
+
+	// Shifting weight from v1 to v2 only moves keys to v2.
+	shifted := b
+	shifted.Variants = []Variant{{AgentID: "v1", Weight: 80}, {AgentID: "v2", Weight: 20}}
+	for i := range 1000 {
+		key := fmt.Sprint(i)
+		if b.Assign(key) == "v2" && shifted.Assign(key) != "v2" {
+			t.Errorf("key %s moved from v2 to v1", key)
+		}
+	}
+
+	// Another experiment splits the same keys independently.
+	other := b
+	other.Experiment = "other"
+	same := 0
+	for i := range 1000 {
+		key := fmt.Sprint(i)
+		if b.Assign(key) == "v2" && other.Assign(key) == "v2" {
+			same++
+		}
+	}
+	if same > 40 {
+		t.Errorf("%d keys in v2 of both experiments, want about 10", same)
+	}
+
+	if got := (&Binding{AgentID: "solo"}).Assign("k"); got != "solo" {
+		t.Errorf("no variants: Assign = %q, want solo", got)
+	}
+	if got := b.ExperimentName(); got != "v1/v2" {
+		t.Errorf("ExperimentName = %q, want v1/v2", got)
+	}
+}
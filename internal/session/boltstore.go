@@ -0,0 +1,209 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketActive  = []byte("sessions")
+	bucketArchive = []byte("archive")
+)
+
+// BoltStore is a Store backed by an embedded bbolt database file.
+//
+// Active sessions live in the "sessions" bucket keyed by session key.
+// Archived sessions live in the "archive" bucket keyed by
+// "{sessionKey}@{archivedAtUnixNano}" so all archives of a key sort together.
+//
+// With a Cipher, records are sealed with AES-GCM before they are written.
+// Plain JSON records from before encryption was enabled remain readable and
+// are sealed on their next write or by Rekey.
+type BoltStore struct {
+	db     *bolt.DB
+	cipher *Cipher
+}
+
+// OpenBoltStore opens (or creates) a bbolt database at path. A nil cipher
+// stores records as plain JSON.
+func OpenBoltStore(path string, c *Cipher) (*BoltStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("session store dir: %w", err)
+		}
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open session store %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketActive, bucketArchive} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init session store: %w", err)
+	}
+	return &BoltStore{db: db, cipher: c}, nil
+}
+
+func (s *BoltStore) Load(key string) (*Record, error) {
+	var rec *Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketActive).Get([]byte(key))
+		if data == nil {
+			return ErrNotFound
+		}
+		rec = &Record{}
+		return s.decode([]byte(key), data, rec)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *BoltStore) Save(rec *Record) error {
+	data, err := s.encode([]byte(rec.Key), rec)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketActive).Put([]byte(rec.Key), data)
+	})
+}
+
+func (s *BoltStore) Archive(key string, at time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		active := tx.Bucket(bucketActive)
+		data := active.Get([]byte(key))
+		if data == nil {
+			return ErrNotFound
+		}
+		var rec Record
+		if err := s.decode([]byte(key), data, &rec); err != nil {
+			return err
+		}
+		rec.ArchivedAt = at
+		akey := []byte(archiveKey(key, at))
+		out, err := s.encode(akey, &rec)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketArchive).Put(akey, out); err != nil {
+			return err
+		}
+		return active.Delete([]byte(key))
+	})
+}
+
+func (s *BoltStore) Scan(fn func(rec *Record) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketActive, bucketArchive} {
+			err := tx.Bucket(name).ForEach(func(k, v []byte) error {
+				var rec Record
+				if err := s.decode(k, v, &rec); err != nil {
+					return err
+				}
+				return fn(&rec)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// Rekey seals every stored record, active and archived, with the cipher's
+// current key. Records already sealed with that key are left alone. It
+// returns the number of records rewritten.
+//
+// To rotate keys, put the new key first in the key list, keep the old one
+// after it, run Rekey, then drop the old key.
+func (s *BoltStore) Rekey() (int, error) {
+	if s.cipher == nil {
+		return 0, fmt.Errorf("rekey: session encryption is not configured")
+	}
+	n := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketActive, bucketArchive} {
+			b := tx.Bucket(name)
+			updates := make(map[string][]byte)
+			err := b.ForEach(func(k, v []byte) error {
+				if sealedKeyID(v) == s.cipher.KeyID() {
+					return nil
+				}
+				var rec Record
+				if err := s.decode(k, v, &rec); err != nil {
+					return err
+				}
+				out, err := s.encode(k, &rec)
+				if err != nil {
+					return err
+				}
+				updates[string(k)] = out
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			// Buckets must not be modified inside ForEach.
+			for k, v := range updates {
+				if err := b.Put([]byte(k), v); err != nil {
+					return err
+				}
+			}
+			n += len(updates)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// encode marshals a record and seals it when encryption is enabled. The
+// storage key is bound to the ciphertext as additional data.
+func (s *BoltStore) encode(key []byte, rec *Record) ([]byte, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal session %s: %w", key, err)
+	}
+	if s.cipher == nil {
+		return data, nil
+	}
+	return s.cipher.Seal(data, key)
+}
+
+// decode opens a sealed record if needed and unmarshals it.
+func (s *BoltStore) decode(key, data []byte, rec *Record) error {
+	if isSealed(data) {
+		if s.cipher == nil {
+			return fmt.Errorf("session %s: %w (no encryption key configured)", key, ErrNoKey)
+		}
+		plain, err := s.cipher.Open(data, key)
+		if err != nil {
+			return fmt.Errorf("session %s: %w", key, err)
+		}
+		data = plain
+	}
+	if err := json.Unmarshal(data, rec); err != nil {
+		return fmt.Errorf("unmarshal session %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoKey is returned when a stored record was encrypted with a key the
+// Cipher does not hold, or when an encrypted store is opened without a Cipher.
+var ErrNoKey = errors.New("session record encrypted with an unknown key")
+
+// Sealed record layout:
+//
+//	version (1) | key ID (4) | nonce (12) | AES-GCM ciphertext+tag
+//
+// Plain JSON records always start with '{', so the two can be told apart
+// and stores written before encryption was enabled stay readable.
+const (
+	sealVersion = 0x01
+	keyIDSize   = 4
+)
+
+// KeySize is the required length of a session encryption key (AES-256).
+const KeySize = 32
+
+// Cipher seals session records with AES-256-GCM.
+//
+// It holds one or more keys. The first key encrypts; every key decrypts, so
+// records written under an older key stay readable until they are rekeyed.
+type Cipher struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewCipher creates a cipher from raw 32-byte keys, current key first.
+func NewCipher(keys ...[]byte) (*Cipher, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("session encryption: no keys")
+	}
+	c := &Cipher{aeads: make(map[string]cipher.AEAD, len(keys))}
+	for i, key := range keys {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("session encryption: key %d is %d bytes, want %d", i+1, len(key), KeySize)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("session encryption: %w", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("session encryption: %w", err)
+		}
+		id := keyID(key)
+		if i == 0 {
+			c.primary = id
+		}
+		c.aeads[id] = aead
+	}
+	return c, nil
+}
+
+// ParseKeys decodes encryption keys from text: one base64 or hex key per
+// line or comma-separated field. Blank lines and lines starting with '#'
+// are ignored.
+func ParseKeys(text string) ([][]byte, error) {
+	var keys [][]byte
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, field := range strings.Split(line, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			key, err := decodeKey(field)
+			if err != nil {
+				return nil, fmt.Errorf("session encryption: key %d: %w", len(keys)+1, err)
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// GenerateKey returns a new random key, base64-encoded.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// KeyID returns the short identifier of the key used for encryption.
+func (c *Cipher) KeyID() string { return c.primary }
+
+// Seal encrypts plaintext with the current key. aad is authenticated but
+// not encrypted; stores pass the record's storage key so sealed records
+// cannot be swapped between keys.
+func (c *Cipher) Seal(plaintext, aad []byte) ([]byte, error) {
+	aead := c.aeads[c.primary]
+	id, _ := hex.DecodeString(c.primary)
+
+	out := make([]byte, 0, 1+keyIDSize+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out = append(out, sealVersion)
+	out = append(out, id...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("session encryption: %w", err)
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, aad), nil
+}
+
+// Open decrypts a record produced by Seal with any of the cipher's keys.
+func (c *Cipher) Open(sealed, aad []byte) ([]byte, error) {
+	if !isSealed(sealed) || len(sealed) < 1+keyIDSize {
+		return nil, errors.New("session encryption: not a sealed record")
+	}
+	id := hex.EncodeToString(sealed[1 : 1+keyIDSize])
+	aead, ok := c.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w (key %s)", ErrNoKey, id)
+	}
+	rest := sealed[1+keyIDSize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("session encryption: truncated record")
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, fmt.Errorf("session encryption: %w", err)
+	}
+	return plaintext, nil
+}
+
+// isSealed reports whether data is a sealed record rather than plain JSON.
+func isSealed(data []byte) bool {
+	return len(data) > 0 && data[0] == sealVersion
+}
+
+// sealedKeyID returns the key ID of a sealed record.
+func sealedKeyID(data []byte) string {
+	if !isSealed(data) || len(data) < 1+keyIDSize {
+		return ""
+	}
+	return hex.EncodeToString(data[1 : 1+keyIDSize])
+}
+
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:keyIDSize])
+}
+
+func decodeKey(s string) ([]byte, error) {
+	if len(s) == hex.EncodedLen(KeySize) {
+		if key, err := hex.DecodeString(s); err == nil {
+			return key, nil
+		}
+	}
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("not valid base64 or hex")
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("%d bytes, want %d", len(key), KeySize)
+	}
+	return key, nil
+}
@@ -0,0 +1,161 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Export formats.
+const (
+	FormatJSONL    = "jsonl"
+	FormatMarkdown = "markdown"
+)
+
+// exportLine is one line of a JSONL export. The first line has type
+// "session" and carries the metadata; each following line has type
+// "message" and carries one history entry.
+type exportLine struct {
+	Type string `json:"type"`
+
+	// Session header fields.
+	Key       string            `json:"key,omitempty"`
+	AgentID   string            `json:"agent_id,omitempty"`
+	CreatedAt time.Time         `json:"created_at,omitzero"`
+	TouchedAt time.Time         `json:"touched_at,omitzero"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Usage     *Usage            `json:"usage,omitempty"`
+
+	// Message fields.
+	Role    string    `json:"role,omitempty"`
+	Content string    `json:"content,omitempty"`
+	Time    time.Time `json:"time,omitzero"`
+}
+
+// Export writes rec to w in the given format.
+func Export(w io.Writer, rec Record, format string) error {
+	switch format {
+	case FormatJSONL, "":
+		return ExportJSONL(w, rec)
+	case FormatMarkdown, "md":
+		return ExportMarkdown(w, rec)
+	default:
+		return fmt.Errorf("unknown export format: %s", format)
+	}
+}
+
+// ExportJSONL writes rec as a session header line followed by one line per message.
+func ExportJSONL(w io.Writer, rec Record) error {
+	enc := json.NewEncoder(w)
+	usage := rec.Usage
+	header := exportLine{
+		Type:      "session",
+		Key:       rec.Key,
+		AgentID:   rec.AgentID,
+		CreatedAt: rec.CreatedAt,
+		TouchedAt: rec.TouchedAt,
+		Metadata:  rec.Metadata,
+		Usage:     &usage,
+	}
+	if err := enc.Encode(header); err != nil {
+		return fmt.Errorf("export session %s: %w", rec.Key, err)
+	}
+	for _, msg := range rec.History {
+		line := exportLine{Type: "message", Role: msg.Role, Content: msg.Content, Time: msg.Time}
+		if err := enc.Encode(line); err != nil {
+			return fmt.Errorf("export session %s: %w", rec.Key, err)
+		}
+	}
+	return nil
+}
+
+// ExportMarkdown writes rec as a human-readable transcript.
+func ExportMarkdown(w io.Writer, rec Record) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Session `%s`\n\n", rec.Key)
+	fmt.Fprintf(&b, "- **Agent:** %s\n", rec.AgentID)
+	fmt.Fprintf(&b, "- **Created:** %s\n", rec.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- **Last active:** %s\n", rec.TouchedAt.Format(time.RFC3339))
+	if !rec.ArchivedAt.IsZero() {
+		fmt.Fprintf(&b, "- **Archived:** %s\n", rec.ArchivedAt.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "- **Messages:** %d\n", len(rec.History))
+	fmt.Fprintf(&b, "- **Usage:** %d runs, %d input tokens, %d output tokens\n",
+		rec.Usage.Runs, rec.Usage.InputTokens, rec.Usage.OutputTokens)
+
+	if len(rec.Metadata) > 0 {
+		keys := make([]string, 0, len(rec.Metadata))
+		for k := range rec.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteString("\n## Metadata\n\n")
+		for _, k := range keys {
+			fmt.Fprintf(&b, "- `%s`: %s\n", k, rec.Metadata[k])
+		}
+	}
+
+	b.WriteString("\n## Transcript\n")
+	for i, msg := range rec.History {
+		fmt.Fprintf(&b, "\n### %d. %s", i, msg.Role)
+		if !msg.Time.IsZero() {
+			fmt.Fprintf(&b, " — %s", msg.Time.Format(time.RFC3339))
+		}
+		fmt.Fprintf(&b, "\n\n%s\n", msg.Content)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ImportJSONL reads a record written by ExportJSONL.
+func ImportJSONL(r io.Reader) (Record, error) {
+	var rec Record
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16<<20)
+
+	lineNo := 0
+	for sc.Scan() {
+		raw := strings.TrimSpace(sc.Text())
+		if raw == "" {
+			continue
+		}
+		lineNo++
+		var line exportLine
+		if err := json.Unmarshal([]byte(raw), &line); err != nil {
+			return Record{}, fmt.Errorf("import line %d: %w", lineNo, err)
+		}
+		switch line.Type {
+		case "session":
+			if lineNo != 1 {
+				return Record{}, fmt.Errorf("import line %d: unexpected session header", lineNo)
+			}
+			rec.Key = line.Key
+			rec.AgentID = line.AgentID
+			rec.CreatedAt = line.CreatedAt
+			rec.TouchedAt = line.TouchedAt
+			rec.Metadata = line.Metadata
+			if line.Usage != nil {
+				rec.Usage = *line.Usage
+			}
+		case "message":
+			if lineNo == 1 {
+				return Record{}, fmt.Errorf("import line 1: missing session header")
+			}
+			rec.History = append(rec.History, Message{Role: line.Role, Content: line.Content, Time: line.Time})
+		default:
+			return Record{}, fmt.Errorf("import line %d: unknown line type %q", lineNo, line.Type)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Record{}, fmt.Errorf("import: %w", err)
+	}
+	if lineNo == 0 {
+		return Record{}, fmt.Errorf("import: empty input")
+	}
+	return rec, nil
+}
@@ -13,20 +13,49 @@ import (
 //	agent:{agentID}:{channel}:{peerKind}:{peerID}         — DM
 //	agent:{agentID}:{channel}:group:{guildID}              — group
 //	agent:{agentID}:{channel}:group:{guildID}:{threadID}   — thread
+//
+// Scoped keys built by BuildKey may append tagged segments to these:
+//
+//	...:thread:{threadID}   — DM thread (ScopeThread)
+//	...:sender:{senderID}   — one sender within a group (ScopeSender)
+//	...:day:{YYYY-MM-DD}    — daily rollover (ScopeDaily)
+//	...:branch:{id}         — a branch of another session (Manager.Branch)
+//
+// Components are escaped, so IDs containing ':' (Matrix user and room IDs,
+// client-chosen WebSocket tokens) stay one segment.
 func Key(agentID, channel, peerKind, peerID, guildID, threadID string) string {
 	if channel == "" || channel == "websocket" {
-		return fmt.Sprintf("agent:%s:main", agentID)
+		return fmt.Sprintf("agent:%s:main", escapeSegment(agentID))
 	}
 	if guildID != "" {
-		base := fmt.Sprintf("agent:%s:%s:group:%s", agentID, channel, guildID)
+		base := fmt.Sprintf("agent:%s:%s:group:%s", escapeSegment(agentID), escapeSegment(channel), escapeSegment(guildID))
 		if threadID != "" {
-			return base + ":" + threadID
+			return base + ":" + escapeSegment(threadID)
 		}
 		return base
 	}
-	return fmt.Sprintf("agent:%s:%s:%s:%s", agentID, channel, peerKind, peerID)
+	return fmt.Sprintf("agent:%s:%s:%s:%s", escapeSegment(agentID), escapeSegment(channel), escapeSegment(peerKind), escapeSegment(peerID))
 }
 
+var (
+	segmentEscaper   = strings.NewReplacer("%", "%25", ":", "%3A")
+	segmentUnescaper = strings.NewReplacer("%3A", ":", "%25", "%")
+)
+
+// escapeSegment encodes '%' and ':' in one key component.
+func escapeSegment(s string) string { return segmentEscaper.Replace(s) }
+
+// unescapeSegment reverses escapeSegment.
+func unescapeSegment(s string) string { return segmentUnescaper.Replace(s) }
+
+// Tags for the optional trailing segments of a scoped session key.
+const (
+	tagThread = "thread"
+	tagSender = "sender"
+	tagDay    = "day"
+	tagBranch = "branch"
+)
+
 // ParsedKey holds the decomposed parts of a session key.
 type ParsedKey struct {
 	AgentID  string
@@ -35,6 +64,9 @@ type ParsedKey struct {
 	PeerID   string
 	GuildID  string
 	ThreadID string
+	SenderID string
+	Day      string
+	Branch   string
 }
 
 // ParseKey decomposes a session key string.
@@ -43,28 +75,96 @@ func ParseKey(key string) (ParsedKey, error) {
 	if len(parts) < 3 || parts[0] != "agent" {
 		return ParsedKey{}, fmt.Errorf("invalid session key: %s", key)
 	}
+	for i := range parts {
+		parts[i] = unescapeSegment(parts[i])
+	}
 
 	pk := ParsedKey{AgentID: parts[1]}
 
+	var rest []string
 	if parts[2] == "main" {
 		pk.Channel = "websocket"
-		return pk, nil
-	}
+		rest = parts[3:]
+	} else {
+		pk.Channel = parts[2]
+		if len(parts) < 5 {
+			return ParsedKey{}, fmt.Errorf("invalid session key: %s", key)
+		}
+		pk.PeerKind = parts[3]
+		pk.PeerID = parts[4]
+		rest = parts[5:]
 
-	pk.Channel = parts[2]
-	if len(parts) < 5 {
-		return ParsedKey{}, fmt.Errorf("invalid session key: %s", key)
+		if pk.PeerKind == "group" {
+			pk.GuildID = parts[4]
+			pk.PeerID = ""
+			// Group threads use the untagged form: group:{guildID}:{threadID}.
+			if len(rest) > 0 && !isTag(rest[0]) {
+				pk.ThreadID = rest[0]
+				rest = rest[1:]
+			}
+		}
 	}
-	pk.PeerKind = parts[3]
-	pk.PeerID = parts[4]
 
-	if pk.PeerKind == "group" {
-		pk.GuildID = parts[4]
-		pk.PeerID = ""
-		if len(parts) >= 6 {
-			pk.ThreadID = parts[5]
+	for len(rest) > 0 {
+		if len(rest) < 2 || !isTag(rest[0]) {
+			return ParsedKey{}, fmt.Errorf("invalid session key: %s", key)
 		}
+		switch rest[0] {
+		case tagThread:
+			pk.ThreadID = rest[1]
+		case tagSender:
+			pk.SenderID = rest[1]
+		case tagDay:
+			pk.Day = rest[1]
+		case tagBranch:
+			pk.Branch = rest[1]
+		}
+		rest = rest[2:]
 	}
 
 	return pk, nil
 }
+
+// String rebuilds the session key. For any key k accepted by ParseKey,
+// ParseKey(k).String() == k.
+func (pk ParsedKey) String() string {
+	var b strings.Builder
+	seg := func(parts ...string) {
+		for _, p := range parts {
+			b.WriteString(":" + escapeSegment(p))
+		}
+	}
+	b.WriteString("agent")
+	seg(pk.AgentID)
+	switch {
+	case pk.PeerKind == "":
+		b.WriteString(":main")
+		if pk.ThreadID != "" {
+			seg(tagThread, pk.ThreadID)
+		}
+	case pk.PeerKind == "group":
+		seg(pk.Channel, "group", pk.GuildID)
+		if pk.ThreadID != "" {
+			seg(pk.ThreadID)
+		}
+	default:
+		seg(pk.Channel, pk.PeerKind, pk.PeerID)
+		if pk.ThreadID != "" {
+			seg(tagThread, pk.ThreadID)
+		}
+	}
+	if pk.SenderID != "" {
+		seg(tagSender, pk.SenderID)
+	}
+	if pk.Day != "" {
+		seg(tagDay, pk.Day)
+	}
+	if pk.Branch != "" {
+		seg(tagBranch, pk.Branch)
+	}
+	return b.String()
+}
+
+func isTag(s string) bool {
+	return s == tagThread || s == tagSender || s == tagDay || s == tagBranch
+}
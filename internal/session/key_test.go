@@ -1,6 +1,9 @@
 package session
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestKey(t *testing.T) {
 	tests := []struct {
@@ -65,3 +68,129 @@ func TestParseKey(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildKey(t *testing.T) {
+	day := time.Date(2026, 3, 14, 23, 0, 0, 0, time.UTC)
+	dm := KeyParams{AgentID: "a", Channel: "telegram", PeerKind: "user", PeerID: "7", SenderID: "7", Time: day}
+	group := KeyParams{AgentID: "a", Channel: "telegram", PeerKind: "group", PeerID: "99", GuildID: "99", SenderID: "7", Time: day}
+	thread := group
+	thread.ThreadID = "42"
+	dmThread := dm
+	dmThread.ThreadID = "5"
+	ws := KeyParams{AgentID: "a", Channel: "websocket", PeerKind: "user", PeerID: "c1", SenderID: "c1", Time: day}
+
+	tests := []struct {
+		name  string
+		scope Scope
+		p     KeyParams
+		want  string
+	}{
+		{"default dm", ScopeDefault, dm, "agent:a:telegram:user:7"},
+		{"default group", ScopeDefault, group, "agent:a:telegram:group:99"},
+		{"default ws", ScopeDefault, ws, "agent:a:main"},
+		{"sender group", ScopeSender, group, "agent:a:telegram:group:99:sender:7"},
+		{"sender group thread", ScopeSender, thread, "agent:a:telegram:group:99:42:sender:7"},
+		{"sender dm", ScopeSender, dm, "agent:a:telegram:user:7"},
+		{"thread group", ScopeThread, thread, "agent:a:telegram:group:99:42"},
+		{"thread dm", ScopeThread, dmThread, "agent:a:telegram:user:7:thread:5"},
+		{"thread ws no thread", ScopeThread, ws, "agent:a:main"},
+		{"daily dm", ScopeDaily, dm, "agent:a:telegram:user:7:day:2026-03-14"},
+		{"daily ws", ScopeDaily, ws, "agent:a:main:day:2026-03-14"},
+		{"global", ScopeGlobal, group, "agent:a:main"},
+		{"client ws", ScopeClient, ws, "agent:a:websocket:user:c1"},
+		{"client group", ScopeClient, group, "agent:a:telegram:user:7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildKey(tt.scope, tt.p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("BuildKey() = %q, want %q", got, tt.want)
+			}
+			pk, err := ParseKey(got)
+			if err != nil {
+				t.Fatalf("ParseKey(%q): %v", got, err)
+			}
+			if pk.String() != got {
+				t.Errorf("round trip = %q, want %q", pk.String(), got)
+			}
+		})
+	}
+}
+
+func TestBuildKeyColonIDs(t *testing.T) {
+	day := time.Date(2026, 3, 14, 23, 0, 0, 0, time.UTC)
+	mxDM := KeyParams{AgentID: "main", Channel: "matrix", PeerKind: "user", PeerID: "@bob:matrix.org", SenderID: "@bob:matrix.org", ThreadID: "$ev:x", Time: day}
+	mxGroup := KeyParams{AgentID: "main", Channel: "matrix", PeerKind: "group", PeerID: "!r:matrix.org", GuildID: "!r:matrix.org", SenderID: "@al:matrix.org", ThreadID: "$root:matrix.org", Time: day}
+	ws := KeyParams{AgentID: "main", Channel: "websocket", PeerKind: "user", PeerID: "tok:en%3A", SenderID: "tok:en%3A", Time: day}
+
+	for _, scope := range []Scope{ScopeDefault, ScopeSender, ScopeThread, ScopeDaily, ScopeGlobal, ScopeClient} {
+		for _, p := range []KeyParams{mxDM, mxGroup, ws} {
+			key, err := BuildKey(scope, p)
+			if err != nil {
+				t.Fatalf("%s %s: %v", scope, p.PeerID, err)
+			}
+			pk, err := ParseKey(key)
+			if err != nil {
+				t.Fatalf("%s: ParseKey(%q): %v", scope, key, err)
+			}
+			if pk.String() != key {
+				t.Errorf("%s: round trip = %q, want %q", scope, pk.String(), key)
+			}
+			if pk.AgentID != "main" {
+				t.Errorf("%s: %q lost the agent: %+v", scope, key, pk)
+			}
+			switch {
+			case scope == ScopeGlobal:
+			case p.Channel == "matrix" && p.GuildID == "" && pk.PeerID != p.PeerID:
+				t.Errorf("%s: %q peer = %q, want %q", scope, key, pk.PeerID, p.PeerID)
+			case p.GuildID != "" && scope != ScopeClient && pk.GuildID != p.GuildID:
+				t.Errorf("%s: %q guild = %q, want %q", scope, key, pk.GuildID, p.GuildID)
+			case scope == ScopeClient && p.Channel == "websocket" && pk.PeerID != p.PeerID:
+				t.Errorf("%s: %q peer = %q, want %q", scope, key, pk.PeerID, p.PeerID)
+			}
+		}
+	}
+
+	// Distinct users never share a daily session.
+	other := mxDM
+	other.PeerID = "@eve:matrix.org"
+	a, _ := BuildKey(ScopeDaily, mxDM)
+	b, _ := BuildKey(ScopeDaily, other)
+	if a == b {
+		t.Errorf("daily keys collide: %q", a)
+	}
+}
+
+func TestParseKeyTagged(t *testing.T) {
+	pk, err := ParseKey("agent:a:telegram:group:99:42:sender:7:day:2026-03-14")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pk.GuildID != "99" || pk.ThreadID != "42" || pk.SenderID != "7" || pk.Day != "2026-03-14" {
+		t.Errorf("ParseKey = %+v", pk)
+	}
+	if _, err := ParseKey("agent:a:telegram:user:7:sender"); err == nil {
+		t.Error("expected error for dangling tag")
+	}
+}
+
+func TestScopesFor(t *testing.T) {
+	s := Scopes{
+		Default:  ScopeDefault,
+		Channels: map[string]Scope{"websocket": ScopeClient},
+		Agents:   map[string]Scope{"journal": ScopeDaily},
+	}
+	if got := s.For("x", ""); got != ScopeClient {
+		t.Errorf("websocket scope = %q", got)
+	}
+	if got := s.For("journal", "websocket"); got != ScopeDaily {
+		t.Errorf("agent override = %q", got)
+	}
+	if got := s.For("x", "telegram"); got != ScopeDefault {
+		t.Errorf("default = %q", got)
+	}
+}
@@ -2,26 +2,45 @@ package session
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // Manager handles session lifecycle.
+//
+// Active sessions are cached in memory and written through to a Store.
+// Sessions missing from the cache are loaded lazily from the store.
 type Manager struct {
 	sessions   map[string]*Entry
 	mu         sync.RWMutex
 	ttl        time.Duration
 	maxHistory int
+	store      Store
+
+	indexMu sync.Mutex
+	index   *Index // nil until the first search
 }
 
-// NewManager creates a session manager.
-func NewManager(ttl time.Duration, maxHistory int) *Manager {
-	return &Manager{
+// NewManager creates a session manager. A nil store keeps sessions in memory only.
+// The search index is built from the store on the first search, so session
+// plaintext is only held in memory once search is actually used.
+func NewManager(ttl time.Duration, maxHistory int, store Store) *Manager {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	m := &Manager{
 		sessions:   make(map[string]*Entry),
 		ttl:        ttl,
 		maxHistory: maxHistory,
+		store:      store,
 	}
+	return m
 }
 
 // GetOrCreate returns an existing session or creates a new one.
@@ -34,27 +53,225 @@ func (m *Manager) GetOrCreate(key, agentID string) *Entry {
 		return e
 	}
 
-	now := time.Now()
-	e := &Entry{
-		Key:       key,
-		AgentID:   agentID,
-		CreatedAt: now,
-		TouchedAt: now,
+	if e, ok := m.load(key); ok {
+		return e
 	}
-	m.sessions[key] = e
+
+	e := m.create(key, agentID)
 	slog.Debug("session created", "key", key, "agent", agentID)
 	return e
 }
 
-// Get returns a session if it exists.
+// Get returns a session if it exists, loading it from the store if needed.
 func (m *Manager) Get(key string) (*Entry, bool) {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
 	e, ok := m.sessions[key]
+	m.mu.RUnlock()
 	if ok {
 		e.Touch()
+		return e, true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.sessions[key]; ok {
+		e.Touch()
+		return e, true
+	}
+	return m.load(key)
+}
+
+// Append adds messages to a session's history and writes it through to the store.
+func (m *Manager) Append(e *Entry, msgs ...Message) {
+	for _, msg := range msgs {
+		e.AppendHistory(msg, m.maxHistory)
+	}
+	m.save(e)
+}
+
+// Save writes the current state of a session through to the store.
+func (m *Manager) Save(e *Entry) {
+	m.save(e)
+}
+
+// Reset archives the session under key and starts a fresh one with the same key.
+// If agentID is empty, the agent of the archived session is kept.
+func (m *Manager) Reset(key, agentID string) *Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if agentID == "" {
+		if old, ok := m.sessions[key]; ok {
+			agentID = old.AgentID
+		} else if rec, err := m.store.Load(key); err == nil {
+			agentID = rec.AgentID
+		}
+	}
+	m.retire(key)
+
+	e := m.create(key, agentID)
+	slog.Info("session reset", "key", key, "agent", agentID)
+	return e
+}
+
+// Fork copies the full history of srcKey into a new session under dstKey.
+// An empty dstKey generates one derived from srcKey.
+func (m *Manager) Fork(srcKey, dstKey string) (*Entry, error) {
+	return m.Branch(srcKey, dstKey, -1)
+}
+
+// Branch creates a new session under dstKey holding the first index messages
+// of srcKey's history, so the conversation can diverge at message index.
+// A negative index copies the whole history. An empty dstKey generates one
+// derived from srcKey.
+func (m *Manager) Branch(srcKey, dstKey string, index int) (*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var src Record
+	if e, ok := m.sessions[srcKey]; ok {
+		src = e.Snapshot()
+	} else {
+		rec, err := m.store.Load(srcKey)
+		if err != nil {
+			return nil, fmt.Errorf("branch %s: %w", srcKey, err)
+		}
+		src = *rec
+	}
+
+	if index < 0 {
+		index = len(src.History)
+	}
+	if index > len(src.History) {
+		return nil, fmt.Errorf("branch %s: index %d out of range (history has %d messages)", srcKey, index, len(src.History))
+	}
+
+	if dstKey == "" {
+		dstKey = branchKey(srcKey, uuid.NewString()[:8])
+	}
+	if _, ok := m.sessions[dstKey]; ok {
+		return nil, fmt.Errorf("branch %s: %w: %s", srcKey, ErrExists, dstKey)
+	}
+	if _, err := m.store.Load(dstKey); err == nil {
+		return nil, fmt.Errorf("branch %s: %w: %s", srcKey, ErrExists, dstKey)
+	}
+
+	e := &Entry{
+		Key:       dstKey,
+		AgentID:   src.AgentID,
+		CreatedAt: time.Now(),
+		TouchedAt: time.Now(),
+		History:   make([]Message, index),
+		Metadata:  make(map[string]string, len(src.Metadata)+2),
+		Tokens:    src.Tokens,
+	}
+	copy(e.History, src.History[:index])
+	for k, v := range src.Metadata {
+		e.Metadata[k] = v
+	}
+	e.Metadata["parent"] = srcKey
+	e.Metadata["branch_index"] = strconv.Itoa(index)
+
+	m.sessions[dstKey] = e
+	m.save(e)
+	slog.Info("session branched", "src", srcKey, "dst", dstKey, "index", index)
+	return e, nil
+}
+
+// branchKey derives the key of a new branch of srcKey: a parsable key
+// gets a branch segment, so the branch keeps its channel and peer.
+func branchKey(srcKey, id string) string {
+	pk, err := ParseKey(srcKey)
+	if err != nil {
+		return srcKey + ":" + tagBranch + ":" + id
+	}
+	pk.Branch = id
+	return pk.String()
+}
+
+// Export returns a snapshot of the session under key.
+func (m *Manager) Export(key string) (Record, error) {
+	m.mu.RLock()
+	e, ok := m.sessions[key]
+	m.mu.RUnlock()
+	if ok {
+		return e.Snapshot(), nil
+	}
+	rec, err := m.store.Load(key)
+	if err != nil {
+		return Record{}, fmt.Errorf("export %s: %w", key, err)
+	}
+	return *rec, nil
+}
+
+// Import recreates a session from rec. An empty key keeps rec.Key.
+// If a session already exists under the key, Import fails with ErrExists
+// unless replace is set, in which case the existing session is archived first.
+func (m *Manager) Import(rec Record, key string, replace bool) (*Entry, error) {
+	if key == "" {
+		key = rec.Key
+	}
+	if key == "" {
+		return nil, fmt.Errorf("import: session key is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, cached := m.sessions[key]
+	_, err := m.store.Load(key)
+	if cached || err == nil {
+		if !replace {
+			return nil, fmt.Errorf("import %s: %w", key, ErrExists)
+		}
+		m.retire(key)
+	}
+
+	e := entryFromRecord(&rec)
+	if rec.Key != key {
+		e.Metadata = make(map[string]string, len(rec.Metadata)+1)
+		for k, v := range rec.Metadata {
+			e.Metadata[k] = v
+		}
+		e.Metadata["imported_from"] = rec.Key
+	}
+	e.Key = key
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	e.TouchedAt = time.Now()
+
+	m.sessions[key] = e
+	m.save(e)
+	slog.Info("session imported", "key", key, "from", rec.Key, "messages", len(rec.History))
+	return e, nil
+}
+
+// Search finds messages across active and archived sessions.
+func (m *Manager) Search(q SearchQuery) []SearchHit {
+	m.indexMu.Lock()
+	if m.index == nil {
+		m.index = NewIndex(maxIndexedDocs)
+		err := m.store.Scan(func(rec *Record) error {
+			m.index.Put(*rec)
+			return nil
+		})
+		if err != nil {
+			slog.Error("session index build error", "err", err)
+		}
+	}
+	ix := m.index
+	m.indexMu.Unlock()
+	return ix.Search(q)
+}
+
+// indexed runs fn on the search index if it has been built.
+func (m *Manager) indexed(fn func(ix *Index)) {
+	m.indexMu.Lock()
+	defer m.indexMu.Unlock()
+	if m.index != nil {
+		fn(m.index)
 	}
-	return e, ok
 }
 
 // MaxHistory returns the configured max history.
@@ -62,7 +279,12 @@ func (m *Manager) MaxHistory() int {
 	return m.maxHistory
 }
 
-// StartCleanup launches a goroutine that removes expired sessions.
+// Close releases the underlying store.
+func (m *Manager) Close() error {
+	return m.store.Close()
+}
+
+// StartCleanup launches a goroutine that archives expired sessions.
 func (m *Manager) StartCleanup(ctx context.Context, interval time.Duration) {
 	go func() {
 		ticker := time.NewTicker(interval)
@@ -78,18 +300,100 @@ func (m *Manager) StartCleanup(ctx context.Context, interval time.Duration) {
 	}()
 }
 
+// load reads a session from the store into the cache. A stored session that
+// has outlived the TTL (e.g. across a restart) is archived instead of resumed.
+// Callers must hold m.mu.
+func (m *Manager) load(key string) (*Entry, bool) {
+	rec, err := m.store.Load(key)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			slog.Error("session load error", "key", key, "err", err)
+		}
+		return nil, false
+	}
+	now := time.Now()
+	if m.ttl > 0 && rec.TouchedAt.Before(now.Add(-m.ttl)) {
+		if m.archive(key, now) {
+			slog.Debug("session archived", "key", key)
+		}
+		return nil, false
+	}
+	e := entryFromRecord(rec)
+	e.Touch()
+	m.sessions[key] = e
+	slog.Debug("session loaded", "key", key, "agent", e.AgentID, "history", len(e.History))
+	return e, true
+}
+
+// create caches and persists a new empty session. Callers must hold m.mu.
+func (m *Manager) create(key, agentID string) *Entry {
+	now := time.Now()
+	e := &Entry{
+		Key:       key,
+		AgentID:   agentID,
+		CreatedAt: now,
+		TouchedAt: now,
+	}
+	m.sessions[key] = e
+	m.save(e)
+	return e
+}
+
+// save writes an entry through to the store. Entries that have already been
+// archived (e.g. a run finishing after a reset) are not written back.
+func (m *Manager) save(e *Entry) {
+	if e.isArchived() {
+		slog.Debug("session save skipped (archived)", "key", e.Key)
+		return
+	}
+	rec := e.Snapshot()
+	if err := m.store.Save(&rec); err != nil {
+		slog.Error("session save error", "key", e.Key, "err", err)
+		return
+	}
+	m.indexed(func(ix *Index) { ix.Put(rec) })
+}
+
+// retire archives the session under key, including any cached entry, so that
+// a new session can take its place. Callers must hold m.mu.
+func (m *Manager) retire(key string) {
+	if old, ok := m.sessions[key]; ok {
+		m.save(old)
+		old.markArchived()
+		delete(m.sessions, key)
+	}
+	m.archive(key, time.Now())
+}
+
+// archive moves the stored record for key into the archive and re-keys it in
+// the search index. A missing record is not an error.
+func (m *Manager) archive(key string, at time.Time) bool {
+	if err := m.store.Archive(key, at); err != nil && !errors.Is(err, ErrNotFound) {
+		slog.Error("session archive error", "key", key, "err", err)
+		return false
+	}
+	m.indexed(func(ix *Index) { ix.Archive(key, at) })
+	return true
+}
+
 func (m *Manager) cleanup() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	cutoff := time.Now().Add(-m.ttl)
+	now := time.Now()
+	cutoff := now.Add(-m.ttl)
 	for key, e := range m.sessions {
 		e.mu.Lock()
 		expired := e.TouchedAt.Before(cutoff)
 		e.mu.Unlock()
 		if expired {
+			m.save(e)
+			if !m.archive(key, now) {
+				continue
+			}
+			e.markArchived()
 			delete(m.sessions, key)
-			slog.Debug("session expired", "key", key)
+			slog.Debug("session archived", "key", key)
 		}
 	}
 }
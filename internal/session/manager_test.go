@@ -0,0 +1,108 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func seed(m *Manager, key string, texts ...string) *Entry {
+	e := m.GetOrCreate(key, "default")
+	for i, text := range texts {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		m.Append(e, Message{Role: role, Content: text})
+	}
+	return e
+}
+
+func TestReset(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManager(time.Hour, 10, store)
+	old := seed(m, "k", "hi", "hello")
+
+	fresh := m.Reset("k", "")
+	if fresh == old {
+		t.Fatal("Reset returned the old entry")
+	}
+	if fresh.AgentID != "default" || len(fresh.GetHistory()) != 0 {
+		t.Errorf("fresh = %+v", fresh.Snapshot())
+	}
+	if len(store.archived) != 1 || len(store.archived[0].History) != 2 {
+		t.Fatalf("archived = %+v", store.archived)
+	}
+
+	// A run finishing on the old entry must not overwrite the fresh session.
+	m.Append(old, Message{Role: "user", Content: "late"})
+	rec, err := store.Load("k")
+	if err != nil || len(rec.History) != 0 {
+		t.Errorf("stored after late append = %+v, err=%v", rec, err)
+	}
+}
+
+func TestBranch(t *testing.T) {
+	m := NewManager(time.Hour, 10, nil)
+	seed(m, "src", "a", "b", "c", "d")
+
+	b, err := m.Branch("src", "dst", 2)
+	if err != nil {
+		t.Fatalf("Branch: %v", err)
+	}
+	h := b.GetHistory()
+	if len(h) != 2 || h[1].Content != "b" {
+		t.Errorf("branch history = %+v", h)
+	}
+	if b.GetMeta("parent") != "src" || b.GetMeta("branch_index") != "2" {
+		t.Errorf("branch metadata = %+v", b.Metadata)
+	}
+
+	if _, err := m.Branch("src", "dst", 1); !errors.Is(err, ErrExists) {
+		t.Errorf("expected ErrExists, got %v", err)
+	}
+	if _, err := m.Branch("src", "", 5); err == nil {
+		t.Error("expected out-of-range error")
+	}
+	if _, err := m.Fork("missing", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	f, err := m.Fork("src", "")
+	if err != nil {
+		t.Fatalf("Fork: %v", err)
+	}
+	if len(f.GetHistory()) != 4 || f.Key == "src" {
+		t.Errorf("fork = %+v", f.Snapshot())
+	}
+}
+
+func TestBranchKeyParses(t *testing.T) {
+	m := NewManager(time.Hour, 10, nil)
+	src := Key("a", "matrix", "user", "@bob:matrix.org", "", "")
+	seed(m, src, "hi")
+
+	b, err := m.Branch(src, "", -1)
+	if err != nil {
+		t.Fatalf("Branch: %v", err)
+	}
+	pk, err := ParseKey(b.Key)
+	if err != nil {
+		t.Fatalf("ParseKey(%q): %v", b.Key, err)
+	}
+	if pk.String() != b.Key {
+		t.Errorf("round trip = %q, want %q", pk.String(), b.Key)
+	}
+	if pk.Channel != "matrix" || pk.PeerID != "@bob:matrix.org" || pk.Branch == "" {
+		t.Errorf("branch key parsed as %+v", pk)
+	}
+
+	// A branch of a branch gets a new branch ID.
+	bb, err := m.Branch(b.Key, "", -1)
+	if err != nil {
+		t.Fatalf("Branch: %v", err)
+	}
+	if bb.Key == b.Key {
+		t.Errorf("nested branch reused key %q", bb.Key)
+	}
+}
@@ -0,0 +1,146 @@
+package session
+
+import (
+	"fmt"
+	"time"
+)
+
+// Scope selects how inbound messages are grouped into sessions.
+type Scope string
+
+const (
+	// ScopeDefault is the built-in scoping of Key: per peer for DMs, per chat
+	// (and per thread, when present) for groups, one shared session per agent
+	// for WebSocket clients.
+	ScopeDefault Scope = "default"
+
+	// ScopeSender gives every sender in a group their own session.
+	ScopeSender Scope = "sender"
+
+	// ScopeThread gives every thread its own session, in DMs as well as groups.
+	ScopeThread Scope = "thread"
+
+	// ScopeDaily is ScopeDefault with a new session every calendar day.
+	ScopeDaily Scope = "daily"
+
+	// ScopeGlobal shares one session per agent across all peers and channels.
+	ScopeGlobal Scope = "global"
+
+	// ScopeClient gives every peer its own session on every channel, including
+	// one session per WebSocket connection.
+	ScopeClient Scope = "client"
+)
+
+// ParseScope validates a scope name. An empty name is ScopeDefault.
+func ParseScope(s string) (Scope, error) {
+	switch sc := Scope(s); sc {
+	case "":
+		return ScopeDefault, nil
+	case ScopeDefault, ScopeSender, ScopeThread, ScopeDaily, ScopeGlobal, ScopeClient:
+		return sc, nil
+	default:
+		return "", fmt.Errorf("unknown session scope: %q", s)
+	}
+}
+
+// KeyParams are the message attributes a session key can be built from.
+type KeyParams struct {
+	AgentID  string
+	Channel  string
+	PeerKind string
+	PeerID   string
+	GuildID  string
+	ThreadID string
+	SenderID string    // individual sender, for group messages
+	Time     time.Time // message time in the rollover timezone, for ScopeDaily
+}
+
+// BuildKey builds the session key for a message under the given scope.
+func BuildKey(scope Scope, p KeyParams) (string, error) {
+	switch scope {
+	case ScopeGlobal:
+		return ParsedKey{AgentID: p.AgentID}.String(), nil
+
+	case ScopeClient:
+		if p.PeerID == "" {
+			return Key(p.AgentID, p.Channel, p.PeerKind, p.PeerID, p.GuildID, p.ThreadID), nil
+		}
+		kind := p.PeerKind
+		id := p.PeerID
+		if p.GuildID != "" && p.SenderID != "" {
+			kind, id = "user", p.SenderID
+		}
+		if kind == "" {
+			kind = "user"
+		}
+		return ParsedKey{AgentID: p.AgentID, Channel: channelOrWebsocket(p.Channel), PeerKind: kind, PeerID: id}.String(), nil
+
+	case ScopeSender:
+		if p.GuildID == "" || p.SenderID == "" {
+			return Key(p.AgentID, p.Channel, p.PeerKind, p.PeerID, p.GuildID, p.ThreadID), nil
+		}
+		return ParsedKey{
+			AgentID:  p.AgentID,
+			Channel:  p.Channel,
+			PeerKind: "group",
+			GuildID:  p.GuildID,
+			ThreadID: p.ThreadID,
+			SenderID: p.SenderID,
+		}.String(), nil
+
+	case ScopeThread:
+		pk, err := ParseKey(Key(p.AgentID, p.Channel, p.PeerKind, p.PeerID, p.GuildID, ""))
+		if err != nil {
+			return "", err
+		}
+		pk.ThreadID = p.ThreadID
+		return pk.String(), nil
+
+	case ScopeDaily:
+		pk, err := ParseKey(Key(p.AgentID, p.Channel, p.PeerKind, p.PeerID, p.GuildID, p.ThreadID))
+		if err != nil {
+			return "", err
+		}
+		t := p.Time
+		if t.IsZero() {
+			t = time.Now()
+		}
+		pk.Day = t.Format("2006-01-02")
+		return pk.String(), nil
+
+	default:
+		return Key(p.AgentID, p.Channel, p.PeerKind, p.PeerID, p.GuildID, p.ThreadID), nil
+	}
+}
+
+func channelOrWebsocket(channel string) string {
+	if channel == "" {
+		return "websocket"
+	}
+	return channel
+}
+
+// Scopes resolves the scope for an agent and channel. Agent overrides take
+// precedence over channel overrides, which take precedence over Default.
+type Scopes struct {
+	Default  Scope
+	Channels map[string]Scope
+	Agents   map[string]Scope
+}
+
+// For returns the scope that applies to agentID on channel.
+func (s Scopes) For(agentID, channel string) Scope {
+	if sc, ok := s.Agents[agentID]; ok {
+		return sc
+	}
+	if channel == "" {
+		channel = "websocket"
+	}
+	if sc, ok := s.Channels[channel]; ok {
+		return sc
+	}
+	if s.Default == "" {
+		return ScopeDefault
+	}
+	return s.Default
+}
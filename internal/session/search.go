@@ -0,0 +1,323 @@
+package session
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultSearchLimit = 50
+	snippetRadius      = 60 // runes of context on each side of a match
+
+	// maxIndexedDocs bounds the index; the least recently touched documents
+	// are evicted first.
+	maxIndexedDocs = 10000
+)
+
+// SearchQuery selects messages from the search index.
+// Text is required; all of its terms must appear in a message for it to match.
+type SearchQuery struct {
+	Text    string
+	AgentID string
+	Channel string
+	PeerID  string // matches the peer ID or the group ID of the session
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+// SearchHit is one matching message.
+type SearchHit struct {
+	SessionID  string    `json:"session_id"`
+	AgentID    string    `json:"agent_id"`
+	ArchivedAt time.Time `json:"archived_at,omitzero"`
+	Index      int       `json:"index"` // position of the message in the session history
+	Role       string    `json:"role"`
+	Time       time.Time `json:"time,omitzero"`
+	Snippet    string    `json:"snippet"`
+}
+
+// Index is an in-memory inverted index over the messages of active and
+// archived sessions. Documents are keyed by session key for active sessions
+// and by archive key for archived ones. It holds at most limit documents.
+type Index struct {
+	mu       sync.RWMutex
+	limit    int
+	docs     map[string]*indexedDoc
+	postings map[string]map[string][]int // term -> doc ID -> message indexes
+}
+
+type indexedDoc struct {
+	rec    Record
+	parsed ParsedKey
+}
+
+// NewIndex creates an empty search index holding at most limit documents.
+// A limit of zero or less uses the default.
+func NewIndex(limit int) *Index {
+	if limit <= 0 {
+		limit = maxIndexedDocs
+	}
+	return &Index{
+		limit:    limit,
+		docs:     make(map[string]*indexedDoc),
+		postings: make(map[string]map[string][]int),
+	}
+}
+
+// Put indexes rec, replacing any previous version of the same document.
+func (ix *Index) Put(rec Record) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.put(rec)
+}
+
+// Archive re-keys the active document for key as archived at the given time.
+func (ix *Index) Archive(key string, at time.Time) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	doc, ok := ix.docs[key]
+	if !ok {
+		return
+	}
+	rec := doc.rec
+	rec.ArchivedAt = at
+	ix.remove(key)
+	ix.put(rec)
+}
+
+// put indexes rec. Callers must hold ix.mu.
+func (ix *Index) put(rec Record) {
+	id := rec.Key
+	if !rec.ArchivedAt.IsZero() {
+		id = archiveKey(rec.Key, rec.ArchivedAt)
+	}
+	ix.remove(id)
+
+	// Keys that do not parse (e.g. explicit fork targets) are still searchable
+	// by text and agent, but not by channel or peer.
+	parsed, err := ParseKey(rec.Key)
+	if err != nil {
+		slog.Debug("session index: key not filterable by channel or peer", "key", rec.Key, "err", err)
+	}
+	ix.docs[id] = &indexedDoc{rec: rec, parsed: parsed}
+	for i, msg := range rec.History {
+		seen := make(map[string]bool)
+		for _, term := range tokenize(msg.Content) {
+			if seen[term] {
+				continue
+			}
+			seen[term] = true
+			if ix.postings[term] == nil {
+				ix.postings[term] = make(map[string][]int)
+			}
+			ix.postings[term][id] = append(ix.postings[term][id], i)
+		}
+	}
+	if len(ix.docs) > ix.limit {
+		ix.evictOldest()
+	}
+}
+
+// evictOldest drops the least recently touched document. Callers must hold ix.mu.
+func (ix *Index) evictOldest() {
+	var oldest string
+	var at time.Time
+	for id, doc := range ix.docs {
+		t := doc.lastActive()
+		if oldest == "" || t.Before(at) {
+			oldest, at = id, t
+		}
+	}
+	ix.remove(oldest)
+}
+
+// Search returns matching messages, most recent first.
+func (ix *Index) Search(q SearchQuery) []SearchHit {
+	terms := tokenize(q.Text)
+	if len(terms) == 0 {
+		return nil
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Intersect postings: doc ID -> message indexes containing every term.
+	var matches map[string][]int
+	for _, term := range terms {
+		docs := ix.postings[term]
+		if len(docs) == 0 {
+			return nil
+		}
+		if matches == nil {
+			matches = make(map[string][]int, len(docs))
+			for id, idxs := range docs {
+				matches[id] = idxs
+			}
+			continue
+		}
+		for id, idxs := range matches {
+			kept := intersect(idxs, docs[id])
+			if len(kept) == 0 {
+				delete(matches, id)
+			} else {
+				matches[id] = kept
+			}
+		}
+	}
+
+	var hits []SearchHit
+	for id, idxs := range matches {
+		doc := ix.docs[id]
+		if !doc.matches(q) {
+			continue
+		}
+		for _, i := range idxs {
+			msg := doc.rec.History[i]
+			at := msg.Time
+			if at.IsZero() {
+				at = doc.rec.TouchedAt
+			}
+			if !q.Since.IsZero() && at.Before(q.Since) {
+				continue
+			}
+			if !q.Until.IsZero() && at.After(q.Until) {
+				continue
+			}
+			hits = append(hits, SearchHit{
+				SessionID:  doc.rec.Key,
+				AgentID:    doc.rec.AgentID,
+				ArchivedAt: doc.rec.ArchivedAt,
+				Index:      i,
+				Role:       msg.Role,
+				Time:       at,
+				Snippet:    snippet(msg.Content, terms[0]),
+			})
+		}
+	}
+
+	sort.Slice(hits, func(a, b int) bool {
+		if !hits[a].Time.Equal(hits[b].Time) {
+			return hits[a].Time.After(hits[b].Time)
+		}
+		if hits[a].SessionID != hits[b].SessionID {
+			return hits[a].SessionID < hits[b].SessionID
+		}
+		return hits[a].Index < hits[b].Index
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// remove drops a document and its postings. Callers must hold ix.mu.
+func (ix *Index) remove(id string) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for _, msg := range doc.rec.History {
+		for _, term := range tokenize(msg.Content) {
+			if docs := ix.postings[term]; docs != nil {
+				delete(docs, id)
+				if len(docs) == 0 {
+					delete(ix.postings, term)
+				}
+			}
+		}
+	}
+	delete(ix.docs, id)
+}
+
+func (d *indexedDoc) lastActive() time.Time {
+	if !d.rec.ArchivedAt.IsZero() {
+		return d.rec.ArchivedAt
+	}
+	return d.rec.TouchedAt
+}
+
+func (d *indexedDoc) matches(q SearchQuery) bool {
+	if q.AgentID != "" && d.rec.AgentID != q.AgentID {
+		return false
+	}
+	if q.Channel != "" && d.parsed.Channel != q.Channel {
+		return false
+	}
+	if q.PeerID != "" && d.parsed.PeerID != q.PeerID && d.parsed.GuildID != q.PeerID {
+		return false
+	}
+	return true
+}
+
+// tokenize lowercases text and splits it into letter/digit runs.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// intersect returns the values present in both sorted slices.
+func intersect(a, b []int) []int {
+	var out []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, a[i])
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return out
+}
+
+// snippet returns the text surrounding the first occurrence of term.
+func snippet(text, term string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	at := 0
+	if len(lower) == len(runes) {
+		if i := indexRunes(lower, []rune(term)); i >= 0 {
+			at = i
+		}
+	}
+
+	start := max(at-snippetRadius, 0)
+	end := min(at+len([]rune(term))+snippetRadius, len(runes))
+	out := strings.TrimSpace(string(runes[start:end]))
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(runes) {
+		out += "…"
+	}
+	return out
+}
+
+func indexRunes(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		match := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
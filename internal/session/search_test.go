@@ -0,0 +1,83 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManager(time.Hour, 10, store)
+	seed(m, "agent:support:telegram:user:42", "how do I reset my password", "Click Forgot Password on the login page.")
+	seed(m, "agent:default:telegram:group:100", "the deploy failed again", "Check the password in the vault.")
+	m.Reset("agent:support:telegram:user:42", "")
+
+	hits := m.Search(SearchQuery{Text: "forgot password"})
+	if len(hits) != 1 {
+		t.Fatalf("hits = %+v", hits)
+	}
+	h := hits[0]
+	if h.SessionID != "agent:support:telegram:user:42" || h.Index != 1 || h.ArchivedAt.IsZero() {
+		t.Errorf("hit = %+v", h)
+	}
+
+	if got := m.Search(SearchQuery{Text: "password"}); len(got) != 3 {
+		t.Errorf("password hits = %d, want 3", len(got))
+	}
+	if got := m.Search(SearchQuery{Text: "password", PeerID: "100"}); len(got) != 1 {
+		t.Errorf("peer filter hits = %d, want 1", len(got))
+	}
+	if got := m.Search(SearchQuery{Text: "password", AgentID: "default", Channel: "telegram", PeerID: "42"}); len(got) != 2 {
+		t.Errorf("agent filter hits = %d, want 2", len(got))
+	}
+	if got := m.Search(SearchQuery{Text: "password", Since: time.Now().Add(time.Hour)}); len(got) != 0 {
+		t.Errorf("time filter hits = %d, want 0", len(got))
+	}
+
+	// The index is built from the store on the first search.
+	m2 := NewManager(time.Hour, 10, store)
+	if got := m2.Search(SearchQuery{Text: "vault"}); len(got) != 1 {
+		t.Errorf("rebuilt index hits = %d, want 1", len(got))
+	}
+
+	// Writes after the index is built are indexed too.
+	seed(m2, "agent:default:telegram:user:7", "vault access")
+	if got := m2.Search(SearchQuery{Text: "vault"}); len(got) != 2 {
+		t.Errorf("hits after write = %d, want 2", len(got))
+	}
+}
+
+func TestIndexLimit(t *testing.T) {
+	ix := NewIndex(2)
+	now := time.Now()
+	ix.Put(Record{Key: "agent:a:telegram:user:1", TouchedAt: now.Add(-2 * time.Hour), History: []Message{{Role: "user", Content: "alpha"}}})
+	ix.Put(Record{Key: "agent:a:telegram:user:2", TouchedAt: now.Add(-time.Hour), History: []Message{{Role: "user", Content: "alpha"}}})
+	ix.Put(Record{Key: "agent:a:telegram:user:3", TouchedAt: now, History: []Message{{Role: "user", Content: "alpha"}}})
+
+	hits := ix.Search(SearchQuery{Text: "alpha"})
+	if len(hits) != 2 {
+		t.Fatalf("hits = %+v, want 2", hits)
+	}
+	for _, h := range hits {
+		if h.SessionID == "agent:a:telegram:user:1" {
+			t.Errorf("oldest document was not evicted: %+v", hits)
+		}
+	}
+
+	// Documents with unparsable keys are still found by text.
+	ix.Put(Record{Key: "custom-fork", TouchedAt: now, History: []Message{{Role: "user", Content: "beta"}}})
+	if got := ix.Search(SearchQuery{Text: "beta"}); len(got) != 1 {
+		t.Errorf("unparsable key hits = %d, want 1", len(got))
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa Needle bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	got := snippet(long, "needle")
+	if got[:3] != "…" || got[len(got)-3:] != "…" {
+		t.Errorf("snippet missing ellipses: %q", got)
+	}
+	if snippet("short Needle", "needle") != "short Needle" {
+		t.Errorf("short snippet = %q", snippet("short Needle", "needle"))
+	}
+}
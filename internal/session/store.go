@@ -0,0 +1,116 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when no session exists for a key.
+	ErrNotFound = errors.New("session not found")
+
+	// ErrExists is returned when creating a session under a key already in use.
+	ErrExists = errors.New("session already exists")
+)
+
+// Store persists session records. Active sessions are keyed by session key;
+// archived sessions are kept separately and never returned by Load.
+type Store interface {
+	// Load returns the active record for key, or ErrNotFound.
+	Load(key string) (*Record, error)
+
+	// Save writes the active record, replacing any previous version.
+	Save(rec *Record) error
+
+	// Archive moves the active record for key into the archive.
+	Archive(key string, at time.Time) error
+
+	// Scan calls fn for every active and archived record. Archived records
+	// have a non-zero ArchivedAt. Returning an error from fn stops the scan.
+	Scan(fn func(rec *Record) error) error
+
+	// Close releases any underlying resources.
+	Close() error
+}
+
+// MemoryStore is a Store that keeps records in process memory.
+// It is the default when no persistent backend is configured.
+type MemoryStore struct {
+	mu       sync.Mutex
+	active   map[string]Record
+	archived []Record
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{active: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Load(key string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.active[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := copyRecord(rec)
+	return &cp, nil
+}
+
+func (s *MemoryStore) Save(rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[rec.Key] = copyRecord(*rec)
+	return nil
+}
+
+func (s *MemoryStore) Archive(key string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.active[key]
+	if !ok {
+		return ErrNotFound
+	}
+	rec.ArchivedAt = at
+	s.archived = append(s.archived, rec)
+	delete(s.active, key)
+	return nil
+}
+
+func (s *MemoryStore) Scan(fn func(rec *Record) error) error {
+	s.mu.Lock()
+	recs := make([]Record, 0, len(s.active)+len(s.archived))
+	for _, rec := range s.active {
+		recs = append(recs, copyRecord(rec))
+	}
+	for _, rec := range s.archived {
+		recs = append(recs, copyRecord(rec))
+	}
+	s.mu.Unlock()
+
+	for i := range recs {
+		if err := fn(&recs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Close() error { return nil }
+
+// archiveKey identifies an archived record: "{sessionKey}@{archivedAtUnixNano}",
+// so all archives of one key sort together and in time order.
+func archiveKey(key string, at time.Time) string {
+	return fmt.Sprintf("%s@%020d", key, at.UnixNano())
+}
+
+func copyRecord(rec Record) Record {
+	cp := rec
+	cp.History = make([]Message, len(rec.History))
+	copy(cp.History, rec.History)
+	cp.Metadata = copyMap(rec.Metadata)
+	cp.Tokens = copyMap(rec.Tokens)
+	return cp
+}
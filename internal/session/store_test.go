@@ -0,0 +1,206 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBoltStorePersistsAcrossManagers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+
+	store, err := OpenBoltStore(path, nil)
+	if err != nil {
+		t.Fatalf("OpenBoltStore: %v", err)
+	}
+	m := NewManager(time.Hour, 10, store)
+	e := m.GetOrCreate("agent:default:main", "default")
+	e.SetMeta("title", "greeting")
+	e.AddUsage(Usage{InputTokens: 10, OutputTokens: 5, Runs: 1})
+	m.Append(e, Message{Role: "user", Content: "hi"}, Message{Role: "assistant", Content: "hello"})
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	store, err = OpenBoltStore(path, nil)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	m = NewManager(time.Hour, 10, store)
+	defer m.Close()
+
+	got, ok := m.Get("agent:default:main")
+	if !ok {
+		t.Fatal("session not loaded after restart")
+	}
+	if h := got.GetHistory(); len(h) != 2 || h[1].Content != "hello" {
+		t.Errorf("history = %+v", h)
+	}
+	if got.GetMeta("title") != "greeting" {
+		t.Errorf("metadata = %+v", got.Metadata)
+	}
+	if got.Usage.InputTokens != 10 || got.Usage.Runs != 1 {
+		t.Errorf("usage = %+v", got.Usage)
+	}
+}
+
+func TestCleanupArchivesExpired(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManager(time.Minute, 10, store)
+	e := m.GetOrCreate("k", "default")
+	m.Append(e, Message{Role: "user", Content: "old"})
+
+	e.mu.Lock()
+	e.TouchedAt = time.Now().Add(-2 * time.Minute)
+	e.mu.Unlock()
+	m.cleanup()
+
+	if _, err := store.Load("k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected active record removed, got err=%v", err)
+	}
+	if len(store.archived) != 1 || store.archived[0].History[0].Content != "old" {
+		t.Fatalf("archived = %+v", store.archived)
+	}
+
+	fresh := m.GetOrCreate("k", "default")
+	if len(fresh.GetHistory()) != 0 {
+		t.Errorf("expected fresh session after archive")
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	m := NewManager(time.Hour, 10, nil)
+	e := seed(m, "agent:default:telegram:user:42", "hi", "hello **there**")
+	e.SetMeta("title", "greeting")
+	e.AddUsage(Usage{InputTokens: 3, OutputTokens: 4, Runs: 1})
+	m.Save(e)
+
+	rec, err := m.Export(e.Key)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := ExportJSONL(&buf, rec); err != nil {
+		t.Fatalf("ExportJSONL: %v", err)
+	}
+	got, err := ImportJSONL(&buf)
+	if err != nil {
+		t.Fatalf("ImportJSONL: %v", err)
+	}
+	if got.Key != rec.Key || got.Usage != rec.Usage || len(got.History) != 2 ||
+		got.History[1].Content != "hello **there**" || !got.History[1].Time.Equal(rec.History[1].Time) {
+		t.Errorf("round trip = %+v, want %+v", got, rec)
+	}
+
+	if _, err := m.Import(got, "", false); !errors.Is(err, ErrExists) {
+		t.Errorf("expected ErrExists, got %v", err)
+	}
+	imp, err := m.Import(got, "agent:default:main", false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if imp.GetMeta("imported_from") != rec.Key || imp.GetMeta("title") != "greeting" || len(imp.GetHistory()) != 2 {
+		t.Errorf("imported = %+v", imp.Snapshot())
+	}
+
+	var md bytes.Buffer
+	if err := ExportMarkdown(&md, rec); err != nil {
+		t.Fatalf("ExportMarkdown: %v", err)
+	}
+	if !strings.Contains(md.String(), "### 1. assistant") || !strings.Contains(md.String(), "hello **there**") {
+		t.Errorf("markdown = %s", md.String())
+	}
+}
+
+func TestBoltStoreEncryptionAndRekey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	keyA := bytes.Repeat([]byte{0xa}, KeySize)
+	keyB := bytes.Repeat([]byte{0xb}, KeySize)
+	open := func(keys ...[]byte) *BoltStore {
+		t.Helper()
+		var c *Cipher
+		if len(keys) > 0 {
+			var err error
+			if c, err = NewCipher(keys...); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s, err := OpenBoltStore(path, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	rec := func(key, text string) *Record {
+		return &Record{Key: key, AgentID: "default", History: []Message{{Role: "user", Content: text}}}
+	}
+
+	// A plaintext store from before encryption was enabled.
+	s := open()
+	s.Save(rec("plain", "legacy secret"))
+	s.Close()
+
+	s = open(keyA)
+	if got, err := s.Load("plain"); err != nil || got.History[0].Content != "legacy secret" {
+		t.Fatalf("legacy Load = %+v, %v", got, err)
+	}
+	s.Save(rec("sealed", "customer secret"))
+	s.Archive("sealed", time.Now())
+	raw, _ := s.db.Begin(false)
+	raw.Bucket(bucketArchive).ForEach(func(k, v []byte) error {
+		if bytes.Contains(v, []byte("customer secret")) {
+			t.Errorf("archived record %s stored in plaintext", k)
+		}
+		return nil
+	})
+	raw.Rollback()
+	if n, err := s.Rekey(); err != nil || n != 1 {
+		t.Errorf("Rekey = %d, %v; want the 1 legacy record", n, err)
+	}
+	s.Close()
+
+	// Rotate: new key first, old key still accepted.
+	s = open(keyB, keyA)
+	if n, err := s.Rekey(); err != nil || n != 2 {
+		t.Errorf("rotate Rekey = %d, %v; want 2", n, err)
+	}
+	s.Close()
+
+	s = open(keyB)
+	count := 0
+	if err := s.Scan(func(*Record) error { count++; return nil }); err != nil || count != 2 {
+		t.Errorf("Scan with new key only = %d, %v", count, err)
+	}
+	s.Close()
+
+	s = open(keyA)
+	if _, err := s.Load("plain"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Load with retired key: got %v, want ErrNoKey", err)
+	}
+	s.Close()
+
+	s = open()
+	defer s.Close()
+	if _, err := s.Load("plain"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Load without key: got %v, want ErrNoKey", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	b64 := "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+	hexKey := "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+	keys, err := ParseKeys("# current\n" + b64 + "\n\n" + hexKey + "\n")
+	if err != nil || len(keys) != 2 || !bytes.Equal(keys[0], keys[1]) {
+		t.Errorf("ParseKeys(file) = %x, %v", keys, err)
+	}
+	if keys, err := ParseKeys(b64 + "," + hexKey); err != nil || len(keys) != 2 {
+		t.Errorf("ParseKeys(env) = %x, %v", keys, err)
+	}
+	if _, err := ParseKeys("c2hvcnQ="); err == nil {
+		t.Error("short key accepted")
+	}
+}
@@ -12,13 +12,38 @@ type Entry struct {
 	CreatedAt time.Time
 	TouchedAt time.Time
 	History   []Message
+	Metadata  map[string]string
+	Tokens    map[string]string // redaction token -> original text, for reversible redaction
+	Usage     Usage
+	archived  bool // set once the entry has been archived; it is no longer saved
 	mu        sync.Mutex
 }
 
 // Message is a single turn in the conversation history.
 type Message struct {
-	Role    string `json:"role"` // "user", "assistant"
-	Content string `json:"content"`
+	Role    string    `json:"role"` // "user", "assistant"
+	Content string    `json:"content"`
+	Time    time.Time `json:"time,omitzero"`
+}
+
+// Usage accumulates token consumption for a session.
+type Usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	Runs         int   `json:"runs"`
+}
+
+// Record is the serialisable form of an Entry, as written to a Store.
+type Record struct {
+	Key        string            `json:"key"`
+	AgentID    string            `json:"agent_id"`
+	CreatedAt  time.Time         `json:"created_at"`
+	TouchedAt  time.Time         `json:"touched_at"`
+	ArchivedAt time.Time         `json:"archived_at,omitzero"`
+	History    []Message         `json:"history"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Tokens     map[string]string `json:"tokens,omitempty"`
+	Usage      Usage             `json:"usage"`
 }
 
 // Touch updates the last-access timestamp.
@@ -29,14 +54,19 @@ func (e *Entry) Touch() {
 }
 
 // AppendHistory adds a message, enforcing max history length.
+// Messages without a timestamp are stamped with the current time.
 func (e *Entry) AppendHistory(msg Message, maxHistory int) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	now := time.Now()
+	if msg.Time.IsZero() {
+		msg.Time = now
+	}
 	e.History = append(e.History, msg)
 	if maxHistory > 0 && len(e.History) > maxHistory {
 		e.History = e.History[len(e.History)-maxHistory:]
 	}
-	e.TouchedAt = time.Now()
+	e.TouchedAt = now
 }
 
 // GetHistory returns a copy of the conversation history.
@@ -47,3 +77,96 @@ func (e *Entry) GetHistory() []Message {
 	copy(cp, e.History)
 	return cp
 }
+
+// SetMeta sets a metadata value on the session.
+func (e *Entry) SetMeta(key, value string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]string)
+	}
+	e.Metadata[key] = value
+}
+
+// GetMeta returns a metadata value from the session.
+func (e *Entry) GetMeta(key string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.Metadata[key]
+}
+
+// WithTokens calls fn with the session's redaction token map, creating it if
+// needed. fn may add tokens; it must not retain the map.
+func (e *Entry) WithTokens(fn func(tokens map[string]string)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.Tokens == nil {
+		e.Tokens = make(map[string]string)
+	}
+	fn(e.Tokens)
+}
+
+// AddUsage adds token counts from one run to the session totals.
+func (e *Entry) AddUsage(u Usage) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Usage.InputTokens += u.InputTokens
+	e.Usage.OutputTokens += u.OutputTokens
+	e.Usage.Runs += u.Runs
+}
+
+func (e *Entry) markArchived() {
+	e.mu.Lock()
+	e.archived = true
+	e.mu.Unlock()
+}
+
+func (e *Entry) isArchived() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.archived
+}
+
+// Snapshot returns a deep copy of the entry as a Record.
+func (e *Entry) Snapshot() Record {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rec := Record{
+		Key:       e.Key,
+		AgentID:   e.AgentID,
+		CreatedAt: e.CreatedAt,
+		TouchedAt: e.TouchedAt,
+		History:   make([]Message, len(e.History)),
+		Usage:     e.Usage,
+	}
+	copy(rec.History, e.History)
+	rec.Metadata = copyMap(e.Metadata)
+	rec.Tokens = copyMap(e.Tokens)
+	return rec
+}
+
+// entryFromRecord rebuilds an Entry from a stored Record.
+func entryFromRecord(rec *Record) *Entry {
+	return &Entry{
+		Key:       rec.Key,
+		AgentID:   rec.AgentID,
+		CreatedAt: rec.CreatedAt,
+		TouchedAt: rec.TouchedAt,
+		History:   rec.History,
+		Metadata:  rec.Metadata,
+		Tokens:    rec.Tokens,
+		Usage:     rec.Usage,
+	}
+}
+
+// copyMap returns a copy of m, or nil if m is empty.
+func copyMap(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	cp := make(map[string]string, len(m))
+	for k, v := range m {
+		cp[k] = v
+	}
+	return cp
+}
@@ -2,21 +2,48 @@ package protocol
 
 // Methods (client -> server requests)
 const (
-	MethodChatSend    = "chat.send"
-	MethodChatCancel  = "chat.cancel"
-	MethodSessionList = "session.list"
-	MethodSessionGet  = "session.get"
-	MethodPing        = "ping"
+	MethodChatSend         = "chat.send"
+	MethodChatCancel       = "chat.cancel"
+	MethodSessionList      = "session.list"
+	MethodSessionGet       = "session.get"
+	MethodSessionReset     = "session.reset"
+	MethodSessionFork      = "session.fork"
+	MethodSessionBranch    = "session.branch"
+	MethodSessionSearch    = "session.search"
+	MethodSessionExport    = "session.export"
+	MethodSessionImport    = "session.import"
+	MethodSessionAgent     = "session.agent"
+	MethodRoutingExplain   = "routing.explain"
+	MethodBindingList      = "routing.bindings.list"
+	MethodBindingAdd       = "routing.bindings.add"
+	MethodBindingRemove    = "routing.bindings.remove"
+	MethodBindingAudit     = "routing.bindings.audit"
+	MethodExperiments      = "routing.experiments"
+	MethodIdentityGet      = "identity.get"
+	MethodLinkStart        = "identity.link.start"
+	MethodLinkConfirm      = "identity.link.confirm"
+	MethodUnlink           = "identity.unlink"
+	MethodDeadLetterList   = "queue.deadletter.list"
+	MethodDeadLetterRetry  = "queue.deadletter.retry"
+	MethodDeadLetterDelete = "queue.deadletter.delete"
+	MethodQueueLanes       = "queue.lanes"
+	MethodQueuePurge       = "queue.purge"
+	MethodQueueDrain       = "queue.drain"
+	MethodQueueResume      = "queue.resume"
+	MethodPing             = "ping"
 )
 
 // Events (server -> client pushes)
 const (
-	EventChatDelta    = "chat.delta"
-	EventChatComplete = "chat.complete"
-	EventChatError    = "chat.error"
-	EventChatToolUse  = "chat.tool_use"
-	EventChatToolDone = "chat.tool_done"
-	EventRunStart     = "run.start"
-	EventRunEnd       = "run.end"
-	EventConnected    = "connected"
+	EventChatDelta     = "chat.delta"
+	EventChatComplete  = "chat.complete"
+	EventChatError     = "chat.error"
+	EventChatToolUse   = "chat.tool_use"
+	EventChatToolDone  = "chat.tool_done"
+	EventRunStart      = "run.start"
+	EventRunEnd        = "run.end"
+	EventConnected     = "connected"
+	EventSessionReset  = "session.reset"
+	EventQueuePosition = "queue.position"
+	EventFramesDropped = "frames.dropped"
 )
@@ -2,14 +2,22 @@ package protocol
 
 // InboundMessage represents a message arriving from any channel.
 type InboundMessage struct {
+	MessageID string `json:"message_id,omitempty"` // unique per channel, used to drop redeliveries
 	SessionID string `json:"session_id"`
 	Channel   string `json:"channel"`   // e.g. "telegram", "websocket"
 	PeerKind  string `json:"peer_kind"` // "user", "group", "channel"
 	PeerID    string `json:"peer_id"`
 	GuildID   string `json:"guild_id,omitempty"` // for group contexts
+	TeamID    string `json:"team_id,omitempty"`  // workspace or organization, where the channel has one
 	ThreadID  string `json:"thread_id,omitempty"`
+	SenderID  string `json:"sender_id,omitempty"` // individual sender, also set in group contexts
 	Text      string `json:"text"`
+	Command   string `json:"command,omitempty"`  // chat command without the slash, e.g. "reset"
 	AgentID   string `json:"agent_id,omitempty"` // resolved by router
+	UserID    string `json:"user_id,omitempty"`  // canonical identity, resolved from the sender
+	Priority  string `json:"priority,omitempty"` // requested queue priority, e.g. "high" for system jobs
+
+	Attachments []string `json:"attachments,omitempty"` // media types attached, e.g. "photo", "document"
 }
 
 // OutboundMessage represents a message to be sent back to a channel.